@@ -0,0 +1,734 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const secret = "test-secret"
+
+type mockUsers struct{}
+
+// mockPermissions grants the permissions of the default roles held by the users of mockUsers
+type mockPermissions struct{}
+
+// mockMembers puts every user in the default organization and only James (id 1) in organization 2
+type mockMembers struct{}
+
+// mockGroups knows group 10, owned by David (id 2)
+type mockGroups struct{}
+
+func TestJwtParser_Parse_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name     string
+		token    string
+		identity *Identity
+		errMsg   string
+	}{
+		{
+			name: "valid token",
+			token: signToken(t, secret, jwt.MapClaims{
+				"access_uuid": "uuid-1",
+				"user_id":     1,
+				"exp":         time.Now().Add(time.Minute).Unix(),
+			}),
+			identity: &Identity{UserID: 1, AccessUuid: "uuid-1"},
+		},
+		{
+			name: "expired token",
+			token: signToken(t, secret, jwt.MapClaims{
+				"access_uuid": "uuid-1",
+				"user_id":     1,
+				"exp":         time.Now().Add(-time.Minute).Unix(),
+			}),
+			errMsg: "invalid access token",
+		},
+		{
+			name: "token signed with another secret",
+			token: signToken(t, "another-secret", jwt.MapClaims{
+				"access_uuid": "uuid-1",
+				"user_id":     1,
+			}),
+			errMsg: "invalid access token",
+		},
+		{
+			name: "token without user id",
+			token: signToken(t, secret, jwt.MapClaims{
+				"access_uuid": "uuid-1",
+			}),
+			errMsg: "invalid access token",
+		},
+		{
+			name:   "not a token",
+			token:  "not-a-token",
+			errMsg: "invalid access token",
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			identity, err := NewJWTParser(secret).Parse(tc.token)
+			if err != nil {
+				assert.Equal(t, tc.errMsg, err.Error())
+				assert.Equal(t, model.CodeUnauthenticated, model.ErrorCodeOf(err))
+				return
+			}
+			assert.Equal(t, tc.identity, identity)
+		})
+	}
+}
+
+func TestJwtParser_ParseRefresh_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name    string
+		token   string
+		refresh *RefreshToken
+		errMsg  string
+	}{
+		{
+			name: "valid token",
+			token: signToken(t, secret, jwt.MapClaims{
+				"refresh_uuid": "uuid-1",
+				"user_id":      "1",
+				"exp":          time.Now().Add(time.Hour).Unix(),
+			}),
+			refresh: &RefreshToken{UserID: 1, RefreshUuid: "uuid-1"},
+		},
+		{
+			name: "access token is not a refresh token",
+			token: signToken(t, secret, jwt.MapClaims{
+				"access_uuid": "uuid-1",
+				"user_id":     1,
+			}),
+			errMsg: "invalid refresh token",
+		},
+		{
+			name: "expired token",
+			token: signToken(t, secret, jwt.MapClaims{
+				"refresh_uuid": "uuid-1",
+				"user_id":      1,
+				"exp":          time.Now().Add(-time.Minute).Unix(),
+			}),
+			errMsg: "invalid refresh token",
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			refresh, err := NewJWTRefreshParser(secret).ParseRefresh(tc.token)
+			if err != nil {
+				assert.Equal(t, tc.errMsg, err.Error())
+				assert.Equal(t, model.CodeUnauthenticated, model.ErrorCodeOf(err))
+				return
+			}
+			assert.Equal(t, tc.refresh, refresh)
+		})
+	}
+}
+
+func TestBearerToken_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name   string
+		header string
+		token  string
+		errMsg string
+	}{
+		{
+			name:   "bearer token",
+			header: "Bearer abc.def.ghi",
+			token:  "abc.def.ghi",
+		},
+		{
+			name:   "missing header",
+			header: "",
+			errMsg: "missing bearer access token",
+		},
+		{
+			name:   "basic auth header",
+			header: "Basic dXNlcjpwYXNz",
+			errMsg: "missing bearer access token",
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			token, err := BearerToken(tc.header)
+			if err != nil {
+				assert.Equal(t, tc.errMsg, err.Error())
+				return
+			}
+			assert.Equal(t, tc.token, token)
+		})
+	}
+}
+
+func TestAuthorizer_Authorize_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name         string
+		identity     *Identity
+		organization int64
+		action       Action
+		targetID     int64
+		code         model.ErrorCode
+	}{
+		{
+			name:     "admin lists users",
+			identity: &Identity{UserID: 1},
+			action:   ActionListUsers,
+			code:     "",
+		},
+		{
+			name:     "user can not list users",
+			identity: &Identity{UserID: 2},
+			action:   ActionListUsers,
+			code:     model.CodePermissionDenied,
+		},
+		{
+			name:     "admin deletes another user",
+			identity: &Identity{UserID: 1},
+			action:   ActionDeleteUser,
+			targetID: 2,
+			code:     "",
+		},
+		{
+			name:     "user deletes themselves",
+			identity: &Identity{UserID: 2},
+			action:   ActionDeleteUser,
+			targetID: 2,
+			code:     "",
+		},
+		{
+			name:     "user can not read another user",
+			identity: &Identity{UserID: 2},
+			action:   ActionReadUser,
+			targetID: 1,
+			code:     model.CodePermissionDenied,
+		},
+		{
+			name:     "user can not make themselves admin",
+			identity: &Identity{UserID: 2},
+			action:   ActionChangeAdmin,
+			targetID: 2,
+			code:     model.CodePermissionDenied,
+		},
+		{
+			name:     "admin can not change another users password",
+			identity: &Identity{UserID: 1},
+			action:   ActionChangePassword,
+			targetID: 2,
+			code:     model.CodePermissionDenied,
+		},
+		{
+			name:     "user can not reset a password",
+			identity: &Identity{UserID: 2},
+			action:   ActionResetPassword,
+			targetID: 2,
+			code:     model.CodePermissionDenied,
+		},
+		{
+			name:     "user can not unlock their account",
+			identity: &Identity{UserID: 2},
+			action:   ActionUnlockUser,
+			targetID: 2,
+			code:     model.CodePermissionDenied,
+		},
+		{
+			name:     "admin unlocks a user",
+			identity: &Identity{UserID: 1},
+			action:   ActionUnlockUser,
+			targetID: 2,
+			code:     "",
+		},
+		{
+			name:     "user with a reset password changes it",
+			identity: &Identity{UserID: 3},
+			action:   ActionChangePassword,
+			targetID: 3,
+			code:     "",
+		},
+		{
+			name:     "user with a reset password can do nothing else",
+			identity: &Identity{UserID: 3},
+			action:   ActionReadUser,
+			targetID: 3,
+			code:     model.CodePermissionDenied,
+		},
+		{
+			name:     "admin changes the status of a user",
+			identity: &Identity{UserID: 1},
+			action:   ActionChangeStatus,
+			targetID: 2,
+			code:     "",
+		},
+		{
+			name:     "user can not change their status",
+			identity: &Identity{UserID: 2},
+			action:   ActionChangeStatus,
+			targetID: 2,
+			code:     model.CodePermissionDenied,
+		},
+		{
+			name:     "suspended caller refused before their token expires",
+			identity: &Identity{UserID: 4},
+			action:   ActionReadUser,
+			targetID: 4,
+			code:     model.CodePermissionDenied,
+		},
+		{
+			name:     "support unlocks a user",
+			identity: &Identity{UserID: 5},
+			action:   ActionUnlockUser,
+			targetID: 2,
+			code:     "",
+		},
+		{
+			name:     "support reads another user",
+			identity: &Identity{UserID: 5},
+			action:   ActionReadUser,
+			targetID: 2,
+			code:     "",
+		},
+		{
+			name:     "support can not delete another user",
+			identity: &Identity{UserID: 5},
+			action:   ActionDeleteUser,
+			targetID: 2,
+			code:     model.CodePermissionDenied,
+		},
+		{
+			name:     "support can not grant roles",
+			identity: &Identity{UserID: 5},
+			action:   ActionManageRoles,
+			targetID: 5,
+			code:     model.CodePermissionDenied,
+		},
+		{
+			name:     "admin grants roles",
+			identity: &Identity{UserID: 1},
+			action:   ActionManageRoles,
+			targetID: 5,
+			code:     "",
+		},
+		{
+			name:     "no permission lets an admin enroll mfa for another user",
+			identity: &Identity{UserID: 1},
+			action:   ActionEnrollMFA,
+			targetID: 2,
+			code:     model.CodePermissionDenied,
+		},
+		{
+			name:         "member acts in another organization",
+			identity:     &Identity{UserID: 1},
+			organization: 2,
+			action:       ActionListUsers,
+			code:         "",
+		},
+		{
+			name:         "caller is not a member of the organization",
+			identity:     &Identity{UserID: 2},
+			organization: 2,
+			action:       ActionReadUser,
+			targetID:     2,
+			code:         model.CodePermissionDenied,
+		},
+		{
+			name:     "owner manages their group",
+			identity: &Identity{UserID: 2},
+			action:   ActionManageGroup,
+			targetID: 10,
+			code:     "",
+		},
+		{
+			name:     "admin manages any group",
+			identity: &Identity{UserID: 1},
+			action:   ActionManageGroup,
+			targetID: 10,
+			code:     "",
+		},
+		{
+			name:     "support can not read a group they do not own",
+			identity: &Identity{UserID: 5},
+			action:   ActionReadGroup,
+			targetID: 10,
+			code:     model.CodePermissionDenied,
+		},
+		{
+			name:     "owning a group does not allow creating groups",
+			identity: &Identity{UserID: 2},
+			action:   ActionCreateGroup,
+			code:     model.CodePermissionDenied,
+		},
+		{
+			name:     "admin creates a group for another owner",
+			identity: &Identity{UserID: 1},
+			action:   ActionAssignGroupOwner,
+			targetID: 2,
+			code:     "",
+		},
+		{
+			name:     "group owner can not create a group for another owner",
+			identity: &Identity{UserID: 2},
+			action:   ActionAssignGroupOwner,
+			targetID: 10,
+			code:     model.CodePermissionDenied,
+		},
+		{
+			name:     "unknown group",
+			identity: &Identity{UserID: 2},
+			action:   ActionManageGroup,
+			targetID: 11,
+			code:     model.CodeNotFound,
+		},
+		{
+			name:     "caller no longer exists",
+			identity: &Identity{UserID: 42},
+			action:   ActionReadUser,
+			targetID: 42,
+			code:     model.CodeUnauthenticated,
+		},
+		{
+			name:     "unauthenticated request",
+			identity: nil,
+			action:   ActionReadUser,
+			targetID: 1,
+			code:     model.CodeUnauthenticated,
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			if tc.identity != nil {
+				ctx = WithIdentity(ctx, tc.identity)
+			}
+			if tc.organization != 0 {
+				ctx = model.WithOrganization(ctx, tc.organization)
+			}
+
+			err := NewAuthorizer(mockUsers{}, mockPermissions{}, mockMembers{}, mockGroups{}).Authorize(ctx, tc.action, tc.targetID)
+			if tc.code == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, tc.code, model.ErrorCodeOf(err))
+		})
+	}
+}
+
+func (m mockUsers) GetByID(_ context.Context, id int64) (*model.User, error) {
+	users := map[int64]*model.User{
+		1: {ID: 1, Username: "James", Roles: []string{model.RoleAdmin}},
+		2: {ID: 2, Username: "David"},
+		3: {ID: 3, Username: "Michael", PasswordChangeRequired: true},
+		4: {ID: 4, Username: "Teddy", Status: model.StatusSuspended},
+		5: {ID: 5, Username: "Maclom", Roles: []string{model.RoleSupport}},
+	}
+	if user, ok := users[id]; ok {
+		return user, nil
+	}
+	return nil, model.NotFoundError("could not find user with id")
+}
+
+func (m mockMembers) IsMember(_ context.Context, organizationID, userID int64) (bool, error) {
+	return organizationID == model.DefaultOrganizationID || userID == 1, nil
+}
+
+func (m mockGroups) Group(_ context.Context, id int64) (*model.Group, error) {
+	if id == 10 {
+		return &model.Group{ID: 10, Name: "Engineering", OwnerID: 2}, nil
+	}
+	return nil, model.NotFoundError("group not found")
+}
+
+func (m mockPermissions) HasPermission(ctx context.Context, userID int64, permission string) (bool, error) {
+	user, err := mockUsers{}.GetByID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	for _, role := range model.DefaultRoles() {
+		if user.HasRole(role.Name) && role.HasPermission(permission) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func signToken(t *testing.T, secret string, claims jwt.MapClaims) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("could not sign token: %v", err)
+	}
+	return token
+}
+
+// rfcTOTPSecret is the base32 encoding of the SHA1 seed used by the test vectors of RFC 6238
+const rfcTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestValidateTOTP_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name  string
+		code  string
+		now   time.Time
+		step  int64
+		valid bool
+	}{
+		{
+			name:  "rfc 6238 vector at 59",
+			code:  "287082",
+			now:   time.Unix(59, 0),
+			step:  1,
+			valid: true,
+		},
+		{
+			name:  "rfc 6238 vector at 1111111109",
+			code:  "081804",
+			now:   time.Unix(1111111109, 0),
+			step:  37037036,
+			valid: true,
+		},
+		{
+			name:  "rfc 6238 vector at 2000000000",
+			code:  "279037",
+			now:   time.Unix(2000000000, 0),
+			step:  66666666,
+			valid: true,
+		},
+		{
+			name:  "code from the previous step",
+			code:  "287082",
+			now:   time.Unix(89, 0),
+			step:  1,
+			valid: true,
+		},
+		{
+			name:  "code too old",
+			code:  "287082",
+			now:   time.Unix(120, 0),
+			valid: false,
+		},
+		{
+			name:  "wrong code",
+			code:  "123456",
+			now:   time.Unix(59, 0),
+			valid: false,
+		},
+		{
+			name:  "code with the wrong length",
+			code:  "28708",
+			now:   time.Unix(59, 0),
+			valid: false,
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			step, valid := ValidateTOTP(rfcTOTPSecret, tc.code, tc.now)
+			assert.Equal(t, tc.valid, valid)
+			assert.Equal(t, tc.step, step)
+		})
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPURI("user-service", "James", "SECRET")
+	assert.Equal(t, "otpauth://totp/user-service:James?algorithm=SHA1&digits=6&issuer=user-service&period=30&secret=SECRET", uri)
+
+	secret, err := NewTOTPSecret()
+	assert.NoError(t, err)
+	assert.Len(t, secret, 32)
+
+	now := time.Now()
+	code, err := GenerateTOTP(secret, now)
+	assert.NoError(t, err)
+	_, valid := ValidateTOTP(secret, code, now)
+	assert.True(t, valid)
+}
+
+// testArgon2idParams are cheap enough to hash with in every test
+var testArgon2idParams = Argon2idParams{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestPasswordHasher_Test_Cases(t *testing.T) {
+	bcryptHasher, err := NewBcryptHasher(bcrypt.MinCost)
+	assert.NoError(t, err)
+	argon2idHasher, err := NewArgon2idHasher(testArgon2idParams)
+	assert.NoError(t, err)
+
+	tt := []struct {
+		name   string
+		hasher PasswordHasher
+		other  PasswordHasher
+		prefix string
+	}{
+		{
+			name:   "bcrypt",
+			hasher: bcryptHasher,
+			other:  argon2idHasher,
+			prefix: "$2a$04$",
+		},
+		{
+			name:   "argon2id",
+			hasher: argon2idHasher,
+			other:  bcryptHasher,
+			prefix: "$argon2id$v=19$m=64,t=1,p=1$",
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			hash, err := tc.hasher.Hash("password")
+			assert.NoError(t, err)
+			assert.True(t, strings.HasPrefix(hash, tc.prefix), hash)
+
+			again, err := tc.hasher.Hash("password")
+			assert.NoError(t, err)
+			assert.NotEqual(t, hash, again, "hashes must be salted")
+
+			assert.NoError(t, tc.hasher.Verify(hash, "password"))
+			assert.Equal(t, ErrPasswordMismatch, tc.hasher.Verify(hash, "wrong-password"))
+			assert.False(t, tc.hasher.NeedsRehash(hash))
+
+			// Hashes of the other algorithm still verify but are due a rehash
+			assert.NoError(t, tc.other.Verify(hash, "password"))
+			assert.True(t, tc.other.NeedsRehash(hash))
+		})
+	}
+}
+
+func TestPasswordHasher_NeedsRehash_Test_Cases(t *testing.T) {
+	bcryptHash, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	argon2idHasher, _ := NewArgon2idHasher(testArgon2idParams)
+	argon2idHash, _ := argon2idHasher.Hash("password")
+
+	stronger := testArgon2idParams
+	stronger.Iterations = 2
+	strongerArgon2idHasher, _ := NewArgon2idHasher(stronger)
+	longerKey := testArgon2idParams
+	longerKey.KeyLength = 64
+	longerKeyArgon2idHasher, _ := NewArgon2idHasher(longerKey)
+	bcryptHasher, _ := NewBcryptHasher(bcrypt.MinCost)
+	costlierBcryptHasher, _ := NewBcryptHasher(bcrypt.MinCost + 1)
+
+	tt := []struct {
+		name     string
+		hasher   PasswordHasher
+		hash     string
+		expected bool
+	}{
+		{name: "bcrypt with the same cost", hasher: bcryptHasher, hash: string(bcryptHash), expected: false},
+		{name: "bcrypt with another cost", hasher: costlierBcryptHasher, hash: string(bcryptHash), expected: true},
+		{name: "argon2id with the same parameters", hasher: argon2idHasher, hash: argon2idHash, expected: false},
+		{name: "argon2id with more iterations", hasher: strongerArgon2idHasher, hash: argon2idHash, expected: true},
+		{name: "argon2id with a longer key", hasher: longerKeyArgon2idHasher, hash: argon2idHash, expected: true},
+		{name: "unknown hash", hasher: argon2idHasher, hash: "password", expected: true},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, tc.hasher.NeedsRehash(tc.hash))
+		})
+	}
+}
+
+func TestPasswordHasher_Verify_Invalid_Hash_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name string
+		hash string
+	}{
+		{name: "plain text", hash: "password"},
+		{name: "empty", hash: ""},
+		{name: "unsupported algorithm", hash: "$argon2i$v=19$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5a2V5"},
+		{name: "unsupported argon2 version", hash: "$argon2id$v=16$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5a2V5"},
+		{name: "missing parameters", hash: "$argon2id$v=19$c2FsdHNhbHQ$a2V5a2V5"},
+		{name: "zero iterations", hash: "$argon2id$v=19$m=64,t=0,p=1$c2FsdHNhbHQ$a2V5a2V5"},
+		{name: "invalid salt", hash: "$argon2id$v=19$m=64,t=1,p=1$!!!$a2V5a2V5"},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, ErrUnknownPasswordHash, DefaultPasswordHasher.Verify(tc.hash, "password"))
+		})
+	}
+}
+
+func TestNewPasswordHasher_Invalid_Parameters(t *testing.T) {
+	_, err := NewBcryptHasher(bcrypt.MaxCost + 1)
+	assert.Error(t, err)
+
+	params := testArgon2idParams
+	params.Iterations = 0
+	_, err = NewArgon2idHasher(params)
+	assert.Error(t, err)
+
+	params = testArgon2idParams
+	params.SaltLength = 4
+	_, err = NewArgon2idHasher(params)
+	assert.Error(t, err)
+}
+
+func TestServiceError_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name   string
+		err    error
+		code   model.ErrorCode
+		errMsg string
+	}{
+		{
+			name:   "rpc deadline exceeded",
+			err:    status.Error(codes.DeadlineExceeded, "deadline exceeded"),
+			code:   model.CodeTimeout,
+			errMsg: "auth service timed out",
+		},
+		{
+			name:   "context deadline exceeded",
+			err:    fmt.Errorf("calling auth service: %w", context.DeadlineExceeded),
+			code:   model.CodeTimeout,
+			errMsg: "auth service timed out",
+		},
+		{
+			name:   "auth service unavailable",
+			err:    status.Error(codes.Unavailable, "connection refused"),
+			code:   model.CodeUnavailable,
+			errMsg: "auth service unavailable",
+		},
+		{
+			name:   "unknown session",
+			err:    status.Error(codes.NotFound, "access token not found"),
+			code:   model.CodeUnauthenticated,
+			errMsg: "session not found",
+		},
+		{
+			name:   "unexpected failure is a bad gateway",
+			err:    status.Error(codes.Internal, "redis: connection pool timeout"),
+			code:   model.CodeBadGateway,
+			errMsg: "auth service error",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			err := ServiceError(tc.err)
+			assert.Equal(t, tc.code, model.ErrorCodeOf(err))
+			assert.Equal(t, tc.errMsg, err.Error())
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+)
+
+// Action is an operation on a user that is subject to authorization, it is also the name of the permission a role
+// must grant for its users to perform it on anyone
+type Action string
+
+const (
+	ActionListUsers   Action = "users:list"
+	ActionReadUser    Action = "users:read"
+	ActionUpdateUser  Action = "users:update"
+	ActionChangeAdmin Action = "users:change_admin"
+	ActionDeleteUser  Action = "users:delete"
+	// ActionRestoreUser is admin only, deleted users can not sign in to restore themselves
+	ActionRestoreUser Action = "users:restore"
+	// ActionChangePassword is the only action allowed while a password change is required
+	ActionChangePassword Action = "users:change_password"
+	ActionResetPassword  Action = "users:reset_password"
+	ActionUnlockUser     Action = "users:unlock"
+	ActionChangeStatus   Action = "users:change_status"
+	// ActionInviteUser invites people to register, inviting them with a role also needs ActionManageRoles
+	ActionInviteUser Action = "users:invite"
+	// ActionEnrollMFA is self only so nobody can add an authenticator they hold to another account
+	ActionEnrollMFA       Action = "users:enroll_mfa"
+	ActionDisableMFA      Action = "users:disable_mfa"
+	ActionReadMFAPolicy   Action = "mfa:read_policy"
+	ActionManageMFAPolicy Action = "mfa:manage_policy"
+	ActionListRoles       Action = "roles:list"
+	// ActionManageRoles grants and revokes roles, whoever holds it can grant themselves any role
+	ActionManageRoles Action = "roles:manage"
+	// ActionCreateOrganization creates organizations, it is only allowed in the default organization
+	ActionCreateOrganization Action = "organizations:create"
+	// ActionJoinOrganization accepts an invitation to another organization, only the invitee can consent to it
+	ActionJoinOrganization Action = "organizations:join"
+	// ActionManageMembers removes the users of other organizations that joined the organization of the request
+	ActionManageMembers Action = "organizations:manage_members"
+	// Group actions target the group in the id of the request rather than a user
+	ActionListGroups  Action = "groups:list"
+	ActionReadGroup   Action = "groups:read"
+	ActionCreateGroup Action = "groups:create"
+	// ActionManageGroup deletes groups and adds and removes their members
+	ActionManageGroup Action = "groups:manage"
+	// ActionAssignGroupOwner creates a group for the user in the id of the request, who then manages it, so it is
+	// granted by the permission of ActionManageGroup
+	ActionAssignGroupOwner Action = "groups:assign_owner"
+)
+
+// policy decides who may perform an action besides the callers granted its permission
+type policy int
+
+const (
+	// permitted actions need the permission, whoever the target is
+	permitted policy = iota
+	// selfOrPermitted actions are allowed on the caller themselves without the permission
+	selfOrPermitted
+	// selfOnly actions are only allowed on the caller themselves, no permission allows them on anyone else
+	selfOnly
+	// ownerOrPermitted actions target a group and are allowed to its owner without the permission
+	ownerOrPermitted
+)
+
+var policies = map[Action]policy{
+	ActionListUsers:       permitted,
+	ActionReadUser:        selfOrPermitted,
+	ActionUpdateUser:      selfOrPermitted,
+	ActionChangeAdmin:     permitted,
+	ActionDeleteUser:      selfOrPermitted,
+	ActionRestoreUser:     permitted,
+	ActionChangePassword:  selfOnly,
+	ActionResetPassword:   permitted,
+	ActionUnlockUser:      permitted,
+	ActionChangeStatus:    permitted,
+	ActionInviteUser:      permitted,
+	ActionEnrollMFA:       selfOnly,
+	ActionDisableMFA:      selfOrPermitted,
+	ActionReadMFAPolicy:   permitted,
+	ActionManageMFAPolicy: permitted,
+	ActionListRoles:       permitted,
+	ActionManageRoles:     permitted,
+
+	ActionCreateOrganization: permitted,
+	ActionJoinOrganization:   selfOnly,
+	ActionManageMembers:      permitted,
+
+	ActionListGroups:  permitted,
+	ActionReadGroup:   ownerOrPermitted,
+	ActionCreateGroup: permitted,
+	ActionManageGroup: ownerOrPermitted,
+
+	ActionAssignGroupOwner: permitted,
+}
+
+// grantedBy names the permission of the actions that are not granted by a permission of their own
+var grantedBy = map[Action]Action{
+	ActionAssignGroupOwner: ActionManageGroup,
+}
+
+// UserGetter loads the caller of a request, it is satisfied by service.UserService
+type UserGetter interface {
+	GetByID(ctx context.Context, id int64) (*model.User, error)
+}
+
+// PermissionChecker answers whether a user holds a permission, it is satisfied by service.RoleService
+type PermissionChecker interface {
+	HasPermission(ctx context.Context, userID int64, permission string) (bool, error)
+}
+
+// MembershipChecker answers whether a user belongs to an organization, it is satisfied by service.OrganizationService
+type MembershipChecker interface {
+	IsMember(ctx context.Context, organizationID, userID int64) (bool, error)
+}
+
+// GroupGetter loads the group targeted by group actions to find its owner, it is satisfied by service.GroupService
+type GroupGetter interface {
+	Group(ctx context.Context, id int64) (*model.Group, error)
+}
+
+type Authorizer interface {
+	// Authorize returns an unauthenticated error when ctx carries no identity and a
+	// permission denied error when the caller may not perform action on targetID,
+	// or does not belong to the organization of ctx. targetID is a group for group
+	// actions, unknown groups are not found.
+	Authorize(ctx context.Context, action Action, targetID int64) error
+}
+
+type authorizer struct {
+	users       UserGetter
+	permissions PermissionChecker
+	members     MembershipChecker
+	groups      GroupGetter
+}
+
+func NewAuthorizer(users UserGetter, permissions PermissionChecker, members MembershipChecker, groups GroupGetter) Authorizer {
+	return &authorizer{users: users, permissions: permissions, members: members, groups: groups}
+}
+
+func (a *authorizer) Authorize(ctx context.Context, action Action, targetID int64) error {
+	identity, ok := IdentityFromContext(ctx)
+	if !ok {
+		return model.UnauthenticatedError("authentication required")
+	}
+
+	policy, ok := policies[action]
+	if !ok {
+		return model.PermissionDeniedError("permission denied")
+	}
+
+	// Access tokens are not tied to an organization, callers can only act in the organizations they belong to
+	member, err := a.members.IsMember(ctx, model.OrganizationFromContext(ctx), identity.UserID)
+	if err != nil {
+		return model.WrapError(err, "unable to authorize request")
+	}
+	if !member {
+		return model.PermissionDeniedError("caller is not a member of this organization")
+	}
+
+	caller, err := a.users.GetByID(ctx, identity.UserID)
+	if errors.Is(err, model.ErrNotFound) {
+		return model.UnauthenticatedError("caller no longer exists")
+	}
+	if err != nil {
+		return model.WrapError(err, "unable to authorize request")
+	}
+
+	// Access tokens are verified locally, so a suspended caller is refused here even before their tokens expire
+	if err := caller.CheckActive(); err != nil {
+		return err
+	}
+
+	if caller.PasswordChangeRequired && action != ActionChangePassword {
+		return model.PermissionDeniedError("password change required")
+	}
+
+	if policy == ownerOrPermitted {
+		group, err := a.groups.Group(ctx, targetID)
+		if err != nil {
+			return err
+		}
+		if group.OwnerID == caller.ID {
+			return nil
+		}
+	} else if policy != permitted && caller.ID == targetID {
+		return nil
+	}
+	if policy == selfOnly {
+		return model.PermissionDeniedError("users may only perform this action on themselves")
+	}
+
+	permission := action
+	if p, ok := grantedBy[action]; ok {
+		permission = p
+	}
+
+	granted, err := a.permissions.HasPermission(ctx, caller.ID, string(permission))
+	if err != nil {
+		return model.WrapError(err, "unable to authorize request")
+	}
+	if granted {
+		return nil
+	}
+
+	if policy == selfOrPermitted {
+		return model.PermissionDeniedError("users may only perform this action on themselves")
+	}
+	if policy == ownerOrPermitted {
+		return model.PermissionDeniedError("only the owner of the group may perform this action")
+	}
+	return model.PermissionDeniedError(fmt.Sprintf("permission %s required", permission))
+}
@@ -0,0 +1,197 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrPasswordMismatch is returned by Verify when the password does not match the hash
+	ErrPasswordMismatch = errors.New("password does not match")
+	// ErrUnknownPasswordHash is returned for hashes that were not produced by a supported algorithm
+	ErrUnknownPasswordHash = errors.New("unknown password hash format")
+)
+
+// PasswordHasher hashes new passwords with one algorithm and verifies hashes of every supported algorithm,
+// so the algorithm or its parameters can be changed without locking out existing users.
+type PasswordHasher interface {
+	// Hash returns the encoded hash of password, the encoding identifies the algorithm and its parameters
+	Hash(password string) (string, error)
+	// Verify returns ErrPasswordMismatch unless password matches hash, whichever supported algorithm produced it
+	Verify(hash, password string) error
+	// NeedsRehash reports whether hash was produced with another algorithm or other parameters than Hash uses
+	NeedsRehash(hash string) bool
+}
+
+// Argon2idParams are the cost parameters of argon2id, see RFC 9106
+type Argon2idParams struct {
+	// Memory is in KiB
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2idParams follow the second recommended option of RFC 9106 with 64 MiB of memory
+var DefaultArgon2idParams = Argon2idParams{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 4,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// DefaultPasswordHasher hashes with bcrypt at its default cost, the only algorithm used before hashers were configurable
+var DefaultPasswordHasher PasswordHasher = &bcryptHasher{cost: bcrypt.DefaultCost}
+
+var argon2Encoding = base64.RawStdEncoding
+
+type bcryptHasher struct {
+	cost int
+}
+
+// NewBcryptHasher returns a PasswordHasher that hashes with bcrypt at cost, the bcrypt default is used when cost is 0
+func NewBcryptHasher(cost int) (PasswordHasher, error) {
+	if cost == 0 {
+		cost = bcrypt.DefaultCost
+	}
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	return &bcryptHasher{cost: cost}, nil
+}
+
+func (h *bcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (h *bcryptHasher) Verify(hash, password string) error {
+	return verifyPassword(hash, password)
+}
+
+func (h *bcryptHasher) NeedsRehash(hash string) bool {
+	if !isBcryptHash(hash) {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.cost
+}
+
+type argon2idHasher struct {
+	params Argon2idParams
+}
+
+// NewArgon2idHasher returns a PasswordHasher that hashes with argon2id, hashes are encoded in the PHC string format
+// $argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>$<salt>$<key>
+func NewArgon2idHasher(params Argon2idParams) (PasswordHasher, error) {
+	if params.Memory < 8*uint32(params.Parallelism) || params.Iterations < 1 || params.Parallelism < 1 {
+		return nil, errors.New("argon2id needs at least one iteration and thread, and 8 KiB of memory per thread")
+	}
+	if params.SaltLength < 8 || params.KeyLength < 16 {
+		return nil, errors.New("argon2id needs a salt of at least 8 bytes and a key of at least 16 bytes")
+	}
+	return &argon2idHasher{params: params}, nil
+}
+
+func (h *argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.params.Iterations, h.params.Memory, h.params.Parallelism, h.params.KeyLength)
+
+	return encodeArgon2id(h.params, salt, key), nil
+}
+
+func (h *argon2idHasher) Verify(hash, password string) error {
+	return verifyPassword(hash, password)
+}
+
+func (h *argon2idHasher) NeedsRehash(hash string) bool {
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return true
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params != h.params
+}
+
+// verifyPassword checks password against a hash of any supported algorithm
+func verifyPassword(hash, password string) error {
+	switch {
+	case isBcryptHash(hash):
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrPasswordMismatch
+		}
+		return err
+	case strings.HasPrefix(hash, "$argon2id$"):
+		params, salt, key, err := decodeArgon2id(hash)
+		if err != nil {
+			return err
+		}
+		other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+		if subtle.ConstantTimeCompare(key, other) != 1 {
+			return ErrPasswordMismatch
+		}
+		return nil
+	}
+	return ErrUnknownPasswordHash
+}
+
+// isBcryptHash reports whether hash is in the modular crypt format of bcrypt, $2a$, $2b$ or $2y$
+func isBcryptHash(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+func encodeArgon2id(params Argon2idParams, salt, key []byte) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, params.Memory, params.Iterations, params.Parallelism,
+		argon2Encoding.EncodeToString(salt), argon2Encoding.EncodeToString(key))
+}
+
+func decodeArgon2id(hash string) (Argon2idParams, []byte, []byte, error) {
+	var params Argon2idParams
+
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, ErrUnknownPasswordHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrUnknownPasswordHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, ErrUnknownPasswordHash
+	}
+	if params.Iterations < 1 || params.Parallelism < 1 {
+		return params, nil, nil, ErrUnknownPasswordHash
+	}
+
+	salt, err := argon2Encoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrUnknownPasswordHash
+	}
+	key, err := argon2Encoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrUnknownPasswordHash
+	}
+
+	return params, salt, key, nil
+}
@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TokenType is the type of the access tokens issued by the auth service
+const TokenType = "Bearer"
+
+// ServiceError converts an error returned by an AuthService rpc into a domain error,
+// failures of the auth service itself are not exposed to clients.
+func ServiceError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return model.TimeoutError("auth service timed out")
+	}
+
+	switch status.Code(err) {
+	case codes.DeadlineExceeded:
+		return model.TimeoutError("auth service timed out")
+	case codes.Unavailable:
+		return model.UnavailableError("auth service unavailable")
+	case codes.NotFound, codes.Unauthenticated:
+		return model.UnauthenticatedError("session not found")
+	default:
+		return &model.Error{Code: model.CodeBadGateway, Message: "auth service error", Err: err}
+	}
+}
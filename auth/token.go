@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Identity is the authenticated caller of a request
+type Identity struct {
+	UserID     int64
+	AccessUuid string
+}
+
+// TokenParser verifies an access token issued by the auth service and returns the identity it was issued for
+type TokenParser interface {
+	Parse(token string) (*Identity, error)
+}
+
+// RefreshToken is a verified refresh token issued by the auth service
+type RefreshToken struct {
+	UserID      int64
+	RefreshUuid string
+}
+
+// RefreshTokenParser verifies a refresh token issued by the auth service
+type RefreshTokenParser interface {
+	ParseRefresh(token string) (*RefreshToken, error)
+}
+
+type jwtParser struct {
+	secret []byte
+}
+
+// NewJWTParser returns a TokenParser for HMAC signed access tokens, secret must match the ACCESS_SECRET of the auth service
+func NewJWTParser(secret string) TokenParser {
+	return &jwtParser{secret: []byte(secret)}
+}
+
+// NewJWTRefreshParser returns a RefreshTokenParser for HMAC signed refresh tokens, secret must match the REFRESH_SECRET of the auth service
+func NewJWTRefreshParser(secret string) RefreshTokenParser {
+	return &jwtParser{secret: []byte(secret)}
+}
+
+func (p *jwtParser) Parse(raw string) (*Identity, error) {
+	userID, accessUuid, err := p.parse(raw, "access_uuid")
+	if err != nil {
+		return nil, model.UnauthenticatedError("invalid access token")
+	}
+
+	return &Identity{
+		UserID:     userID,
+		AccessUuid: accessUuid,
+	}, nil
+}
+
+func (p *jwtParser) ParseRefresh(raw string) (*RefreshToken, error) {
+	userID, refreshUuid, err := p.parse(raw, "refresh_uuid")
+	if err != nil {
+		return nil, model.UnauthenticatedError("invalid refresh token")
+	}
+
+	return &RefreshToken{
+		UserID:      userID,
+		RefreshUuid: refreshUuid,
+	}, nil
+}
+
+// parse verifies raw and returns its user_id claim and the uuid stored in uuidClaim
+func (p *jwtParser) parse(raw, uuidClaim string) (int64, string, error) {
+	token, err := jwt.Parse(raw, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return p.secret, nil
+	})
+	if err != nil {
+		return 0, "", err
+	}
+	if !token.Valid {
+		return 0, "", fmt.Errorf("invalid token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return 0, "", fmt.Errorf("unexpected claims type: %T", token.Claims)
+	}
+
+	uuid, _ := claims[uuidClaim].(string)
+	userID, err := claimInt64(claims["user_id"])
+	if err != nil {
+		return 0, "", err
+	}
+	if uuid == "" || userID <= 0 {
+		return 0, "", fmt.Errorf("missing %s or user_id claim", uuidClaim)
+	}
+
+	return userID, uuid, nil
+}
+
+// BearerToken returns the token of an "Authorization: Bearer <token>" header value
+func BearerToken(header string) (string, error) {
+	parts := strings.Fields(header)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+		return "", model.UnauthenticatedError("missing bearer access token")
+	}
+	return parts[1], nil
+}
+
+type identityKey struct{}
+
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+func IdentityFromContext(ctx context.Context) (*Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(*Identity)
+	return identity, ok && identity != nil
+}
+
+func claimInt64(claim interface{}) (int64, error) {
+	switch v := claim.(type) {
+	case float64:
+		return int64(v), nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	default:
+		return 0, fmt.Errorf("unexpected claim type: %T", claim)
+	}
+}
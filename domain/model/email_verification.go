@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// EmailVerificationToken is a single use token proving a user owns Email, only the sha256 hash of the token is stored.
+type EmailVerificationToken struct {
+	ID        int64
+	UserID    int64
+	Email     string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
@@ -0,0 +1,131 @@
+package model
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrorCode classifies an error so each transport can map it to its own status codes
+type ErrorCode string
+
+const (
+	CodeInternal         ErrorCode = "internal"
+	CodeNotFound         ErrorCode = "not_found"
+	CodeAlreadyExists    ErrorCode = "already_exists"
+	CodeInvalidArgument  ErrorCode = "invalid_argument"
+	CodeUnauthenticated  ErrorCode = "unauthenticated"
+	CodePermissionDenied ErrorCode = "permission_denied"
+	CodeUnavailable      ErrorCode = "unavailable"
+	CodeTimeout          ErrorCode = "timeout"
+	CodeBadGateway       ErrorCode = "bad_gateway"
+	CodeTooManyRequests  ErrorCode = "too_many_requests"
+)
+
+// Sentinel errors to compare against with errors.Is, any Error with the same code matches
+var (
+	ErrNotFound         = &Error{Code: CodeNotFound, Message: "not found"}
+	ErrAlreadyExists    = &Error{Code: CodeAlreadyExists, Message: "already exists"}
+	ErrInvalidArgument  = &Error{Code: CodeInvalidArgument, Message: "invalid argument"}
+	ErrUnauthenticated  = &Error{Code: CodeUnauthenticated, Message: "unauthenticated"}
+	ErrPermissionDenied = &Error{Code: CodePermissionDenied, Message: "permission denied"}
+)
+
+// Error is a domain error, Message is safe to return to clients
+type Error struct {
+	Code    ErrorCode
+	Message string
+	Fields  []FieldError
+	Err     error
+}
+
+// FieldError describes why a single field of a request is invalid
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+	// Code identifies the rule the field broke when clients may want to act on it, like a password policy violation
+	Code string `json:"code,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+func NotFoundError(message string) error {
+	return &Error{Code: CodeNotFound, Message: message}
+}
+
+// ValidationError is an invalid argument error listing every invalid field of a request
+func ValidationError(fields ...FieldError) error {
+	messages := make([]string, 0, len(fields))
+	for _, field := range fields {
+		messages = append(messages, field.Message)
+	}
+	return &Error{Code: CodeInvalidArgument, Message: strings.Join(messages, ", "), Fields: fields}
+}
+
+func AlreadyExistsError(message string) error {
+	return &Error{Code: CodeAlreadyExists, Message: message}
+}
+
+func InvalidArgumentError(message string) error {
+	return &Error{Code: CodeInvalidArgument, Message: message}
+}
+
+func UnauthenticatedError(message string) error {
+	return &Error{Code: CodeUnauthenticated, Message: message}
+}
+
+func PermissionDeniedError(message string) error {
+	return &Error{Code: CodePermissionDenied, Message: message}
+}
+
+// UnavailableError reports that a service the request depends on can not be reached
+func UnavailableError(message string) error {
+	return &Error{Code: CodeUnavailable, Message: message}
+}
+
+// TimeoutError reports that a service the request depends on did not answer in time
+func TimeoutError(message string) error {
+	return &Error{Code: CodeTimeout, Message: message}
+}
+
+// BadGatewayError reports that a service the request depends on failed to handle it
+func BadGatewayError(message string) error {
+	return &Error{Code: CodeBadGateway, Message: message}
+}
+
+func TooManyRequestsError(message string) error {
+	return &Error{Code: CodeTooManyRequests, Message: message}
+}
+
+// WrapError replaces the message of err while keeping its code and fields, errors without a code become internal errors
+func WrapError(err error, message string) error {
+	return &Error{Code: ErrorCodeOf(err), Message: message, Fields: FieldErrorsOf(err), Err: err}
+}
+
+// ErrorCodeOf returns the code of the first Error in the chain of err, or CodeInternal
+func ErrorCodeOf(err error) ErrorCode {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return CodeInternal
+}
+
+// FieldErrorsOf returns the field errors of the first Error in the chain of err
+func FieldErrorsOf(err error) []FieldError {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Fields
+	}
+	return nil
+}
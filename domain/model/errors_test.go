@@ -0,0 +1,78 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestError_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name     string
+		err      error
+		sentinel error
+		code     ErrorCode
+		message  string
+	}{
+		{
+			name:     "not found error",
+			err:      NotFoundError("could not find user with id"),
+			sentinel: ErrNotFound,
+			code:     CodeNotFound,
+			message:  "could not find user with id",
+		},
+		{
+			name:     "wrapped error keeps code",
+			err:      WrapError(AlreadyExistsError("duplicate key"), "username already exists"),
+			sentinel: ErrAlreadyExists,
+			code:     CodeAlreadyExists,
+			message:  "username already exists",
+		},
+		{
+			name:     "error wrapped with fmt keeps code",
+			err:      fmt.Errorf("deleting user: %w", PermissionDeniedError("only admins can delete users")),
+			sentinel: ErrPermissionDenied,
+			code:     CodePermissionDenied,
+			message:  "deleting user: only admins can delete users",
+		},
+		{
+			name:     "plain error is internal",
+			err:      WrapError(errors.New("connection refused"), "unable to get users"),
+			sentinel: nil,
+			code:     CodeInternal,
+			message:  "unable to get users",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.code, ErrorCodeOf(tc.err))
+			assert.Equal(t, tc.message, tc.err.Error())
+			if tc.sentinel != nil {
+				assert.True(t, errors.Is(tc.err, tc.sentinel))
+			}
+			assert.False(t, errors.Is(tc.err, ErrUnauthenticated))
+		})
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	err := ValidationError(
+		FieldError{Field: "username", Message: "username invalid"},
+		FieldError{Field: "password", Message: "password invalid"},
+	)
+
+	assert.Equal(t, CodeInvalidArgument, ErrorCodeOf(err))
+	assert.Equal(t, "username invalid, password invalid", err.Error())
+	assert.True(t, errors.Is(err, ErrInvalidArgument))
+
+	wrapped := WrapError(err, "error creating user")
+	assert.Equal(t, []FieldError{
+		{Field: "username", Message: "username invalid"},
+		{Field: "password", Message: "password invalid"},
+	}, FieldErrorsOf(wrapped))
+}
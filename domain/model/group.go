@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+// Group is a named set of members of an organization, managed by its owner
+type Group struct {
+	ID             int64  `json:"id"`
+	OrganizationID int64  `json:"-"`
+	Name           string `json:"name"`
+	Description    string `json:"description"`
+	// OwnerID is zero once the owner was purged, the group is then only managed by the users allowed to manage any group
+	OwnerID   int64 `json:"owner_id"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// GroupMember records that a user belongs to a group
+type GroupMember struct {
+	GroupID   int64 `pg:",pk"`
+	UserID    int64 `pg:",pk"`
+	CreatedAt time.Time
+}
+
+// GroupPage is a single page of groups, NextPageToken is empty on the last page.
+type GroupPage struct {
+	Groups        []*Group
+	NextPageToken string
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// Invitation lets the holder of a single use token register in an organization with Email, holding Role once they
+// accepted when it is set. Only the sha256 hash of the token is stored.
+type Invitation struct {
+	ID             int64  `json:"id"`
+	OrganizationID int64  `json:"-"`
+	Email          string `json:"email"`
+	Role           string `json:"role,omitempty"`
+	// InvitedBy is zero once the user who sent the invitation was purged
+	InvitedBy  int64  `json:"invited_by"`
+	TokenHash  string `json:"-"`
+	ExpiresAt  time.Time
+	AcceptedAt *time.Time
+	CreatedAt  time.Time
+}
@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// LoginAttempt counts the failed logins recorded against a key, which is either an account or a client address
+type LoginAttempt struct {
+	Key           string `pg:",pk"`
+	Failures      int    `pg:",use_zero"`
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+}
+
+// Locked reports whether logins for the key are refused at now
+func (a *LoginAttempt) Locked(now time.Time) bool {
+	return a.LockedUntil != nil && a.LockedUntil.After(now)
+}
@@ -0,0 +1,60 @@
+package model
+
+import "time"
+
+// MFA is the TOTP authenticator of a user, it is only asked for at login once it is confirmed with a first code
+type MFA struct {
+	tableName struct{} `pg:"user_mfa"`
+
+	UserID int64 `pg:",pk"`
+	Secret string
+	// LastUsedStep is the time step of the last accepted code, so no code can be used twice
+	LastUsedStep int64 `pg:",use_zero"`
+	ConfirmedAt  *time.Time
+	CreatedAt    time.Time
+}
+
+func (m *MFA) Confirmed() bool {
+	return m.ConfirmedAt != nil
+}
+
+// MFARecoveryCode is a single use code to log in without the authenticator, only the sha256 hash of the code is stored.
+type MFARecoveryCode struct {
+	ID        int64
+	UserID    int64
+	CodeHash  string
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// MFAChallenge is the single use token a login that needs a second factor returns instead of access tokens,
+// only the sha256 hash of the token is stored.
+type MFAChallenge struct {
+	ID        int64
+	UserID    int64
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// MFAEnrollment is what an authenticator app needs to start generating codes for a user
+type MFAEnrollment struct {
+	Secret string
+	URI    string
+}
+
+// LoginChallenge is the result of a login that needs a second factor, EnrollmentRequired is set
+// when the user has to set up an authenticator first
+type LoginChallenge struct {
+	Token              string
+	EnrollmentRequired bool
+}
+
+// Setting is a runtime option that admins can change without restarting the service, each organization has its own
+type Setting struct {
+	OrganizationID int64  `pg:",pk"`
+	Key            string `pg:",pk"`
+	Value          string
+	UpdatedAt      time.Time
+}
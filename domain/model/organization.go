@@ -0,0 +1,44 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultOrganizationID is the organization of requests that name none, users stored before organizations existed
+// belong to it and it is the only organization new organizations can be created from
+const DefaultOrganizationID int64 = 1
+
+// DefaultOrganizationSlug is the slug of the default organization
+const DefaultOrganizationSlug = "default"
+
+// Organization is a tenant of the service. Users register in, and are managed by, a single organization but can
+// be members of others, every read and write of users is scoped to the organization of the request.
+type Organization struct {
+	ID        int64  `json:"id"`
+	Slug      string `json:"slug"`
+	Name      string `json:"name"`
+	CreatedAt time.Time
+}
+
+// OrganizationMember records that a user belongs to an organization, the roles of a user are granted per organization
+type OrganizationMember struct {
+	OrganizationID int64 `pg:",pk"`
+	UserID         int64 `pg:",pk"`
+	CreatedAt      time.Time
+}
+
+type organizationKey struct{}
+
+// WithOrganization returns a copy of ctx scoped to the organization with id
+func WithOrganization(ctx context.Context, id int64) context.Context {
+	return context.WithValue(ctx, organizationKey{}, id)
+}
+
+// OrganizationFromContext returns the id of the organization ctx is scoped to, DefaultOrganizationID when it is not
+func OrganizationFromContext(ctx context.Context) int64 {
+	if id, ok := ctx.Value(organizationKey{}).(int64); ok && id > 0 {
+		return id
+	}
+	return DefaultOrganizationID
+}
@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// PasswordResetToken is a single use token letting a user who forgot their password set a new one,
+// only the sha256 hash of the token is stored.
+type PasswordResetToken struct {
+	ID        int64
+	UserID    int64
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
@@ -0,0 +1,75 @@
+package model
+
+import "time"
+
+// Roles every deployment starts with, the migration creating the roles tables seeds the same roles
+const (
+	// RoleAdmin holds every permission, IsAdmin reports whether a user has it
+	RoleAdmin         = "admin"
+	RoleSupport       = "support"
+	RoleAuditor       = "auditor"
+	RoleReadOnlyAdmin = "read_only_admin"
+)
+
+// PermissionAll grants every permission, including the ones added after a role was created
+const PermissionAll = "*"
+
+// Role is a named set of permissions granted to the users holding it. Permissions are the names of the actions
+// checked by the authorizer, like "users:list".
+type Role struct {
+	ID          int64
+	Name        string
+	Description string
+	Permissions []string `pg:"-"`
+	CreatedAt   time.Time
+}
+
+// RolePermission is a permission granted by a role
+type RolePermission struct {
+	RoleID     int64  `pg:",pk"`
+	Permission string `pg:",pk"`
+}
+
+// UserRole grants a role to a user within an organization
+type UserRole struct {
+	OrganizationID int64 `pg:",pk"`
+	UserID         int64 `pg:",pk"`
+	RoleID         int64 `pg:",pk"`
+	CreatedAt      time.Time
+}
+
+// HasPermission reports whether the role grants permission
+func (r *Role) HasPermission(permission string) bool {
+	for _, p := range r.Permissions {
+		if p == permission || p == PermissionAll {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultRoles returns the built in roles with their permissions
+func DefaultRoles() []*Role {
+	return []*Role{
+		{
+			Name:        RoleAdmin,
+			Description: "Full access to every user and setting",
+			Permissions: []string{PermissionAll},
+		},
+		{
+			Name:        RoleSupport,
+			Description: "Helps users back into their accounts",
+			Permissions: []string{"users:list", "users:read", "users:unlock", "users:reset_password", "users:disable_mfa"},
+		},
+		{
+			Name:        RoleAuditor,
+			Description: "Reviews users and the roles they hold",
+			Permissions: []string{"users:list", "users:read", "roles:list"},
+		},
+		{
+			Name:        RoleReadOnlyAdmin,
+			Description: "Sees everything an admin sees without changing anything",
+			Permissions: []string{"users:list", "users:read", "roles:list", "mfa:read_policy"},
+		},
+	}
+}
@@ -3,29 +3,168 @@ package model
 import (
 	"encoding/json"
 	"errors"
-	"golang.org/x/crypto/bcrypt"
 	"net/http"
+	"strings"
 	"time"
 )
 
+// AccountStatus is where an account is in its lifecycle, only active accounts can log in
+type AccountStatus string
+
+const (
+	// StatusPending accounts wait for an admin to activate them
+	StatusPending   AccountStatus = "pending"
+	StatusActive    AccountStatus = "active"
+	StatusSuspended AccountStatus = "suspended"
+	// StatusDisabled is final, a disabled account can not be brought back
+	StatusDisabled AccountStatus = "disabled"
+)
+
+// statusTransitions lists the statuses each status can change to, any status can change to disabled
+var statusTransitions = map[AccountStatus][]AccountStatus{
+	StatusPending:   {StatusActive, StatusDisabled},
+	StatusActive:    {StatusSuspended, StatusDisabled},
+	StatusSuspended: {StatusActive, StatusDisabled},
+}
+
 type User struct {
-	ID        int64     `json:"id"`
-	Username  string    `json:"username"`
-	Password  string    `json:"password"`
-	Admin     bool      `json:"admin"`
-	CreatedAt time.Time `json:"-"`
-	UpdatedAt time.Time `json:"-"`
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	// Email is stored normalized, see NormalizeEmail
+	Email    string `json:"email"`
+	Password string `json:"-"`
+	// OrganizationID is the organization the user registered in, their username and email are unique within it
+	OrganizationID int64 `json:"-"`
+	// Roles are the names of the roles granted to the user in the organization they were read from, loaded with the
+	// user by the repositories
+	Roles []string `json:"roles" pg:"-"`
+	// VerifiedAt is when the user proved they own Email, nil until then
+	VerifiedAt *time.Time    `json:"-"`
+	Status     AccountStatus `json:"status"`
+	// StatusReason is why an admin last changed Status, at StatusChangedAt
+	StatusReason    string     `json:"-"`
+	StatusChangedAt *time.Time `json:"-"`
+	// PasswordChangeRequired is set by an admin password reset, the user may do nothing but change it
+	PasswordChangeRequired bool      `json:"-" pg:",use_zero"`
+	CreatedAt              time.Time `json:"-"`
+	UpdatedAt              time.Time `json:"-"`
+	// DeletedAt is when the user was soft deleted, zero until then. Deleted users are left out of every read until
+	// they are restored or purged.
+	DeletedAt time.Time `json:"-" pg:",soft_delete"`
+}
+
+// UserUpdate holds the changes to apply to an existing user, nil fields are left untouched.
+type UserUpdate struct {
+	Username *string
+	// Admin grants or revokes the admin role
+	Admin *bool
+}
+
+// UserFilter narrows down the users returned when listing, zero value fields are ignored.
+type UserFilter struct {
+	// UsernamePrefix only keeps the users whose username starts with it, ignoring case
+	UsernamePrefix string
+	// Admin only keeps the users that hold, or do not hold, the admin role
+	Admin         *bool
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
+// Page requests a single page of a listing, Token is the NextPageToken of the previous page.
+type Page struct {
+	Size  int
+	Token string
+}
+
+// UserPage is a single page of users, NextPageToken is empty on the last page.
+type UserPage struct {
+	Users         []*User
+	NextPageToken string
+}
+
+// EmailVerified reports whether the user proved they own their email address
+func (u *User) EmailVerified() bool {
+	return u != nil && u.Email != "" && u.VerifiedAt != nil
+}
+
+// Valid reports whether s is a known status
+func (s AccountStatus) Valid() bool {
+	_, ok := statusTransitions[s]
+	return ok || s == StatusDisabled
+}
+
+// CanTransitionTo reports whether an account can change from status s to next
+func (s AccountStatus) CanTransitionTo(next AccountStatus) bool {
+	for _, allowed := range statusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckActive returns a permission denied error saying why the user may not log in or act, unless they are active.
+// Users stored before statuses existed have none and are active.
+func (u *User) CheckActive() error {
+	switch u.Status {
+	case StatusActive, "":
+		return nil
+	case StatusPending:
+		return PermissionDeniedError("account is pending activation")
+	case StatusSuspended:
+		return PermissionDeniedError("account is suspended")
+	default:
+		return PermissionDeniedError("account is disabled")
+	}
 }
 
+// Deleted reports whether the user was soft deleted
+func (u *User) Deleted() bool {
+	return u != nil && !u.DeletedAt.IsZero()
+}
+
+// NormalizeEmail returns the form emails are stored and compared in
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// IsAdmin reports whether the user holds the admin role
 func (u *User) IsAdmin() bool {
+	return u.HasRole(RoleAdmin)
+}
+
+// HasRole reports whether the user holds role
+func (u *User) HasRole(role string) bool {
 	if u == nil {
 		return false
 	}
-	return u.Admin
+	for _, r := range u.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// SetRole grants role to the user, or revokes it when granted is false
+func (u *User) SetRole(role string, granted bool) {
+	roles := u.Roles[:0:0]
+	for _, r := range u.Roles {
+		if r != role {
+			roles = append(roles, r)
+		}
+	}
+	if granted {
+		roles = append(roles, role)
+	}
+	u.Roles = roles
 }
 
+// ToJson writes body as the json response, a Content-Type already set by the caller is kept
 func ToJson(rw http.ResponseWriter, status int, body interface{}) error {
-	rw.Header().Set("Content-Type", "application/json")
+	if rw.Header().Get("Content-Type") == "" {
+		rw.Header().Set("Content-Type", "application/json")
+	}
 	rw.WriteHeader(status)
 	return json.NewEncoder(rw).Encode(body)
 }
@@ -41,22 +180,6 @@ func (u *User) Validate() error {
 	return nil
 }
 
-func (u *User) HashPassword(password string) error {
-	bytePassword := []byte(password)
-	hashPassword, err := bcrypt.GenerateFromPassword(bytePassword, bcrypt.DefaultCost)
-	if err != nil {
-		return err
-	}
-	u.Password = string(hashPassword)
-	return nil
-}
-
-func (u *User) ValidatePassword(password string) error {
-	bytePassword := []byte(password)
-	byteHashPassword := []byte(u.Password)
-	return bcrypt.CompareHashAndPassword(byteHashPassword, bytePassword)
-}
-
 func validateUser(user *User) (bool, error) {
 	if user == nil {
 		return false, errors.New("user is empty")
@@ -64,15 +187,10 @@ func validateUser(user *User) (bool, error) {
 	if user.ID <= 0 {
 		return false, errors.New("user id is invalid")
 	}
+	// The rest of the username rules belong to the username policy of the service, they may change after users registered
 	if user.Username == "" {
 		return false, errors.New("username is empty")
 	}
-	if len(user.Username) > 12 {
-		return false, errors.New("username is to long")
-	}
-	if len(user.Username) < 3 {
-		return false, errors.New("username is too short")
-	}
 
 	return true, nil
 }
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -19,7 +20,7 @@ func TestUser_Validate(t *testing.T) {
 			user: &User{
 				ID:       1,
 				Username: "James",
-				Admin:    true,
+				Roles:    []string{RoleAdmin},
 			},
 			errorMsg: "nil",
 			ok:       true,
@@ -29,7 +30,7 @@ func TestUser_Validate(t *testing.T) {
 			user: &User{
 				ID:       0,
 				Username: "James",
-				Admin:    true,
+				Roles:    []string{RoleAdmin},
 			},
 			errorMsg: "user id is invalid",
 			ok:       false,
@@ -39,30 +40,20 @@ func TestUser_Validate(t *testing.T) {
 			user: &User{
 				ID:       1,
 				Username: "",
-				Admin:    true,
+				Roles:    []string{RoleAdmin},
 			},
 			errorMsg: "username is empty",
 			ok:       false,
 		},
 		{
-			name: "invalid user name, username too long",
+			name: "username length is left to the username policy",
 			user: &User{
 				ID:       1,
 				Username: "JamesNameOver10",
-				Admin:    true,
+				Roles:    []string{RoleAdmin},
 			},
-			errorMsg: "username is to long",
-			ok:       false,
-		},
-		{
-			name: "invalid user name, username too short",
-			user: &User{
-				ID:       1,
-				Username: "Ja",
-				Admin:    true,
-			},
-			errorMsg: "username is too short",
-			ok:       false,
+			errorMsg: "nil",
+			ok:       true,
 		},
 		{
 			name:     "invalid user, user is nil",
@@ -98,14 +89,14 @@ func TestUser_IsAdmin(t *testing.T) {
 		{
 			name: "User is Admin",
 			user: &User{
-				Admin: true,
+				Roles: []string{RoleSupport, RoleAdmin},
 			},
 			expected: true,
 		},
 		{
 			name: "User is not an Admin",
 			user: &User{
-				Admin: false,
+				Roles: []string{RoleSupport},
 			},
 			expected: false,
 		},
@@ -125,3 +116,90 @@ func TestUser_IsAdmin(t *testing.T) {
 		})
 	}
 }
+
+func TestUser_Json_Omits_Password(t *testing.T) {
+	user := &User{ID: 1, Username: "James", Password: "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy"}
+
+	b, err := json.Marshal(user)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(b), "password")
+	assert.NotContains(t, string(b), user.Password)
+}
+
+func TestAccountStatus_CanTransitionTo(t *testing.T) {
+	tt := []struct {
+		name     string
+		from, to AccountStatus
+		expected bool
+	}{
+		{name: "pending to active", from: StatusPending, to: StatusActive, expected: true},
+		{name: "pending to suspended", from: StatusPending, to: StatusSuspended, expected: false},
+		{name: "active to suspended", from: StatusActive, to: StatusSuspended, expected: true},
+		{name: "active to pending", from: StatusActive, to: StatusPending, expected: false},
+		{name: "suspended to active", from: StatusSuspended, to: StatusActive, expected: true},
+		{name: "suspended to disabled", from: StatusSuspended, to: StatusDisabled, expected: true},
+		{name: "disabled is final", from: StatusDisabled, to: StatusActive, expected: false},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, tc.from.CanTransitionTo(tc.to))
+		})
+	}
+}
+
+func TestUser_CheckActive(t *testing.T) {
+	tt := []struct {
+		name   string
+		status AccountStatus
+		errMsg string
+	}{
+		{name: "active", status: StatusActive},
+		{name: "status not set", status: ""},
+		{name: "pending", status: StatusPending, errMsg: "account is pending activation"},
+		{name: "suspended", status: StatusSuspended, errMsg: "account is suspended"},
+		{name: "disabled", status: StatusDisabled, errMsg: "account is disabled"},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			err := (&User{Status: tc.status}).CheckActive()
+			if tc.errMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, tc.errMsg, err.Error())
+			assert.Equal(t, CodePermissionDenied, ErrorCodeOf(err))
+		})
+	}
+}
+
+func TestUser_SetRole(t *testing.T) {
+	user := &User{Roles: []string{RoleSupport}}
+
+	user.SetRole(RoleAdmin, true)
+	user.SetRole(RoleAdmin, true)
+	assert.Equal(t, []string{RoleSupport, RoleAdmin}, user.Roles)
+	assert.True(t, user.IsAdmin())
+
+	user.SetRole(RoleAdmin, false)
+	assert.Equal(t, []string{RoleSupport}, user.Roles)
+	assert.False(t, user.IsAdmin())
+}
+
+func TestRole_HasPermission(t *testing.T) {
+	roles := map[string]*Role{}
+	for _, role := range DefaultRoles() {
+		roles[role.Name] = role
+	}
+
+	assert.True(t, roles[RoleAdmin].HasPermission("users:delete"))
+	assert.True(t, roles[RoleSupport].HasPermission("users:unlock"))
+	assert.False(t, roles[RoleSupport].HasPermission("users:delete"))
+	assert.True(t, roles[RoleAuditor].HasPermission("roles:list"))
+	assert.False(t, roles[RoleReadOnlyAdmin].HasPermission("mfa:manage_policy"))
+}
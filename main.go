@@ -3,15 +3,22 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"time"
 
 	api "github.com/JamieBShaw/user-service/api/auth_serivce_grpc"
+	"github.com/JamieBShaw/user-service/auth"
+	"github.com/JamieBShaw/user-service/notify"
 	"github.com/JamieBShaw/user-service/protob"
+	"github.com/JamieBShaw/user-service/repository"
+	"github.com/JamieBShaw/user-service/repository/memory"
 	"github.com/JamieBShaw/user-service/repository/postgres"
+	"github.com/JamieBShaw/user-service/repository/postgres/migrations"
 	"github.com/JamieBShaw/user-service/service"
 	internalGrpc "github.com/JamieBShaw/user-service/transport/grpc"
 	internalhttp "github.com/JamieBShaw/user-service/transport/http"
@@ -22,42 +29,141 @@ import (
 )
 
 var (
-	log        = logrus.New()
-	router     = mux.NewRouter()
-	grpc       = flag.Bool("grpc", false, "service will use grpc (http2) as the transport layer")
-	port       = os.Getenv("PORT")
-	DbUser     = os.Getenv("PGUSER")
-	DbPassword = os.Getenv("PGPASSWORD")
-	DbName     = os.Getenv("PGDATABASE")
+	log                  = logrus.New()
+	router               = mux.NewRouter()
+	grpc                 = flag.Bool("grpc", false, "service will use grpc (http2) as the transport layer")
+	store                = flag.String("store", "postgres", "storage backend for users, either postgres or memory")
+	autoMigrate          = flag.Bool("migrate", true, "apply pending database migrations on service startup")
+	notifyFile           = flag.String("notify-file", "", "append notifications, like password reset tokens, to this file instead of logging them")
+	requireVerifiedEmail = flag.Bool("require-verified-email", false, "stop users logging in until they verified their email address")
+	requireApproval      = flag.Bool("require-approval", false, "register users as pending, they can not log in until an admin activates them")
+	inviteOnly           = flag.Bool("invite-only", false, "disable open registration, users can only register by accepting an invitation")
+	passwordHash         = flag.String("password-hash", "bcrypt", "algorithm new passwords are hashed with, either bcrypt or argon2id")
+	bcryptCost           = flag.Int("bcrypt-cost", 0, "cost of bcrypt password hashes, 0 uses the bcrypt default")
+	argon2Memory         = flag.Uint("argon2-memory", uint(auth.DefaultArgon2idParams.Memory), "memory of argon2id password hashes in KiB")
+	argon2Iterations     = flag.Uint("argon2-iterations", uint(auth.DefaultArgon2idParams.Iterations), "iterations of argon2id password hashes")
+	argon2Parallelism    = flag.Uint("argon2-parallelism", uint(auth.DefaultArgon2idParams.Parallelism), "threads of argon2id password hashes")
+	passwordMinLength    = flag.Int("password-min-length", service.DefaultPasswordPolicy.MinLength, "minimum number of characters in a password")
+	passwordMaxLength    = flag.Int("password-max-length", service.DefaultPasswordPolicy.MaxLength, "maximum number of characters in a password, 0 for no maximum")
+	passwordRequire      = flag.String("password-require", "", "comma separated character classes every password must contain: lower, upper, digit, symbol")
+	passwordDenylist     = flag.String("password-denylist", "", "file or directory of breached password SHA-1 hashes that are refused as passwords")
+	usernameMinLength    = flag.Int("username-min-length", service.DefaultUsernamePolicy.MinLength, "minimum number of characters in a username")
+	usernameMaxLength    = flag.Int("username-max-length", service.DefaultUsernamePolicy.MaxLength, "maximum number of characters in a username")
+	usernameUnicode      = flag.Bool("username-unicode", false, "allow letters and digits of every script in usernames instead of only ASCII")
+	deletedRetention     = flag.Duration("deleted-retention", service.DefaultDeletedRetention, "how long deleted users can be restored before they are purged, 0 never purges them")
+	purgeInterval        = flag.Duration("purge-interval", time.Hour, "how often users past the deleted retention are purged")
+	usernameReserved     = flag.String("username-reserved", strings.Join(service.DefaultReservedUsernames, ","), "comma separated usernames nobody may take")
+	port                 = os.Getenv("PORT")
+	DbUser               = os.Getenv("PGUSER")
+	DbPassword           = os.Getenv("PGPASSWORD")
+	DbName               = os.Getenv("PGDATABASE")
+	// accessSecret verifies access tokens issued by the auth service, it must match its ACCESS_SECRET
+	accessSecret = os.Getenv("ACCESS_SECRET")
+	// refreshSecret verifies refresh tokens issued by the auth service, it must match its REFRESH_SECRET
+	refreshSecret = os.Getenv("REFRESH_SECRET")
 )
 
 func main() {
 	flag.Parse()
 
-	dbConnection := pg.Connect(&pg.Options{
-		User:     DbUser,
-		Password: DbPassword,
-		Database: DbName,
-		OnConnect: func(ctx context.Context, cn *pg.Conn) error {
-			_, err := cn.Exec("CREATE TABLE IF NOT EXISTS users (" +
-				"id bigserial primary key," +
-				"username varchar(40) unique," +
-				"admin bool," +
-				"created_at timestamp default now() not null," +
-				"updated_at timestamp default now() not null" +
-				");")
+	if flag.Arg(0) == "migrate" {
+		runMigrations(flag.Arg(1))
+		return
+	}
+
+	var (
+		repo               repository.Repository
+		resetTokens        repository.PasswordResetRepository
+		verificationTokens repository.EmailVerificationRepository
+		loginAttempts      repository.LoginAttemptRepository
+		mfaRepo            repository.MFARepository
+		settings           repository.SettingsRepository
+		roles              repository.RoleRepository
+		organizations      repository.OrganizationRepository
+		groups             repository.GroupRepository
+		invitations        repository.InvitationRepository
+	)
+
+	switch *store {
+	case "memory":
+		log.Info("Using in memory user store, users will be lost on shutdown")
+		memoryRepo := memory.NewRepository(log)
+		repo, resetTokens, verificationTokens, loginAttempts, mfaRepo, settings, roles, organizations, groups, invitations = memoryRepo, memoryRepo, memoryRepo, memoryRepo, memoryRepo, memoryRepo, memoryRepo, memoryRepo, memoryRepo, memoryRepo
+	case "postgres":
+		dbConnection := connectPostgres()
+		defer dbConnection.Close()
+
+		if *autoMigrate {
+			migrator, err := migrations.NewMigrator(log, dbConnection)
 			if err != nil {
-				log.Errorf("error creating table on service startup: %v", err)
-				return err
+				log.Fatalf("error loading migrations: %v", err)
 			}
-			return nil
-		},
-	})
+			if err := migrator.Up(context.Background()); err != nil {
+				log.Fatalf("error migrating database on service startup: %v", err)
+			}
+		}
 
-	defer dbConnection.Close()
+		postgresRepo := postgres.NewRepository(log, dbConnection)
+		repo, resetTokens, verificationTokens, loginAttempts, mfaRepo, settings, roles, organizations, groups, invitations = postgresRepo, postgresRepo, postgresRepo, postgresRepo, postgresRepo, postgresRepo, postgresRepo, postgresRepo, postgresRepo, postgresRepo
+	default:
+		log.Fatalf("unknown store: %v", *store)
+	}
+
+	var notifier notify.Notifier = notify.NewLogNotifier(log)
+	if *notifyFile != "" {
+		notifier = notify.NewFileNotifier(*notifyFile)
+	}
+
+	hasher, err := newPasswordHasher()
+	if err != nil {
+		log.Fatalf("error configuring password hashing: %v", err)
+	}
+
+	passwordPolicy, err := newPasswordPolicy()
+	if err != nil {
+		log.Fatalf("error configuring password policy: %v", err)
+	}
+
+	usernamePolicy, err := newUsernamePolicy()
+	if err != nil {
+		log.Fatalf("error configuring username policy: %v", err)
+	}
+
+	userService := service.NewUserService(repo, loginAttempts, service.Options{
+		RequireVerifiedEmail: *requireVerifiedEmail,
+		RequireApproval:      *requireApproval,
+		InviteOnly:           *inviteOnly,
+		Lockout:              service.DefaultLockoutPolicy,
+		Hasher:               hasher,
+		PasswordPolicy:       &passwordPolicy,
+		UsernamePolicy:       &usernamePolicy,
+	})
+	passwordResetService := service.NewPasswordResetService(repo, resetTokens, notifier, hasher, passwordPolicy)
+	emailVerificationService := service.NewEmailVerificationService(repo, verificationTokens, notifier)
+	mfaService := service.NewMFAService(repo, mfaRepo, settings, loginAttempts, service.DefaultLockoutPolicy)
+	roleService := service.NewRoleService(repo, roles)
+	organizationService := service.NewOrganizationService(repo, organizations)
+	groupService := service.NewGroupService(repo, groups)
+	invitationService := service.NewInvitationService(userService, repo, invitations, roles, organizations, verificationTokens, notifier)
 
-	repo := postgres.NewRepository(log, dbConnection)
-	userService := service.NewUserService(repo)
+	if *deletedRetention > 0 {
+		if *purgeInterval <= 0 {
+			log.Fatalf("purge-interval must be positive")
+		}
+		purgeService := service.NewPurgeService(repo, *deletedRetention)
+		go purgeService.Run(context.Background(), *purgeInterval)
+	}
+	// Tokens signed with an empty key can be forged by anyone
+	if accessSecret == "" {
+		log.Fatal("ACCESS_SECRET must be set")
+	}
+	if refreshSecret == "" {
+		log.Fatal("REFRESH_SECRET must be set")
+	}
+	tokens := auth.NewJWTParser(accessSecret)
+	refreshTokens := auth.NewJWTRefreshParser(refreshSecret)
+	authorizer := auth.NewAuthorizer(userService, roleService, organizationService, groupService)
+	authClient := protob.NewAuthServiceClient(api.NewAuthClientConn())
 
 	if port == "" {
 		port = "8080"
@@ -71,8 +177,11 @@ func main() {
 			log.Fatal("Failed to listen", err)
 		}
 
-		s := googlegrpc.NewServer()
-		srv := internalGrpc.NewGrpcServer(userService)
+		s := googlegrpc.NewServer(googlegrpc.ChainUnaryInterceptor(
+			internalGrpc.OrganizationInterceptor(organizationService),
+			internalGrpc.AuthInterceptor(tokens, authClient, authorizer),
+		))
+		srv := internalGrpc.NewGrpcServer(userService, emailVerificationService, mfaService, roleService, organizationService, groupService, invitationService, authorizer, authClient, refreshTokens)
 		protob.RegisterUserServiceServer(s, srv)
 
 		if err := s.Serve(lis); err != nil {
@@ -81,8 +190,7 @@ func main() {
 
 	} else {
 
-		cc := api.NewAuthClientConn()
-		handler := internalhttp.NewHttpHandler(userService, router, protob.NewAuthServiceClient(cc))
+		handler := internalhttp.NewHttpHandler(userService, passwordResetService, emailVerificationService, mfaService, roleService, organizationService, groupService, invitationService, router, authClient, tokens, refreshTokens)
 
 		srv := &http.Server{
 			Addr:         "0.0.0.0:" + port,
@@ -121,3 +229,122 @@ func main() {
 		os.Exit(0)
 	}
 }
+
+// newPasswordHasher returns the hasher selected by the -password-hash flags, stored hashes of the other
+// algorithm or with other parameters keep working and are replaced on the next login of their user
+func newPasswordHasher() (auth.PasswordHasher, error) {
+	switch *passwordHash {
+	case "bcrypt":
+		return auth.NewBcryptHasher(*bcryptCost)
+	case "argon2id":
+		if *argon2Parallelism > 255 {
+			return nil, fmt.Errorf("argon2-parallelism must be at most 255")
+		}
+		params := auth.DefaultArgon2idParams
+		params.Memory = uint32(*argon2Memory)
+		params.Iterations = uint32(*argon2Iterations)
+		params.Parallelism = uint8(*argon2Parallelism)
+		return auth.NewArgon2idHasher(params)
+	}
+	return nil, fmt.Errorf("unknown password hash: %v", *passwordHash)
+}
+
+// newPasswordPolicy returns the policy configured by the -password flags
+func newPasswordPolicy() (service.PasswordPolicy, error) {
+	policy := service.DefaultPasswordPolicy
+	policy.MinLength = *passwordMinLength
+	policy.MaxLength = *passwordMaxLength
+	if policy.MaxLength > 0 && policy.MaxLength < policy.MinLength {
+		return policy, fmt.Errorf("password-max-length must not be less than password-min-length")
+	}
+
+	for _, class := range strings.Split(*passwordRequire, ",") {
+		switch strings.TrimSpace(class) {
+		case "":
+		case "lower":
+			policy.RequireLower = true
+		case "upper":
+			policy.RequireUpper = true
+		case "digit":
+			policy.RequireDigit = true
+		case "symbol":
+			policy.RequireSymbol = true
+		default:
+			return policy, fmt.Errorf("unknown password character class: %v", class)
+		}
+	}
+
+	if *passwordDenylist != "" {
+		denylist, err := service.LoadPasswordDenylist(*passwordDenylist)
+		if err != nil {
+			return policy, err
+		}
+		policy.Denylist = denylist
+	}
+
+	return policy, nil
+}
+
+// newUsernamePolicy returns the policy configured by the -username flags
+func newUsernamePolicy() (service.UsernamePolicy, error) {
+	policy := service.UsernamePolicy{
+		MinLength:    *usernameMinLength,
+		MaxLength:    *usernameMaxLength,
+		AllowUnicode: *usernameUnicode,
+	}
+	if policy.MinLength < 1 || policy.MaxLength < policy.MinLength || policy.MaxLength > service.UsernameColumnLength {
+		return policy, fmt.Errorf("username lengths must be between 1 and %d, with the minimum not above the maximum", service.UsernameColumnLength)
+	}
+
+	for _, reserved := range strings.Split(*usernameReserved, ",") {
+		if reserved = strings.TrimSpace(reserved); reserved != "" {
+			policy.Reserved = append(policy.Reserved, reserved)
+		}
+	}
+
+	return policy, nil
+}
+
+func connectPostgres() *pg.DB {
+	return pg.Connect(&pg.Options{
+		User:     DbUser,
+		Password: DbPassword,
+		Database: DbName,
+	})
+}
+
+// runMigrations handles the migrate subcommand: migrate [up|down|status]
+func runMigrations(command string) {
+	dbConnection := connectPostgres()
+	defer dbConnection.Close()
+
+	migrator, err := migrations.NewMigrator(log, dbConnection)
+	if err != nil {
+		log.Fatalf("error loading migrations: %v", err)
+	}
+
+	ctx := context.Background()
+
+	switch command {
+	case "", "up":
+		err = migrator.Up(ctx)
+	case "down":
+		err = migrator.Down(ctx)
+	case "status":
+		var statuses []migrations.Status
+		statuses, err = migrator.Status(ctx)
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = "applied at " + status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%s: %s\n", status.Version, status.Name, applied)
+		}
+	default:
+		log.Fatalf("unknown migrate command: %v, expected up, down or status", command)
+	}
+
+	if err != nil {
+		log.Fatalf("error running migrations: %v", err)
+	}
+}
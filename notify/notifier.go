@@ -0,0 +1,121 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/sirupsen/logrus"
+)
+
+// Notifier delivers secrets that only the owner of an account may see, like password reset tokens
+type Notifier interface {
+	SendPasswordReset(ctx context.Context, user *model.User, token string) error
+	// SendEmailVerification delivers the token to user.Email
+	SendEmailVerification(ctx context.Context, user *model.User, token string) error
+	// SendInvitation delivers the token to invitation.Email, who has no account yet
+	SendInvitation(ctx context.Context, invitation *model.Invitation, token string) error
+}
+
+// message is a notification written by the local notifiers
+type message struct {
+	Kind     string    `json:"kind"`
+	UserID   int64     `json:"user_id,omitempty"`
+	Username string    `json:"username,omitempty"`
+	Email    string    `json:"email,omitempty"`
+	Token    string    `json:"token"`
+	SentAt   time.Time `json:"sent_at"`
+}
+
+type logNotifier struct {
+	log *logrus.Logger
+}
+
+// NewLogNotifier logs every notification, secrets included, it is only meant for running the service locally
+func NewLogNotifier(log *logrus.Logger) Notifier {
+	return &logNotifier{log: log}
+}
+
+func (n *logNotifier) SendPasswordReset(_ context.Context, user *model.User, token string) error {
+	n.log.WithFields(logrus.Fields{
+		"user_id":  user.ID,
+		"username": user.Username,
+		"token":    token,
+	}).Info("[NOTIFIER]: Password reset requested")
+	return nil
+}
+
+func (n *logNotifier) SendEmailVerification(_ context.Context, user *model.User, token string) error {
+	n.log.WithFields(logrus.Fields{
+		"user_id": user.ID,
+		"email":   user.Email,
+		"token":   token,
+	}).Info("[NOTIFIER]: Email verification sent")
+	return nil
+}
+
+func (n *logNotifier) SendInvitation(_ context.Context, invitation *model.Invitation, token string) error {
+	n.log.WithFields(logrus.Fields{
+		"invitation_id": invitation.ID,
+		"email":         invitation.Email,
+		"token":         token,
+	}).Info("[NOTIFIER]: Invitation sent")
+	return nil
+}
+
+type fileNotifier struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileNotifier appends every notification as a json line to the file at path, it is only meant for running the service locally
+func NewFileNotifier(path string) Notifier {
+	return &fileNotifier{path: path}
+}
+
+func (n *fileNotifier) SendPasswordReset(_ context.Context, user *model.User, token string) error {
+	return n.write(message{
+		Kind:     "password_reset",
+		UserID:   user.ID,
+		Username: user.Username,
+		Email:    user.Email,
+		Token:    token,
+		SentAt:   time.Now().UTC(),
+	})
+}
+
+func (n *fileNotifier) SendEmailVerification(_ context.Context, user *model.User, token string) error {
+	return n.write(message{
+		Kind:     "email_verification",
+		UserID:   user.ID,
+		Username: user.Username,
+		Email:    user.Email,
+		Token:    token,
+		SentAt:   time.Now().UTC(),
+	})
+}
+
+func (n *fileNotifier) SendInvitation(_ context.Context, invitation *model.Invitation, token string) error {
+	return n.write(message{
+		Kind:   "invitation",
+		Email:  invitation.Email,
+		Token:  token,
+		SentAt: time.Now().UTC(),
+	})
+}
+
+func (n *fileNotifier) write(m message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	f, err := os.OpenFile(n.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return json.NewEncoder(f).Encode(m)
+}
@@ -0,0 +1,49 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileNotifier(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notifications.jsonl")
+	notifier := NewFileNotifier(path)
+
+	assert.NoError(t, notifier.SendPasswordReset(context.Background(), &model.User{ID: 1, Username: "James"}, "token-1"))
+	assert.NoError(t, notifier.SendEmailVerification(context.Background(), &model.User{ID: 2, Username: "David", Email: "david@example.com"}, "token-2"))
+	assert.NoError(t, notifier.SendInvitation(context.Background(), &model.Invitation{ID: 3, Email: "michael@example.com"}, "token-3"))
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("could not open notifications: %v", err)
+	}
+	defer f.Close()
+
+	var messages []message
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var m message
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			t.Fatalf("could not read notification: %v", err)
+		}
+		messages = append(messages, m)
+	}
+
+	assert.Len(t, messages, 3)
+	assert.Equal(t, "password_reset", messages[0].Kind)
+	assert.Equal(t, "James", messages[0].Username)
+	assert.Equal(t, "token-1", messages[0].Token)
+	assert.Equal(t, "email_verification", messages[1].Kind)
+	assert.Equal(t, "david@example.com", messages[1].Email)
+	assert.Equal(t, int64(2), messages[1].UserID)
+	assert.Equal(t, "invitation", messages[2].Kind)
+	assert.Equal(t, "michael@example.com", messages[2].Email)
+	assert.Equal(t, "token-3", messages[2].Token)
+}
@@ -11,7 +11,7 @@ import (
 
 // This is a compile-time assertion to ensure that this generated file
 // is compatible with the grpc package it is being compiled against.
-const _ = grpc.SupportPackageIsVersion6
+const _ = grpc.SupportPackageIsVersion7
 
 // AuthServiceClient is the client API for AuthService service.
 //
@@ -20,8 +20,14 @@ type AuthServiceClient interface {
 	// Generate an AccessToken object for a user and save it in redis, return the access token
 	// and refresh token to be used by other services
 	CreateAccessToken(ctx context.Context, in *CreateAccessTokenRequest, opts ...grpc.CallOption) (*CreateAccessTokenResponse, error)
+	// Exchange a refresh token for a new access token and refresh token, the used refresh token is revoked
+	RefreshAccessToken(ctx context.Context, in *RefreshAccessTokenRequest, opts ...grpc.CallOption) (*CreateAccessTokenResponse, error)
 	// Delete the AccessToken of a specific user from redis, return a confirmation.
 	DeleteAccessToken(ctx context.Context, in *DeleteAccessTokenRequest, opts ...grpc.CallOption) (*DeleteAccessTokenResponse, error)
+	// Delete every AccessToken of a user from redis, signing them out of all their sessions.
+	DeleteUserAccessTokens(ctx context.Context, in *DeleteUserAccessTokensRequest, opts ...grpc.CallOption) (*DeleteUserAccessTokensResponse, error)
+	// Look up the AccessToken of a session in redis, NotFound once it expired or was deleted.
+	CheckAccessToken(ctx context.Context, in *CheckAccessTokenRequest, opts ...grpc.CallOption) (*CheckAccessTokenResponse, error)
 }
 
 type authServiceClient struct {
@@ -41,6 +47,15 @@ func (c *authServiceClient) CreateAccessToken(ctx context.Context, in *CreateAcc
 	return out, nil
 }
 
+func (c *authServiceClient) RefreshAccessToken(ctx context.Context, in *RefreshAccessTokenRequest, opts ...grpc.CallOption) (*CreateAccessTokenResponse, error) {
+	out := new(CreateAccessTokenResponse)
+	err := c.cc.Invoke(ctx, "/AuthService/RefreshAccessToken", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *authServiceClient) DeleteAccessToken(ctx context.Context, in *DeleteAccessTokenRequest, opts ...grpc.CallOption) (*DeleteAccessTokenResponse, error) {
 	out := new(DeleteAccessTokenResponse)
 	err := c.cc.Invoke(ctx, "/AuthService/DeleteAccessToken", in, out, opts...)
@@ -50,6 +65,24 @@ func (c *authServiceClient) DeleteAccessToken(ctx context.Context, in *DeleteAcc
 	return out, nil
 }
 
+func (c *authServiceClient) DeleteUserAccessTokens(ctx context.Context, in *DeleteUserAccessTokensRequest, opts ...grpc.CallOption) (*DeleteUserAccessTokensResponse, error) {
+	out := new(DeleteUserAccessTokensResponse)
+	err := c.cc.Invoke(ctx, "/AuthService/DeleteUserAccessTokens", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authServiceClient) CheckAccessToken(ctx context.Context, in *CheckAccessTokenRequest, opts ...grpc.CallOption) (*CheckAccessTokenResponse, error) {
+	out := new(CheckAccessTokenResponse)
+	err := c.cc.Invoke(ctx, "/AuthService/CheckAccessToken", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // AuthServiceServer is the server API for AuthService service.
 // All implementations must embed UnimplementedAuthServiceServer
 // for forward compatibility
@@ -57,8 +90,14 @@ type AuthServiceServer interface {
 	// Generate an AccessToken object for a user and save it in redis, return the access token
 	// and refresh token to be used by other services
 	CreateAccessToken(context.Context, *CreateAccessTokenRequest) (*CreateAccessTokenResponse, error)
+	// Exchange a refresh token for a new access token and refresh token, the used refresh token is revoked
+	RefreshAccessToken(context.Context, *RefreshAccessTokenRequest) (*CreateAccessTokenResponse, error)
 	// Delete the AccessToken of a specific user from redis, return a confirmation.
 	DeleteAccessToken(context.Context, *DeleteAccessTokenRequest) (*DeleteAccessTokenResponse, error)
+	// Delete every AccessToken of a user from redis, signing them out of all their sessions.
+	DeleteUserAccessTokens(context.Context, *DeleteUserAccessTokensRequest) (*DeleteUserAccessTokensResponse, error)
+	// Look up the AccessToken of a session in redis, NotFound once it expired or was deleted.
+	CheckAccessToken(context.Context, *CheckAccessTokenRequest) (*CheckAccessTokenResponse, error)
 	mustEmbedUnimplementedAuthServiceServer()
 }
 
@@ -66,15 +105,31 @@ type AuthServiceServer interface {
 type UnimplementedAuthServiceServer struct {
 }
 
-func (*UnimplementedAuthServiceServer) CreateAccessToken(context.Context, *CreateAccessTokenRequest) (*CreateAccessTokenResponse, error) {
+func (UnimplementedAuthServiceServer) CreateAccessToken(context.Context, *CreateAccessTokenRequest) (*CreateAccessTokenResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CreateAccessToken not implemented")
 }
-func (*UnimplementedAuthServiceServer) DeleteAccessToken(context.Context, *DeleteAccessTokenRequest) (*DeleteAccessTokenResponse, error) {
+func (UnimplementedAuthServiceServer) RefreshAccessToken(context.Context, *RefreshAccessTokenRequest) (*CreateAccessTokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RefreshAccessToken not implemented")
+}
+func (UnimplementedAuthServiceServer) DeleteAccessToken(context.Context, *DeleteAccessTokenRequest) (*DeleteAccessTokenResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteAccessToken not implemented")
 }
-func (*UnimplementedAuthServiceServer) mustEmbedUnimplementedAuthServiceServer() {}
+func (UnimplementedAuthServiceServer) DeleteUserAccessTokens(context.Context, *DeleteUserAccessTokensRequest) (*DeleteUserAccessTokensResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteUserAccessTokens not implemented")
+}
+func (UnimplementedAuthServiceServer) CheckAccessToken(context.Context, *CheckAccessTokenRequest) (*CheckAccessTokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CheckAccessToken not implemented")
+}
+func (UnimplementedAuthServiceServer) mustEmbedUnimplementedAuthServiceServer() {}
 
-func RegisterAuthServiceServer(s *grpc.Server, srv AuthServiceServer) {
+// UnsafeAuthServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AuthServiceServer will
+// result in compilation errors.
+type UnsafeAuthServiceServer interface {
+	mustEmbedUnimplementedAuthServiceServer()
+}
+
+func RegisterAuthServiceServer(s grpc.ServiceRegistrar, srv AuthServiceServer) {
 	s.RegisterService(&_AuthService_serviceDesc, srv)
 }
 
@@ -96,6 +151,24 @@ func _AuthService_CreateAccessToken_Handler(srv interface{}, ctx context.Context
 	return interceptor(ctx, in, info, handler)
 }
 
+func _AuthService_RefreshAccessToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RefreshAccessTokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServiceServer).RefreshAccessToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/AuthService/RefreshAccessToken",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServiceServer).RefreshAccessToken(ctx, req.(*RefreshAccessTokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _AuthService_DeleteAccessToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(DeleteAccessTokenRequest)
 	if err := dec(in); err != nil {
@@ -114,6 +187,42 @@ func _AuthService_DeleteAccessToken_Handler(srv interface{}, ctx context.Context
 	return interceptor(ctx, in, info, handler)
 }
 
+func _AuthService_DeleteUserAccessTokens_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteUserAccessTokensRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServiceServer).DeleteUserAccessTokens(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/AuthService/DeleteUserAccessTokens",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServiceServer).DeleteUserAccessTokens(ctx, req.(*DeleteUserAccessTokensRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AuthService_CheckAccessToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CheckAccessTokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServiceServer).CheckAccessToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/AuthService/CheckAccessToken",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServiceServer).CheckAccessToken(ctx, req.(*CheckAccessTokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _AuthService_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "AuthService",
 	HandlerType: (*AuthServiceServer)(nil),
@@ -122,10 +231,22 @@ var _AuthService_serviceDesc = grpc.ServiceDesc{
 			MethodName: "CreateAccessToken",
 			Handler:    _AuthService_CreateAccessToken_Handler,
 		},
+		{
+			MethodName: "RefreshAccessToken",
+			Handler:    _AuthService_RefreshAccessToken_Handler,
+		},
 		{
 			MethodName: "DeleteAccessToken",
 			Handler:    _AuthService_DeleteAccessToken_Handler,
 		},
+		{
+			MethodName: "DeleteUserAccessTokens",
+			Handler:    _AuthService_DeleteUserAccessTokens_Handler,
+		},
+		{
+			MethodName: "CheckAccessToken",
+			Handler:    _AuthService_CheckAccessToken_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "protob/auth_service.proto",
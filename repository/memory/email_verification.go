@@ -0,0 +1,63 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+)
+
+var ErrVerificationTokenNotFound = model.NotFoundError("verification token not found")
+
+func (repo *repository) CreateVerificationToken(_ context.Context, userID int64, email, tokenHash string, expiresAt time.Time) error {
+	repo.log.Info("[MEMORY REPO]: Executing Create Verification Token")
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.user(userID); !ok {
+		return ErrUserNotFound
+	}
+
+	for hash, token := range repo.verificationTokens {
+		if token.UserID == userID && token.UsedAt == nil {
+			delete(repo.verificationTokens, hash)
+		}
+	}
+
+	repo.lastVerificationTokenID++
+	repo.verificationTokens[tokenHash] = &model.EmailVerificationToken{
+		ID:        repo.lastVerificationTokenID,
+		UserID:    userID,
+		Email:     email,
+		TokenHash: tokenHash,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+
+	return nil
+}
+
+func (repo *repository) VerifyEmail(_ context.Context, tokenHash string, now time.Time) error {
+	repo.log.Info("[MEMORY REPO]: Executing Verify Email")
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	token, ok := repo.verificationTokens[tokenHash]
+	if !ok || token.UsedAt != nil || !token.ExpiresAt.After(now) {
+		return ErrVerificationTokenNotFound
+	}
+
+	// The token only verifies the email it was sent to
+	user, ok := repo.user(token.UserID)
+	if !ok || user.Email != token.Email {
+		return ErrVerificationTokenNotFound
+	}
+
+	token.UsedAt = &now
+	user.VerifiedAt = &now
+	user.UpdatedAt = now
+
+	return nil
+}
@@ -0,0 +1,218 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+)
+
+var (
+	ErrGroupNotFound     = model.NotFoundError("group not found")
+	ErrGroupNameTaken    = model.AlreadyExistsError("group already exists")
+	ErrGroupMemberAbsent = model.NotFoundError("user is not a member of the group")
+)
+
+func (repo *repository) Group(ctx context.Context, id int64) (*model.Group, error) {
+	repo.log.Info("[MEMORY REPO]: Executing Group")
+
+	organizationID := model.OrganizationFromContext(ctx)
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	group, ok := repo.group(organizationID, id)
+	if !ok {
+		return nil, ErrGroupNotFound
+	}
+
+	c := *group
+	return &c, nil
+}
+
+func (repo *repository) CreateGroup(ctx context.Context, name, description string, ownerID int64) (*model.Group, error) {
+	repo.log.Info("[MEMORY REPO]: Executing Create Group")
+
+	organizationID := model.OrganizationFromContext(ctx)
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.member(organizationID, ownerID); !ok {
+		return nil, ErrUserNotFound
+	}
+	for _, group := range repo.groups {
+		if group.OrganizationID == organizationID && strings.EqualFold(group.Name, name) {
+			return nil, ErrGroupNameTaken
+		}
+	}
+
+	repo.lastGroupID++
+	now := time.Now()
+	group := &model.Group{
+		ID:             repo.lastGroupID,
+		OrganizationID: organizationID,
+		Name:           name,
+		Description:    description,
+		OwnerID:        ownerID,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	repo.groups[group.ID] = group
+	repo.groupMembers[group.ID] = make(map[int64]struct{})
+
+	c := *group
+	return &c, nil
+}
+
+func (repo *repository) DeleteGroup(ctx context.Context, id int64) error {
+	repo.log.Info("[MEMORY REPO]: Executing Delete Group")
+
+	organizationID := model.OrganizationFromContext(ctx)
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.group(organizationID, id); !ok {
+		return ErrGroupNotFound
+	}
+
+	delete(repo.groups, id)
+	delete(repo.groupMembers, id)
+
+	return nil
+}
+
+func (repo *repository) Groups(ctx context.Context, afterID int64, limit int) ([]*model.Group, error) {
+	repo.log.Info("[MEMORY REPO]: Executing Groups")
+
+	organizationID := model.OrganizationFromContext(ctx)
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	return repo.groupPage(afterID, limit, func(group *model.Group) bool {
+		return group.OrganizationID == organizationID
+	}), nil
+}
+
+func (repo *repository) AddGroupMember(ctx context.Context, groupID, userID int64) error {
+	repo.log.Info("[MEMORY REPO]: Executing Add Group Member")
+
+	organizationID := model.OrganizationFromContext(ctx)
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.group(organizationID, groupID); !ok {
+		return ErrGroupNotFound
+	}
+	if _, ok := repo.member(organizationID, userID); !ok {
+		return ErrUserNotFound
+	}
+
+	repo.groupMembers[groupID][userID] = struct{}{}
+
+	return nil
+}
+
+func (repo *repository) RemoveGroupMember(ctx context.Context, groupID, userID int64) error {
+	repo.log.Info("[MEMORY REPO]: Executing Remove Group Member")
+
+	organizationID := model.OrganizationFromContext(ctx)
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.group(organizationID, groupID); !ok {
+		return ErrGroupNotFound
+	}
+	if _, ok := repo.groupMembers[groupID][userID]; !ok {
+		return ErrGroupMemberAbsent
+	}
+
+	delete(repo.groupMembers[groupID], userID)
+
+	return nil
+}
+
+func (repo *repository) GroupMembers(ctx context.Context, groupID, afterID int64, limit int) ([]*model.User, error) {
+	repo.log.Info("[MEMORY REPO]: Executing Group Members")
+
+	organizationID := model.OrganizationFromContext(ctx)
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	if _, ok := repo.group(organizationID, groupID); !ok {
+		return nil, ErrGroupNotFound
+	}
+
+	users := []*model.User{}
+	for id := range repo.groupMembers[groupID] {
+		user, ok := repo.member(organizationID, id)
+		if !ok || user.ID <= afterID {
+			continue
+		}
+		users = append(users, repo.copyUser(organizationID, user))
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
+
+	if len(users) > limit {
+		users = users[:limit]
+	}
+
+	return users, nil
+}
+
+func (repo *repository) UserGroups(ctx context.Context, userID, afterID int64, limit int) ([]*model.Group, error) {
+	repo.log.Info("[MEMORY REPO]: Executing User Groups")
+
+	organizationID := model.OrganizationFromContext(ctx)
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	return repo.groupPage(afterID, limit, func(group *model.Group) bool {
+		_, ok := repo.groupMembers[group.ID][userID]
+		return ok && group.OrganizationID == organizationID
+	}), nil
+}
+
+// group returns the group with id if it belongs to the organization, it must be called with the lock held
+func (repo *repository) group(organizationID, id int64) (*model.Group, bool) {
+	group, ok := repo.groups[id]
+	if !ok || group.OrganizationID != organizationID {
+		return nil, false
+	}
+	return group, true
+}
+
+// groupPage returns copies of at most limit groups kept by keep with an id greater than afterID, ordered by id. It
+// must be called with the lock held.
+func (repo *repository) groupPage(afterID int64, limit int, keep func(*model.Group) bool) []*model.Group {
+	groups := []*model.Group{}
+	for _, group := range repo.groups {
+		if group.ID <= afterID || !keep(group) {
+			continue
+		}
+		c := *group
+		groups = append(groups, &c)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].ID < groups[j].ID
+	})
+
+	if len(groups) > limit {
+		groups = groups[:limit]
+	}
+
+	return groups
+}
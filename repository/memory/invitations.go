@@ -0,0 +1,73 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+)
+
+var ErrInvitationNotFound = model.NotFoundError("invitation not found")
+
+func (repo *repository) CreateInvitation(ctx context.Context, invitation *model.Invitation) (*model.Invitation, error) {
+	repo.log.Info("[MEMORY REPO]: Executing Create Invitation")
+
+	organizationID := model.OrganizationFromContext(ctx)
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.member(organizationID, invitation.InvitedBy); !ok {
+		return nil, ErrUserNotFound
+	}
+
+	for hash, pending := range repo.invitations {
+		if pending.OrganizationID == organizationID && pending.Email == invitation.Email && pending.AcceptedAt == nil {
+			delete(repo.invitations, hash)
+		}
+	}
+
+	repo.lastInvitationID++
+	stored := *invitation
+	stored.ID = repo.lastInvitationID
+	stored.OrganizationID = organizationID
+	stored.AcceptedAt = nil
+	stored.CreatedAt = time.Now()
+	repo.invitations[stored.TokenHash] = &stored
+
+	c := stored
+	return &c, nil
+}
+
+func (repo *repository) AcceptInvitation(_ context.Context, tokenHash string, now time.Time) (*model.Invitation, error) {
+	repo.log.Info("[MEMORY REPO]: Executing Accept Invitation")
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	invitation, ok := repo.invitations[tokenHash]
+	if !ok || invitation.AcceptedAt != nil || !invitation.ExpiresAt.After(now) {
+		return nil, ErrInvitationNotFound
+	}
+
+	invitation.AcceptedAt = &now
+
+	c := *invitation
+	return &c, nil
+}
+
+func (repo *repository) ReopenInvitation(_ context.Context, id int64) error {
+	repo.log.Info("[MEMORY REPO]: Executing Reopen Invitation")
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for _, invitation := range repo.invitations {
+		if invitation.ID == id {
+			invitation.AcceptedAt = nil
+			return nil
+		}
+	}
+
+	return ErrInvitationNotFound
+}
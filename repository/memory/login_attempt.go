@@ -0,0 +1,82 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+)
+
+var ErrLoginAttemptNotFound = model.NotFoundError("login attempt not found")
+
+func (repo *repository) LoginAttempt(_ context.Context, key string) (*model.LoginAttempt, error) {
+	repo.log.Info("[MEMORY REPO]: Executing Login Attempt")
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	attempt, ok := repo.loginAttempts[key]
+	if !ok {
+		return nil, ErrLoginAttemptNotFound
+	}
+
+	return copyLoginAttempt(attempt), nil
+}
+
+func (repo *repository) RecordLoginFailure(_ context.Context, key string, now time.Time, window time.Duration) (*model.LoginAttempt, error) {
+	repo.log.Info("[MEMORY REPO]: Executing Record Login Failure")
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	attempt, ok := repo.loginAttempts[key]
+	if !ok {
+		attempt = &model.LoginAttempt{Key: key}
+		repo.loginAttempts[key] = attempt
+	}
+
+	if attempt.LastFailureAt.After(now.Add(-window)) {
+		attempt.Failures++
+	} else {
+		attempt.Failures = 1
+	}
+	attempt.LastFailureAt = now
+
+	return copyLoginAttempt(attempt), nil
+}
+
+func (repo *repository) LockLogin(_ context.Context, key string, until time.Time) error {
+	repo.log.Info("[MEMORY REPO]: Executing Lock Login")
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	attempt, ok := repo.loginAttempts[key]
+	if !ok {
+		attempt = &model.LoginAttempt{Key: key}
+		repo.loginAttempts[key] = attempt
+	}
+	attempt.LockedUntil = &until
+
+	return nil
+}
+
+func (repo *repository) ClearLoginAttempts(_ context.Context, key string) error {
+	repo.log.Info("[MEMORY REPO]: Executing Clear Login Attempts")
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	delete(repo.loginAttempts, key)
+
+	return nil
+}
+
+func copyLoginAttempt(attempt *model.LoginAttempt) *model.LoginAttempt {
+	c := *attempt
+	if attempt.LockedUntil != nil {
+		lockedUntil := *attempt.LockedUntil
+		c.LockedUntil = &lockedUntil
+	}
+	return &c
+}
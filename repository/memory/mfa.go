@@ -0,0 +1,195 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+)
+
+var (
+	ErrMFANotFound          = model.NotFoundError("mfa not found")
+	ErrRecoveryCodeNotFound = model.NotFoundError("recovery code not found")
+	ErrMFAChallengeNotFound = model.NotFoundError("mfa challenge not found")
+)
+
+func (repo *repository) MFA(_ context.Context, userID int64) (*model.MFA, error) {
+	repo.log.Info("[MEMORY REPO]: Executing MFA")
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	mfa, ok := repo.mfa[userID]
+	if !ok {
+		return nil, ErrMFANotFound
+	}
+
+	c := *mfa
+	return &c, nil
+}
+
+func (repo *repository) SaveMFA(_ context.Context, userID int64, secret string) error {
+	repo.log.Info("[MEMORY REPO]: Executing Save MFA")
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.user(userID); !ok {
+		return ErrUserNotFound
+	}
+
+	repo.mfa[userID] = &model.MFA{
+		UserID:    userID,
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	}
+
+	return nil
+}
+
+func (repo *repository) ConfirmMFA(_ context.Context, userID int64, step int64, recoveryCodeHashes []string, now time.Time) error {
+	repo.log.Info("[MEMORY REPO]: Executing Confirm MFA")
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	mfa, ok := repo.mfa[userID]
+	if !ok {
+		return ErrMFANotFound
+	}
+
+	mfa.ConfirmedAt = &now
+	mfa.LastUsedStep = step
+
+	codes := repo.recoveryCodes[:0]
+	for _, code := range repo.recoveryCodes {
+		if code.UserID != userID {
+			codes = append(codes, code)
+		}
+	}
+	for _, hash := range recoveryCodeHashes {
+		repo.lastRecoveryCodeID++
+		codes = append(codes, &model.MFARecoveryCode{
+			ID:        repo.lastRecoveryCodeID,
+			UserID:    userID,
+			CodeHash:  hash,
+			CreatedAt: now,
+		})
+	}
+	repo.recoveryCodes = codes
+
+	return nil
+}
+
+func (repo *repository) UseMFAStep(_ context.Context, userID int64, step int64) error {
+	repo.log.Info("[MEMORY REPO]: Executing Use MFA Step")
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	mfa, ok := repo.mfa[userID]
+	if !ok || mfa.LastUsedStep >= step {
+		return ErrMFANotFound
+	}
+
+	mfa.LastUsedStep = step
+
+	return nil
+}
+
+func (repo *repository) ConsumeRecoveryCode(_ context.Context, userID int64, codeHash string, now time.Time) error {
+	repo.log.Info("[MEMORY REPO]: Executing Consume Recovery Code")
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for _, code := range repo.recoveryCodes {
+		if code.UserID == userID && code.CodeHash == codeHash && code.UsedAt == nil {
+			code.UsedAt = &now
+			return nil
+		}
+	}
+
+	return ErrRecoveryCodeNotFound
+}
+
+func (repo *repository) DeleteMFA(_ context.Context, userID int64) error {
+	repo.log.Info("[MEMORY REPO]: Executing Delete MFA")
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.mfa[userID]; !ok {
+		return ErrMFANotFound
+	}
+
+	repo.deleteMFA(userID)
+
+	return nil
+}
+
+func (repo *repository) CreateMFAChallenge(_ context.Context, userID int64, tokenHash string, expiresAt time.Time) error {
+	repo.log.Info("[MEMORY REPO]: Executing Create MFA Challenge")
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.user(userID); !ok {
+		return ErrUserNotFound
+	}
+
+	repo.lastMFAChallengeID++
+	repo.mfaChallenges[tokenHash] = &model.MFAChallenge{
+		ID:        repo.lastMFAChallengeID,
+		UserID:    userID,
+		TokenHash: tokenHash,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+
+	return nil
+}
+
+func (repo *repository) MFAChallenge(_ context.Context, tokenHash string, now time.Time) (*model.MFAChallenge, error) {
+	repo.log.Info("[MEMORY REPO]: Executing MFA Challenge")
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	challenge, ok := repo.mfaChallenges[tokenHash]
+	if !ok || challenge.UsedAt != nil || !challenge.ExpiresAt.After(now) {
+		return nil, ErrMFAChallengeNotFound
+	}
+
+	c := *challenge
+	return &c, nil
+}
+
+func (repo *repository) ConsumeMFAChallenge(_ context.Context, tokenHash string, now time.Time) error {
+	repo.log.Info("[MEMORY REPO]: Executing Consume MFA Challenge")
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	challenge, ok := repo.mfaChallenges[tokenHash]
+	if !ok || challenge.UsedAt != nil || !challenge.ExpiresAt.After(now) {
+		return ErrMFAChallengeNotFound
+	}
+
+	challenge.UsedAt = &now
+
+	return nil
+}
+
+// deleteMFA removes the authenticator and recovery codes of the user, repo.mu must be held
+func (repo *repository) deleteMFA(userID int64) {
+	delete(repo.mfa, userID)
+
+	codes := repo.recoveryCodes[:0]
+	for _, code := range repo.recoveryCodes {
+		if code.UserID != userID {
+			codes = append(codes, code)
+		}
+	}
+	repo.recoveryCodes = codes
+}
@@ -0,0 +1,172 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+)
+
+var (
+	ErrOrganizationNotFound  = model.NotFoundError("organization not found")
+	ErrOrganizationSlugTaken = model.AlreadyExistsError("organization already exists")
+	ErrMemberAbsent          = model.NotFoundError("user is not a member of the organization")
+)
+
+func (repo *repository) Organization(_ context.Context, id int64) (*model.Organization, error) {
+	repo.log.Info("[MEMORY REPO]: Executing Organization")
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	organization, ok := repo.organizations[id]
+	if !ok {
+		return nil, ErrOrganizationNotFound
+	}
+
+	c := *organization
+	return &c, nil
+}
+
+func (repo *repository) OrganizationBySlug(_ context.Context, slug string) (*model.Organization, error) {
+	repo.log.Info("[MEMORY REPO]: Executing Organization By Slug")
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	organization := repo.organizationBySlug(slug)
+	if organization == nil {
+		return nil, ErrOrganizationNotFound
+	}
+
+	c := *organization
+	return &c, nil
+}
+
+func (repo *repository) CreateOrganization(_ context.Context, slug, name string, ownerID int64) (*model.Organization, error) {
+	repo.log.Info("[MEMORY REPO]: Executing Create Organization")
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.user(ownerID); !ok {
+		return nil, ErrUserNotFound
+	}
+	if repo.organizationBySlug(slug) != nil {
+		return nil, ErrOrganizationSlugTaken
+	}
+
+	repo.lastOrganizationID++
+	organization := &model.Organization{
+		ID:        repo.lastOrganizationID,
+		Slug:      slug,
+		Name:      name,
+		CreatedAt: time.Now(),
+	}
+
+	repo.organizations[organization.ID] = organization
+	repo.members[organization.ID] = map[int64][]string{
+		ownerID: {model.RoleAdmin},
+	}
+
+	c := *organization
+	return &c, nil
+}
+
+func (repo *repository) UserOrganizations(_ context.Context, userID int64) ([]*model.Organization, error) {
+	repo.log.Info("[MEMORY REPO]: Executing User Organizations")
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	organizations := []*model.Organization{}
+	for id, members := range repo.members {
+		if _, ok := members[userID]; !ok {
+			continue
+		}
+		c := *repo.organizations[id]
+		organizations = append(organizations, &c)
+	}
+
+	sort.Slice(organizations, func(i, j int) bool {
+		return organizations[i].Slug < organizations[j].Slug
+	})
+
+	return organizations, nil
+}
+
+func (repo *repository) IsMember(_ context.Context, organizationID, userID int64) (bool, error) {
+	repo.log.Info("[MEMORY REPO]: Executing Is Member")
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	_, ok := repo.members[organizationID][userID]
+	return ok, nil
+}
+
+func (repo *repository) AddMember(_ context.Context, organizationID, userID int64) error {
+	repo.log.Info("[MEMORY REPO]: Executing Add Member")
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.organizations[organizationID]; !ok {
+		return ErrOrganizationNotFound
+	}
+	if _, ok := repo.user(userID); !ok {
+		return ErrUserNotFound
+	}
+
+	if _, ok := repo.members[organizationID][userID]; !ok {
+		repo.members[organizationID][userID] = nil
+	}
+
+	return nil
+}
+
+func (repo *repository) RemoveMember(_ context.Context, organizationID, userID int64) error {
+	repo.log.Info("[MEMORY REPO]: Executing Remove Member")
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.members[organizationID][userID]; !ok {
+		return ErrMemberAbsent
+	}
+
+	// The roles of the user in the organization are kept with their membership
+	delete(repo.members[organizationID], userID)
+	for id, group := range repo.groups {
+		if group.OrganizationID != organizationID {
+			continue
+		}
+		delete(repo.groupMembers[id], userID)
+		if group.OwnerID == userID {
+			group.OwnerID = 0
+		}
+	}
+
+	return nil
+}
+
+// organizationBySlug must be called with the lock held
+func (repo *repository) organizationBySlug(slug string) *model.Organization {
+	for _, organization := range repo.organizations {
+		if organization.Slug == slug {
+			return organization
+		}
+	}
+	return nil
+}
+
+// defaultOrganization is the organization the migration creating the organizations table seeds
+func defaultOrganization() *model.Organization {
+	return &model.Organization{
+		ID:        model.DefaultOrganizationID,
+		Slug:      model.DefaultOrganizationSlug,
+		Name:      "Default",
+		CreatedAt: time.Now(),
+	}
+}
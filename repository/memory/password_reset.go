@@ -0,0 +1,70 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+)
+
+var ErrResetTokenNotFound = model.NotFoundError("reset token not found")
+
+func (repo *repository) CreateResetToken(_ context.Context, userID int64, tokenHash string, expiresAt time.Time) error {
+	repo.log.Info("[MEMORY REPO]: Executing Create Reset Token")
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.user(userID); !ok {
+		return ErrUserNotFound
+	}
+
+	for hash, token := range repo.resetTokens {
+		if token.UserID == userID && token.UsedAt == nil {
+			delete(repo.resetTokens, hash)
+		}
+	}
+
+	repo.lastResetTokenID++
+	repo.resetTokens[tokenHash] = &model.PasswordResetToken{
+		ID:        repo.lastResetTokenID,
+		UserID:    userID,
+		TokenHash: tokenHash,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+
+	return nil
+}
+
+func (repo *repository) ResetToken(_ context.Context, tokenHash string, now time.Time) (*model.PasswordResetToken, error) {
+	repo.log.Info("[MEMORY REPO]: Executing Reset Token")
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	token, ok := repo.resetTokens[tokenHash]
+	if !ok || token.UsedAt != nil || !token.ExpiresAt.After(now) {
+		return nil, ErrResetTokenNotFound
+	}
+
+	c := *token
+	return &c, nil
+}
+
+func (repo *repository) ConsumeResetToken(_ context.Context, tokenHash string, now time.Time) (*model.PasswordResetToken, error) {
+	repo.log.Info("[MEMORY REPO]: Executing Consume Reset Token")
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	token, ok := repo.resetTokens[tokenHash]
+	if !ok || token.UsedAt != nil || !token.ExpiresAt.After(now) {
+		return nil, ErrResetTokenNotFound
+	}
+
+	token.UsedAt = &now
+
+	c := *token
+	return &c, nil
+}
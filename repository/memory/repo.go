@@ -0,0 +1,471 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrUserNotFound  = model.NotFoundError("user not found")
+	ErrUsernameTaken = model.AlreadyExistsError("username already exists")
+	ErrEmailTaken    = model.AlreadyExistsError("email already exists")
+)
+
+// repository keeps users in memory, it is safe for concurrent use and is intended
+// for tests and running the service locally without postgres.
+type repository struct {
+	mu     sync.RWMutex
+	users  map[int64]*model.User
+	lastID int64
+	// resetTokens and verificationTokens are keyed by token hash
+	resetTokens             map[string]*model.PasswordResetToken
+	lastResetTokenID        int64
+	verificationTokens      map[string]*model.EmailVerificationToken
+	lastVerificationTokenID int64
+	loginAttempts           map[string]*model.LoginAttempt
+	mfa                     map[int64]*model.MFA
+	recoveryCodes           []*model.MFARecoveryCode
+	lastRecoveryCodeID      int64
+	// mfaChallenges are keyed by token hash
+	mfaChallenges      map[string]*model.MFAChallenge
+	lastMFAChallengeID int64
+	// settings are keyed by organization id and then by key
+	settings map[int64]map[string]string
+	// roles are keyed by name
+	roles              map[string]*model.Role
+	organizations      map[int64]*model.Organization
+	lastOrganizationID int64
+	// members are keyed by organization id and then by user id, each member maps to the roles they hold in the organization
+	members     map[int64]map[int64][]string
+	groups      map[int64]*model.Group
+	lastGroupID int64
+	// groupMembers are keyed by group id and then by user id
+	groupMembers map[int64]map[int64]struct{}
+	// invitations are keyed by token hash
+	invitations      map[string]*model.Invitation
+	lastInvitationID int64
+	log              *logrus.Logger
+}
+
+func NewRepository(log *logrus.Logger) *repository {
+	return &repository{
+		users:              make(map[int64]*model.User),
+		resetTokens:        make(map[string]*model.PasswordResetToken),
+		verificationTokens: make(map[string]*model.EmailVerificationToken),
+		loginAttempts:      make(map[string]*model.LoginAttempt),
+		mfa:                make(map[int64]*model.MFA),
+		mfaChallenges:      make(map[string]*model.MFAChallenge),
+		settings:           make(map[int64]map[string]string),
+		roles:              defaultRoles(),
+		organizations: map[int64]*model.Organization{
+			model.DefaultOrganizationID: defaultOrganization(),
+		},
+		lastOrganizationID: model.DefaultOrganizationID,
+		members: map[int64]map[int64][]string{
+			model.DefaultOrganizationID: {},
+		},
+		groups:       make(map[int64]*model.Group),
+		groupMembers: make(map[int64]map[int64]struct{}),
+		invitations:  make(map[string]*model.Invitation),
+		log:          log,
+	}
+}
+
+func (repo *repository) UserById(ctx context.Context, id int64) (*model.User, error) {
+	repo.log.Info("[MEMORY REPO]: Executing User By ID")
+
+	organizationID := model.OrganizationFromContext(ctx)
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	user, ok := repo.member(organizationID, id)
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+
+	return repo.copyUser(organizationID, user), nil
+}
+
+func (repo *repository) UserByUsername(ctx context.Context, username string) (*model.User, error) {
+	repo.log.Info("[MEMORY REPO]: Executing Getting User by Username")
+
+	organizationID := model.OrganizationFromContext(ctx)
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	user := repo.userByUsername(organizationID, username)
+	if user == nil || user.Deleted() {
+		return nil, ErrUserNotFound
+	}
+
+	return repo.copyUser(organizationID, user), nil
+}
+
+func (repo *repository) UserByEmail(ctx context.Context, email string) (*model.User, error) {
+	repo.log.Info("[MEMORY REPO]: Executing Getting User by Email")
+
+	organizationID := model.OrganizationFromContext(ctx)
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	user := repo.userByEmail(organizationID, email)
+	if user == nil || user.Deleted() {
+		return nil, ErrUserNotFound
+	}
+
+	return repo.copyUser(organizationID, user), nil
+}
+
+func (repo *repository) Create(ctx context.Context, username, email, passwordHash string, status model.AccountStatus) (*model.User, error) {
+	repo.log.Info("[MEMORY REPO]: Executing Register User")
+
+	organizationID := model.OrganizationFromContext(ctx)
+	user := &model.User{
+		Username:       username,
+		Email:          email,
+		Password:       passwordHash,
+		OrganizationID: organizationID,
+		Status:         status,
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.organizations[organizationID]; !ok {
+		return nil, ErrOrganizationNotFound
+	}
+	if repo.userByUsername(organizationID, username) != nil {
+		return nil, ErrUsernameTaken
+	}
+	if email != "" && repo.userByEmail(organizationID, email) != nil {
+		return nil, ErrEmailTaken
+	}
+
+	repo.lastID++
+	now := time.Now()
+	user.ID = repo.lastID
+	user.CreatedAt = now
+	user.UpdatedAt = now
+
+	repo.users[user.ID] = user
+	repo.members[organizationID][user.ID] = nil
+
+	return repo.copyUser(organizationID, user), nil
+}
+
+func (repo *repository) Update(ctx context.Context, user *model.User) (*model.User, error) {
+	repo.log.Info("[MEMORY REPO]: Executing Update User")
+
+	organizationID := model.OrganizationFromContext(ctx)
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	stored, ok := repo.managed(organizationID, user.ID)
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+
+	if existing := repo.userByUsername(organizationID, user.Username); existing != nil && existing.ID != user.ID {
+		return nil, ErrUsernameTaken
+	}
+
+	stored.Username = user.Username
+	repo.grant(organizationID, stored.ID, model.RoleAdmin, user.IsAdmin())
+	stored.UpdatedAt = time.Now()
+
+	return repo.copyUser(organizationID, stored), nil
+}
+
+func (repo *repository) UpdatePassword(ctx context.Context, id int64, passwordHash string, changeRequired bool) error {
+	repo.log.Info("[MEMORY REPO]: Executing Update Password")
+
+	organizationID := model.OrganizationFromContext(ctx)
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	stored, ok := repo.managed(organizationID, id)
+	if !ok {
+		return ErrUserNotFound
+	}
+
+	stored.Password = passwordHash
+	stored.PasswordChangeRequired = changeRequired
+	stored.UpdatedAt = time.Now()
+
+	return nil
+}
+
+func (repo *repository) ReplacePasswordHash(ctx context.Context, id int64, oldHash, newHash string) error {
+	repo.log.Info("[MEMORY REPO]: Executing Replace Password Hash")
+
+	organizationID := model.OrganizationFromContext(ctx)
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	stored, ok := repo.managed(organizationID, id)
+	if !ok || stored.Password != oldHash {
+		return ErrUserNotFound
+	}
+
+	stored.Password = newHash
+
+	return nil
+}
+
+func (repo *repository) UpdateStatus(ctx context.Context, id int64, from, to model.AccountStatus, reason string, at time.Time) (*model.User, error) {
+	repo.log.Info("[MEMORY REPO]: Executing Update Status")
+
+	organizationID := model.OrganizationFromContext(ctx)
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	stored, ok := repo.managed(organizationID, id)
+	if !ok || stored.Status != from {
+		return nil, ErrUserNotFound
+	}
+
+	stored.Status = to
+	stored.StatusReason = reason
+	stored.StatusChangedAt = &at
+	stored.UpdatedAt = at
+
+	return repo.copyUser(organizationID, stored), nil
+}
+
+func (repo *repository) Delete(ctx context.Context, id int64) error {
+	repo.log.Info("[MEMORY REPO]: Executing Delete User")
+
+	organizationID := model.OrganizationFromContext(ctx)
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	stored, ok := repo.managed(organizationID, id)
+	if !ok {
+		return ErrUserNotFound
+	}
+
+	stored.DeletedAt = time.Now()
+	repo.deleteTokens(id)
+
+	return nil
+}
+
+func (repo *repository) Restore(ctx context.Context, id int64) (*model.User, error) {
+	repo.log.Info("[MEMORY REPO]: Executing Restore User")
+
+	organizationID := model.OrganizationFromContext(ctx)
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	stored, ok := repo.users[id]
+	if !ok || !stored.Deleted() || stored.OrganizationID != organizationID {
+		return nil, ErrUserNotFound
+	}
+
+	stored.DeletedAt = time.Time{}
+	stored.UpdatedAt = time.Now()
+
+	return repo.copyUser(organizationID, stored), nil
+}
+
+func (repo *repository) PurgeDeleted(_ context.Context, deletedBefore time.Time) (int, error) {
+	repo.log.Info("[MEMORY REPO]: Executing Purge Deleted Users")
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	purged := 0
+	for id, user := range repo.users {
+		if !user.Deleted() || !user.DeletedAt.Before(deletedBefore) {
+			continue
+		}
+		repo.purge(id)
+		purged++
+	}
+
+	return purged, nil
+}
+
+func (repo *repository) Purge(ctx context.Context, id int64) error {
+	repo.log.Info("[MEMORY REPO]: Executing Purge User")
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	stored, ok := repo.users[id]
+	if !ok || stored.OrganizationID != model.OrganizationFromContext(ctx) {
+		return ErrUserNotFound
+	}
+
+	repo.purge(id)
+
+	return nil
+}
+
+// purge drops the user together with everything that belongs to them, it must be called with the lock held
+func (repo *repository) purge(id int64) {
+	delete(repo.users, id)
+	for _, members := range repo.members {
+		delete(members, id)
+	}
+	for _, members := range repo.groupMembers {
+		delete(members, id)
+	}
+	for _, group := range repo.groups {
+		if group.OwnerID == id {
+			group.OwnerID = 0
+		}
+	}
+	for _, invitation := range repo.invitations {
+		if invitation.InvitedBy == id {
+			invitation.InvitedBy = 0
+		}
+	}
+	repo.deleteTokens(id)
+	repo.deleteMFA(id)
+}
+
+// deleteTokens drops the pending tokens and challenges of the user, it must be called with the lock held
+func (repo *repository) deleteTokens(id int64) {
+	for hash, token := range repo.resetTokens {
+		if token.UserID == id {
+			delete(repo.resetTokens, hash)
+		}
+	}
+	for hash, token := range repo.verificationTokens {
+		if token.UserID == id {
+			delete(repo.verificationTokens, hash)
+		}
+	}
+	for hash, challenge := range repo.mfaChallenges {
+		if challenge.UserID == id {
+			delete(repo.mfaChallenges, hash)
+		}
+	}
+}
+
+func (repo *repository) GetUsers(ctx context.Context, filter model.UserFilter, afterID int64, limit int) ([]*model.User, error) {
+	repo.log.Info("[MEMORY REPO]: Executing Get Users")
+
+	organizationID := model.OrganizationFromContext(ctx)
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	var users []*model.User
+	for id := range repo.members[organizationID] {
+		user, ok := repo.user(id)
+		if !ok || user.ID <= afterID {
+			continue
+		}
+		if user = repo.copyUser(organizationID, user); matches(user, filter) {
+			users = append(users, user)
+		}
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
+
+	if len(users) > limit {
+		users = users[:limit]
+	}
+
+	return users, nil
+}
+
+// user returns the user with id unless they are deleted, whatever organization they belong to. It must be called
+// with the lock held.
+func (repo *repository) user(id int64) (*model.User, bool) {
+	user, ok := repo.users[id]
+	if !ok || user.Deleted() {
+		return nil, false
+	}
+	return user, true
+}
+
+// member returns the user with id if they belong to the organization, it must be called with the lock held
+func (repo *repository) member(organizationID, id int64) (*model.User, bool) {
+	if _, ok := repo.members[organizationID][id]; !ok {
+		return nil, false
+	}
+	return repo.user(id)
+}
+
+// managed returns the user with id if they registered in the organization, it must be called with the lock held
+func (repo *repository) managed(organizationID, id int64) (*model.User, bool) {
+	user, ok := repo.user(id)
+	if !ok || user.OrganizationID != organizationID {
+		return nil, false
+	}
+	return user, true
+}
+
+// userByUsername must be called with the lock held, usernames are compared ignoring case like the postgres repository does.
+// Deleted users are returned too, their username stays taken in their organization until they are purged.
+func (repo *repository) userByUsername(organizationID int64, username string) *model.User {
+	username = strings.ToLower(username)
+	for _, user := range repo.users {
+		if user.OrganizationID == organizationID && strings.ToLower(user.Username) == username {
+			return user
+		}
+	}
+	return nil
+}
+
+// userByEmail must be called with the lock held, deleted users are returned too
+func (repo *repository) userByEmail(organizationID int64, email string) *model.User {
+	for _, user := range repo.users {
+		if user.OrganizationID == organizationID && user.Email == email {
+			return user
+		}
+	}
+	return nil
+}
+
+func matches(user *model.User, filter model.UserFilter) bool {
+	if filter.UsernamePrefix != "" && !strings.HasPrefix(strings.ToLower(user.Username), strings.ToLower(filter.UsernamePrefix)) {
+		return false
+	}
+	if filter.Admin != nil && user.IsAdmin() != *filter.Admin {
+		return false
+	}
+	if !filter.CreatedAfter.IsZero() && user.CreatedAt.Before(filter.CreatedAfter) {
+		return false
+	}
+	if !filter.CreatedBefore.IsZero() && !user.CreatedAt.Before(filter.CreatedBefore) {
+		return false
+	}
+	return true
+}
+
+// copyUser stops callers from mutating the stored user without holding the lock, the copy holds the roles of the
+// user in the organization. It must be called with the lock held.
+func (repo *repository) copyUser(organizationID int64, user *model.User) *model.User {
+	c := *user
+	if user.VerifiedAt != nil {
+		verifiedAt := *user.VerifiedAt
+		c.VerifiedAt = &verifiedAt
+	}
+	c.Roles = append([]string(nil), repo.members[organizationID][user.ID]...)
+	if user.StatusChangedAt != nil {
+		statusChangedAt := *user.StatusChangedAt
+		c.StatusChangedAt = &statusChangedAt
+	}
+	return &c
+}
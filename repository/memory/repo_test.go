@@ -0,0 +1,721 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+var l = logrus.New()
+
+func TestRepository_Create_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name     string
+		username string
+		email    string
+		err      error
+	}{
+		{
+			name:     "user created",
+			username: "David",
+			email:    "david@example.com",
+			err:      nil,
+		},
+		{
+			name:     "username already exists",
+			username: "James",
+			email:    "other@example.com",
+			err:      ErrUsernameTaken,
+		},
+		{
+			name:     "username already exists ignoring case",
+			username: "JAMES",
+			email:    "other@example.com",
+			err:      ErrUsernameTaken,
+		},
+		{
+			name:     "email already exists",
+			username: "David",
+			email:    "james@example.com",
+			err:      ErrEmailTaken,
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			repo := seedRepository(t, "James")
+
+			created, err := repo.Create(context.Background(), tc.username, tc.email, "password-hash", model.StatusActive)
+			assert.Equal(t, tc.err, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, int64(2), created.ID)
+
+			user, err := repo.UserByEmail(context.Background(), tc.email)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(2), user.ID)
+			assert.Equal(t, tc.username, user.Username)
+			assert.Equal(t, "password-hash", user.Password)
+			assert.False(t, user.CreatedAt.IsZero())
+		})
+	}
+}
+
+func TestRepository_UserById_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name     string
+		id       int64
+		username string
+		err      error
+	}{
+		{
+			name:     "user found",
+			id:       2,
+			username: "David",
+			err:      nil,
+		},
+		{
+			name: "user not found",
+			id:   42,
+			err:  ErrUserNotFound,
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			repo := seedRepository(t, "James", "David")
+
+			user, err := repo.UserById(context.Background(), tc.id)
+			if err != nil {
+				assert.Equal(t, tc.err, err)
+				assert.Nil(t, user)
+				return
+			}
+			assert.Equal(t, tc.username, user.Username)
+		})
+	}
+}
+
+func TestRepository_Update_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name     string
+		user     *model.User
+		username string
+		err      error
+	}{
+		{
+			name:     "user updated",
+			user:     &model.User{ID: 1, Username: "Jimmy", Roles: []string{model.RoleAdmin}},
+			username: "Jimmy",
+			err:      nil,
+		},
+		{
+			name: "username already exists",
+			user: &model.User{ID: 1, Username: "David"},
+			err:  ErrUsernameTaken,
+		},
+		{
+			name: "user not found",
+			user: &model.User{ID: 42, Username: "Jimmy"},
+			err:  ErrUserNotFound,
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			repo := seedRepository(t, "James", "David")
+
+			user, err := repo.Update(context.Background(), tc.user)
+			if err != nil {
+				assert.Equal(t, tc.err, err)
+				return
+			}
+			assert.Equal(t, tc.username, user.Username)
+			assert.True(t, user.IsAdmin())
+			assert.True(t, user.UpdatedAt.After(user.CreatedAt))
+		})
+	}
+}
+
+func TestRepository_UpdatePassword(t *testing.T) {
+	repo := seedRepository(t, "James")
+
+	assert.NoError(t, repo.UpdatePassword(context.Background(), 1, "new-password-hash", true))
+	assert.Equal(t, ErrUserNotFound, repo.UpdatePassword(context.Background(), 42, "new-password-hash", false))
+
+	user, err := repo.UserById(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "new-password-hash", user.Password)
+	assert.True(t, user.PasswordChangeRequired)
+}
+
+func TestRepository_ReplacePasswordHash(t *testing.T) {
+	repo := seedRepository(t, "James")
+
+	assert.NoError(t, repo.ReplacePasswordHash(context.Background(), 1, "password-hash", "rehashed"))
+	// the password changed since "password-hash" was read, so it is left alone
+	assert.Equal(t, ErrUserNotFound, repo.ReplacePasswordHash(context.Background(), 1, "password-hash", "stale"))
+
+	user, err := repo.UserById(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "rehashed", user.Password)
+}
+
+func TestRepository_UpdateStatus(t *testing.T) {
+	repo := seedRepository(t, "James")
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	user, err := repo.UpdateStatus(context.Background(), 1, model.StatusActive, model.StatusSuspended, "chargeback", at)
+	assert.NoError(t, err)
+	assert.Equal(t, model.StatusSuspended, user.Status)
+	// the status changed since it was read as active, so it is left alone
+	_, err = repo.UpdateStatus(context.Background(), 1, model.StatusActive, model.StatusDisabled, "spam", at)
+	assert.Equal(t, ErrUserNotFound, err)
+
+	user, err = repo.UserById(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, model.StatusSuspended, user.Status)
+	assert.Equal(t, "chargeback", user.StatusReason)
+	assert.Equal(t, at, *user.StatusChangedAt)
+}
+
+func TestRepository_Roles(t *testing.T) {
+	repo := seedRepository(t, "James")
+
+	roles, err := repo.Roles(context.Background())
+	assert.NoError(t, err)
+	var names []string
+	for _, role := range roles {
+		names = append(names, role.Name)
+	}
+	assert.Equal(t, []string{model.RoleAdmin, model.RoleAuditor, model.RoleReadOnlyAdmin, model.RoleSupport}, names)
+
+	assert.NoError(t, repo.AssignRole(context.Background(), 1, model.RoleSupport))
+	assert.NoError(t, repo.AssignRole(context.Background(), 1, model.RoleSupport))
+	assert.Equal(t, ErrRoleNotFound, repo.AssignRole(context.Background(), 1, "owner"))
+	assert.Equal(t, ErrUserNotFound, repo.AssignRole(context.Background(), 42, model.RoleSupport))
+
+	permissions, err := repo.UserPermissions(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Contains(t, permissions, "users:unlock")
+
+	// Update only grants or revokes the admin role, the other roles stay
+	user, err := repo.Update(context.Background(), &model.User{ID: 1, Username: "James", Roles: []string{model.RoleAdmin}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{model.RoleAdmin, model.RoleSupport}, user.Roles)
+
+	assert.NoError(t, repo.RevokeRole(context.Background(), 1, model.RoleSupport))
+	assert.Equal(t, ErrRoleNotAssigned, repo.RevokeRole(context.Background(), 1, model.RoleSupport))
+
+	user, err = repo.UserById(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{model.RoleAdmin}, user.Roles)
+}
+
+func TestRepository_Organizations(t *testing.T) {
+	repo := seedRepository(t, "James", "David")
+	ctx := context.Background()
+
+	acme, err := repo.CreateOrganization(ctx, "acme", "Acme", 1)
+	assert.NoError(t, err)
+	_, err = repo.CreateOrganization(ctx, "acme", "Acme", 2)
+	assert.Equal(t, ErrOrganizationSlugTaken, err)
+	_, err = repo.CreateOrganization(ctx, "globex", "Globex", 42)
+	assert.Equal(t, ErrUserNotFound, err)
+
+	found, err := repo.OrganizationBySlug(ctx, "acme")
+	assert.NoError(t, err)
+	assert.Equal(t, acme.ID, found.ID)
+	_, err = repo.Organization(ctx, 42)
+	assert.Equal(t, ErrOrganizationNotFound, err)
+
+	organizations, err := repo.UserOrganizations(ctx, 1)
+	assert.NoError(t, err)
+	assert.Len(t, organizations, 2)
+	assert.Equal(t, "acme", organizations[0].Slug)
+
+	member, err := repo.IsMember(ctx, acme.ID, 2)
+	assert.NoError(t, err)
+	assert.False(t, member)
+
+	// The owner administers the new organization, not the default one
+	acmeCtx := model.WithOrganization(ctx, acme.ID)
+	user, err := repo.UserById(acmeCtx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{model.RoleAdmin}, user.Roles)
+	user, err = repo.UserById(ctx, 1)
+	assert.NoError(t, err)
+	assert.Empty(t, user.Roles)
+
+	// Users of the default organization are not members of acme
+	_, err = repo.UserById(acmeCtx, 2)
+	assert.Equal(t, ErrUserNotFound, err)
+	assert.Equal(t, ErrUserNotFound, repo.AssignRole(acmeCtx, 2, model.RoleSupport))
+	users, err := repo.GetUsers(acmeCtx, model.UserFilter{}, 0, 10)
+	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+
+	// Members are only managed by the organization they registered in
+	_, err = repo.Update(acmeCtx, &model.User{ID: 1, Username: "Jim"})
+	assert.Equal(t, ErrUserNotFound, err)
+	assert.Equal(t, ErrUserNotFound, repo.Delete(acmeCtx, 1))
+
+	// Usernames and emails are only unique within an organization
+	created, err := repo.Create(acmeCtx, "david", "james@example.com", "hash", model.StatusActive)
+	assert.NoError(t, err)
+	assert.Equal(t, acme.ID, created.OrganizationID)
+	_, err = repo.Create(acmeCtx, "David", "", "hash", model.StatusActive)
+	assert.Equal(t, ErrUsernameTaken, err)
+
+	byUsername, err := repo.UserByUsername(ctx, "david")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), byUsername.ID)
+	byUsername, err = repo.UserByUsername(acmeCtx, "david")
+	assert.NoError(t, err)
+	assert.Equal(t, created.ID, byUsername.ID)
+
+	// Settings are kept per organization
+	assert.NoError(t, repo.SetSetting(acmeCtx, "key", "acme"))
+	_, err = repo.Setting(ctx, "key")
+	assert.Equal(t, ErrSettingNotFound, err)
+	value, err := repo.Setting(acmeCtx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", value)
+
+	_, err = repo.Create(model.WithOrganization(ctx, 42), "Teddy", "", "hash", model.StatusActive)
+	assert.Equal(t, ErrOrganizationNotFound, err)
+}
+
+func TestRepository_OrganizationMembers(t *testing.T) {
+	repo := seedRepository(t, "James", "David")
+	ctx := context.Background()
+
+	acme, err := repo.CreateOrganization(ctx, "acme", "Acme", 1)
+	assert.NoError(t, err)
+	acmeCtx := model.WithOrganization(ctx, acme.ID)
+
+	assert.Equal(t, ErrOrganizationNotFound, repo.AddMember(ctx, 42, 2))
+	assert.Equal(t, ErrUserNotFound, repo.AddMember(ctx, acme.ID, 42))
+	assert.NoError(t, repo.AddMember(ctx, acme.ID, 2))
+	assert.NoError(t, repo.AddMember(ctx, acme.ID, 2))
+
+	// The new member is read from acme without any role, but still only managed by the default organization
+	user, err := repo.UserById(acmeCtx, 2)
+	assert.NoError(t, err)
+	assert.Empty(t, user.Roles)
+	assert.Equal(t, model.DefaultOrganizationID, user.OrganizationID)
+	assert.Equal(t, ErrUserNotFound, repo.Delete(acmeCtx, 2))
+
+	assert.NoError(t, repo.AssignRole(acmeCtx, 2, model.RoleSupport))
+	engineering, err := repo.CreateGroup(acmeCtx, "Engineering", "", 2)
+	assert.NoError(t, err)
+	assert.NoError(t, repo.AddGroupMember(acmeCtx, engineering.ID, 2))
+
+	// Removing the member takes away their roles and groups in acme and leaves their groups without an owner
+	assert.NoError(t, repo.RemoveMember(ctx, acme.ID, 2))
+	assert.Equal(t, ErrMemberAbsent, repo.RemoveMember(ctx, acme.ID, 2))
+	_, err = repo.UserById(acmeCtx, 2)
+	assert.Equal(t, ErrUserNotFound, err)
+	group, err := repo.Group(acmeCtx, engineering.ID)
+	assert.NoError(t, err)
+	assert.Zero(t, group.OwnerID)
+
+	assert.NoError(t, repo.AddMember(ctx, acme.ID, 2))
+	user, err = repo.UserById(acmeCtx, 2)
+	assert.NoError(t, err)
+	assert.Empty(t, user.Roles)
+	groups, err := repo.UserGroups(acmeCtx, 2, 0, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, groups)
+}
+
+func TestRepository_Groups(t *testing.T) {
+	repo := seedRepository(t, "James", "David", "Michael")
+	ctx := context.Background()
+
+	engineering, err := repo.CreateGroup(ctx, "Engineering", "Builds the product", 1)
+	assert.NoError(t, err)
+	_, err = repo.CreateGroup(ctx, "engineering", "", 2)
+	assert.Equal(t, ErrGroupNameTaken, err)
+	_, err = repo.CreateGroup(ctx, "Design", "", 42)
+	assert.Equal(t, ErrUserNotFound, err)
+	support, err := repo.CreateGroup(ctx, "Support", "", 2)
+	assert.NoError(t, err)
+
+	groups, err := repo.Groups(ctx, engineering.ID, 10)
+	assert.NoError(t, err)
+	assert.Len(t, groups, 1)
+	assert.Equal(t, support.ID, groups[0].ID)
+
+	assert.NoError(t, repo.AddGroupMember(ctx, engineering.ID, 2))
+	assert.NoError(t, repo.AddGroupMember(ctx, engineering.ID, 2))
+	assert.NoError(t, repo.AddGroupMember(ctx, engineering.ID, 3))
+	assert.NoError(t, repo.AddGroupMember(ctx, support.ID, 3))
+	assert.Equal(t, ErrUserNotFound, repo.AddGroupMember(ctx, engineering.ID, 42))
+	assert.Equal(t, ErrGroupNotFound, repo.AddGroupMember(ctx, 42, 2))
+
+	members, err := repo.GroupMembers(ctx, engineering.ID, 0, 1)
+	assert.NoError(t, err)
+	assert.Len(t, members, 1)
+	assert.Equal(t, int64(2), members[0].ID)
+	members, err = repo.GroupMembers(ctx, engineering.ID, 2, 10)
+	assert.NoError(t, err)
+	assert.Len(t, members, 1)
+	assert.Equal(t, int64(3), members[0].ID)
+
+	groups, err = repo.UserGroups(ctx, 3, 0, 10)
+	assert.NoError(t, err)
+	assert.Len(t, groups, 2)
+
+	assert.NoError(t, repo.RemoveGroupMember(ctx, engineering.ID, 2))
+	assert.Equal(t, ErrGroupMemberAbsent, repo.RemoveGroupMember(ctx, engineering.ID, 2))
+
+	// Groups of other organizations are not found
+	acme, err := repo.CreateOrganization(ctx, "acme", "Acme", 1)
+	assert.NoError(t, err)
+	_, err = repo.Group(model.WithOrganization(ctx, acme.ID), engineering.ID)
+	assert.Equal(t, ErrGroupNotFound, err)
+
+	// Deleting a group with members only removes the memberships
+	assert.NoError(t, repo.DeleteGroup(ctx, engineering.ID))
+	assert.Equal(t, ErrGroupNotFound, repo.DeleteGroup(ctx, engineering.ID))
+	_, err = repo.UserById(ctx, 3)
+	assert.NoError(t, err)
+	groups, err = repo.UserGroups(ctx, 3, 0, 10)
+	assert.NoError(t, err)
+	assert.Len(t, groups, 1)
+	assert.Equal(t, support.ID, groups[0].ID)
+}
+
+func TestRepository_Invitations(t *testing.T) {
+	repo := seedRepository(t, "James")
+	ctx := context.Background()
+	now := time.Now()
+
+	acme, err := repo.CreateOrganization(ctx, "acme", "Acme", 1)
+	assert.NoError(t, err)
+	acmeCtx := model.WithOrganization(ctx, acme.ID)
+
+	_, err = repo.CreateInvitation(acmeCtx, &model.Invitation{Email: "new@example.com", InvitedBy: 1, TokenHash: "hash-1", ExpiresAt: now.Add(time.Hour)})
+	assert.NoError(t, err)
+	invitation, err := repo.CreateInvitation(acmeCtx, &model.Invitation{Email: "new@example.com", Role: "support", InvitedBy: 1, TokenHash: "hash-2", ExpiresAt: now.Add(time.Hour)})
+	assert.NoError(t, err)
+	assert.Equal(t, acme.ID, invitation.OrganizationID)
+	_, err = repo.CreateInvitation(acmeCtx, &model.Invitation{Email: "other@example.com", InvitedBy: 42, TokenHash: "hash-3", ExpiresAt: now.Add(time.Hour)})
+	assert.Equal(t, ErrUserNotFound, err)
+
+	// a new invitation replaces the pending one sent to the same email
+	_, err = repo.AcceptInvitation(ctx, "hash-1", now)
+	assert.Equal(t, ErrInvitationNotFound, err)
+	_, err = repo.AcceptInvitation(ctx, "hash-2", now.Add(2*time.Hour))
+	assert.Equal(t, ErrInvitationNotFound, err)
+
+	// invitations are found in any organization
+	accepted, err := repo.AcceptInvitation(ctx, "hash-2", now)
+	assert.NoError(t, err)
+	assert.Equal(t, acme.ID, accepted.OrganizationID)
+	assert.Equal(t, "support", accepted.Role)
+	_, err = repo.AcceptInvitation(ctx, "hash-2", now)
+	assert.Equal(t, ErrInvitationNotFound, err)
+
+	assert.NoError(t, repo.ReopenInvitation(ctx, accepted.ID))
+	_, err = repo.AcceptInvitation(ctx, "hash-2", now)
+	assert.NoError(t, err)
+	assert.Equal(t, ErrInvitationNotFound, repo.ReopenInvitation(ctx, 42))
+}
+
+func TestRepository_ResetTokens(t *testing.T) {
+	repo := seedRepository(t, "James")
+	now := time.Now()
+
+	assert.NoError(t, repo.CreateResetToken(context.Background(), 1, "hash-1", now.Add(time.Hour)))
+	assert.NoError(t, repo.CreateResetToken(context.Background(), 1, "hash-2", now.Add(time.Hour)))
+	assert.Equal(t, ErrUserNotFound, repo.CreateResetToken(context.Background(), 42, "hash-3", now.Add(time.Hour)))
+
+	// a new token invalidates the ones sent before it
+	_, err := repo.ConsumeResetToken(context.Background(), "hash-1", now)
+	assert.Equal(t, ErrResetTokenNotFound, err)
+
+	_, err = repo.ConsumeResetToken(context.Background(), "hash-2", now.Add(2*time.Hour))
+	assert.Equal(t, ErrResetTokenNotFound, err)
+
+	token, err := repo.ConsumeResetToken(context.Background(), "hash-2", now)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), token.UserID)
+
+	_, err = repo.ConsumeResetToken(context.Background(), "hash-2", now)
+	assert.Equal(t, ErrResetTokenNotFound, err)
+}
+
+func TestRepository_VerifyEmail(t *testing.T) {
+	repo := seedRepository(t, "James", "David")
+	now := time.Now()
+
+	assert.NoError(t, repo.CreateVerificationToken(context.Background(), 1, "james@example.com", "hash-1", now.Add(time.Hour)))
+	assert.NoError(t, repo.CreateVerificationToken(context.Background(), 2, "old@example.com", "hash-2", now.Add(time.Hour)))
+
+	// a token only verifies the email it was sent to
+	assert.Equal(t, ErrVerificationTokenNotFound, repo.VerifyEmail(context.Background(), "hash-2", now))
+	assert.Equal(t, ErrVerificationTokenNotFound, repo.VerifyEmail(context.Background(), "hash-1", now.Add(2*time.Hour)))
+
+	assert.NoError(t, repo.VerifyEmail(context.Background(), "hash-1", now))
+	assert.Equal(t, ErrVerificationTokenNotFound, repo.VerifyEmail(context.Background(), "hash-1", now))
+
+	user, err := repo.UserById(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.True(t, user.EmailVerified())
+}
+
+func TestRepository_LoginAttempts(t *testing.T) {
+	repo := NewRepository(l)
+	now := time.Now()
+
+	_, err := repo.LoginAttempt(context.Background(), "account:1")
+	assert.Equal(t, ErrLoginAttemptNotFound, err)
+
+	attempt, err := repo.RecordLoginFailure(context.Background(), "account:1", now, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, attempt.Failures)
+
+	attempt, err = repo.RecordLoginFailure(context.Background(), "account:1", now.Add(30*time.Second), time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, attempt.Failures)
+
+	// failures older than the window are not counted
+	attempt, err = repo.RecordLoginFailure(context.Background(), "account:1", now.Add(2*time.Minute), time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, attempt.Failures)
+
+	assert.NoError(t, repo.LockLogin(context.Background(), "account:1", now.Add(time.Hour)))
+	attempt, err = repo.LoginAttempt(context.Background(), "account:1")
+	assert.NoError(t, err)
+	assert.True(t, attempt.Locked(now))
+	assert.False(t, attempt.Locked(now.Add(2*time.Hour)))
+
+	assert.NoError(t, repo.ClearLoginAttempts(context.Background(), "account:1"))
+	_, err = repo.LoginAttempt(context.Background(), "account:1")
+	assert.Equal(t, ErrLoginAttemptNotFound, err)
+}
+
+func TestRepository_MFA(t *testing.T) {
+	repo := seedRepository(t, "James")
+	now := time.Now()
+
+	assert.NoError(t, repo.SaveMFA(context.Background(), 1, "secret"))
+	assert.NoError(t, repo.ConfirmMFA(context.Background(), 1, 10, []string{"code-hash"}, now))
+
+	mfa, err := repo.MFA(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.True(t, mfa.Confirmed())
+
+	// a step can only be used once and never before the last one used
+	assert.Equal(t, ErrMFANotFound, repo.UseMFAStep(context.Background(), 1, 10))
+	assert.NoError(t, repo.UseMFAStep(context.Background(), 1, 11))
+	assert.Equal(t, ErrMFANotFound, repo.UseMFAStep(context.Background(), 1, 11))
+
+	assert.NoError(t, repo.ConsumeRecoveryCode(context.Background(), 1, "code-hash", now))
+	assert.Equal(t, ErrRecoveryCodeNotFound, repo.ConsumeRecoveryCode(context.Background(), 1, "code-hash", now))
+
+	assert.NoError(t, repo.CreateMFAChallenge(context.Background(), 1, "token-hash", now.Add(time.Minute)))
+	_, err = repo.MFAChallenge(context.Background(), "token-hash", now.Add(2*time.Minute))
+	assert.Equal(t, ErrMFAChallengeNotFound, err)
+	assert.NoError(t, repo.ConsumeMFAChallenge(context.Background(), "token-hash", now))
+	assert.Equal(t, ErrMFAChallengeNotFound, repo.ConsumeMFAChallenge(context.Background(), "token-hash", now))
+
+	// the authenticator is kept while the user can be restored and purged with them
+	assert.NoError(t, repo.Delete(context.Background(), 1))
+	_, err = repo.MFA(context.Background(), 1)
+	assert.NoError(t, err)
+
+	_, err = repo.PurgeDeleted(context.Background(), time.Now().Add(time.Second))
+	assert.NoError(t, err)
+	_, err = repo.MFA(context.Background(), 1)
+	assert.Equal(t, ErrMFANotFound, err)
+}
+
+func TestRepository_Delete(t *testing.T) {
+	repo := seedRepository(t, "James")
+
+	assert.NoError(t, repo.Delete(context.Background(), 1))
+	assert.Equal(t, ErrUserNotFound, repo.Delete(context.Background(), 1))
+
+	_, err := repo.UserById(context.Background(), 1)
+	assert.Equal(t, ErrUserNotFound, err)
+	_, err = repo.UserByUsername(context.Background(), "James")
+	assert.Equal(t, ErrUserNotFound, err)
+	_, err = repo.UserByEmail(context.Background(), "james@example.com")
+	assert.Equal(t, ErrUserNotFound, err)
+	users, err := repo.GetUsers(context.Background(), model.UserFilter{}, 0, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, users)
+
+	// the username stays taken until the user is purged
+	_, err = repo.Create(context.Background(), "james", "other@example.com", "password-hash", model.StatusActive)
+	assert.Equal(t, ErrUsernameTaken, err)
+}
+
+func TestRepository_Restore(t *testing.T) {
+	repo := seedRepository(t, "James")
+
+	_, err := repo.Restore(context.Background(), 1)
+	assert.Equal(t, ErrUserNotFound, err)
+
+	assert.NoError(t, repo.Delete(context.Background(), 1))
+	restored, err := repo.Restore(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.False(t, restored.Deleted())
+
+	user, err := repo.UserByUsername(context.Background(), "James")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), user.ID)
+}
+
+func TestRepository_PurgeDeleted(t *testing.T) {
+	repo := seedRepository(t, "James", "David")
+
+	group, err := repo.CreateGroup(context.Background(), "Engineering", "", 1)
+	assert.NoError(t, err)
+	assert.NoError(t, repo.Delete(context.Background(), 1))
+
+	purged, err := repo.PurgeDeleted(context.Background(), time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, purged)
+
+	purged, err = repo.PurgeDeleted(context.Background(), time.Now().Add(time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, purged)
+
+	_, err = repo.Restore(context.Background(), 1)
+	assert.Equal(t, ErrUserNotFound, err)
+	_, err = repo.UserById(context.Background(), 2)
+	assert.NoError(t, err)
+
+	// groups outlive their purged owner
+	group, err = repo.Group(context.Background(), group.ID)
+	assert.NoError(t, err)
+	assert.Zero(t, group.OwnerID)
+
+	// the username is free again once purged
+	_, err = repo.Create(context.Background(), "James", "james@example.com", "password-hash", model.StatusActive)
+	assert.NoError(t, err)
+}
+
+func TestRepository_Purge(t *testing.T) {
+	repo := seedRepository(t, "James", "David")
+
+	assert.NoError(t, repo.Purge(context.Background(), 1))
+	assert.Equal(t, ErrUserNotFound, repo.Purge(context.Background(), 1))
+
+	_, err := repo.UserById(context.Background(), 1)
+	assert.Equal(t, ErrUserNotFound, err)
+	_, err = repo.UserById(context.Background(), 2)
+	assert.NoError(t, err)
+
+	// the username and email are free right away
+	_, err = repo.Create(context.Background(), "James", "james@example.com", "password-hash", model.StatusActive)
+	assert.NoError(t, err)
+}
+
+func TestRepository_GetUsers_Test_Cases(t *testing.T) {
+	admin := true
+
+	tt := []struct {
+		name    string
+		filter  model.UserFilter
+		afterID int64
+		limit   int
+		ids     []int64
+	}{
+		{
+			name:  "first page",
+			limit: 2,
+			ids:   []int64{1, 2},
+		},
+		{
+			name:    "next page",
+			afterID: 2,
+			limit:   2,
+			ids:     []int64{3, 4},
+		},
+		{
+			name:   "username prefix",
+			filter: model.UserFilter{UsernamePrefix: "Ja"},
+			limit:  10,
+			ids:    []int64{1, 3},
+		},
+		{
+			name:   "username prefix ignores case",
+			filter: model.UserFilter{UsernamePrefix: "jA"},
+			limit:  10,
+			ids:    []int64{1, 3},
+		},
+		{
+			name:   "admins only",
+			filter: model.UserFilter{Admin: &admin},
+			limit:  10,
+			ids:    []int64{4},
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			repo := seedRepository(t, "James", "David", "Jack", "Mary")
+			_, err := repo.Update(context.Background(), &model.User{ID: 4, Username: "Mary", Roles: []string{model.RoleAdmin}})
+			assert.NoError(t, err)
+
+			users, err := repo.GetUsers(context.Background(), tc.filter, tc.afterID, tc.limit)
+			assert.NoError(t, err)
+
+			var ids []int64
+			for _, user := range users {
+				ids = append(ids, user.ID)
+			}
+			assert.Equal(t, tc.ids, ids)
+		})
+	}
+}
+
+func TestRepository_Create_Concurrent(t *testing.T) {
+	repo := NewRepository(l)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := repo.Create(context.Background(), fmt.Sprintf("user%d", i), fmt.Sprintf("user%d@example.com", i), "password", model.StatusActive)
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	users, err := repo.GetUsers(context.Background(), model.UserFilter{}, 0, 100)
+	assert.NoError(t, err)
+	assert.Len(t, users, 10)
+	for i, user := range users {
+		assert.Equal(t, int64(i+1), user.ID)
+	}
+}
+
+func seedRepository(t *testing.T, usernames ...string) *repository {
+	repo := NewRepository(l)
+	for _, username := range usernames {
+		email := strings.ToLower(username) + "@example.com"
+		if _, err := repo.Create(context.Background(), username, email, "password-hash", model.StatusActive); err != nil {
+			t.Fatalf("could not seed repository: %v", err)
+		}
+	}
+	return repo
+}
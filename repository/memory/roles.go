@@ -0,0 +1,115 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+)
+
+var (
+	ErrRoleNotFound    = model.NotFoundError("role not found")
+	ErrRoleNotAssigned = model.NotFoundError("user does not have the role")
+)
+
+func (repo *repository) Roles(_ context.Context) ([]*model.Role, error) {
+	repo.log.Info("[MEMORY REPO]: Executing Roles")
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	roles := make([]*model.Role, 0, len(repo.roles))
+	for _, role := range repo.roles {
+		c := *role
+		c.Permissions = append([]string(nil), role.Permissions...)
+		roles = append(roles, &c)
+	}
+
+	sort.Slice(roles, func(i, j int) bool {
+		return roles[i].Name < roles[j].Name
+	})
+
+	return roles, nil
+}
+
+func (repo *repository) AssignRole(ctx context.Context, userID int64, role string) error {
+	repo.log.Info("[MEMORY REPO]: Executing Assign Role")
+
+	organizationID := model.OrganizationFromContext(ctx)
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.member(organizationID, userID); !ok {
+		return ErrUserNotFound
+	}
+	if _, ok := repo.roles[role]; !ok {
+		return ErrRoleNotFound
+	}
+
+	repo.grant(organizationID, userID, role, true)
+
+	return nil
+}
+
+func (repo *repository) RevokeRole(ctx context.Context, userID int64, role string) error {
+	repo.log.Info("[MEMORY REPO]: Executing Revoke Role")
+
+	organizationID := model.OrganizationFromContext(ctx)
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	stored, ok := repo.member(organizationID, userID)
+	if !ok {
+		return ErrUserNotFound
+	}
+	if !repo.copyUser(organizationID, stored).HasRole(role) {
+		return ErrRoleNotAssigned
+	}
+
+	repo.grant(organizationID, userID, role, false)
+
+	return nil
+}
+
+func (repo *repository) UserPermissions(ctx context.Context, userID int64) ([]string, error) {
+	repo.log.Info("[MEMORY REPO]: Executing User Permissions")
+
+	organizationID := model.OrganizationFromContext(ctx)
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	if _, ok := repo.member(organizationID, userID); !ok {
+		return nil, ErrUserNotFound
+	}
+
+	var permissions []string
+	for _, name := range repo.members[organizationID][userID] {
+		if role, ok := repo.roles[name]; ok {
+			permissions = append(permissions, role.Permissions...)
+		}
+	}
+
+	return permissions, nil
+}
+
+// grant grants role to the member of the organization, or revokes it when granted is false. It must be called with
+// the lock held.
+func (repo *repository) grant(organizationID, userID int64, role string, granted bool) {
+	member := &model.User{Roles: repo.members[organizationID][userID]}
+	member.SetRole(role, granted)
+	sort.Strings(member.Roles)
+	repo.members[organizationID][userID] = member.Roles
+}
+
+// defaultRoles numbers the built in roles like the migration seeding them does
+func defaultRoles() map[string]*model.Role {
+	roles := make(map[string]*model.Role)
+	for i, role := range model.DefaultRoles() {
+		role.ID = int64(i + 1)
+		roles[role.Name] = role
+	}
+	return roles
+}
@@ -0,0 +1,41 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+)
+
+var ErrSettingNotFound = model.NotFoundError("setting not found")
+
+func (repo *repository) Setting(ctx context.Context, key string) (string, error) {
+	repo.log.Info("[MEMORY REPO]: Executing Setting")
+
+	organizationID := model.OrganizationFromContext(ctx)
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	value, ok := repo.settings[organizationID][key]
+	if !ok {
+		return "", ErrSettingNotFound
+	}
+
+	return value, nil
+}
+
+func (repo *repository) SetSetting(ctx context.Context, key, value string) error {
+	repo.log.Info("[MEMORY REPO]: Executing Set Setting")
+
+	organizationID := model.OrganizationFromContext(ctx)
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if repo.settings[organizationID] == nil {
+		repo.settings[organizationID] = make(map[string]string)
+	}
+	repo.settings[organizationID][key] = value
+
+	return nil
+}
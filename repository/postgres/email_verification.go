@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/go-pg/pg/v10"
+)
+
+var errVerificationTokenNotFound = model.NotFoundError("verification token not found")
+
+func (repo *repository) CreateVerificationToken(ctx context.Context, userID int64, email, tokenHash string, expiresAt time.Time) error {
+	repo.log.Info("[POSTGRES REPO]: Executing Create Verification Token")
+
+	return repo.db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		_, err := tx.Model((*model.EmailVerificationToken)(nil)).
+			Where("user_id = ?", userID).
+			Where("used_at IS NULL").
+			Delete()
+		if err != nil {
+			repo.log.Errorf("error deleting previous verification tokens: %v", err)
+			return err
+		}
+
+		_, err = tx.Model(&model.EmailVerificationToken{
+			UserID:    userID,
+			Email:     email,
+			TokenHash: tokenHash,
+			ExpiresAt: expiresAt,
+		}).Insert()
+		if err != nil {
+			repo.log.Errorf("error inserting verification token: %v", err)
+			return translateError(err)
+		}
+
+		return nil
+	})
+}
+
+func (repo *repository) VerifyEmail(ctx context.Context, tokenHash string, now time.Time) error {
+	repo.log.Info("[POSTGRES REPO]: Executing Verify Email")
+
+	return repo.db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		token := &model.EmailVerificationToken{}
+
+		res, err := tx.Model(token).
+			Set("used_at = ?", now).
+			Where("token_hash = ?", tokenHash).
+			Where("used_at IS NULL").
+			Where("expires_at > ?", now).
+			Returning("*").
+			Update()
+		if err != nil {
+			repo.log.Errorf("error consuming verification token: %v", err)
+			return err
+		}
+		if res.RowsAffected() == 0 {
+			return errVerificationTokenNotFound
+		}
+
+		// The token only verifies the email it was sent to
+		res, err = tx.Model((*model.User)(nil)).
+			Set("verified_at = ?", now).
+			Set("updated_at = ?", now).
+			Where("id = ?", token.UserID).
+			Where("email = ?", token.Email).
+			Update()
+		if err != nil {
+			repo.log.Errorf("error verifying email: %v", err)
+			return err
+		}
+		if res.RowsAffected() == 0 {
+			return errVerificationTokenNotFound
+		}
+
+		return nil
+	})
+}
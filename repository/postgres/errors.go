@@ -0,0 +1,35 @@
+package postgres
+
+import (
+	"errors"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/go-pg/pg/v10"
+)
+
+// uniqueViolation is the postgres error code raised when a unique constraint fails
+const uniqueViolation = "23505"
+
+// emailConstraint is the unique index on the emails of the users of an organization
+const emailConstraint = "users_organization_email_key"
+
+// translateError converts pg errors into domain errors, errors it does not recognise are returned unchanged
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, pg.ErrNoRows) {
+		return &model.Error{Code: model.CodeNotFound, Message: "user not found", Err: err}
+	}
+
+	var pgErr pg.Error
+	if errors.As(err, &pgErr) && pgErr.Field('C') == uniqueViolation {
+		if pgErr.Field('n') == emailConstraint {
+			return &model.Error{Code: model.CodeAlreadyExists, Message: "email already exists", Err: err}
+		}
+		return &model.Error{Code: model.CodeAlreadyExists, Message: "username already exists", Err: err}
+	}
+
+	return err
+}
@@ -0,0 +1,239 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/go-pg/pg/v10"
+)
+
+// groupNameConstraint is the unique index on the lower case names of the groups of an organization
+const groupNameConstraint = "groups_organization_name_lower_key"
+
+var (
+	errGroupNotFound     = model.NotFoundError("group not found")
+	errGroupNameTaken    = model.AlreadyExistsError("group already exists")
+	errGroupMemberAbsent = model.NotFoundError("user is not a member of the group")
+)
+
+func (repo *repository) Group(ctx context.Context, id int64) (*model.Group, error) {
+	repo.log.Info("[POSTGRES REPO]: Executing Group")
+
+	group := &model.Group{}
+
+	err := repo.db.ModelContext(ctx, group).
+		Where("id = ?", id).
+		Where("organization_id = ?", model.OrganizationFromContext(ctx)).
+		Select()
+	if errors.Is(err, pg.ErrNoRows) {
+		return nil, errGroupNotFound
+	}
+	if err != nil {
+		repo.log.Errorf("error getting group: %v", err)
+		return nil, err
+	}
+
+	return group, nil
+}
+
+func (repo *repository) CreateGroup(ctx context.Context, name, description string, ownerID int64) (*model.Group, error) {
+	repo.log.Info("[POSTGRES REPO]: Executing Create Group")
+
+	organizationID := model.OrganizationFromContext(ctx)
+	now := time.Now()
+	group := &model.Group{
+		OrganizationID: organizationID,
+		Name:           name,
+		Description:    description,
+		OwnerID:        ownerID,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	err := repo.db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		exists, err := tx.Model((*model.User)(nil)).Where("id = ?", ownerID).Where(memberOf, organizationID).Exists()
+		if err != nil {
+			repo.log.Errorf("error checking owner of group: %v", err)
+			return err
+		}
+		if !exists {
+			return translateError(pg.ErrNoRows)
+		}
+
+		_, err = tx.Model(group).Returning("*").Insert()
+		var pgErr pg.Error
+		if errors.As(err, &pgErr) && pgErr.Field('C') == uniqueViolation && pgErr.Field('n') == groupNameConstraint {
+			return errGroupNameTaken
+		}
+		if err != nil {
+			repo.log.Errorf("error creating group: %v", err)
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return group, nil
+}
+
+func (repo *repository) DeleteGroup(ctx context.Context, id int64) error {
+	repo.log.Info("[POSTGRES REPO]: Executing Delete Group")
+
+	// The memberships of the group are deleted with it by the foreign key of group_members
+	res, err := repo.db.ModelContext(ctx, (*model.Group)(nil)).
+		Where("id = ?", id).
+		Where("organization_id = ?", model.OrganizationFromContext(ctx)).
+		Delete()
+	if err != nil {
+		repo.log.Errorf("error deleting group: %v", err)
+		return err
+	}
+
+	if res.RowsAffected() == 0 {
+		return errGroupNotFound
+	}
+
+	return nil
+}
+
+func (repo *repository) Groups(ctx context.Context, afterID int64, limit int) ([]*model.Group, error) {
+	repo.log.Info("[POSTGRES REPO]: Executing Groups")
+
+	groups := []*model.Group{}
+
+	err := repo.db.ModelContext(ctx, &groups).
+		Where("organization_id = ?", model.OrganizationFromContext(ctx)).
+		Where("id > ?", afterID).
+		Order("id ASC").
+		Limit(limit).
+		Select()
+	if err != nil {
+		repo.log.Errorf("error selecting groups: %v", err)
+		return nil, err
+	}
+
+	return groups, nil
+}
+
+func (repo *repository) AddGroupMember(ctx context.Context, groupID, userID int64) error {
+	repo.log.Info("[POSTGRES REPO]: Executing Add Group Member")
+
+	organizationID := model.OrganizationFromContext(ctx)
+
+	return repo.db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		exists, err := tx.Model((*model.Group)(nil)).
+			Where("id = ?", groupID).
+			Where("organization_id = ?", organizationID).
+			For("SHARE").
+			Exists()
+		if err != nil {
+			repo.log.Errorf("error checking group: %v", err)
+			return err
+		}
+		if !exists {
+			return errGroupNotFound
+		}
+
+		exists, err = tx.Model((*model.User)(nil)).Where("id = ?", userID).Where(memberOf, organizationID).Exists()
+		if err != nil {
+			repo.log.Errorf("error checking user of group: %v", err)
+			return err
+		}
+		if !exists {
+			return translateError(pg.ErrNoRows)
+		}
+
+		_, err = tx.Model(&model.GroupMember{GroupID: groupID, UserID: userID, CreatedAt: time.Now()}).
+			OnConflict("DO NOTHING").
+			Insert()
+		if err != nil {
+			repo.log.Errorf("error adding group member: %v", err)
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (repo *repository) RemoveGroupMember(ctx context.Context, groupID, userID int64) error {
+	repo.log.Info("[POSTGRES REPO]: Executing Remove Group Member")
+
+	if _, err := repo.Group(ctx, groupID); err != nil {
+		return err
+	}
+
+	res, err := repo.db.ModelContext(ctx, (*model.GroupMember)(nil)).
+		Where("group_id = ?", groupID).
+		Where("user_id = ?", userID).
+		Delete()
+	if err != nil {
+		repo.log.Errorf("error removing group member: %v", err)
+		return err
+	}
+
+	if res.RowsAffected() == 0 {
+		return errGroupMemberAbsent
+	}
+
+	return nil
+}
+
+func (repo *repository) GroupMembers(ctx context.Context, groupID, afterID int64, limit int) ([]*model.User, error) {
+	repo.log.Info("[POSTGRES REPO]: Executing Group Members")
+
+	if _, err := repo.Group(ctx, groupID); err != nil {
+		return nil, err
+	}
+
+	organizationID := model.OrganizationFromContext(ctx)
+	users := []*model.User{}
+
+	err := repo.db.ModelContext(ctx, &users).
+		Where(`EXISTS (
+			SELECT 1 FROM group_members
+			WHERE group_members.user_id = "user".id AND group_members.group_id = ?
+		)`, groupID).
+		Where(memberOf, organizationID).
+		Where("id > ?", afterID).
+		Order("id ASC").
+		Limit(limit).
+		Select()
+	if err != nil {
+		repo.log.Errorf("error selecting group members: %v", err)
+		return nil, err
+	}
+
+	if err := repo.loadRoles(repo.db, organizationID, users...); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (repo *repository) UserGroups(ctx context.Context, userID, afterID int64, limit int) ([]*model.Group, error) {
+	repo.log.Info("[POSTGRES REPO]: Executing User Groups")
+
+	groups := []*model.Group{}
+
+	err := repo.db.ModelContext(ctx, &groups).
+		Where(`EXISTS (
+			SELECT 1 FROM group_members
+			WHERE group_members.group_id = "group".id AND group_members.user_id = ?
+		)`, userID).
+		Where("organization_id = ?", model.OrganizationFromContext(ctx)).
+		Where("id > ?", afterID).
+		Order("id ASC").
+		Limit(limit).
+		Select()
+	if err != nil {
+		repo.log.Errorf("error selecting groups of user: %v", err)
+		return nil, err
+	}
+
+	return groups, nil
+}
@@ -0,0 +1,103 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/go-pg/pg/v10"
+)
+
+var errInvitationNotFound = model.NotFoundError("invitation not found")
+
+func (repo *repository) CreateInvitation(ctx context.Context, invitation *model.Invitation) (*model.Invitation, error) {
+	repo.log.Info("[POSTGRES REPO]: Executing Create Invitation")
+
+	organizationID := model.OrganizationFromContext(ctx)
+	stored := &model.Invitation{
+		OrganizationID: organizationID,
+		Email:          invitation.Email,
+		Role:           invitation.Role,
+		InvitedBy:      invitation.InvitedBy,
+		TokenHash:      invitation.TokenHash,
+		ExpiresAt:      invitation.ExpiresAt,
+	}
+
+	err := repo.db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		exists, err := tx.Model((*model.User)(nil)).Where("id = ?", invitation.InvitedBy).Where(memberOf, organizationID).Exists()
+		if err != nil {
+			repo.log.Errorf("error checking inviter: %v", err)
+			return err
+		}
+		if !exists {
+			return translateError(pg.ErrNoRows)
+		}
+
+		_, err = tx.Model((*model.Invitation)(nil)).
+			Where("organization_id = ?", organizationID).
+			Where("email = ?", invitation.Email).
+			Where("accepted_at IS NULL").
+			Delete()
+		if err != nil {
+			repo.log.Errorf("error deleting previous invitations: %v", err)
+			return err
+		}
+
+		_, err = tx.Model(stored).Returning("*").Insert()
+		if err != nil {
+			repo.log.Errorf("error inserting invitation: %v", err)
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stored, nil
+}
+
+func (repo *repository) AcceptInvitation(ctx context.Context, tokenHash string, now time.Time) (*model.Invitation, error) {
+	repo.log.Info("[POSTGRES REPO]: Executing Accept Invitation")
+
+	invitation := &model.Invitation{}
+
+	// A single conditional update so an invitation can never be accepted twice, even by concurrent requests
+	res, err := repo.db.ModelContext(ctx, invitation).
+		Set("accepted_at = ?", now).
+		Where("token_hash = ?", tokenHash).
+		Where("accepted_at IS NULL").
+		Where("expires_at > ?", now).
+		Returning("*").
+		Update()
+	if err != nil {
+		repo.log.Errorf("error accepting invitation: %v", err)
+		return nil, err
+	}
+
+	if res.RowsAffected() == 0 {
+		return nil, errInvitationNotFound
+	}
+
+	return invitation, nil
+}
+
+func (repo *repository) ReopenInvitation(ctx context.Context, id int64) error {
+	repo.log.Info("[POSTGRES REPO]: Executing Reopen Invitation")
+
+	res, err := repo.db.ModelContext(ctx, (*model.Invitation)(nil)).
+		Set("accepted_at = NULL").
+		Where("id = ?", id).
+		Update()
+	if err != nil {
+		repo.log.Errorf("error reopening invitation: %v", err)
+		return err
+	}
+
+	if res.RowsAffected() == 0 {
+		return errInvitationNotFound
+	}
+
+	return nil
+}
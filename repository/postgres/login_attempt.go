@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/go-pg/pg/v10"
+)
+
+var errLoginAttemptNotFound = model.NotFoundError("login attempt not found")
+
+func (repo *repository) LoginAttempt(ctx context.Context, key string) (*model.LoginAttempt, error) {
+	repo.log.Info("[POSTGRES REPO]: Executing Login Attempt")
+
+	attempt := &model.LoginAttempt{}
+
+	err := repo.db.ModelContext(ctx, attempt).Where("key = ?", key).Select()
+	if errors.Is(err, pg.ErrNoRows) {
+		return nil, errLoginAttemptNotFound
+	}
+	if err != nil {
+		repo.log.Errorf("error getting login attempt: %v", err)
+		return nil, err
+	}
+
+	return attempt, nil
+}
+
+func (repo *repository) RecordLoginFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*model.LoginAttempt, error) {
+	repo.log.Info("[POSTGRES REPO]: Executing Record Login Failure")
+
+	attempt := &model.LoginAttempt{
+		Key:           key,
+		Failures:      1,
+		LastFailureAt: now,
+	}
+
+	// A single upsert so concurrent failures are all counted
+	_, err := repo.db.ModelContext(ctx, attempt).
+		OnConflict("(key) DO UPDATE").
+		Set("failures = CASE WHEN login_attempt.last_failure_at > ? THEN login_attempt.failures + 1 ELSE 1 END", now.Add(-window)).
+		Set("last_failure_at = EXCLUDED.last_failure_at").
+		Returning("*").
+		Insert()
+	if err != nil {
+		repo.log.Errorf("error recording login failure: %v", err)
+		return nil, err
+	}
+
+	return attempt, nil
+}
+
+func (repo *repository) LockLogin(ctx context.Context, key string, until time.Time) error {
+	repo.log.Info("[POSTGRES REPO]: Executing Lock Login")
+
+	_, err := repo.db.ModelContext(ctx, &model.LoginAttempt{Key: key, LastFailureAt: time.Now(), LockedUntil: &until}).
+		OnConflict("(key) DO UPDATE").
+		Set("locked_until = EXCLUDED.locked_until").
+		Insert()
+	if err != nil {
+		repo.log.Errorf("error locking login: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func (repo *repository) ClearLoginAttempts(ctx context.Context, key string) error {
+	repo.log.Info("[POSTGRES REPO]: Executing Clear Login Attempts")
+
+	_, err := repo.db.ModelContext(ctx, (*model.LoginAttempt)(nil)).Where("key = ?", key).Delete()
+	if err != nil {
+		repo.log.Errorf("error clearing login attempts: %v", err)
+		return err
+	}
+
+	return nil
+}
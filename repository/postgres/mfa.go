@@ -0,0 +1,215 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/go-pg/pg/v10"
+)
+
+var (
+	errMFANotFound          = model.NotFoundError("mfa not found")
+	errRecoveryCodeNotFound = model.NotFoundError("recovery code not found")
+	errMFAChallengeNotFound = model.NotFoundError("mfa challenge not found")
+)
+
+func (repo *repository) MFA(ctx context.Context, userID int64) (*model.MFA, error) {
+	repo.log.Info("[POSTGRES REPO]: Executing MFA")
+
+	mfa := &model.MFA{}
+
+	err := repo.db.ModelContext(ctx, mfa).Where("user_id = ?", userID).Select()
+	if errors.Is(err, pg.ErrNoRows) {
+		return nil, errMFANotFound
+	}
+	if err != nil {
+		repo.log.Errorf("error getting mfa: %v", err)
+		return nil, err
+	}
+
+	return mfa, nil
+}
+
+func (repo *repository) SaveMFA(ctx context.Context, userID int64, secret string) error {
+	repo.log.Info("[POSTGRES REPO]: Executing Save MFA")
+
+	_, err := repo.db.ModelContext(ctx, &model.MFA{UserID: userID, Secret: secret}).
+		OnConflict("(user_id) DO UPDATE").
+		Set("secret = EXCLUDED.secret").
+		Set("last_used_step = 0").
+		Set("created_at = now()").
+		Where("mfa.confirmed_at IS NULL").
+		Insert()
+	if err != nil {
+		repo.log.Errorf("error saving mfa: %v", err)
+		return translateError(err)
+	}
+
+	return nil
+}
+
+func (repo *repository) ConfirmMFA(ctx context.Context, userID int64, step int64, recoveryCodeHashes []string, now time.Time) error {
+	repo.log.Info("[POSTGRES REPO]: Executing Confirm MFA")
+
+	return repo.db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		res, err := tx.Model((*model.MFA)(nil)).
+			Set("confirmed_at = ?", now).
+			Set("last_used_step = ?", step).
+			Where("user_id = ?", userID).
+			Update()
+		if err != nil {
+			repo.log.Errorf("error confirming mfa: %v", err)
+			return err
+		}
+		if res.RowsAffected() == 0 {
+			return errMFANotFound
+		}
+
+		_, err = tx.Model((*model.MFARecoveryCode)(nil)).Where("user_id = ?", userID).Delete()
+		if err != nil {
+			repo.log.Errorf("error deleting previous recovery codes: %v", err)
+			return err
+		}
+
+		codes := make([]*model.MFARecoveryCode, 0, len(recoveryCodeHashes))
+		for _, hash := range recoveryCodeHashes {
+			codes = append(codes, &model.MFARecoveryCode{UserID: userID, CodeHash: hash})
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+
+		_, err = tx.Model(&codes).Insert()
+		if err != nil {
+			repo.log.Errorf("error inserting recovery codes: %v", err)
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (repo *repository) UseMFAStep(ctx context.Context, userID int64, step int64) error {
+	repo.log.Info("[POSTGRES REPO]: Executing Use MFA Step")
+
+	// A single conditional update so a code can never be used twice, even by concurrent requests
+	res, err := repo.db.ModelContext(ctx, (*model.MFA)(nil)).
+		Set("last_used_step = ?", step).
+		Where("user_id = ?", userID).
+		Where("last_used_step < ?", step).
+		Update()
+	if err != nil {
+		repo.log.Errorf("error using mfa step: %v", err)
+		return err
+	}
+
+	if res.RowsAffected() == 0 {
+		return errMFANotFound
+	}
+
+	return nil
+}
+
+func (repo *repository) ConsumeRecoveryCode(ctx context.Context, userID int64, codeHash string, now time.Time) error {
+	repo.log.Info("[POSTGRES REPO]: Executing Consume Recovery Code")
+
+	res, err := repo.db.ModelContext(ctx, (*model.MFARecoveryCode)(nil)).
+		Set("used_at = ?", now).
+		Where("user_id = ?", userID).
+		Where("code_hash = ?", codeHash).
+		Where("used_at IS NULL").
+		Update()
+	if err != nil {
+		repo.log.Errorf("error consuming recovery code: %v", err)
+		return err
+	}
+
+	if res.RowsAffected() == 0 {
+		return errRecoveryCodeNotFound
+	}
+
+	return nil
+}
+
+func (repo *repository) DeleteMFA(ctx context.Context, userID int64) error {
+	repo.log.Info("[POSTGRES REPO]: Executing Delete MFA")
+
+	return repo.db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		_, err := tx.Model((*model.MFARecoveryCode)(nil)).Where("user_id = ?", userID).Delete()
+		if err != nil {
+			repo.log.Errorf("error deleting recovery codes: %v", err)
+			return err
+		}
+
+		res, err := tx.Model((*model.MFA)(nil)).Where("user_id = ?", userID).Delete()
+		if err != nil {
+			repo.log.Errorf("error deleting mfa: %v", err)
+			return err
+		}
+		if res.RowsAffected() == 0 {
+			return errMFANotFound
+		}
+
+		return nil
+	})
+}
+
+func (repo *repository) CreateMFAChallenge(ctx context.Context, userID int64, tokenHash string, expiresAt time.Time) error {
+	repo.log.Info("[POSTGRES REPO]: Executing Create MFA Challenge")
+
+	_, err := repo.db.ModelContext(ctx, &model.MFAChallenge{
+		UserID:    userID,
+		TokenHash: tokenHash,
+		ExpiresAt: expiresAt,
+	}).Insert()
+	if err != nil {
+		repo.log.Errorf("error inserting mfa challenge: %v", err)
+		return translateError(err)
+	}
+
+	return nil
+}
+
+func (repo *repository) MFAChallenge(ctx context.Context, tokenHash string, now time.Time) (*model.MFAChallenge, error) {
+	repo.log.Info("[POSTGRES REPO]: Executing MFA Challenge")
+
+	challenge := &model.MFAChallenge{}
+
+	err := repo.db.ModelContext(ctx, challenge).
+		Where("token_hash = ?", tokenHash).
+		Where("used_at IS NULL").
+		Where("expires_at > ?", now).
+		Select()
+	if errors.Is(err, pg.ErrNoRows) {
+		return nil, errMFAChallengeNotFound
+	}
+	if err != nil {
+		repo.log.Errorf("error getting mfa challenge: %v", err)
+		return nil, err
+	}
+
+	return challenge, nil
+}
+
+func (repo *repository) ConsumeMFAChallenge(ctx context.Context, tokenHash string, now time.Time) error {
+	repo.log.Info("[POSTGRES REPO]: Executing Consume MFA Challenge")
+
+	res, err := repo.db.ModelContext(ctx, (*model.MFAChallenge)(nil)).
+		Set("used_at = ?", now).
+		Where("token_hash = ?", tokenHash).
+		Where("used_at IS NULL").
+		Where("expires_at > ?", now).
+		Update()
+	if err != nil {
+		repo.log.Errorf("error consuming mfa challenge: %v", err)
+		return err
+	}
+
+	if res.RowsAffected() == 0 {
+		return errMFAChallengeNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,258 @@
+package migrations
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/sirupsen/logrus"
+)
+
+// lockID is the postgres advisory lock key held while migrating, so replicas
+// starting at the same time apply each migration exactly once.
+const lockID int64 = 7_365_021_864
+
+//go:embed sql/*.sql
+var embedded embed.FS
+
+var filename = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+type Migrator struct {
+	db         *pg.DB
+	log        *logrus.Logger
+	migrations []Migration
+}
+
+func NewMigrator(log *logrus.Logger, db *pg.DB) (*Migrator, error) {
+	migrations, err := load(embedded)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		db:         db,
+		log:        log,
+		migrations: migrations,
+	}, nil
+}
+
+// Up applies every migration that has not been applied yet, in order
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *pg.Conn) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			m.log.Infof("[MIGRATIONS]: Applying %d_%s", migration.Version, migration.Name)
+
+			err = conn.RunInTransaction(ctx, func(tx *pg.Tx) error {
+				if _, err := tx.Exec(migration.Up); err != nil {
+					return err
+				}
+				_, err := tx.Exec("INSERT INTO schema_migrations (version, name) VALUES (?, ?)", migration.Version, migration.Name)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("applying migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// Down rolls back the most recently applied migration
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *pg.Conn) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+
+			if migration.Down == "" {
+				return fmt.Errorf("migration %d_%s can not be rolled back", migration.Version, migration.Name)
+			}
+
+			m.log.Infof("[MIGRATIONS]: Rolling back %d_%s", migration.Version, migration.Name)
+
+			err = conn.RunInTransaction(ctx, func(tx *pg.Tx) error {
+				if _, err := tx.Exec(migration.Down); err != nil {
+					return err
+				}
+				_, err := tx.Exec("DELETE FROM schema_migrations WHERE version = ?", migration.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("rolling back migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			return nil
+		}
+
+		m.log.Info("[MIGRATIONS]: Nothing to roll back")
+		return nil
+	})
+}
+
+// Status lists every known migration along with when it was applied
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+
+	err := m.withLock(ctx, func(conn *pg.Conn) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			status := Status{Migration: migration}
+			if appliedAt, ok := applied[migration.Version]; ok {
+				status.AppliedAt = &appliedAt
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return statuses, nil
+}
+
+// withLock runs fn on a single connection holding the migrations advisory lock
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *pg.Conn) error) error {
+	conn := m.db.Conn().WithContext(ctx)
+	defer conn.Close()
+
+	if _, err := conn.Exec("SELECT pg_advisory_lock(?)", lockID); err != nil {
+		return fmt.Errorf("acquiring migrations lock: %w", err)
+	}
+	defer func() {
+		if _, err := conn.Exec("SELECT pg_advisory_unlock(?)", lockID); err != nil {
+			m.log.Errorf("error releasing migrations lock: %v", err)
+		}
+	}()
+
+	_, err := conn.Exec("CREATE TABLE IF NOT EXISTS schema_migrations (" +
+		"version bigint primary key," +
+		"name text not null," +
+		"applied_at timestamptz default now() not null" +
+		");")
+	if err != nil {
+		return fmt.Errorf("creating schema_migrations table: %w", err)
+	}
+
+	return fn(conn)
+}
+
+func appliedVersions(conn *pg.Conn) (map[int64]time.Time, error) {
+	var rows []struct {
+		Version   int64
+		AppliedAt time.Time
+	}
+
+	_, err := conn.Query(&rows, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int64]time.Time, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row.AppliedAt
+	}
+
+	return applied, nil
+}
+
+// load reads the migrations named <version>_<name>.(up|down).sql from fsys, ordered by version
+func load(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "sql/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+
+	for _, file := range files {
+		match := filename.FindStringSubmatch(path.Base(file))
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration filename: %s", file)
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version: %s", file)
+		}
+
+		b, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("duplicate migration version: %d", version)
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(b)
+		} else {
+			migration.Down = string(b)
+		}
+	}
+
+	var migrations []Migration
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up migration", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	if len(migrations) == 0 {
+		return nil, errors.New("no migrations found")
+	}
+
+	return migrations, nil
+}
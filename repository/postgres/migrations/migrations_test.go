@@ -0,0 +1,84 @@
+package migrations
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name     string
+		fsys     fstest.MapFS
+		versions []int64
+		errMsg   string
+	}{
+		{
+			name: "migrations ordered by version",
+			fsys: fstest.MapFS{
+				"sql/0010_add_email.up.sql":      {Data: []byte("ALTER TABLE users ADD COLUMN email text;")},
+				"sql/0002_add_index.up.sql":      {Data: []byte("CREATE INDEX users_admin ON users (admin);")},
+				"sql/0002_add_index.down.sql":    {Data: []byte("DROP INDEX users_admin;")},
+				"sql/0001_create_users.up.sql":   {Data: []byte("CREATE TABLE users ();")},
+				"sql/0001_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+			},
+			versions: []int64{1, 2, 10},
+		},
+		{
+			name: "invalid filename",
+			fsys: fstest.MapFS{
+				"sql/create_users.up.sql": {Data: []byte("CREATE TABLE users ();")},
+			},
+			errMsg: "invalid migration filename: sql/create_users.up.sql",
+		},
+		{
+			name: "duplicate version",
+			fsys: fstest.MapFS{
+				"sql/0001_create_users.up.sql": {Data: []byte("CREATE TABLE users ();")},
+				"sql/0001_create_roles.up.sql": {Data: []byte("CREATE TABLE roles ();")},
+			},
+			errMsg: "duplicate migration version: 1",
+		},
+		{
+			name: "missing up migration",
+			fsys: fstest.MapFS{
+				"sql/0001_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+			},
+			errMsg: "migration 1_create_users has no up migration",
+		},
+		{
+			name:   "no migrations",
+			fsys:   fstest.MapFS{},
+			errMsg: "no migrations found",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			migrations, err := load(tc.fsys)
+			if err != nil {
+				assert.Equal(t, tc.errMsg, err.Error())
+				return
+			}
+
+			var versions []int64
+			for _, migration := range migrations {
+				versions = append(versions, migration.Version)
+			}
+			assert.Equal(t, tc.versions, versions)
+		})
+	}
+}
+
+func TestLoad_Embedded(t *testing.T) {
+	migrations, err := load(embedded)
+	assert.NoError(t, err)
+
+	for _, migration := range migrations {
+		assert.NotEmpty(t, migration.Up, "migration %d_%s", migration.Version, migration.Name)
+		assert.NotEmpty(t, migration.Down, "migration %d_%s", migration.Version, migration.Name)
+	}
+}
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS users (
+    id bigserial primary key,
+    username varchar(40) unique,
+    admin bool,
+    created_at timestamp default now() not null,
+    updated_at timestamp default now() not null
+);
+
+-- Tables created by the old start up bootstrap are missing the password column
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password varchar(60);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    id bigserial primary key,
+    user_id bigint not null references users (id) on delete cascade,
+    token_hash char(64) not null unique,
+    expires_at timestamp not null,
+    used_at timestamp,
+    created_at timestamp default now() not null
+);
+
+CREATE INDEX IF NOT EXISTS password_reset_tokens_user_id ON password_reset_tokens (user_id);
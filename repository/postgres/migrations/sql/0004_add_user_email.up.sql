@@ -0,0 +1,17 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email varchar(254);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS verified_at timestamp;
+
+-- Emails are normalized to lower case by the service before they are stored
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_key ON users (email);
+
+CREATE TABLE IF NOT EXISTS email_verification_tokens (
+    id bigserial primary key,
+    user_id bigint not null references users (id) on delete cascade,
+    email varchar(254) not null,
+    token_hash char(64) not null unique,
+    expires_at timestamp not null,
+    used_at timestamp,
+    created_at timestamp default now() not null
+);
+
+CREATE INDEX IF NOT EXISTS email_verification_tokens_user_id ON email_verification_tokens (user_id);
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS login_attempts (
+    key varchar(300) primary key,
+    failures integer default 0 not null,
+    last_failure_at timestamp not null,
+    locked_until timestamp
+);
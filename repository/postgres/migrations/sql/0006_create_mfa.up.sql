@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS user_mfa (
+    user_id bigint primary key references users (id) on delete cascade,
+    secret varchar(64) not null,
+    last_used_step bigint default 0 not null,
+    confirmed_at timestamp,
+    created_at timestamp default now() not null
+);
+
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    id bigserial primary key,
+    user_id bigint not null references users (id) on delete cascade,
+    code_hash char(64) not null,
+    used_at timestamp,
+    created_at timestamp default now() not null
+);
+
+CREATE INDEX IF NOT EXISTS mfa_recovery_codes_user_id ON mfa_recovery_codes (user_id);
+
+CREATE TABLE IF NOT EXISTS mfa_challenges (
+    id bigserial primary key,
+    user_id bigint not null references users (id) on delete cascade,
+    token_hash char(64) not null unique,
+    expires_at timestamp not null,
+    used_at timestamp,
+    created_at timestamp default now() not null
+);
+
+CREATE TABLE IF NOT EXISTS settings (
+    key varchar(100) primary key,
+    value text not null,
+    updated_at timestamp default now() not null
+);
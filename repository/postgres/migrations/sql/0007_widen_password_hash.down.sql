@@ -0,0 +1,2 @@
+-- Fails while any user still has an argon2id hash, they must log in with bcrypt configured or reset their password first
+ALTER TABLE users ALTER COLUMN password TYPE varchar(60);
@@ -0,0 +1,3 @@
+-- Usernames are unique ignoring case, the service looks them up with lower(username).
+-- Users whose usernames only differ in case must be renamed before this migration can be applied.
+CREATE UNIQUE INDEX IF NOT EXISTS users_username_lower_key ON users (lower(username));
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS users_deleted_at;
+-- Soft deleted users would come back to life without the column
+DELETE FROM users WHERE deleted_at IS NOT NULL;
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN IF EXISTS status_changed_at;
+ALTER TABLE users DROP COLUMN IF EXISTS status_reason;
+ALTER TABLE users DROP COLUMN IF EXISTS status;
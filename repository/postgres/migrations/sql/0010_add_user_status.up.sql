@@ -0,0 +1,4 @@
+-- Existing users are active, the service sets the status of every new user
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status varchar(16) not null default 'active';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status_reason text;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status_changed_at timestamp;
@@ -0,0 +1,11 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS admin bool;
+
+-- Only the admin role survives as the admin flag, every other role is lost
+UPDATE users SET admin = EXISTS (
+    SELECT 1 FROM user_roles JOIN roles ON roles.id = user_roles.role_id
+    WHERE user_roles.user_id = users.id AND roles.name = 'admin'
+);
+
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS roles;
@@ -0,0 +1,56 @@
+CREATE TABLE IF NOT EXISTS roles (
+    id bigserial primary key,
+    name varchar(40) not null unique,
+    description text default '' not null,
+    created_at timestamp default now() not null
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role_id bigint not null references roles (id) on delete cascade,
+    permission varchar(64) not null,
+    primary key (role_id, permission)
+);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_id bigint not null references users (id) on delete cascade,
+    role_id bigint not null references roles (id) on delete cascade,
+    created_at timestamp default now() not null,
+    primary key (user_id, role_id)
+);
+
+CREATE INDEX IF NOT EXISTS user_roles_role_id ON user_roles (role_id);
+
+-- The built in roles, model.DefaultRoles holds the same roles for the memory store
+INSERT INTO roles (name, description) VALUES
+    ('admin', 'Full access to every user and setting'),
+    ('support', 'Helps users back into their accounts'),
+    ('auditor', 'Reviews users and the roles they hold'),
+    ('read_only_admin', 'Sees everything an admin sees without changing anything')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission)
+SELECT roles.id, permissions.permission
+FROM roles
+JOIN (VALUES
+    ('admin', '*'),
+    ('support', 'users:list'),
+    ('support', 'users:read'),
+    ('support', 'users:unlock'),
+    ('support', 'users:reset_password'),
+    ('support', 'users:disable_mfa'),
+    ('auditor', 'users:list'),
+    ('auditor', 'users:read'),
+    ('auditor', 'roles:list'),
+    ('read_only_admin', 'users:list'),
+    ('read_only_admin', 'users:read'),
+    ('read_only_admin', 'roles:list'),
+    ('read_only_admin', 'mfa:read_policy')
+) AS permissions (role, permission) ON permissions.role = roles.name
+ON CONFLICT DO NOTHING;
+
+-- The admin flag becomes the admin role
+INSERT INTO user_roles (user_id, role_id)
+SELECT users.id, roles.id FROM users, roles WHERE users.admin AND roles.name = 'admin'
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE users DROP COLUMN IF EXISTS admin;
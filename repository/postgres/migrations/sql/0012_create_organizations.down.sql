@@ -0,0 +1,21 @@
+-- Only the settings and roles of the default organization are kept
+DELETE FROM settings WHERE organization_id <> 1;
+ALTER TABLE settings DROP CONSTRAINT IF EXISTS settings_pkey;
+ALTER TABLE settings DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE settings ADD PRIMARY KEY (key);
+
+DELETE FROM user_roles WHERE organization_id <> 1;
+ALTER TABLE user_roles DROP CONSTRAINT IF EXISTS user_roles_pkey;
+ALTER TABLE user_roles DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE user_roles ADD PRIMARY KEY (user_id, role_id);
+
+-- Users of different organizations sharing a username or email must be renamed before this migration can be reverted
+DROP INDEX IF EXISTS users_organization_username_lower_key;
+DROP INDEX IF EXISTS users_organization_email_key;
+CREATE UNIQUE INDEX IF NOT EXISTS users_username_lower_key ON users (lower(username));
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_key ON users (email);
+ALTER TABLE users ADD CONSTRAINT users_username_key UNIQUE (username);
+
+DROP TABLE IF EXISTS organization_members;
+ALTER TABLE users DROP COLUMN IF EXISTS organization_id;
+DROP TABLE IF EXISTS organizations;
@@ -0,0 +1,48 @@
+CREATE TABLE IF NOT EXISTS organizations (
+    id bigserial primary key,
+    slug varchar(40) not null,
+    name varchar(100) not null,
+    created_at timestamp default now() not null
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS organizations_slug_key ON organizations (slug);
+
+-- The default organization serves the requests naming none, every user stored until now belongs to it
+INSERT INTO organizations (id, slug, name) VALUES (1, 'default', 'Default') ON CONFLICT DO NOTHING;
+SELECT setval(pg_get_serial_sequence('organizations', 'id'), (SELECT max(id) FROM organizations));
+
+-- organization_id is the organization the user registered in and is managed by
+ALTER TABLE users ADD COLUMN IF NOT EXISTS organization_id bigint not null default 1 references organizations (id);
+ALTER TABLE users ALTER COLUMN organization_id DROP DEFAULT;
+
+CREATE TABLE IF NOT EXISTS organization_members (
+    organization_id bigint not null references organizations (id) on delete cascade,
+    user_id bigint not null references users (id) on delete cascade,
+    created_at timestamp default now() not null,
+    primary key (organization_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS organization_members_user_id ON organization_members (user_id);
+
+INSERT INTO organization_members (organization_id, user_id)
+SELECT organization_id, id FROM users
+ON CONFLICT DO NOTHING;
+
+-- Usernames and emails are unique within an organization, which replaces the unique constraint of the username column
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_username_key;
+DROP INDEX IF EXISTS users_username_lower_key;
+DROP INDEX IF EXISTS users_email_key;
+CREATE UNIQUE INDEX IF NOT EXISTS users_organization_username_lower_key ON users (organization_id, lower(username));
+CREATE UNIQUE INDEX IF NOT EXISTS users_organization_email_key ON users (organization_id, email);
+
+-- Roles are granted within an organization
+ALTER TABLE user_roles ADD COLUMN IF NOT EXISTS organization_id bigint not null default 1 references organizations (id) on delete cascade;
+ALTER TABLE user_roles ALTER COLUMN organization_id DROP DEFAULT;
+ALTER TABLE user_roles DROP CONSTRAINT IF EXISTS user_roles_pkey;
+ALTER TABLE user_roles ADD PRIMARY KEY (organization_id, user_id, role_id);
+
+-- Every organization has its own settings
+ALTER TABLE settings ADD COLUMN IF NOT EXISTS organization_id bigint not null default 1 references organizations (id) on delete cascade;
+ALTER TABLE settings ALTER COLUMN organization_id DROP DEFAULT;
+ALTER TABLE settings DROP CONSTRAINT IF EXISTS settings_pkey;
+ALTER TABLE settings ADD PRIMARY KEY (organization_id, key);
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS groups (
+    id bigserial primary key,
+    organization_id bigint not null references organizations (id) on delete cascade,
+    name varchar(100) not null,
+    description varchar(500) default '' not null,
+    -- owner_id is cleared when the owner is purged, the group then stays with the users allowed to manage any group
+    owner_id bigint references users (id) on delete set null,
+    created_at timestamp default now() not null,
+    updated_at timestamp default now() not null
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS groups_organization_name_lower_key ON groups (organization_id, lower(name));
+
+-- Deleting a group removes its memberships, never the users
+CREATE TABLE IF NOT EXISTS group_members (
+    group_id bigint not null references groups (id) on delete cascade,
+    user_id bigint not null references users (id) on delete cascade,
+    created_at timestamp default now() not null,
+    primary key (group_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS group_members_user_id ON group_members (user_id);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS invitations (
+    id bigserial primary key,
+    organization_id bigint not null references organizations (id) on delete cascade,
+    email varchar(254) not null,
+    -- role is granted to the invitee once they accepted, empty grants none
+    role varchar(40) default '' not null,
+    invited_by bigint references users (id) on delete set null,
+    token_hash char(64) not null unique,
+    expires_at timestamp not null,
+    accepted_at timestamp,
+    created_at timestamp default now() not null
+);
+
+CREATE INDEX IF NOT EXISTS invitations_organization_email ON invitations (organization_id, email);
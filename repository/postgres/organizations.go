@@ -0,0 +1,216 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/go-pg/pg/v10"
+)
+
+// organizationSlugConstraint is the unique index on organizations.slug
+const organizationSlugConstraint = "organizations_slug_key"
+
+var (
+	errOrganizationNotFound  = model.NotFoundError("organization not found")
+	errOrganizationSlugTaken = model.AlreadyExistsError("organization already exists")
+	errMemberAbsent          = model.NotFoundError("user is not a member of the organization")
+)
+
+func (repo *repository) Organization(ctx context.Context, id int64) (*model.Organization, error) {
+	repo.log.Info("[POSTGRES REPO]: Executing Organization")
+
+	organization := &model.Organization{}
+
+	err := repo.db.ModelContext(ctx, organization).Where("id = ?", id).Select()
+	if errors.Is(err, pg.ErrNoRows) {
+		return nil, errOrganizationNotFound
+	}
+	if err != nil {
+		repo.log.Errorf("error getting organization: %v", err)
+		return nil, err
+	}
+
+	return organization, nil
+}
+
+func (repo *repository) OrganizationBySlug(ctx context.Context, slug string) (*model.Organization, error) {
+	repo.log.Info("[POSTGRES REPO]: Executing Organization By Slug")
+
+	organization := &model.Organization{}
+
+	err := repo.db.ModelContext(ctx, organization).Where("slug = ?", slug).Select()
+	if errors.Is(err, pg.ErrNoRows) {
+		return nil, errOrganizationNotFound
+	}
+	if err != nil {
+		repo.log.Errorf("error getting organization by slug: %v", err)
+		return nil, err
+	}
+
+	return organization, nil
+}
+
+func (repo *repository) CreateOrganization(ctx context.Context, slug, name string, ownerID int64) (*model.Organization, error) {
+	repo.log.Info("[POSTGRES REPO]: Executing Create Organization")
+
+	organization := &model.Organization{
+		Slug: slug,
+		Name: name,
+	}
+
+	err := repo.db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		exists, err := tx.Model((*model.User)(nil)).Where("id = ?", ownerID).Exists()
+		if err != nil {
+			repo.log.Errorf("error checking owner of organization: %v", err)
+			return err
+		}
+		if !exists {
+			return translateError(pg.ErrNoRows)
+		}
+
+		_, err = tx.Model(organization).Returning("*").Insert()
+		var pgErr pg.Error
+		if errors.As(err, &pgErr) && pgErr.Field('C') == uniqueViolation && pgErr.Field('n') == organizationSlugConstraint {
+			return errOrganizationSlugTaken
+		}
+		if err != nil {
+			repo.log.Errorf("error creating organization: %v", err)
+			return err
+		}
+
+		now := time.Now()
+		_, err = tx.Model(&model.OrganizationMember{OrganizationID: organization.ID, UserID: ownerID, CreatedAt: now}).Insert()
+		if err != nil {
+			repo.log.Errorf("error adding owner to organization: %v", err)
+			return err
+		}
+
+		// The owner administers the organization they created
+		return repo.setAdmin(tx, organization.ID, ownerID, true)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return organization, nil
+}
+
+func (repo *repository) UserOrganizations(ctx context.Context, userID int64) ([]*model.Organization, error) {
+	repo.log.Info("[POSTGRES REPO]: Executing User Organizations")
+
+	organizations := []*model.Organization{}
+
+	err := repo.db.ModelContext(ctx, &organizations).
+		Where(`EXISTS (
+			SELECT 1 FROM organization_members
+			WHERE organization_members.organization_id = organization.id AND organization_members.user_id = ?
+		)`, userID).
+		Order("slug ASC").
+		Select()
+	if err != nil {
+		repo.log.Errorf("error selecting organizations of user: %v", err)
+		return nil, err
+	}
+
+	return organizations, nil
+}
+
+func (repo *repository) IsMember(ctx context.Context, organizationID, userID int64) (bool, error) {
+	repo.log.Info("[POSTGRES REPO]: Executing Is Member")
+
+	exists, err := repo.db.ModelContext(ctx, (*model.OrganizationMember)(nil)).
+		Where("organization_id = ?", organizationID).
+		Where("user_id = ?", userID).
+		Exists()
+	if err != nil {
+		repo.log.Errorf("error checking organization member: %v", err)
+		return false, err
+	}
+
+	return exists, nil
+}
+
+func (repo *repository) AddMember(ctx context.Context, organizationID, userID int64) error {
+	repo.log.Info("[POSTGRES REPO]: Executing Add Member")
+
+	return repo.db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		exists, err := tx.Model((*model.Organization)(nil)).Where("id = ?", organizationID).For("SHARE").Exists()
+		if err != nil {
+			repo.log.Errorf("error checking organization: %v", err)
+			return err
+		}
+		if !exists {
+			return errOrganizationNotFound
+		}
+
+		exists, err = tx.Model((*model.User)(nil)).Where("id = ?", userID).Exists()
+		if err != nil {
+			repo.log.Errorf("error checking member of organization: %v", err)
+			return err
+		}
+		if !exists {
+			return translateError(pg.ErrNoRows)
+		}
+
+		_, err = tx.Model(&model.OrganizationMember{OrganizationID: organizationID, UserID: userID, CreatedAt: time.Now()}).
+			OnConflict("DO NOTHING").
+			Insert()
+		if err != nil {
+			repo.log.Errorf("error adding member to organization: %v", err)
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (repo *repository) RemoveMember(ctx context.Context, organizationID, userID int64) error {
+	repo.log.Info("[POSTGRES REPO]: Executing Remove Member")
+
+	return repo.db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		res, err := tx.Model((*model.OrganizationMember)(nil)).
+			Where("organization_id = ?", organizationID).
+			Where("user_id = ?", userID).
+			Delete()
+		if err != nil {
+			repo.log.Errorf("error removing member from organization: %v", err)
+			return err
+		}
+		if res.RowsAffected() == 0 {
+			return errMemberAbsent
+		}
+
+		_, err = tx.Model((*model.UserRole)(nil)).
+			Where("organization_id = ?", organizationID).
+			Where("user_id = ?", userID).
+			Delete()
+		if err != nil {
+			repo.log.Errorf("error revoking roles of removed member: %v", err)
+			return err
+		}
+
+		_, err = tx.Model((*model.GroupMember)(nil)).
+			Where("user_id = ?", userID).
+			Where("group_id IN (SELECT id FROM groups WHERE organization_id = ?)", organizationID).
+			Delete()
+		if err != nil {
+			repo.log.Errorf("error removing removed member from groups: %v", err)
+			return err
+		}
+
+		// Like a purged owner, the groups stay with the users allowed to manage any group
+		_, err = tx.Model((*model.Group)(nil)).
+			Set("owner_id = NULL").
+			Where("organization_id = ?", organizationID).
+			Where("owner_id = ?", userID).
+			Update()
+		if err != nil {
+			repo.log.Errorf("error clearing groups owned by removed member: %v", err)
+			return err
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,85 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/go-pg/pg/v10"
+)
+
+var errResetTokenNotFound = model.NotFoundError("reset token not found")
+
+func (repo *repository) CreateResetToken(ctx context.Context, userID int64, tokenHash string, expiresAt time.Time) error {
+	repo.log.Info("[POSTGRES REPO]: Executing Create Reset Token")
+
+	return repo.db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		_, err := tx.Model((*model.PasswordResetToken)(nil)).
+			Where("user_id = ?", userID).
+			Where("used_at IS NULL").
+			Delete()
+		if err != nil {
+			repo.log.Errorf("error deleting previous reset tokens: %v", err)
+			return err
+		}
+
+		_, err = tx.Model(&model.PasswordResetToken{
+			UserID:    userID,
+			TokenHash: tokenHash,
+			ExpiresAt: expiresAt,
+		}).Insert()
+		if err != nil {
+			repo.log.Errorf("error inserting reset token: %v", err)
+			return translateError(err)
+		}
+
+		return nil
+	})
+}
+
+func (repo *repository) ResetToken(_ context.Context, tokenHash string, now time.Time) (*model.PasswordResetToken, error) {
+	repo.log.Info("[POSTGRES REPO]: Executing Reset Token")
+
+	token := &model.PasswordResetToken{}
+
+	err := repo.db.Model(token).
+		Where("token_hash = ?", tokenHash).
+		Where("used_at IS NULL").
+		Where("expires_at > ?", now).
+		Select()
+	if errors.Is(err, pg.ErrNoRows) {
+		return nil, errResetTokenNotFound
+	}
+	if err != nil {
+		repo.log.Errorf("error getting reset token: %v", err)
+		return nil, err
+	}
+
+	return token, nil
+}
+
+func (repo *repository) ConsumeResetToken(_ context.Context, tokenHash string, now time.Time) (*model.PasswordResetToken, error) {
+	repo.log.Info("[POSTGRES REPO]: Executing Consume Reset Token")
+
+	token := &model.PasswordResetToken{}
+
+	// A single conditional update so a token can never be used twice, even by concurrent requests
+	res, err := repo.db.Model(token).
+		Set("used_at = ?", now).
+		Where("token_hash = ?", tokenHash).
+		Where("used_at IS NULL").
+		Where("expires_at > ?", now).
+		Returning("*").
+		Update()
+	if err != nil {
+		repo.log.Errorf("error consuming reset token: %v", err)
+		return nil, err
+	}
+
+	if res.RowsAffected() == 0 {
+		return nil, errResetTokenNotFound
+	}
+
+	return token, nil
+}
@@ -2,6 +2,8 @@ package postgres
 
 import (
 	"context"
+	"strings"
+	"time"
 
 	"github.com/JamieBShaw/user-service/domain/model"
 	"github.com/go-pg/pg/v10"
@@ -9,6 +11,16 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// likeEscaper escapes the LIKE wildcards so user input is matched literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// memberOf keeps the users belonging to an organization, users are read from every organization they belong to but
+// only written in the one they registered in
+const memberOf = `EXISTS (
+	SELECT 1 FROM organization_members
+	WHERE organization_members.user_id = "user".id AND organization_members.organization_id = ?
+)`
+
 type repository struct {
 	db  *pg.DB
 	log *logrus.Logger
@@ -21,83 +33,361 @@ func NewRepository(log *logrus.Logger, db *pg.DB) *repository {
 	}
 }
 
-func (repo *repository) UserById(_ context.Context, id int64) (*model.User, error) {
+func (repo *repository) UserById(ctx context.Context, id int64) (*model.User, error) {
 	repo.log.Info("[POSTGRES REPO]: Executing User By ID")
 
+	organizationID := model.OrganizationFromContext(ctx)
 	var user model.User
 
-	err := repo.db.Model(&user).Where("id = ?", id).First()
+	err := repo.db.Model(&user).Where("id = ?", id).Where(memberOf, organizationID).First()
 	if err != nil {
+		return nil, translateError(err)
+	}
+
+	if err := repo.loadRoles(repo.db, organizationID, &user); err != nil {
 		return nil, err
 	}
 
 	return &user, nil
 }
 
-func (repo *repository) Create(_ context.Context, username, password string) error {
+func (repo *repository) Create(ctx context.Context, username, email, passwordHash string, status model.AccountStatus) (*model.User, error) {
 	repo.log.Info("[POSTGRES REPO]: Executing Register User")
 
 	user := &model.User{
-		Username: username,
-		Password: password,
+		Username:       username,
+		Email:          email,
+		Password:       passwordHash,
+		OrganizationID: model.OrganizationFromContext(ctx),
+		Status:         status,
 	}
 
-	err := user.HashPassword(password)
+	err := repo.db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		_, err := tx.Model(user).Returning("*").Insert()
+		if err != nil {
+			return translateError(err)
+		}
+
+		_, err = tx.Model(&model.OrganizationMember{OrganizationID: user.OrganizationID, UserID: user.ID, CreatedAt: user.CreatedAt}).Insert()
+		if err != nil {
+			repo.log.Errorf("error adding user to organization: %v", err)
+			return err
+		}
+
+		return nil
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	_, err = repo.db.Model(user).Insert()
+	return user, nil
+}
+
+func (repo *repository) Update(ctx context.Context, user *model.User) (*model.User, error) {
+	repo.log.Info("[POSTGRES REPO]: Executing Update User")
+
+	organizationID := model.OrganizationFromContext(ctx)
+	admin := user.IsAdmin()
+	user.UpdatedAt = time.Now()
+
+	err := repo.db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		res, err := tx.Model(user).
+			Column("username", "updated_at").
+			WherePK().
+			Where("organization_id = ?", organizationID).
+			Returning("*").
+			Update()
+		if err != nil {
+			repo.log.Errorf("error updating user: %v", err)
+			return translateError(err)
+		}
+
+		if res.RowsAffected() == 0 {
+			return translateError(pg.ErrNoRows)
+		}
+
+		if err := repo.setAdmin(tx, organizationID, user.ID, admin); err != nil {
+			return err
+		}
+
+		return repo.loadRoles(tx, organizationID, user)
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if err = user.Validate(); err != nil {
-		return err
+	return user, nil
+}
+
+func (repo *repository) UpdatePassword(ctx context.Context, id int64, passwordHash string, changeRequired bool) error {
+	repo.log.Info("[POSTGRES REPO]: Executing Update Password")
+
+	user := &model.User{
+		ID:                     id,
+		Password:               passwordHash,
+		PasswordChangeRequired: changeRequired,
+		UpdatedAt:              time.Now(),
+	}
+
+	res, err := repo.db.Model(user).
+		Column("password", "password_change_required", "updated_at").
+		WherePK().
+		Where("organization_id = ?", model.OrganizationFromContext(ctx)).
+		Update()
+	if err != nil {
+		repo.log.Errorf("error updating password: %v", err)
+		return translateError(err)
+	}
+
+	if res.RowsAffected() == 0 {
+		return translateError(pg.ErrNoRows)
+	}
+
+	return nil
+}
+
+func (repo *repository) ReplacePasswordHash(ctx context.Context, id int64, oldHash, newHash string) error {
+	repo.log.Info("[POSTGRES REPO]: Executing Replace Password Hash")
+
+	res, err := repo.db.Model((*model.User)(nil)).
+		Set("password = ?", newHash).
+		Where("id = ?", id).
+		Where("organization_id = ?", model.OrganizationFromContext(ctx)).
+		Where("password = ?", oldHash).
+		Update()
+	if err != nil {
+		repo.log.Errorf("error replacing password hash: %v", err)
+		return translateError(err)
+	}
+
+	if res.RowsAffected() == 0 {
+		return translateError(pg.ErrNoRows)
 	}
 
 	return nil
 }
 
-func (repo *repository) GetUsers(_ context.Context) ([]*model.User, error) {
+func (repo *repository) GetUsers(ctx context.Context, filter model.UserFilter, afterID int64, limit int) ([]*model.User, error) {
 	repo.log.Info("[POSTGRES REPO]: Executing Get Users")
 
+	organizationID := model.OrganizationFromContext(ctx)
 	var users []*model.User
 
-	err := repo.db.Model(&users).Select()
+	query := repo.db.Model(&users).
+		Where(memberOf, organizationID).
+		Where("id > ?", afterID).
+		Order("id ASC").
+		Limit(limit)
+
+	if filter.UsernamePrefix != "" {
+		// Usernames are compared ignoring case like the lookups by username
+		query.Where("lower(username) LIKE lower(?) || '%'", likeEscaper.Replace(filter.UsernamePrefix))
+	}
+	if filter.Admin != nil {
+		query.Where(`EXISTS (
+			SELECT 1 FROM user_roles JOIN roles ON roles.id = user_roles.role_id
+			WHERE user_roles.user_id = "user".id AND user_roles.organization_id = ? AND roles.name = ?
+		) = ?`, organizationID, model.RoleAdmin, *filter.Admin)
+	}
+	if !filter.CreatedAfter.IsZero() {
+		query.Where("created_at >= ?", filter.CreatedAfter)
+	}
+	if !filter.CreatedBefore.IsZero() {
+		query.Where("created_at < ?", filter.CreatedBefore)
+	}
+
+	err := query.Select()
 	if err != nil {
 		repo.log.Errorf("error selecting users: %v", err)
 		return nil, err
 	}
 
+	if err := repo.loadRoles(repo.db, organizationID, users...); err != nil {
+		return nil, err
+	}
+
 	return users, nil
 }
 
+func (repo *repository) UpdateStatus(ctx context.Context, id int64, from, to model.AccountStatus, reason string, at time.Time) (*model.User, error) {
+	repo.log.Info("[POSTGRES REPO]: Executing Update Status")
+
+	organizationID := model.OrganizationFromContext(ctx)
+	user := &model.User{
+		ID: id,
+	}
+	res, err := repo.db.Model(user).
+		Set("status = ?", to).
+		Set("status_reason = ?", reason).
+		Set("status_changed_at = ?", at).
+		Set("updated_at = ?", at).
+		WherePK().
+		Where("organization_id = ?", organizationID).
+		Where("status = ?", from).
+		Returning("*").
+		Update()
+	if err != nil {
+		repo.log.Errorf("error updating status: %v", err)
+		return nil, translateError(err)
+	}
+
+	if res.RowsAffected() == 0 {
+		return nil, translateError(pg.ErrNoRows)
+	}
+
+	if err := repo.loadRoles(repo.db, organizationID, user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
 func (repo *repository) Delete(ctx context.Context, id int64) error {
 	repo.log.Info("[POSTGRES REPO]: Executing Delete User")
 
+	return repo.db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		user := &model.User{
+			ID: id,
+		}
+		// The deleted_at soft_delete column turns this into an update
+		res, err := tx.Model(user).
+			Where("id = ?", id).
+			Where("organization_id = ?", model.OrganizationFromContext(ctx)).
+			Delete()
+		if err != nil {
+			repo.log.Errorf("error deleting user: %v", err)
+			return translateError(err)
+		}
+
+		if res.RowsAffected() == 0 {
+			return translateError(pg.ErrNoRows)
+		}
+
+		// Tokens sent before the deletion must not work after a restore
+		for _, tokens := range []interface{}{
+			(*model.PasswordResetToken)(nil),
+			(*model.EmailVerificationToken)(nil),
+			(*model.MFAChallenge)(nil),
+		} {
+			_, err = tx.Model(tokens).Where("user_id = ?", id).Delete()
+			if err != nil {
+				repo.log.Errorf("error deleting tokens of deleted user: %v", err)
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (repo *repository) Restore(ctx context.Context, id int64) (*model.User, error) {
+	repo.log.Info("[POSTGRES REPO]: Executing Restore User")
+
+	organizationID := model.OrganizationFromContext(ctx)
 	user := &model.User{
 		ID: id,
 	}
-	_, err := repo.db.Model(user).Where("id = ?", id).Delete()
+	res, err := repo.db.Model(user).
+		Deleted().
+		Set("deleted_at = NULL").
+		Set("updated_at = ?", time.Now()).
+		WherePK().
+		Where("organization_id = ?", organizationID).
+		Returning("*").
+		Update()
 	if err != nil {
-		repo.log.Errorf("error deleting user: %v", err)
+		repo.log.Errorf("error restoring user: %v", err)
+		return nil, translateError(err)
+	}
+
+	if res.RowsAffected() == 0 {
+		return nil, translateError(pg.ErrNoRows)
+	}
+
+	if err := repo.loadRoles(repo.db, organizationID, user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (repo *repository) PurgeDeleted(_ context.Context, deletedBefore time.Time) (int, error) {
+	repo.log.Info("[POSTGRES REPO]: Executing Purge Deleted Users")
+
+	// Tokens, authenticators, recovery codes, memberships and roles of the users go with them, their foreign keys cascade
+	res, err := repo.db.Model((*model.User)(nil)).
+		Where("deleted_at < ?", deletedBefore).
+		ForceDelete()
+	if err != nil {
+		repo.log.Errorf("error purging deleted users: %v", err)
+		return 0, err
+	}
+
+	return res.RowsAffected(), nil
+}
+
+func (repo *repository) Purge(ctx context.Context, id int64) error {
+	repo.log.Info("[POSTGRES REPO]: Executing Purge User")
+
+	res, err := repo.db.ModelContext(ctx, (*model.User)(nil)).
+		Where("id = ?", id).
+		Where("organization_id = ?", model.OrganizationFromContext(ctx)).
+		// ForceDelete alone only matches soft deleted rows, a live user is purged too
+		AllWithDeleted().
+		ForceDelete()
+	if err != nil {
+		repo.log.Errorf("error purging user: %v", err)
 		return err
 	}
 
+	if res.RowsAffected() == 0 {
+		return translateError(pg.ErrNoRows)
+	}
+
 	return nil
 }
 
 func (repo *repository) UserByUsername(ctx context.Context, username string) (*model.User, error) {
 	repo.log.Info("[POSTGRES REPO]: Executing Getting User by Username")
 
+	organizationID := model.OrganizationFromContext(ctx)
 	user := &model.User{
 		Username: username,
 	}
 
-	err := repo.db.Model(user).Where("username = ?", username).First()
+	// Usernames are unique in an organization ignoring case, users_organization_username_lower_key serves this lookup
+	err := repo.db.Model(user).
+		Where("organization_id = ?", organizationID).
+		Where("lower(username) = lower(?)", username).
+		First()
 	if err != nil {
 		repo.log.Errorf("error getting user by username: %s, error: %v", username, err)
+		return nil, translateError(err)
+	}
+
+	if err := repo.loadRoles(repo.db, organizationID, user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (repo *repository) UserByEmail(ctx context.Context, email string) (*model.User, error) {
+	repo.log.Info("[POSTGRES REPO]: Executing Getting User by Email")
+
+	organizationID := model.OrganizationFromContext(ctx)
+	user := &model.User{}
+
+	err := repo.db.Model(user).
+		Where("organization_id = ?", organizationID).
+		Where("email = ?", email).
+		First()
+	if err != nil {
+		repo.log.Errorf("error getting user by email, error: %v", err)
+		return nil, translateError(err)
+	}
+
+	if err := repo.loadRoles(repo.db, organizationID, user); err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,188 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/JamieBShaw/user-service/repository/postgres/migrations"
+	"github.com/go-pg/pg/v10"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// testRepository connects to the database named by TEST_PGDATABASE as PGUSER with PGPASSWORD and applies every
+// migration, tests using it are skipped when TEST_PGDATABASE is not set
+func testRepository(t *testing.T) *repository {
+	database := os.Getenv("TEST_PGDATABASE")
+	if database == "" {
+		t.Skip("TEST_PGDATABASE is not set")
+	}
+
+	db := pg.Connect(&pg.Options{
+		User:     os.Getenv("PGUSER"),
+		Password: os.Getenv("PGPASSWORD"),
+		Database: database,
+	})
+	t.Cleanup(func() { db.Close() })
+
+	log := logrus.New()
+	migrator, err := migrations.NewMigrator(log, db)
+	if err != nil {
+		t.Fatalf("could not load migrations: %v", err)
+	}
+	if err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("could not migrate test database: %v", err)
+	}
+
+	return NewRepository(log, db)
+}
+
+func TestRepository_Create_Username_Per_Organization(t *testing.T) {
+	repo := testRepository(t)
+	ctx := context.Background()
+	// The database outlives the test, so every run uses its own names
+	suffix := fmt.Sprint(time.Now().UnixNano())
+	username := "dana" + suffix
+
+	create := func(ctx context.Context, username, email string) (*model.User, error) {
+		user, err := repo.Create(ctx, username, email, "password-hash", model.StatusActive)
+		if err == nil {
+			t.Cleanup(func() { _ = repo.Purge(ctx, user.ID) })
+		}
+		return user, err
+	}
+
+	owner, err := create(ctx, "owner"+suffix, "owner"+suffix+"@example.com")
+	if err != nil {
+		t.Fatalf("could not create owner: %v", err)
+	}
+	acme, err := repo.CreateOrganization(ctx, "acme"+suffix, "Acme", owner.ID)
+	if err != nil {
+		t.Fatalf("could not create organization: %v", err)
+	}
+	// Cleanups run last in first, the users of acme are purged before it, their rows would keep it from being deleted
+	t.Cleanup(func() { _, _ = repo.db.Model((*model.Organization)(nil)).Where("id = ?", acme.ID).Delete() })
+	inAcme := model.WithOrganization(ctx, acme.ID)
+
+	_, err = create(ctx, username, username+"@example.com")
+	assert.NoError(t, err)
+	_, err = create(inAcme, username, username+"@example.com")
+	assert.NoError(t, err)
+
+	// Usernames stay unique, ignoring case, within an organization
+	_, err = create(inAcme, "Dana"+suffix, "other"+suffix+"@example.com")
+	assert.Equal(t, model.CodeAlreadyExists, model.ErrorCodeOf(err))
+}
+
+func TestRepository_GetUsers_Username_Prefix(t *testing.T) {
+	repo := testRepository(t)
+	ctx := context.Background()
+	prefix := fmt.Sprintf("p%d_", time.Now().UnixNano())
+
+	for _, username := range []string{strings.ToUpper(prefix) + "ann", prefix[:len(prefix)-1] + "xbob"} {
+		user, err := repo.Create(ctx, username, username+"@example.com", "password-hash", model.StatusActive)
+		if err != nil {
+			t.Fatalf("could not create user: %v", err)
+		}
+		t.Cleanup(func() { _ = repo.Purge(ctx, user.ID) })
+	}
+
+	// The prefix ignores case and its _ is not a wildcard
+	users, err := repo.GetUsers(ctx, model.UserFilter{UsernamePrefix: prefix}, 0, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, users, 1) {
+		assert.Equal(t, strings.ToUpper(prefix)+"ann", users[0].Username)
+	}
+}
+
+func TestRepository_Purge_Live_User(t *testing.T) {
+	repo := testRepository(t)
+	ctx := context.Background()
+	username := fmt.Sprintf("live%d", time.Now().UnixNano())
+
+	user, err := repo.Create(ctx, username, username+"@example.com", "password-hash", model.StatusActive)
+	if err != nil {
+		t.Fatalf("could not create user: %v", err)
+	}
+
+	// A user who was never soft deleted is purged as well
+	assert.NoError(t, repo.Purge(ctx, user.ID))
+	_, err = repo.UserById(ctx, user.ID)
+	assert.Equal(t, model.CodeNotFound, model.ErrorCodeOf(err))
+	assert.Equal(t, model.CodeNotFound, model.ErrorCodeOf(repo.Purge(ctx, user.ID)))
+
+	// Their username is free again
+	user, err = repo.Create(ctx, username, username+"@example.com", "password-hash", model.StatusActive)
+	if assert.NoError(t, err) {
+		assert.NoError(t, repo.Purge(ctx, user.ID))
+	}
+}
+
+func TestRepository_Organization_Members(t *testing.T) {
+	repo := testRepository(t)
+	ctx := context.Background()
+	suffix := fmt.Sprint(time.Now().UnixNano())
+
+	create := func(username string) *model.User {
+		user, err := repo.Create(ctx, username, username+"@example.com", "password-hash", model.StatusActive)
+		if err != nil {
+			t.Fatalf("could not create user: %v", err)
+		}
+		t.Cleanup(func() { _ = repo.Purge(ctx, user.ID) })
+		return user
+	}
+
+	owner := create("owner" + suffix)
+	acme, err := repo.CreateOrganization(ctx, "acme"+suffix, "Acme", owner.ID)
+	if err != nil {
+		t.Fatalf("could not create organization: %v", err)
+	}
+	// Memberships, roles and groups of acme cascade when it is deleted
+	t.Cleanup(func() { _, _ = repo.db.Model((*model.Organization)(nil)).Where("id = ?", acme.ID).Delete() })
+	inAcme := model.WithOrganization(ctx, acme.ID)
+	bob := create("bob" + suffix)
+
+	assert.NoError(t, repo.AddMember(ctx, acme.ID, bob.ID))
+	// Adding a member again does nothing
+	assert.NoError(t, repo.AddMember(ctx, acme.ID, bob.ID))
+	member, err := repo.IsMember(ctx, acme.ID, bob.ID)
+	assert.NoError(t, err)
+	assert.True(t, member)
+
+	assert.NoError(t, repo.AssignRole(inAcme, bob.ID, model.RoleSupport))
+	group, err := repo.CreateGroup(inAcme, "Support", "", bob.ID)
+	if err != nil {
+		t.Fatalf("could not create group: %v", err)
+	}
+	assert.NoError(t, repo.AddGroupMember(inAcme, group.ID, bob.ID))
+
+	// Removing bob takes away their roles and memberships in acme and the groups they own there lose their owner
+	assert.NoError(t, repo.RemoveMember(ctx, acme.ID, bob.ID))
+	member, err = repo.IsMember(ctx, acme.ID, bob.ID)
+	assert.NoError(t, err)
+	assert.False(t, member)
+	permissions, err := repo.UserPermissions(inAcme, bob.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, permissions)
+	members, err := repo.GroupMembers(inAcme, group.ID, 0, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, members)
+	group, err = repo.Group(inAcme, group.ID)
+	if assert.NoError(t, err) {
+		assert.Zero(t, group.OwnerID)
+	}
+
+	// bob is still a member of the organization they registered in
+	member, err = repo.IsMember(ctx, model.DefaultOrganizationID, bob.ID)
+	assert.NoError(t, err)
+	assert.True(t, member)
+
+	assert.Equal(t, model.CodeNotFound, model.ErrorCodeOf(repo.RemoveMember(ctx, acme.ID, bob.ID)))
+	assert.Equal(t, model.CodeNotFound, model.ErrorCodeOf(repo.AddMember(ctx, acme.ID, -1)))
+	assert.Equal(t, model.CodeNotFound, model.ErrorCodeOf(repo.AddMember(ctx, -1, bob.ID)))
+}
@@ -0,0 +1,201 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+var (
+	errRoleNotFound    = model.NotFoundError("role not found")
+	errRoleNotAssigned = model.NotFoundError("user does not have the role")
+)
+
+func (repo *repository) Roles(ctx context.Context) ([]*model.Role, error) {
+	repo.log.Info("[POSTGRES REPO]: Executing Roles")
+
+	var roles []*model.Role
+
+	err := repo.db.ModelContext(ctx, &roles).Order("name ASC").Select()
+	if err != nil {
+		repo.log.Errorf("error selecting roles: %v", err)
+		return nil, err
+	}
+	if len(roles) == 0 {
+		return roles, nil
+	}
+
+	byID := make(map[int64]*model.Role, len(roles))
+	ids := make([]int64, 0, len(roles))
+	for _, role := range roles {
+		byID[role.ID] = role
+		ids = append(ids, role.ID)
+	}
+
+	var permissions []*model.RolePermission
+
+	err = repo.db.ModelContext(ctx, &permissions).
+		Where("role_id IN (?)", pg.In(ids)).
+		Order("permission ASC").
+		Select()
+	if err != nil {
+		repo.log.Errorf("error selecting role permissions: %v", err)
+		return nil, err
+	}
+
+	for _, permission := range permissions {
+		role := byID[permission.RoleID]
+		role.Permissions = append(role.Permissions, permission.Permission)
+	}
+
+	return roles, nil
+}
+
+func (repo *repository) AssignRole(ctx context.Context, userID int64, role string) error {
+	repo.log.Info("[POSTGRES REPO]: Executing Assign Role")
+
+	organizationID := model.OrganizationFromContext(ctx)
+
+	return repo.db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		roleID, err := repo.roleID(tx, role)
+		if err != nil {
+			return err
+		}
+
+		exists, err := tx.Model((*model.User)(nil)).Where("id = ?", userID).Where(memberOf, organizationID).Exists()
+		if err != nil {
+			repo.log.Errorf("error checking user of role: %v", err)
+			return err
+		}
+		if !exists {
+			return translateError(pg.ErrNoRows)
+		}
+
+		_, err = tx.Model(&model.UserRole{OrganizationID: organizationID, UserID: userID, RoleID: roleID, CreatedAt: time.Now()}).
+			OnConflict("DO NOTHING").
+			Insert()
+		if err != nil {
+			repo.log.Errorf("error assigning role: %v", err)
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (repo *repository) RevokeRole(ctx context.Context, userID int64, role string) error {
+	repo.log.Info("[POSTGRES REPO]: Executing Revoke Role")
+
+	res, err := repo.db.ModelContext(ctx, (*model.UserRole)(nil)).
+		Where("organization_id = ?", model.OrganizationFromContext(ctx)).
+		Where("user_id = ?", userID).
+		Where("role_id = (SELECT id FROM roles WHERE name = ?)", role).
+		Delete()
+	if err != nil {
+		repo.log.Errorf("error revoking role: %v", err)
+		return err
+	}
+
+	if res.RowsAffected() == 0 {
+		return errRoleNotAssigned
+	}
+
+	return nil
+}
+
+func (repo *repository) UserPermissions(ctx context.Context, userID int64) ([]string, error) {
+	repo.log.Info("[POSTGRES REPO]: Executing User Permissions")
+
+	var permissions []string
+
+	_, err := repo.db.QueryContext(ctx, &permissions, `
+		SELECT DISTINCT role_permissions.permission
+		FROM role_permissions
+		JOIN user_roles ON user_roles.role_id = role_permissions.role_id
+		WHERE user_roles.organization_id = ? AND user_roles.user_id = ?`, model.OrganizationFromContext(ctx), userID)
+	if err != nil {
+		repo.log.Errorf("error selecting user permissions: %v", err)
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
+// roleID looks up the id of the role with name
+func (repo *repository) roleID(db orm.DB, name string) (int64, error) {
+	role := &model.Role{}
+
+	err := db.Model(role).Column("id").Where("name = ?", name).Select()
+	if errors.Is(err, pg.ErrNoRows) {
+		return 0, errRoleNotFound
+	}
+	if err != nil {
+		repo.log.Errorf("error getting role: %v", err)
+		return 0, err
+	}
+
+	return role.ID, nil
+}
+
+// loadRoles sets the names of the roles every user holds in the organization, ordered by name
+func (repo *repository) loadRoles(db orm.DB, organizationID int64, users ...*model.User) error {
+	if len(users) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*model.User, len(users))
+	ids := make([]int64, 0, len(users))
+	for _, user := range users {
+		user.Roles = nil
+		byID[user.ID] = user
+		ids = append(ids, user.ID)
+	}
+
+	var rows []struct {
+		UserID int64
+		Name   string
+	}
+
+	_, err := db.Query(&rows, `
+		SELECT user_roles.user_id, roles.name
+		FROM user_roles
+		JOIN roles ON roles.id = user_roles.role_id
+		WHERE user_roles.organization_id = ? AND user_roles.user_id IN (?)
+		ORDER BY roles.name`, organizationID, pg.In(ids))
+	if err != nil {
+		repo.log.Errorf("error selecting roles of users: %v", err)
+		return err
+	}
+
+	for _, row := range rows {
+		user := byID[row.UserID]
+		user.Roles = append(user.Roles, row.Name)
+	}
+
+	return nil
+}
+
+// setAdmin grants or revokes the admin role of the user in the organization so it matches admin
+func (repo *repository) setAdmin(tx *pg.Tx, organizationID, userID int64, admin bool) error {
+	var err error
+	if admin {
+		_, err = tx.Exec(`
+			INSERT INTO user_roles (organization_id, user_id, role_id)
+			SELECT ?, ?, id FROM roles WHERE name = ?
+			ON CONFLICT DO NOTHING`, organizationID, userID, model.RoleAdmin)
+	} else {
+		_, err = tx.Model((*model.UserRole)(nil)).
+			Where("organization_id = ?", organizationID).
+			Where("user_id = ?", userID).
+			Where("role_id = (SELECT id FROM roles WHERE name = ?)", model.RoleAdmin).
+			Delete()
+	}
+	if err != nil {
+		repo.log.Errorf("error setting admin role: %v", err)
+	}
+	return err
+}
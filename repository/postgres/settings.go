@@ -0,0 +1,55 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/go-pg/pg/v10"
+)
+
+var errSettingNotFound = model.NotFoundError("setting not found")
+
+func (repo *repository) Setting(ctx context.Context, key string) (string, error) {
+	repo.log.Info("[POSTGRES REPO]: Executing Setting")
+
+	setting := &model.Setting{}
+
+	err := repo.db.ModelContext(ctx, setting).
+		Where("organization_id = ?", model.OrganizationFromContext(ctx)).
+		Where("key = ?", key).
+		Select()
+	if errors.Is(err, pg.ErrNoRows) {
+		return "", errSettingNotFound
+	}
+	if err != nil {
+		repo.log.Errorf("error getting setting: %v", err)
+		return "", err
+	}
+
+	return setting.Value, nil
+}
+
+func (repo *repository) SetSetting(ctx context.Context, key, value string) error {
+	repo.log.Info("[POSTGRES REPO]: Executing Set Setting")
+
+	setting := &model.Setting{
+		OrganizationID: model.OrganizationFromContext(ctx),
+		Key:            key,
+		Value:          value,
+		UpdatedAt:      time.Now(),
+	}
+
+	_, err := repo.db.ModelContext(ctx, setting).
+		OnConflict("(organization_id, key) DO UPDATE").
+		Set("value = EXCLUDED.value").
+		Set("updated_at = EXCLUDED.updated_at").
+		Insert()
+	if err != nil {
+		repo.log.Errorf("error setting setting: %v", err)
+		return err
+	}
+
+	return nil
+}
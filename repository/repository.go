@@ -2,14 +2,178 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/JamieBShaw/user-service/domain/model"
 )
 
+// Repository stores users, every user it returns has the names of their roles loaded. It is scoped to the
+// organization of ctx, see model.OrganizationFromContext: users are read from the members of the organization but
+// only written, and looked up by username or email, in the organization they registered in. Users of other
+// organizations are not found.
 type Repository interface {
 	UserById(ctx context.Context, id int64) (*model.User, error)
 	UserByUsername(ctx context.Context, username string) (*model.User, error)
-	Create(ctx context.Context, username, password string) error
+	// UserByEmail looks a user up by their normalized email
+	UserByEmail(ctx context.Context, email string) (*model.User, error)
+	// Create stores a new user of the organization with the encoded hash of their password and their initial status,
+	// email must already be normalized
+	Create(ctx context.Context, username, email, passwordHash string, status model.AccountStatus) (*model.User, error)
+	// Update stores the username of the user and grants or revokes the admin role so it matches IsAdmin, other
+	// roles are left untouched
+	Update(ctx context.Context, user *model.User) (*model.User, error)
+	// UpdatePassword stores the hash of a new password, changeRequired forces the user to change it on their next login
+	UpdatePassword(ctx context.Context, id int64, passwordHash string, changeRequired bool) error
+	// ReplacePasswordHash swaps the hash of an unchanged password for newHash, the user is not found when their
+	// stored hash is no longer oldHash so a password changed in the meantime is never overwritten
+	ReplacePasswordHash(ctx context.Context, id int64, oldHash, newHash string) error
+	// Delete soft deletes the user, who is left out of every read until restored or purged. Their username and email
+	// stay taken until they are purged.
 	Delete(ctx context.Context, id int64) error
-	GetUsers(ctx context.Context) ([]*model.User, error)
+	// Restore undoes the soft delete of the user, users that are not deleted are not found
+	Restore(ctx context.Context, id int64) (*model.User, error)
+	// UpdateStatus changes the status of the user from one status to another, recording why and when. The user is not
+	// found when their status is no longer from, so concurrent changes can not skip a transition.
+	UpdateStatus(ctx context.Context, id int64, from, to model.AccountStatus, reason string, at time.Time) (*model.User, error)
+	// Purge hard deletes the user right away, whether or not they were soft deleted, freeing their username and email
+	Purge(ctx context.Context, id int64) error
+	// PurgeDeleted hard deletes the users soft deleted before deletedBefore in every organization and returns how
+	// many were purged
+	PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int, error)
+	// GetUsers returns at most limit users matching the filter with an id greater than afterID, ordered by id
+	GetUsers(ctx context.Context, filter model.UserFilter, afterID int64, limit int) ([]*model.User, error)
+}
+
+// PasswordResetRepository stores the hashes of the single use tokens sent to users who forgot their password
+type PasswordResetRepository interface {
+	// CreateResetToken stores a new token for the user and invalidates any token they were sent before
+	CreateResetToken(ctx context.Context, userID int64, tokenHash string, expiresAt time.Time) error
+	// ResetToken returns a token that can still be used without consuming it, unknown, used and expired tokens are not found
+	ResetToken(ctx context.Context, tokenHash string, now time.Time) (*model.PasswordResetToken, error)
+	// ConsumeResetToken marks the token as used and returns it, unknown, used and expired tokens are not found
+	ConsumeResetToken(ctx context.Context, tokenHash string, now time.Time) (*model.PasswordResetToken, error)
+}
+
+// EmailVerificationRepository stores the hashes of the single use tokens proving a user owns their email
+type EmailVerificationRepository interface {
+	// CreateVerificationToken stores a new token for the email of the user and invalidates any token they were sent before
+	CreateVerificationToken(ctx context.Context, userID int64, email, tokenHash string, expiresAt time.Time) error
+	// VerifyEmail consumes the token and marks the email it was sent to as verified, unknown, used and expired
+	// tokens, and tokens sent to an email the user no longer has, are not found
+	VerifyEmail(ctx context.Context, tokenHash string, now time.Time) error
+}
+
+// LoginAttemptRepository counts failed logins so accounts and client addresses guessing passwords can be locked out
+type LoginAttemptRepository interface {
+	// LoginAttempt returns the failed logins recorded against key, a key without any is not found
+	LoginAttempt(ctx context.Context, key string) (*model.LoginAttempt, error)
+	// RecordLoginFailure counts a failed login against key and returns the updated attempt, the count starts
+	// again from one when the previous failure is older than window
+	RecordLoginFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*model.LoginAttempt, error)
+	// LockLogin refuses logins for key until the given time
+	LockLogin(ctx context.Context, key string, until time.Time) error
+	// ClearLoginAttempts forgets the failed logins and any lock recorded against key
+	ClearLoginAttempts(ctx context.Context, key string) error
+}
+
+// MFARepository stores the TOTP authenticators of users, their recovery codes and the challenges of logins waiting
+// for a second factor
+type MFARepository interface {
+	// MFA returns the authenticator of the user, confirmed or not
+	MFA(ctx context.Context, userID int64) (*model.MFA, error)
+	// SaveMFA stores an unconfirmed authenticator with secret, replacing any unconfirmed one the user had
+	SaveMFA(ctx context.Context, userID int64, secret string) error
+	// ConfirmMFA enables the authenticator of the user, records step as used and replaces their recovery codes
+	ConfirmMFA(ctx context.Context, userID int64, step int64, recoveryCodeHashes []string, now time.Time) error
+	// UseMFAStep records step as used, a step that is not after the last used one is not found
+	UseMFAStep(ctx context.Context, userID int64, step int64) error
+	// ConsumeRecoveryCode marks the code as used, unknown and used codes are not found
+	ConsumeRecoveryCode(ctx context.Context, userID int64, codeHash string, now time.Time) error
+	// DeleteMFA removes the authenticator and recovery codes of the user
+	DeleteMFA(ctx context.Context, userID int64) error
+	CreateMFAChallenge(ctx context.Context, userID int64, tokenHash string, expiresAt time.Time) error
+	// MFAChallenge returns the challenge with the token, unknown, used and expired challenges are not found
+	MFAChallenge(ctx context.Context, tokenHash string, now time.Time) (*model.MFAChallenge, error)
+	// ConsumeMFAChallenge marks the challenge as used, unknown, used and expired challenges are not found
+	ConsumeMFAChallenge(ctx context.Context, tokenHash string, now time.Time) error
+}
+
+// SettingsRepository stores the settings admins can change at runtime, scoped to the organization of ctx
+type SettingsRepository interface {
+	// Setting returns the value of key, a key that was never set is not found
+	Setting(ctx context.Context, key string) (string, error)
+	SetSetting(ctx context.Context, key, value string) error
+}
+
+// RoleRepository stores the roles, the permissions they grant and the users holding them. Roles are granted within
+// the organization of ctx to its members.
+type RoleRepository interface {
+	// Roles returns every role with its permissions, ordered by name
+	Roles(ctx context.Context) ([]*model.Role, error)
+	// AssignRole grants the role to the user, unknown users and roles are not found. Granting a role the user
+	// already holds does nothing.
+	AssignRole(ctx context.Context, userID int64, role string) error
+	// RevokeRole takes the role away from the user, a user not holding the role is not found
+	RevokeRole(ctx context.Context, userID int64, role string) error
+	// UserPermissions returns the permissions granted to the user by all their roles
+	UserPermissions(ctx context.Context, userID int64) ([]string, error)
+}
+
+// OrganizationRepository stores the organizations and the users belonging to them, it is not scoped to the
+// organization of ctx since it resolves that organization
+type OrganizationRepository interface {
+	// Organization returns the organization with id
+	Organization(ctx context.Context, id int64) (*model.Organization, error)
+	// OrganizationBySlug returns the organization with slug
+	OrganizationBySlug(ctx context.Context, slug string) (*model.Organization, error)
+	// CreateOrganization stores a new organization with owner as its first member, holding the admin role. A taken
+	// slug already exists and an unknown owner is not found.
+	CreateOrganization(ctx context.Context, slug, name string, ownerID int64) (*model.Organization, error)
+	// UserOrganizations returns the organizations the user belongs to, ordered by slug
+	UserOrganizations(ctx context.Context, userID int64) ([]*model.Organization, error)
+	// IsMember reports whether the user belongs to the organization
+	IsMember(ctx context.Context, organizationID, userID int64) (bool, error)
+	// AddMember adds a user of any organization to the organization, the caller makes sure the user consented to it.
+	// Unknown organizations and users are not found, adding a member again does nothing.
+	AddMember(ctx context.Context, organizationID, userID int64) error
+	// RemoveMember takes the user out of the organization together with the roles they hold and the groups they
+	// belong to in it, the groups they own there lose their owner. A user who is not a member is not found.
+	RemoveMember(ctx context.Context, organizationID, userID int64) error
+}
+
+// InvitationRepository stores the invitations sent to join an organization. Invitations are created in the
+// organization of ctx but found by the hash of their token in any organization, the invitee is not signed in.
+type InvitationRepository interface {
+	// CreateInvitation stores a new invitation and invalidates any pending invitation sent to the same email in the
+	// organization, an inviter who is not a member of the organization is not found
+	CreateInvitation(ctx context.Context, invitation *model.Invitation) (*model.Invitation, error)
+	// AcceptInvitation marks the invitation as accepted and returns it, unknown, accepted and expired invitations are
+	// not found
+	AcceptInvitation(ctx context.Context, tokenHash string, now time.Time) (*model.Invitation, error)
+	// ReopenInvitation undoes accepting the invitation with id when no user could be created from it
+	ReopenInvitation(ctx context.Context, id int64) error
+}
+
+// GroupRepository stores the groups of the organization of ctx and their members, only members of the organization
+// can own or join its groups. Deleted users are left out of the members of groups until they are restored.
+type GroupRepository interface {
+	// Group returns the group with id
+	Group(ctx context.Context, id int64) (*model.Group, error)
+	// CreateGroup stores a new group, a name taken in the organization already exists and an owner who is not a
+	// member of the organization is not found
+	CreateGroup(ctx context.Context, name, description string, ownerID int64) (*model.Group, error)
+	// DeleteGroup deletes the group together with its memberships, the users who were members are left untouched
+	DeleteGroup(ctx context.Context, id int64) error
+	// Groups returns at most limit groups with an id greater than afterID, ordered by id
+	Groups(ctx context.Context, afterID int64, limit int) ([]*model.Group, error)
+	// AddGroupMember adds the user to the group, unknown groups and users are not found. Adding a member again
+	// does nothing.
+	AddGroupMember(ctx context.Context, groupID, userID int64) error
+	// RemoveGroupMember takes the user out of the group, a user who is not a member is not found
+	RemoveGroupMember(ctx context.Context, groupID, userID int64) error
+	// GroupMembers returns at most limit members of the group with an id greater than afterID, ordered by id. An
+	// unknown group is not found.
+	GroupMembers(ctx context.Context, groupID, afterID int64, limit int) ([]*model.User, error)
+	// UserGroups returns at most limit groups the user belongs to with an id greater than afterID, ordered by id
+	UserGroups(ctx context.Context, userID, afterID int64, limit int) ([]*model.Group, error)
 }
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/JamieBShaw/user-service/notify"
+	"github.com/JamieBShaw/user-service/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// EmailVerificationTokenTTL is how long an email verification token can be used for
+const EmailVerificationTokenTTL = 24 * time.Hour
+
+type EmailVerificationService interface {
+	// SendVerification sends a verification token to the email of the user
+	SendVerification(ctx context.Context, userID int64) error
+	// ResendVerification sends a new token to an unverified email, it returns nil for unknown and
+	// verified emails so callers can not tell them apart
+	ResendVerification(ctx context.Context, email string) error
+	// Verify marks the email the token was sent to as verified
+	Verify(ctx context.Context, token string) error
+}
+
+type emailVerificationService struct {
+	users    repository.Repository
+	tokens   repository.EmailVerificationRepository
+	notifier notify.Notifier
+	log      *logrus.Logger
+	now      func() time.Time
+}
+
+func NewEmailVerificationService(users repository.Repository, tokens repository.EmailVerificationRepository, notifier notify.Notifier) *emailVerificationService {
+	return &emailVerificationService{
+		users:    users,
+		tokens:   tokens,
+		notifier: notifier,
+		log:      l,
+		now:      time.Now,
+	}
+}
+
+func (e *emailVerificationService) SendVerification(ctx context.Context, userID int64) error {
+	e.log.Info("[EMAIL VERIFICATION SERVICE]: Send Verification")
+
+	user, err := e.users.UserById(ctx, userID)
+	if err != nil {
+		e.log.Errorf("EMAIL VERIFICATION SERVICE: error: %v", err)
+		return model.WrapError(err, "could not find user with id")
+	}
+
+	if user.Email == "" {
+		return model.InvalidArgumentError("user has no email address")
+	}
+	if user.EmailVerified() {
+		return model.AlreadyExistsError("email address already verified")
+	}
+
+	return e.send(ctx, user)
+}
+
+func (e *emailVerificationService) ResendVerification(ctx context.Context, email string) error {
+	e.log.Info("[EMAIL VERIFICATION SERVICE]: Resend Verification")
+
+	user, err := e.users.UserByEmail(ctx, model.NormalizeEmail(email))
+	if errors.Is(err, model.ErrNotFound) {
+		e.log.Info("email verification requested for unknown email")
+		return nil
+	}
+	if err != nil {
+		e.log.Errorf("EMAIL VERIFICATION SERVICE: error: %v", err)
+		return model.WrapError(err, "unable to send email verification")
+	}
+
+	if user.EmailVerified() {
+		return nil
+	}
+
+	return e.send(ctx, user)
+}
+
+func (e *emailVerificationService) Verify(ctx context.Context, token string) error {
+	e.log.Info("[EMAIL VERIFICATION SERVICE]: Verify")
+
+	if token == "" {
+		return model.ValidationError(model.FieldError{Field: "token", Message: "token is required"})
+	}
+
+	err := e.tokens.VerifyEmail(ctx, hashSecretToken(token), e.now())
+	if errors.Is(err, model.ErrNotFound) {
+		return model.InvalidArgumentError("invalid or expired verification token")
+	}
+	if err != nil {
+		e.log.Errorf("EMAIL VERIFICATION SERVICE: error: %v", err)
+		return model.WrapError(err, "unable to verify email")
+	}
+
+	return nil
+}
+
+func (e *emailVerificationService) send(ctx context.Context, user *model.User) error {
+	token, err := newSecretToken()
+	if err != nil {
+		return model.WrapError(err, "unable to send email verification")
+	}
+
+	err = e.tokens.CreateVerificationToken(ctx, user.ID, user.Email, hashSecretToken(token), e.now().Add(EmailVerificationTokenTTL))
+	if err != nil {
+		e.log.Errorf("EMAIL VERIFICATION SERVICE: error: %v", err)
+		return model.WrapError(err, "unable to send email verification")
+	}
+
+	err = e.notifier.SendEmailVerification(ctx, user, token)
+	if err != nil {
+		e.log.Errorf("EMAIL VERIFICATION SERVICE: error sending verification token: %v", err)
+		return model.WrapError(err, "unable to send email verification")
+	}
+
+	return nil
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockVerificationTokens keeps verification tokens in a map keyed by hash
+type mockVerificationTokens struct {
+	mu     sync.Mutex
+	tokens map[string]*model.EmailVerificationToken
+}
+
+func TestEmailVerificationService_SendVerification_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name string
+		id   int64
+		code model.ErrorCode
+	}{
+		{
+			name: "verification sent",
+			id:   1,
+		},
+		{
+			name: "email already verified",
+			id:   2,
+			code: model.CodeAlreadyExists,
+		},
+		{
+			name: "user does not exist",
+			id:   42,
+			code: model.CodeNotFound,
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			tokens := &mockVerificationTokens{tokens: map[string]*model.EmailVerificationToken{}}
+			notifier := &mockNotifier{}
+			service := NewEmailVerificationService(newTestRepository(t), tokens, notifier)
+
+			err := service.SendVerification(context.Background(), tc.id)
+			if tc.code != "" {
+				assert.Equal(t, tc.code, model.ErrorCodeOf(err))
+				assert.Empty(t, notifier.token)
+				return
+			}
+			assert.NoError(t, err)
+			stored, ok := tokens.tokens[hashSecretToken(notifier.token)]
+			assert.True(t, ok)
+			assert.Equal(t, tc.id, stored.UserID)
+			assert.Equal(t, "james@example.com", stored.Email)
+		})
+	}
+}
+
+func TestEmailVerificationService_ResendVerification_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name  string
+		email string
+		sent  bool
+	}{
+		{
+			name:  "verification resent",
+			email: "James@Example.com",
+			sent:  true,
+		},
+		{
+			name:  "email already verified",
+			email: "david@example.com",
+			sent:  false,
+		},
+		{
+			name:  "unknown email",
+			email: "john@example.com",
+			sent:  false,
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			tokens := &mockVerificationTokens{tokens: map[string]*model.EmailVerificationToken{}}
+			notifier := &mockNotifier{}
+			service := NewEmailVerificationService(newTestRepository(t), tokens, notifier)
+
+			err := service.ResendVerification(context.Background(), tc.email)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.sent, notifier.token != "")
+		})
+	}
+}
+
+func TestEmailVerificationService_Verify_Test_Cases(t *testing.T) {
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tt := []struct {
+		name   string
+		token  string
+		now    time.Time
+		errMsg string
+	}{
+		{
+			name:  "email verified",
+			token: "valid-token",
+			now:   now,
+		},
+		{
+			name:   "token expired",
+			token:  "valid-token",
+			now:    now.Add(EmailVerificationTokenTTL + time.Minute),
+			errMsg: "invalid or expired verification token",
+		},
+		{
+			name:   "unknown token",
+			token:  "unknown-token",
+			now:    now,
+			errMsg: "invalid or expired verification token",
+		},
+		{
+			name:   "missing token",
+			token:  "",
+			now:    now,
+			errMsg: "token is required",
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			tokens := &mockVerificationTokens{tokens: map[string]*model.EmailVerificationToken{}}
+			_ = tokens.CreateVerificationToken(context.Background(), 1, "james@example.com", hashSecretToken("valid-token"), now.Add(EmailVerificationTokenTTL))
+			service := NewEmailVerificationService(newTestRepository(t), tokens, &mockNotifier{})
+			service.now = func() time.Time { return tc.now }
+
+			err := service.Verify(context.Background(), tc.token)
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, err.Error())
+				assert.Equal(t, model.CodeInvalidArgument, model.ErrorCodeOf(err))
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestEmailVerificationService_Verify_Single_Use(t *testing.T) {
+	tokens := &mockVerificationTokens{tokens: map[string]*model.EmailVerificationToken{}}
+	notifier := &mockNotifier{}
+	service := NewEmailVerificationService(newTestRepository(t), tokens, notifier)
+
+	assert.NoError(t, service.SendVerification(context.Background(), 1))
+	assert.NoError(t, service.Verify(context.Background(), notifier.token))
+
+	err := service.Verify(context.Background(), notifier.token)
+	assert.Equal(t, "invalid or expired verification token", err.Error())
+}
+
+func (m *mockVerificationTokens) CreateVerificationToken(ctx context.Context, userID int64, email, tokenHash string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens[tokenHash] = &model.EmailVerificationToken{UserID: userID, Email: email, TokenHash: tokenHash, ExpiresAt: expiresAt}
+	return nil
+}
+
+func (m *mockVerificationTokens) VerifyEmail(ctx context.Context, tokenHash string, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	token, ok := m.tokens[tokenHash]
+	if !ok || token.UsedAt != nil || !token.ExpiresAt.After(now) {
+		return model.NotFoundError("verification token not found")
+	}
+	token.UsedAt = &now
+	return nil
+}
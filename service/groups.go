@@ -0,0 +1,271 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/JamieBShaw/user-service/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// maxGroupNameLength and maxGroupDescriptionLength are the sizes of the name and description columns of groups
+const (
+	maxGroupNameLength        = 100
+	maxGroupDescriptionLength = 500
+)
+
+// GroupService manages the groups of the organization of ctx and the users belonging to them
+type GroupService interface {
+	// Group returns the group with id
+	Group(ctx context.Context, id int64) (*model.Group, error)
+	// Groups returns a page of the groups of the organization
+	Groups(ctx context.Context, page model.Page) (*model.GroupPage, error)
+	// Create creates a group owned by owner, who must be a member of the organization
+	Create(ctx context.Context, ownerID int64, name, description string) (*model.Group, error)
+	// Delete deletes the group along with its memberships, the users who were members are left untouched
+	Delete(ctx context.Context, id int64) error
+	// AddMember adds the user to the group, adding a member again does nothing
+	AddMember(ctx context.Context, groupID, userID int64) error
+	// RemoveMember takes the user out of the group
+	RemoveMember(ctx context.Context, groupID, userID int64) error
+	// Members returns a page of the members of the group
+	Members(ctx context.Context, groupID int64, page model.Page) (*model.UserPage, error)
+	// UserGroups returns a page of the groups the user belongs to
+	UserGroups(ctx context.Context, userID int64, page model.Page) (*model.GroupPage, error)
+}
+
+type groupService struct {
+	users  repository.Repository
+	groups repository.GroupRepository
+	log    *logrus.Logger
+}
+
+func NewGroupService(users repository.Repository, groups repository.GroupRepository) *groupService {
+	return &groupService{
+		users:  users,
+		groups: groups,
+		log:    l,
+	}
+}
+
+func (g *groupService) Group(ctx context.Context, id int64) (*model.Group, error) {
+	g.log.Info("[GROUP SERVICE]: Get Group")
+
+	if id <= 0 {
+		return nil, model.InvalidArgumentError("invalid id")
+	}
+
+	group, err := g.groups.Group(ctx, id)
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		g.log.Errorf("GROUP SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "error getting group")
+	}
+
+	return group, nil
+}
+
+func (g *groupService) Groups(ctx context.Context, page model.Page) (*model.GroupPage, error) {
+	g.log.Info("[GROUP SERVICE]: Get Groups")
+
+	afterID, err := decodePageToken(page.Token)
+	if err != nil {
+		return nil, err
+	}
+	size := pageSize(page)
+
+	// Fetch one extra group to find out whether there is a next page
+	groups, err := g.groups.Groups(ctx, afterID, size+1)
+	if err != nil {
+		g.log.Errorf("GROUP SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "error getting groups")
+	}
+
+	return newGroupPage(groups, size), nil
+}
+
+func (g *groupService) Create(ctx context.Context, ownerID int64, name, description string) (*model.Group, error) {
+	g.log.Info("[GROUP SERVICE]: Create Group")
+
+	if ownerID <= 0 {
+		return nil, model.InvalidArgumentError("invalid owner id")
+	}
+
+	name = strings.TrimSpace(name)
+	description = strings.TrimSpace(description)
+
+	var fields []model.FieldError
+	if name == "" {
+		fields = append(fields, model.FieldError{Field: "name", Message: "name is required"})
+	} else if utf8.RuneCountInString(name) > maxGroupNameLength {
+		fields = append(fields, model.FieldError{Field: "name", Message: "name must be at most 100 characters"})
+	}
+	if utf8.RuneCountInString(description) > maxGroupDescriptionLength {
+		fields = append(fields, model.FieldError{Field: "description", Message: "description must be at most 500 characters"})
+	}
+	if len(fields) > 0 {
+		return nil, model.ValidationError(fields...)
+	}
+
+	group, err := g.groups.CreateGroup(ctx, name, description, ownerID)
+	if errors.Is(err, model.ErrNotFound) || errors.Is(err, model.ErrAlreadyExists) {
+		return nil, err
+	}
+	if err != nil {
+		g.log.Errorf("GROUP SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "error creating group")
+	}
+
+	g.log.Infof("[GROUP SERVICE]: Group %d created for user %d", group.ID, ownerID)
+
+	return group, nil
+}
+
+func (g *groupService) Delete(ctx context.Context, id int64) error {
+	g.log.Info("[GROUP SERVICE]: Delete Group")
+
+	if id <= 0 {
+		return model.InvalidArgumentError("invalid id")
+	}
+
+	err := g.groups.DeleteGroup(ctx, id)
+	if errors.Is(err, model.ErrNotFound) {
+		return err
+	}
+	if err != nil {
+		g.log.Errorf("GROUP SERVICE: error: %v", err)
+		return model.WrapError(err, "error deleting group")
+	}
+
+	g.log.Infof("[GROUP SERVICE]: Group %d deleted", id)
+
+	return nil
+}
+
+func (g *groupService) AddMember(ctx context.Context, groupID, userID int64) error {
+	g.log.Info("[GROUP SERVICE]: Add Group Member")
+
+	if err := validateGroupMember(groupID, userID); err != nil {
+		return err
+	}
+
+	err := g.groups.AddGroupMember(ctx, groupID, userID)
+	// The repository tells whether the group or the user is missing
+	if errors.Is(err, model.ErrNotFound) {
+		return err
+	}
+	if err != nil {
+		g.log.Errorf("GROUP SERVICE: error: %v", err)
+		return model.WrapError(err, "error adding group member")
+	}
+
+	g.log.Infof("[GROUP SERVICE]: User %d added to group %d", userID, groupID)
+
+	return nil
+}
+
+func (g *groupService) RemoveMember(ctx context.Context, groupID, userID int64) error {
+	g.log.Info("[GROUP SERVICE]: Remove Group Member")
+
+	if err := validateGroupMember(groupID, userID); err != nil {
+		return err
+	}
+
+	err := g.groups.RemoveGroupMember(ctx, groupID, userID)
+	if errors.Is(err, model.ErrNotFound) {
+		return err
+	}
+	if err != nil {
+		g.log.Errorf("GROUP SERVICE: error: %v", err)
+		return model.WrapError(err, "error removing group member")
+	}
+
+	g.log.Infof("[GROUP SERVICE]: User %d removed from group %d", userID, groupID)
+
+	return nil
+}
+
+func (g *groupService) Members(ctx context.Context, groupID int64, page model.Page) (*model.UserPage, error) {
+	g.log.Info("[GROUP SERVICE]: Get Group Members")
+
+	if groupID <= 0 {
+		return nil, model.InvalidArgumentError("invalid id")
+	}
+
+	afterID, err := decodePageToken(page.Token)
+	if err != nil {
+		return nil, err
+	}
+	size := pageSize(page)
+
+	// Fetch one extra member to find out whether there is a next page
+	users, err := g.groups.GroupMembers(ctx, groupID, afterID, size+1)
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		g.log.Errorf("GROUP SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "error getting group members")
+	}
+
+	res := &model.UserPage{Users: users}
+	if len(users) > size {
+		res.Users = users[:size]
+		res.NextPageToken = encodePageToken(res.Users[size-1].ID)
+	}
+
+	return res, nil
+}
+
+func (g *groupService) UserGroups(ctx context.Context, userID int64, page model.Page) (*model.GroupPage, error) {
+	g.log.Info("[GROUP SERVICE]: Get User Groups")
+
+	if userID <= 0 {
+		return nil, model.InvalidArgumentError("invalid id")
+	}
+
+	afterID, err := decodePageToken(page.Token)
+	if err != nil {
+		return nil, err
+	}
+	size := pageSize(page)
+
+	// Only members of the organization of the request can be looked up
+	if _, err := g.users.UserById(ctx, userID); err != nil {
+		g.log.Errorf("GROUP SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "could not find user with id")
+	}
+
+	groups, err := g.groups.UserGroups(ctx, userID, afterID, size+1)
+	if err != nil {
+		g.log.Errorf("GROUP SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "error getting groups")
+	}
+
+	return newGroupPage(groups, size), nil
+}
+
+// newGroupPage trims groups, fetched with one extra group, to size and points the next page after the last group kept
+func newGroupPage(groups []*model.Group, size int) *model.GroupPage {
+	res := &model.GroupPage{Groups: groups}
+	if len(groups) > size {
+		res.Groups = groups[:size]
+		res.NextPageToken = encodePageToken(res.Groups[size-1].ID)
+	}
+	return res
+}
+
+func validateGroupMember(groupID, userID int64) error {
+	if groupID <= 0 {
+		return model.InvalidArgumentError("invalid id")
+	}
+	if userID <= 0 {
+		return model.InvalidArgumentError("invalid user id")
+	}
+	return nil
+}
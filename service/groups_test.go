@@ -0,0 +1,337 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingGroupDb fails every call with err
+type failingGroupDb struct {
+	err error
+}
+
+func TestGroupService_Create_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name        string
+		ownerID     int64
+		groupName   string
+		description string
+		dbErr       error
+		errMsg      string
+		code        model.ErrorCode
+	}{
+		{
+			name:        "group created",
+			ownerID:     1,
+			groupName:   " Design ",
+			description: "Product designers",
+		},
+		{
+			name:      "missing name",
+			ownerID:   1,
+			groupName: "  ",
+			errMsg:    "name is required",
+			code:      model.CodeInvalidArgument,
+		},
+		{
+			name:        "description too long",
+			ownerID:     1,
+			groupName:   "Design",
+			description: strings.Repeat("a", 501),
+			errMsg:      "description must be at most 500 characters",
+			code:        model.CodeInvalidArgument,
+		},
+		{
+			name:      "invalid owner",
+			groupName: "Design",
+			errMsg:    "invalid owner id",
+			code:      model.CodeInvalidArgument,
+		},
+		{
+			name:      "name taken",
+			ownerID:   1,
+			groupName: "Engineering",
+			errMsg:    "group already exists",
+			code:      model.CodeAlreadyExists,
+		},
+		{
+			name:      "owner not found",
+			ownerID:   42,
+			groupName: "Design",
+			errMsg:    "user not found",
+			code:      model.CodeNotFound,
+		},
+		{
+			name:      "repository error",
+			ownerID:   1,
+			groupName: "Design",
+			dbErr:     errors.New("pq: connection refused"),
+			errMsg:    "error creating group",
+			code:      model.CodeInternal,
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			service := newGroupTestService(t, tc.dbErr)
+
+			group, err := service.Create(context.Background(), tc.ownerID, tc.groupName, tc.description)
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, err.Error())
+				assert.Equal(t, tc.code, model.ErrorCodeOf(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "Design", group.Name)
+			assert.Equal(t, tc.ownerID, group.OwnerID)
+		})
+	}
+}
+
+func TestGroupService_Members_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name          string
+		groupID       int64
+		page          model.Page
+		ids           []int64
+		nextPageToken bool
+		errMsg        string
+		code          model.ErrorCode
+	}{
+		{
+			name:    "every member",
+			groupID: 1,
+			ids:     []int64{2, 3},
+		},
+		{
+			name:          "first page",
+			groupID:       1,
+			page:          model.Page{Size: 1},
+			ids:           []int64{2},
+			nextPageToken: true,
+		},
+		{
+			name:    "next page",
+			groupID: 1,
+			page:    model.Page{Size: 1, Token: encodePageToken(2)},
+			ids:     []int64{3},
+		},
+		{
+			name:    "group without members",
+			groupID: 2,
+		},
+		{
+			name:    "unknown group",
+			groupID: 42,
+			errMsg:  "group not found",
+			code:    model.CodeNotFound,
+		},
+		{
+			name:    "invalid page token",
+			groupID: 1,
+			page:    model.Page{Token: "not-a-token"},
+			errMsg:  "invalid page token",
+			code:    model.CodeInvalidArgument,
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			service := newGroupTestService(t, nil)
+
+			page, err := service.Members(context.Background(), tc.groupID, tc.page)
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, err.Error())
+				assert.Equal(t, tc.code, model.ErrorCodeOf(err))
+				return
+			}
+			assert.NoError(t, err)
+			var ids []int64
+			for _, user := range page.Users {
+				ids = append(ids, user.ID)
+			}
+			assert.Equal(t, tc.ids, ids)
+			assert.Equal(t, tc.nextPageToken, page.NextPageToken != "")
+		})
+	}
+}
+
+func TestGroupService_UserGroups_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name   string
+		userID int64
+		groups []string
+		errMsg string
+		code   model.ErrorCode
+	}{
+		{
+			name:   "member of a group",
+			userID: 3,
+			groups: []string{"Engineering"},
+		},
+		{
+			name:   "user not found",
+			userID: 42,
+			errMsg: "could not find user with id",
+			code:   model.CodeNotFound,
+		},
+		{
+			name:   "invalid id",
+			userID: -1,
+			errMsg: "invalid id",
+			code:   model.CodeInvalidArgument,
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			service := newGroupTestService(t, nil)
+
+			page, err := service.UserGroups(context.Background(), tc.userID, model.Page{})
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, err.Error())
+				assert.Equal(t, tc.code, model.ErrorCodeOf(err))
+				return
+			}
+			assert.NoError(t, err)
+			var groups []string
+			for _, group := range page.Groups {
+				groups = append(groups, group.Name)
+			}
+			assert.Equal(t, tc.groups, groups)
+		})
+	}
+}
+
+func TestGroupService_Membership_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name    string
+		remove  bool
+		groupID int64
+		userID  int64
+		dbErr   error
+		errMsg  string
+		code    model.ErrorCode
+	}{
+		{
+			name:    "member added",
+			groupID: 2,
+			userID:  2,
+		},
+		{
+			name:    "member removed",
+			remove:  true,
+			groupID: 1,
+			userID:  2,
+		},
+		{
+			name:    "remove a user who is not a member",
+			remove:  true,
+			groupID: 2,
+			userID:  2,
+			errMsg:  "user is not a member of the group",
+			code:    model.CodeNotFound,
+		},
+		{
+			name:    "invalid user id",
+			groupID: 1,
+			errMsg:  "invalid user id",
+			code:    model.CodeInvalidArgument,
+		},
+		{
+			name:    "repository error",
+			groupID: 1,
+			userID:  2,
+			dbErr:   errors.New("pq: connection refused"),
+			errMsg:  "error adding group member",
+			code:    model.CodeInternal,
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			service := newGroupTestService(t, tc.dbErr)
+
+			var err error
+			if tc.remove {
+				err = service.RemoveMember(context.Background(), tc.groupID, tc.userID)
+			} else {
+				err = service.AddMember(context.Background(), tc.groupID, tc.userID)
+			}
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, err.Error())
+				assert.Equal(t, tc.code, model.ErrorCodeOf(err))
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+// newGroupTestService runs against the test repository with Engineering (id 1), owned by James (id 1) with the
+// members David (id 2) and Michael (id 3), and Support (id 2), owned by David without members. Its groups fail
+// with dbErr when it is set.
+func newGroupTestService(t *testing.T, dbErr error) *groupService {
+	t.Helper()
+	ctx := context.Background()
+	db := newTestRepository(t)
+
+	engineering, err := db.CreateGroup(ctx, "Engineering", "", 1)
+	if err != nil {
+		t.Fatalf("could not create group: %v", err)
+	}
+	if _, err := db.CreateGroup(ctx, "Support", "", 2); err != nil {
+		t.Fatalf("could not create group: %v", err)
+	}
+	for _, userID := range []int64{2, 3} {
+		if err := db.AddGroupMember(ctx, engineering.ID, userID); err != nil {
+			t.Fatalf("could not add group member: %v", err)
+		}
+	}
+
+	if dbErr != nil {
+		return NewGroupService(db, failingGroupDb{err: dbErr})
+	}
+	return NewGroupService(db, db)
+}
+
+func (m failingGroupDb) Group(ctx context.Context, id int64) (*model.Group, error) {
+	return nil, m.err
+}
+
+func (m failingGroupDb) CreateGroup(ctx context.Context, name, description string, ownerID int64) (*model.Group, error) {
+	return nil, m.err
+}
+
+func (m failingGroupDb) DeleteGroup(ctx context.Context, id int64) error {
+	return m.err
+}
+
+func (m failingGroupDb) Groups(ctx context.Context, afterID int64, limit int) ([]*model.Group, error) {
+	return nil, m.err
+}
+
+func (m failingGroupDb) AddGroupMember(ctx context.Context, groupID, userID int64) error {
+	return m.err
+}
+
+func (m failingGroupDb) RemoveGroupMember(ctx context.Context, groupID, userID int64) error {
+	return m.err
+}
+
+func (m failingGroupDb) GroupMembers(ctx context.Context, groupID, afterID int64, limit int) ([]*model.User, error) {
+	return nil, m.err
+}
+
+func (m failingGroupDb) UserGroups(ctx context.Context, userID, afterID int64, limit int) ([]*model.Group, error) {
+	return nil, m.err
+}
@@ -0,0 +1,299 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/JamieBShaw/user-service/notify"
+	"github.com/JamieBShaw/user-service/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// InvitationTokenTTL is how long an invitation can be accepted for
+const InvitationTokenTTL = 7 * 24 * time.Hour
+
+// InvitationService invites people to register in the organization of ctx, it is the only way to register when
+// Options.InviteOnly is set. Users of other organizations join it with the invitation instead.
+type InvitationService interface {
+	// Invite sends an invitation from the user invitedBy to email, the invitee is granted role once they accepted
+	// when it is not empty. Inviting an email again replaces its pending invitation.
+	Invite(ctx context.Context, invitedBy int64, email, role string) (*model.Invitation, error)
+	// Accept registers the invitee with the email of the invitation in the organization they were invited to
+	Accept(ctx context.Context, token, username, password string) (*model.User, error)
+	// Join makes the user, a member of the organization of ctx, a member of the organization they were invited to
+	// and returns it. The invitation must have been sent to the verified email of the user.
+	Join(ctx context.Context, token string, userID int64) (*model.Organization, error)
+}
+
+type invitationService struct {
+	users         UserService
+	db            repository.Repository
+	invitations   repository.InvitationRepository
+	roles         repository.RoleRepository
+	organizations repository.OrganizationRepository
+	verifications repository.EmailVerificationRepository
+	notifier      notify.Notifier
+	log           *logrus.Logger
+	now           func() time.Time
+}
+
+func NewInvitationService(users UserService, db repository.Repository, invitations repository.InvitationRepository, roles repository.RoleRepository, organizations repository.OrganizationRepository, verifications repository.EmailVerificationRepository, notifier notify.Notifier) *invitationService {
+	return &invitationService{
+		users:         users,
+		db:            db,
+		invitations:   invitations,
+		roles:         roles,
+		organizations: organizations,
+		verifications: verifications,
+		notifier:      notifier,
+		log:           l,
+		now:           time.Now,
+	}
+}
+
+type invitedKey struct{}
+
+// withInvitation marks ctx as accepting an invitation, which registers users even when registration is invite only
+func withInvitation(ctx context.Context) context.Context {
+	return context.WithValue(ctx, invitedKey{}, true)
+}
+
+func invitedFromContext(ctx context.Context) bool {
+	invited, _ := ctx.Value(invitedKey{}).(bool)
+	return invited
+}
+
+func (i *invitationService) Invite(ctx context.Context, invitedBy int64, email, role string) (*model.Invitation, error) {
+	i.log.Info("[INVITATION SERVICE]: Invite")
+
+	if invitedBy <= 0 {
+		return nil, model.InvalidArgumentError("invalid inviter id")
+	}
+
+	email = model.NormalizeEmail(email)
+	role = strings.TrimSpace(role)
+
+	if err := validateEmail(email); err != nil {
+		return nil, model.ValidationError(model.FieldError{Field: "email", Message: err.Error()})
+	}
+	if role != "" {
+		known, err := i.knownRole(ctx, role)
+		if err != nil {
+			return nil, err
+		}
+		if !known {
+			return nil, model.ValidationError(model.FieldError{Field: "role", Message: "unknown role"})
+		}
+	}
+
+	_, err := i.db.UserByEmail(ctx, email)
+	if err == nil {
+		return nil, model.AlreadyExistsError("email already exists")
+	}
+	if !errors.Is(err, model.ErrNotFound) {
+		i.log.Errorf("INVITATION SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "error creating invitation")
+	}
+
+	token, err := newSecretToken()
+	if err != nil {
+		return nil, model.WrapError(err, "error creating invitation")
+	}
+
+	invitation, err := i.invitations.CreateInvitation(ctx, &model.Invitation{
+		Email:     email,
+		Role:      role,
+		InvitedBy: invitedBy,
+		TokenHash: hashSecretToken(token),
+		ExpiresAt: i.now().Add(InvitationTokenTTL),
+	})
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		i.log.Errorf("INVITATION SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "error creating invitation")
+	}
+
+	err = i.notifier.SendInvitation(ctx, invitation, token)
+	if err != nil {
+		i.log.Errorf("INVITATION SERVICE: error sending invitation token: %v", err)
+		return nil, model.WrapError(err, "unable to send invitation")
+	}
+
+	i.log.Infof("[INVITATION SERVICE]: Invitation %d sent by user %d", invitation.ID, invitedBy)
+
+	return invitation, nil
+}
+
+func (i *invitationService) Accept(ctx context.Context, token, username, password string) (*model.User, error) {
+	i.log.Info("[INVITATION SERVICE]: Accept")
+
+	if token == "" {
+		return nil, model.ValidationError(model.FieldError{Field: "token", Message: "token is required"})
+	}
+
+	// Accepting first means concurrent requests with the same token can not register more than one user
+	invitation, err := i.invitations.AcceptInvitation(ctx, hashSecretToken(token), i.now())
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, model.InvalidArgumentError("invalid or expired invitation token")
+	}
+	if err != nil {
+		i.log.Errorf("INVITATION SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "unable to accept invitation")
+	}
+
+	ctx = withInvitation(model.WithOrganization(ctx, invitation.OrganizationID))
+
+	user, err := i.users.Create(ctx, username, invitation.Email, password)
+	if err != nil {
+		// The invitee can try again, with another username for example
+		i.reopen(ctx, invitation)
+		return nil, err
+	}
+
+	if invitation.Role != "" {
+		err = i.roles.AssignRole(ctx, user.ID, invitation.Role)
+		if err != nil {
+			i.log.Errorf("INVITATION SERVICE: error: %v", err)
+			// Registering without the role would use the invitation up, so the user goes and the invitation stays open
+			if purgeErr := i.db.Purge(ctx, user.ID); purgeErr != nil {
+				i.log.Errorf("INVITATION SERVICE: error purging user %d: %v", user.ID, purgeErr)
+			} else {
+				i.reopen(ctx, invitation)
+			}
+			return nil, model.WrapError(err, "error assigning role of invitation")
+		}
+	}
+
+	// The invitee received the token at the email of the invitation, so it needs no further verification. They can
+	// still verify it themselves when this fails.
+	if err := i.verifyEmail(ctx, user); err != nil {
+		i.log.Errorf("INVITATION SERVICE: error verifying email of user %d: %v", user.ID, err)
+	}
+
+	i.log.Infof("[INVITATION SERVICE]: Invitation %d accepted by user %d", invitation.ID, user.ID)
+
+	return user, nil
+}
+
+func (i *invitationService) Join(ctx context.Context, token string, userID int64) (*model.Organization, error) {
+	i.log.Info("[INVITATION SERVICE]: Join")
+
+	if token == "" {
+		return nil, model.ValidationError(model.FieldError{Field: "token", Message: "token is required"})
+	}
+	if userID <= 0 {
+		return nil, model.InvalidArgumentError("invalid id")
+	}
+
+	user, err := i.db.UserById(ctx, userID)
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		i.log.Errorf("INVITATION SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "could not find user with id")
+	}
+
+	invitation, err := i.invitations.AcceptInvitation(ctx, hashSecretToken(token), i.now())
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, model.InvalidArgumentError("invalid or expired invitation token")
+	}
+	if err != nil {
+		i.log.Errorf("INVITATION SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "unable to accept invitation")
+	}
+
+	// Users are only ever added to another organization with their consent, proven by holding the token sent to
+	// their own verified email
+	if user.Email != invitation.Email {
+		i.reopen(ctx, invitation)
+		return nil, model.PermissionDeniedError("invitation was sent to another email address")
+	}
+	if !user.EmailVerified() {
+		i.reopen(ctx, invitation)
+		return nil, model.PermissionDeniedError("email address must be verified to accept an invitation")
+	}
+
+	member, err := i.organizations.IsMember(ctx, invitation.OrganizationID, user.ID)
+	if err != nil {
+		i.log.Errorf("INVITATION SERVICE: error: %v", err)
+		i.reopen(ctx, invitation)
+		return nil, model.WrapError(err, "unable to accept invitation")
+	}
+	if member {
+		i.reopen(ctx, invitation)
+		return nil, model.AlreadyExistsError("user is already a member of the organization")
+	}
+
+	err = i.organizations.AddMember(ctx, invitation.OrganizationID, user.ID)
+	if err != nil {
+		i.log.Errorf("INVITATION SERVICE: error: %v", err)
+		i.reopen(ctx, invitation)
+		return nil, model.WrapError(err, "error adding organization member")
+	}
+
+	if invitation.Role != "" {
+		err = i.roles.AssignRole(model.WithOrganization(ctx, invitation.OrganizationID), user.ID, invitation.Role)
+		if err != nil {
+			i.log.Errorf("INVITATION SERVICE: error: %v", err)
+			// Like a registration, joining without the role would use the invitation up
+			if removeErr := i.organizations.RemoveMember(ctx, invitation.OrganizationID, user.ID); removeErr != nil {
+				i.log.Errorf("INVITATION SERVICE: error removing user %d: %v", user.ID, removeErr)
+			} else {
+				i.reopen(ctx, invitation)
+			}
+			return nil, model.WrapError(err, "error assigning role of invitation")
+		}
+	}
+
+	organization, err := i.organizations.Organization(ctx, invitation.OrganizationID)
+	if err != nil {
+		i.log.Errorf("INVITATION SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "error getting organization")
+	}
+
+	i.log.Infof("[INVITATION SERVICE]: Invitation %d accepted by user %d of organization %d", invitation.ID, user.ID, user.OrganizationID)
+
+	return organization, nil
+}
+
+// reopen lets the invitation be accepted again after accepting it failed
+func (i *invitationService) reopen(ctx context.Context, invitation *model.Invitation) {
+	if err := i.invitations.ReopenInvitation(ctx, invitation.ID); err != nil {
+		i.log.Errorf("INVITATION SERVICE: error reopening invitation %d: %v", invitation.ID, err)
+	}
+}
+
+func (i *invitationService) knownRole(ctx context.Context, name string) (bool, error) {
+	roles, err := i.roles.Roles(ctx)
+	if err != nil {
+		i.log.Errorf("INVITATION SERVICE: error: %v", err)
+		return false, model.WrapError(err, "error creating invitation")
+	}
+	for _, role := range roles {
+		if role.Name == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (i *invitationService) verifyEmail(ctx context.Context, user *model.User) error {
+	token, err := newSecretToken()
+	if err != nil {
+		return err
+	}
+	hash := hashSecretToken(token)
+	now := i.now()
+
+	err = i.verifications.CreateVerificationToken(ctx, user.ID, user.Email, hash, now.Add(EmailVerificationTokenTTL))
+	if err != nil {
+		return err
+	}
+	return i.verifications.VerifyEmail(ctx, hash, now)
+}
@@ -0,0 +1,409 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/JamieBShaw/user-service/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockInvitations keeps invitations in a map keyed by token hash, only users found in users can invite
+type mockInvitations struct {
+	mu          sync.Mutex
+	users       repository.Repository
+	invitations map[string]*model.Invitation
+}
+
+func TestInvitationService_Invite_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name      string
+		invitedBy int64
+		email     string
+		role      string
+		rolesErr  error
+		errMsg    string
+		code      model.ErrorCode
+	}{
+		{
+			name:      "email invited",
+			invitedBy: 1,
+			email:     " New@Example.com ",
+		},
+		{
+			name:      "email invited with a role",
+			invitedBy: 1,
+			email:     "new@example.com",
+			role:      "support",
+		},
+		{
+			name:      "unknown role",
+			invitedBy: 1,
+			email:     "new@example.com",
+			role:      "owner",
+			errMsg:    "unknown role",
+			code:      model.CodeInvalidArgument,
+		},
+		{
+			name:      "invalid email",
+			invitedBy: 1,
+			email:     "new",
+			errMsg:    "email invalid",
+			code:      model.CodeInvalidArgument,
+		},
+		{
+			name:      "email of a registered user",
+			invitedBy: 1,
+			email:     "david@example.com",
+			errMsg:    "email already exists",
+			code:      model.CodeAlreadyExists,
+		},
+		{
+			name:   "invalid inviter",
+			email:  "new@example.com",
+			errMsg: "invalid inviter id",
+			code:   model.CodeInvalidArgument,
+		},
+		{
+			name:      "inviter not found",
+			invitedBy: 42,
+			email:     "new@example.com",
+			errMsg:    "user not found",
+			code:      model.CodeNotFound,
+		},
+		{
+			name:      "roles can not be loaded",
+			invitedBy: 1,
+			email:     "new@example.com",
+			role:      "support",
+			rolesErr:  errors.New("pq: connection refused"),
+			errMsg:    "error creating invitation",
+			code:      model.CodeInternal,
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			db := newTestRepository(t)
+			invitations := &mockInvitations{users: db, invitations: make(map[string]*model.Invitation)}
+			notifier := &mockNotifier{}
+			var roles repository.RoleRepository = db
+			if tc.rolesErr != nil {
+				roles = failingRoleDb{err: tc.rolesErr}
+			}
+			service := NewInvitationService(NewUserService(db, nil, Options{Hasher: testHasher}), db, invitations, roles, db, nil, notifier)
+			now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+			service.now = func() time.Time { return now }
+
+			invitation, err := service.Invite(context.Background(), tc.invitedBy, tc.email, tc.role)
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, err.Error())
+				assert.Equal(t, tc.code, model.ErrorCodeOf(err))
+				assert.Empty(t, notifier.token)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "new@example.com", invitation.Email)
+			assert.Equal(t, tc.role, invitation.Role)
+			assert.Equal(t, now.Add(InvitationTokenTTL), invitation.ExpiresAt)
+			// Only the hash of the token that was sent is stored
+			assert.NotEmpty(t, notifier.token)
+			assert.Equal(t, hashSecretToken(notifier.token), invitation.TokenHash)
+		})
+	}
+}
+
+func TestInvitationService_Accept_Test_Cases(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	acceptedAt := now.Add(-time.Hour)
+
+	tt := []struct {
+		name       string
+		invitation *model.Invitation
+		token      string
+		username   string
+		errMsg     string
+		code       model.ErrorCode
+		reopened   bool
+		purged     bool
+	}{
+		{
+			name:       "invitation accepted",
+			invitation: &model.Invitation{ID: 1, Email: "new@example.com", ExpiresAt: now.Add(time.Hour)},
+			token:      "invitation-token",
+			username:   "newuser",
+		},
+		{
+			name:       "invitation with a role accepted",
+			invitation: &model.Invitation{ID: 1, Email: "new@example.com", Role: "support", ExpiresAt: now.Add(time.Hour)},
+			token:      "invitation-token",
+			username:   "newuser",
+		},
+		{
+			name:       "taken username leaves the invitation open",
+			invitation: &model.Invitation{ID: 1, Email: "new@example.com", ExpiresAt: now.Add(time.Hour)},
+			token:      "invitation-token",
+			username:   "David",
+			errMsg:     "username already exists",
+			code:       model.CodeAlreadyExists,
+			reopened:   true,
+		},
+		{
+			name:       "user of a role that can not be assigned is purged and the invitation left open",
+			invitation: &model.Invitation{ID: 1, Email: "new@example.com", Role: "owner", ExpiresAt: now.Add(time.Hour)},
+			token:      "invitation-token",
+			username:   "newuser",
+			errMsg:     "error assigning role of invitation",
+			code:       model.CodeNotFound,
+			reopened:   true,
+			purged:     true,
+		},
+		{
+			name:       "expired invitation",
+			invitation: &model.Invitation{ID: 1, Email: "new@example.com", ExpiresAt: now.Add(-time.Hour)},
+			token:      "invitation-token",
+			username:   "newuser",
+			errMsg:     "invalid or expired invitation token",
+			code:       model.CodeInvalidArgument,
+		},
+		{
+			name:       "invitation already accepted",
+			invitation: &model.Invitation{ID: 1, Email: "new@example.com", ExpiresAt: now.Add(time.Hour), AcceptedAt: &acceptedAt},
+			token:      "invitation-token",
+			username:   "newuser",
+			errMsg:     "invalid or expired invitation token",
+			code:       model.CodeInvalidArgument,
+		},
+		{
+			name:     "unknown token",
+			token:    "unknown-token",
+			username: "newuser",
+			errMsg:   "invalid or expired invitation token",
+			code:     model.CodeInvalidArgument,
+		},
+		{
+			name:     "missing token",
+			username: "newuser",
+			errMsg:   "token is required",
+			code:     model.CodeInvalidArgument,
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			db := newTestRepository(t)
+			invitations := &mockInvitations{users: db, invitations: make(map[string]*model.Invitation)}
+			if tc.invitation != nil {
+				tc.invitation.TokenHash = hashSecretToken("invitation-token")
+				invitations.invitations[tc.invitation.TokenHash] = tc.invitation
+			}
+			verifications := &mockVerificationTokens{tokens: make(map[string]*model.EmailVerificationToken)}
+			// Invited users register when registration is invite only and need no approval
+			users := NewUserService(db, nil, Options{Hasher: testHasher, InviteOnly: true, RequireApproval: true})
+			service := NewInvitationService(users, db, invitations, db, db, verifications, &mockNotifier{})
+			service.now = func() time.Time { return now }
+
+			user, err := service.Accept(context.Background(), tc.token, tc.username, "Password1")
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, err.Error())
+				assert.Equal(t, tc.code, model.ErrorCodeOf(err))
+				if tc.invitation != nil && tc.reopened {
+					assert.Nil(t, tc.invitation.AcceptedAt)
+				}
+				if tc.purged {
+					_, err := db.UserByUsername(context.Background(), tc.username)
+					assert.Equal(t, model.CodeNotFound, model.ErrorCodeOf(err))
+					assert.Empty(t, verifications.tokens)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "new@example.com", user.Email)
+			assert.Equal(t, model.StatusActive, user.Status)
+			assert.Equal(t, &now, tc.invitation.AcceptedAt)
+			stored, err := db.UserById(context.Background(), user.ID)
+			assert.NoError(t, err)
+			if tc.invitation.Role == "" {
+				assert.Empty(t, stored.Roles)
+			} else {
+				assert.Equal(t, []string{tc.invitation.Role}, stored.Roles)
+			}
+			// The email the invitation was sent to is verified
+			assert.Len(t, verifications.tokens, 1)
+			for _, token := range verifications.tokens {
+				assert.Equal(t, "new@example.com", token.Email)
+				assert.NotNil(t, token.UsedAt)
+			}
+		})
+	}
+}
+
+func TestInvitationService_Join_Test_Cases(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tt := []struct {
+		name       string
+		invitation *model.Invitation
+		token      string
+		userID     int64
+		errMsg     string
+		code       model.ErrorCode
+		reopened   bool
+	}{
+		{
+			name:       "invitation to another organization accepted",
+			invitation: &model.Invitation{ID: 1, OrganizationID: 2, Email: "david@example.com", ExpiresAt: now.Add(time.Hour)},
+			token:      "invitation-token",
+			userID:     2,
+		},
+		{
+			name:       "invitation with a role accepted",
+			invitation: &model.Invitation{ID: 1, OrganizationID: 2, Email: "david@example.com", Role: "support", ExpiresAt: now.Add(time.Hour)},
+			token:      "invitation-token",
+			userID:     2,
+		},
+		{
+			name:       "invitation sent to another email",
+			invitation: &model.Invitation{ID: 1, OrganizationID: 2, Email: "new@example.com", ExpiresAt: now.Add(time.Hour)},
+			token:      "invitation-token",
+			userID:     2,
+			errMsg:     "invitation was sent to another email address",
+			code:       model.CodePermissionDenied,
+			reopened:   true,
+		},
+		{
+			name:       "email of the user not verified",
+			invitation: &model.Invitation{ID: 1, OrganizationID: 2, Email: "michael@example.com", ExpiresAt: now.Add(time.Hour)},
+			token:      "invitation-token",
+			userID:     3,
+			errMsg:     "email address must be verified to accept an invitation",
+			code:       model.CodePermissionDenied,
+			reopened:   true,
+		},
+		{
+			name:       "user already a member",
+			invitation: &model.Invitation{ID: 1, OrganizationID: model.DefaultOrganizationID, Email: "david@example.com", ExpiresAt: now.Add(time.Hour)},
+			token:      "invitation-token",
+			userID:     2,
+			errMsg:     "user is already a member of the organization",
+			code:       model.CodeAlreadyExists,
+			reopened:   true,
+		},
+		{
+			name:       "member of a role that can not be assigned is removed and the invitation left open",
+			invitation: &model.Invitation{ID: 1, OrganizationID: 2, Email: "david@example.com", Role: "owner", ExpiresAt: now.Add(time.Hour)},
+			token:      "invitation-token",
+			userID:     2,
+			errMsg:     "error assigning role of invitation",
+			code:       model.CodeNotFound,
+			reopened:   true,
+		},
+		{
+			name:   "unknown token",
+			token:  "unknown-token",
+			userID: 2,
+			errMsg: "invalid or expired invitation token",
+			code:   model.CodeInvalidArgument,
+		},
+		{
+			name:   "missing token",
+			userID: 2,
+			errMsg: "token is required",
+			code:   model.CodeInvalidArgument,
+		},
+		{
+			name:   "unknown user",
+			token:  "invitation-token",
+			userID: 42,
+			errMsg: "user not found",
+			code:   model.CodeNotFound,
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			db := newTestRepository(t)
+			// James (id 1) created acme (id 2), David (id 2) verified their email and Michael (id 3) did not
+			if _, err := db.CreateOrganization(ctx, "acme", "Acme", 1); err != nil {
+				t.Fatalf("could not create organization: %v", err)
+			}
+			invitations := &mockInvitations{users: db, invitations: make(map[string]*model.Invitation)}
+			if tc.invitation != nil {
+				tc.invitation.TokenHash = hashSecretToken("invitation-token")
+				invitations.invitations[tc.invitation.TokenHash] = tc.invitation
+			}
+			service := NewInvitationService(NewUserService(db, nil, Options{Hasher: testHasher}), db, invitations, db, db, nil, &mockNotifier{})
+			service.now = func() time.Time { return now }
+
+			organization, err := service.Join(ctx, tc.token, tc.userID)
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, err.Error())
+				assert.Equal(t, tc.code, model.ErrorCodeOf(err))
+				if tc.reopened {
+					assert.Nil(t, tc.invitation.AcceptedAt)
+				}
+				// Nobody joins acme without accepting a valid invitation to it
+				member, err := db.IsMember(ctx, 2, tc.userID)
+				assert.NoError(t, err)
+				assert.False(t, member)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "acme", organization.Slug)
+			assert.Equal(t, &now, tc.invitation.AcceptedAt)
+			member, err := db.IsMember(ctx, organization.ID, tc.userID)
+			assert.NoError(t, err)
+			assert.True(t, member)
+			stored, err := db.UserById(model.WithOrganization(ctx, organization.ID), tc.userID)
+			assert.NoError(t, err)
+			if tc.invitation.Role == "" {
+				assert.Empty(t, stored.Roles)
+			} else {
+				assert.Equal(t, []string{tc.invitation.Role}, stored.Roles)
+			}
+		})
+	}
+}
+
+func (m *mockInvitations) CreateInvitation(ctx context.Context, invitation *model.Invitation) (*model.Invitation, error) {
+	if _, err := m.users.UserById(ctx, invitation.InvitedBy); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := *invitation
+	c.ID = int64(len(m.invitations) + 1)
+	m.invitations[c.TokenHash] = &c
+	return &c, nil
+}
+
+func (m *mockInvitations) AcceptInvitation(ctx context.Context, tokenHash string, now time.Time) (*model.Invitation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	invitation, ok := m.invitations[tokenHash]
+	if !ok || invitation.AcceptedAt != nil || !invitation.ExpiresAt.After(now) {
+		return nil, model.NotFoundError("invitation not found")
+	}
+	invitation.AcceptedAt = &now
+	return invitation, nil
+}
+
+func (m *mockInvitations) ReopenInvitation(ctx context.Context, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, invitation := range m.invitations {
+		if invitation.ID == id {
+			invitation.AcceptedAt = nil
+			return nil
+		}
+	}
+	return model.NotFoundError("invitation not found")
+}
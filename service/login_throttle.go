@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/JamieBShaw/user-service/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// LockoutPolicy limits how many passwords can be guessed, the zero value disables it
+type LockoutPolicy struct {
+	// MaxAccountFailures locks an account after that many failed logins within FailureWindow
+	MaxAccountFailures int
+	// MaxClientFailures locks a client IP after that many failed logins within FailureWindow, whatever the account
+	MaxClientFailures int
+	FailureWindow     time.Duration
+	LockoutDuration   time.Duration
+	// BaseDelay is how long an account waits for its next login after its second failure in a row,
+	// it doubles with every further failure up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+var DefaultLockoutPolicy = LockoutPolicy{
+	MaxAccountFailures: 10,
+	MaxClientFailures:  100,
+	FailureWindow:      15 * time.Minute,
+	LockoutDuration:    15 * time.Minute,
+	BaseDelay:          time.Second,
+	MaxDelay:           30 * time.Second,
+}
+
+var errTooManyLogins = model.TooManyRequestsError("too many failed login attempts, try again later")
+
+type clientIPKey struct{}
+
+// WithClientIP returns a copy of ctx carrying the IP of the client logging in, failed logins are counted against it
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+func clientIPFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	return ip
+}
+
+// loginThrottle enforces a LockoutPolicy, it does nothing without a repository to count failures in
+type loginThrottle struct {
+	attempts repository.LoginAttemptRepository
+	policy   LockoutPolicy
+	log      *logrus.Logger
+	now      func() time.Time
+}
+
+func accountKey(id int64) string {
+	return "account:" + strconv.FormatInt(id, 10)
+}
+
+// loginKey counts failures against a login that matches no account, so unknown accounts lock like real ones
+func loginKey(login string) string {
+	return "login:" + login
+}
+
+func clientKey(ip string) string {
+	return "client:" + ip
+}
+
+// checkAccount refuses the login when the account is locked or has not waited out its delay
+func (t loginThrottle) checkAccount(ctx context.Context, key string) error {
+	return t.check(ctx, key, t.policy.MaxAccountFailures, true)
+}
+
+// checkClient refuses the login when the client IP is locked
+func (t loginThrottle) checkClient(ctx context.Context, ip string) error {
+	if ip == "" {
+		return nil
+	}
+	return t.check(ctx, clientKey(ip), t.policy.MaxClientFailures, false)
+}
+
+func (t loginThrottle) check(ctx context.Context, key string, max int, delayed bool) error {
+	if t.attempts == nil || max <= 0 {
+		return nil
+	}
+
+	attempt, err := t.attempts.LoginAttempt(ctx, key)
+	if errors.Is(err, model.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		t.log.Errorf("USER SERVICE: error: %v", err)
+		return model.WrapError(err, "unable to check login attempts")
+	}
+
+	now := t.now()
+	if attempt.Locked(now) {
+		return errTooManyLogins
+	}
+
+	// The lock has run out, the next login starts with a clean slate
+	if attempt.LockedUntil != nil {
+		return t.clear(ctx, key)
+	}
+
+	if delayed && now.Before(attempt.LastFailureAt.Add(t.delay(attempt.Failures))) {
+		return errTooManyLogins
+	}
+
+	return nil
+}
+
+// fail counts a failed login against the account and the client IP, locking whichever reached its limit
+func (t loginThrottle) fail(ctx context.Context, key, ip string) error {
+	err := t.record(ctx, key, t.policy.MaxAccountFailures)
+	if err != nil {
+		return err
+	}
+	if ip == "" {
+		return nil
+	}
+	return t.record(ctx, clientKey(ip), t.policy.MaxClientFailures)
+}
+
+func (t loginThrottle) record(ctx context.Context, key string, max int) error {
+	if t.attempts == nil || max <= 0 {
+		return nil
+	}
+
+	now := t.now()
+	attempt, err := t.attempts.RecordLoginFailure(ctx, key, now, t.policy.FailureWindow)
+	if err != nil {
+		t.log.Errorf("USER SERVICE: error: %v", err)
+		return model.WrapError(err, "unable to record failed login")
+	}
+
+	if attempt.Failures < max {
+		return nil
+	}
+
+	t.log.Warnf("USER SERVICE: locking logins for %s after %d failures", key, attempt.Failures)
+	err = t.attempts.LockLogin(ctx, key, now.Add(t.policy.LockoutDuration))
+	if err != nil {
+		t.log.Errorf("USER SERVICE: error: %v", err)
+		return model.WrapError(err, "unable to record failed login")
+	}
+
+	return nil
+}
+
+// clear forgets the failed logins of key, after a successful login or an admin unlock
+func (t loginThrottle) clear(ctx context.Context, key string) error {
+	if t.attempts == nil {
+		return nil
+	}
+
+	err := t.attempts.ClearLoginAttempts(ctx, key)
+	if err != nil {
+		t.log.Errorf("USER SERVICE: error: %v", err)
+		return model.WrapError(err, "unable to clear login attempts")
+	}
+
+	return nil
+}
+
+// delay is how long to wait after the given number of failures in a row before the next login
+func (t loginThrottle) delay(failures int) time.Duration {
+	if failures < 2 || t.policy.BaseDelay <= 0 {
+		return 0
+	}
+
+	delay := t.policy.BaseDelay
+	for i := 2; i < failures && delay < t.policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	if t.policy.MaxDelay > 0 && delay > t.policy.MaxDelay {
+		return t.policy.MaxDelay
+	}
+	return delay
+}
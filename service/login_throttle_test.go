@@ -0,0 +1,264 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockLoginAttempts keeps login attempts in a map keyed by key
+type mockLoginAttempts struct {
+	mu       sync.Mutex
+	attempts map[string]*model.LoginAttempt
+}
+
+// testLockoutPolicy locks accounts after 3 failures and clients after 5, without delays unless a test sets them
+var testLockoutPolicy = LockoutPolicy{
+	MaxAccountFailures: 3,
+	MaxClientFailures:  5,
+	FailureWindow:      15 * time.Minute,
+	LockoutDuration:    15 * time.Minute,
+}
+
+// newThrottledUserService returns a user service whose clock is moved forward by advancing now
+func newThrottledUserService(t *testing.T, policy LockoutPolicy, now *time.Time) (*userService, *mockLoginAttempts) {
+	attempts := &mockLoginAttempts{attempts: map[string]*model.LoginAttempt{}}
+	service := NewUserService(newTestRepository(t), attempts, Options{Lockout: policy, Hasher: testHasher})
+	service.throttle.now = func() time.Time { return *now }
+	return service, attempts
+}
+
+func TestUserService_Lockout_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name     string
+		login    string
+		failures int
+		code     model.ErrorCode
+	}{
+		{
+			name:     "login before the limit",
+			login:    "David",
+			failures: 2,
+			code:     "",
+		},
+		{
+			name:     "account locked after the limit",
+			login:    "David",
+			failures: 3,
+			code:     model.CodeTooManyRequests,
+		},
+		{
+			name:     "account locked after failures by email",
+			login:    "david@example.com",
+			failures: 3,
+			code:     model.CodeTooManyRequests,
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+			service, _ := newThrottledUserService(t, testLockoutPolicy, &now)
+
+			for i := 0; i < tc.failures; i++ {
+				_, err := service.GetByUsernameAndPassword(context.Background(), tc.login, "wrong-password")
+				assert.Equal(t, model.CodeUnauthenticated, model.ErrorCodeOf(err))
+			}
+
+			_, err := service.GetByUsernameAndPassword(context.Background(), "David", "password")
+			if tc.code != "" {
+				assert.Equal(t, tc.code, model.ErrorCodeOf(err))
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestUserService_Lockout_Expires(t *testing.T) {
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	service, attempts := newThrottledUserService(t, testLockoutPolicy, &now)
+
+	for i := 0; i < 3; i++ {
+		_, _ = service.GetByUsernameAndPassword(context.Background(), "David", "wrong-password")
+	}
+
+	now = now.Add(testLockoutPolicy.LockoutDuration + time.Second)
+
+	_, err := service.GetByUsernameAndPassword(context.Background(), "David", "password")
+	assert.NoError(t, err)
+	_, ok := attempts.attempts[accountKey(2)]
+	assert.False(t, ok)
+}
+
+func TestUserService_Lockout_Unknown_Login(t *testing.T) {
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	service, _ := newThrottledUserService(t, testLockoutPolicy, &now)
+
+	for i := 0; i < 3; i++ {
+		_, err := service.GetByUsernameAndPassword(context.Background(), "Nobody", "password")
+		assert.Equal(t, "invalid username or password", err.Error())
+	}
+
+	// An unknown login locks just like an account, so locking does not reveal which accounts exist
+	_, err := service.GetByUsernameAndPassword(context.Background(), "Nobody", "password")
+	assert.Equal(t, model.CodeTooManyRequests, model.ErrorCodeOf(err))
+}
+
+func TestUserService_Lockout_Client_IP(t *testing.T) {
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	service, _ := newThrottledUserService(t, testLockoutPolicy, &now)
+	ctx := WithClientIP(context.Background(), "10.0.0.1")
+
+	// Spread over several accounts so none of them is locked
+	for _, login := range []string{"James", "James", "Michael", "Michael", "Nobody"} {
+		_, err := service.GetByUsernameAndPassword(ctx, login, "wrong-password")
+		assert.Equal(t, model.CodeUnauthenticated, model.ErrorCodeOf(err))
+	}
+
+	_, err := service.GetByUsernameAndPassword(ctx, "David", "password")
+	assert.Equal(t, model.CodeTooManyRequests, model.ErrorCodeOf(err))
+
+	_, err = service.GetByUsernameAndPassword(WithClientIP(context.Background(), "10.0.0.2"), "David", "password")
+	assert.NoError(t, err)
+}
+
+func TestUserService_Lockout_Progressive_Delay(t *testing.T) {
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	policy := testLockoutPolicy
+	policy.MaxAccountFailures = 10
+	policy.BaseDelay = time.Second
+	policy.MaxDelay = 4 * time.Second
+	service, _ := newThrottledUserService(t, policy, &now)
+
+	_, err := service.GetByUsernameAndPassword(context.Background(), "David", "wrong-password")
+	assert.Equal(t, model.CodeUnauthenticated, model.ErrorCodeOf(err))
+
+	// The first failure is not delayed
+	_, err = service.GetByUsernameAndPassword(context.Background(), "David", "wrong-password")
+	assert.Equal(t, model.CodeUnauthenticated, model.ErrorCodeOf(err))
+
+	_, err = service.GetByUsernameAndPassword(context.Background(), "David", "password")
+	assert.Equal(t, model.CodeTooManyRequests, model.ErrorCodeOf(err))
+
+	now = now.Add(time.Second)
+	_, err = service.GetByUsernameAndPassword(context.Background(), "David", "password")
+	assert.NoError(t, err)
+}
+
+func TestLoginThrottle_Delay_Test_Cases(t *testing.T) {
+	throttle := loginThrottle{policy: LockoutPolicy{BaseDelay: time.Second, MaxDelay: 30 * time.Second}}
+
+	tt := []struct {
+		failures int
+		delay    time.Duration
+	}{
+		{failures: 1, delay: 0},
+		{failures: 2, delay: time.Second},
+		{failures: 3, delay: 2 * time.Second},
+		{failures: 5, delay: 8 * time.Second},
+		{failures: 7, delay: 30 * time.Second},
+		{failures: 1000, delay: 30 * time.Second},
+	}
+	for _, tc := range tt {
+		assert.Equal(t, tc.delay, throttle.delay(tc.failures), "failures: %d", tc.failures)
+	}
+}
+
+func TestUserService_UnlockUser_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name string
+		id   int64
+		code model.ErrorCode
+	}{
+		{
+			name: "user unlocked",
+			id:   2,
+		},
+		{
+			name: "user does not exist",
+			id:   42,
+			code: model.CodeNotFound,
+		},
+		{
+			name: "invalid id",
+			id:   0,
+			code: model.CodeInvalidArgument,
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+			service, _ := newThrottledUserService(t, testLockoutPolicy, &now)
+
+			for i := 0; i < 3; i++ {
+				_, _ = service.GetByUsernameAndPassword(context.Background(), "David", "wrong-password")
+			}
+
+			err := service.UnlockUser(context.Background(), tc.id)
+			if tc.code != "" {
+				assert.Equal(t, tc.code, model.ErrorCodeOf(err))
+				return
+			}
+			assert.NoError(t, err)
+
+			_, err = service.GetByUsernameAndPassword(context.Background(), "David", "password")
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func (m *mockLoginAttempts) LoginAttempt(ctx context.Context, key string) (*model.LoginAttempt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	attempt, ok := m.attempts[key]
+	if !ok {
+		return nil, model.NotFoundError("login attempt not found")
+	}
+	c := *attempt
+	return &c, nil
+}
+
+func (m *mockLoginAttempts) RecordLoginFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*model.LoginAttempt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	attempt, ok := m.attempts[key]
+	if !ok {
+		attempt = &model.LoginAttempt{Key: key}
+		m.attempts[key] = attempt
+	}
+	if attempt.LastFailureAt.After(now.Add(-window)) {
+		attempt.Failures++
+	} else {
+		attempt.Failures = 1
+	}
+	attempt.LastFailureAt = now
+	c := *attempt
+	return &c, nil
+}
+
+func (m *mockLoginAttempts) LockLogin(ctx context.Context, key string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	attempt, ok := m.attempts[key]
+	if !ok {
+		attempt = &model.LoginAttempt{Key: key}
+		m.attempts[key] = attempt
+	}
+	attempt.LockedUntil = &until
+	return nil
+}
+
+func (m *mockLoginAttempts) ClearLoginAttempts(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.attempts, key)
+	return nil
+}
@@ -0,0 +1,454 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JamieBShaw/user-service/auth"
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/JamieBShaw/user-service/repository"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// MFAChallengeTTL is how long a login has to submit its second factor
+	MFAChallengeTTL = 5 * time.Minute
+	// RecoveryCodeCount is how many recovery codes a user gets when they enable MFA
+	RecoveryCodeCount = 10
+	// MFAIssuer is the name authenticator apps show next to the codes of this service
+	MFAIssuer = "user-service"
+)
+
+// settingRequireAdminMFA is the setting holding whether admins must log in with MFA
+const settingRequireAdminMFA = "mfa.require_admin"
+
+var (
+	errInvalidMFAChallenge = model.UnauthenticatedError("invalid or expired mfa token")
+	errInvalidMFACode      = model.UnauthenticatedError("invalid mfa code")
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type MFAService interface {
+	// Enroll starts setting up an authenticator for the user, it is not used until it is confirmed
+	Enroll(ctx context.Context, userID int64) (*model.MFAEnrollment, error)
+	// Confirm enables the authenticator with a first code and returns the recovery codes of the user
+	Confirm(ctx context.Context, userID int64, code string) ([]string, error)
+	// Disable removes the authenticator and recovery codes of the user once they passed a current code or an unused
+	// recovery code, so a stolen access token is not enough to turn MFA off
+	Disable(ctx context.Context, userID int64, code string) error
+	// Reset removes the authenticator and recovery codes of the user without a code, for admins helping users who
+	// lost their authenticator
+	Reset(ctx context.Context, userID int64) error
+	// Challenge returns a challenge when user has to pass a second factor before they are issued tokens, nil otherwise
+	Challenge(ctx context.Context, user *model.User) (*model.LoginChallenge, error)
+	// EnrollChallenge starts setting up an authenticator for the user of a challenge that requires enrollment
+	EnrollChallenge(ctx context.Context, token string) (*model.MFAEnrollment, error)
+	// Verify checks a code, or a recovery code, against the user of the challenge and consumes the challenge.
+	// When it confirms an enrollment the new recovery codes of the user are returned.
+	Verify(ctx context.Context, token, code string) (*model.User, []string, error)
+	// AdminMFARequired reports whether users holding the admin role must log in with MFA
+	AdminMFARequired(ctx context.Context) (bool, error)
+	// SetAdminMFARequired decides whether admins must log in with MFA, admins without it are made to enroll on login
+	SetAdminMFARequired(ctx context.Context, required bool) error
+}
+
+type mfaService struct {
+	users    repository.Repository
+	mfa      repository.MFARepository
+	settings repository.SettingsRepository
+	throttle loginThrottle
+	log      *logrus.Logger
+	now      func() time.Time
+}
+
+// NewMFAService returns an MFAService, wrong codes are throttled with lockout like wrong passwords
+func NewMFAService(users repository.Repository, mfa repository.MFARepository, settings repository.SettingsRepository, attempts repository.LoginAttemptRepository, lockout LockoutPolicy) *mfaService {
+	return &mfaService{
+		users:    users,
+		mfa:      mfa,
+		settings: settings,
+		throttle: loginThrottle{
+			attempts: attempts,
+			policy:   lockout,
+			log:      l,
+			now:      time.Now,
+		},
+		log: l,
+		now: time.Now,
+	}
+}
+
+// mfaKey counts wrong codes separately from wrong passwords, so logging in with the password does not reset them
+func mfaKey(userID int64) string {
+	return "mfa:" + strconv.FormatInt(userID, 10)
+}
+
+func (m *mfaService) Enroll(ctx context.Context, userID int64) (*model.MFAEnrollment, error) {
+	m.log.Info("[MFA SERVICE]: Enroll")
+
+	user, err := m.users.UserById(ctx, userID)
+	if err != nil {
+		m.log.Errorf("MFA SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "could not find user with id")
+	}
+
+	return m.enroll(ctx, user)
+}
+
+func (m *mfaService) Confirm(ctx context.Context, userID int64, code string) ([]string, error) {
+	m.log.Info("[MFA SERVICE]: Confirm")
+
+	mfa, err := m.mfa.MFA(ctx, userID)
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, model.InvalidArgumentError("mfa enrollment not started")
+	}
+	if err != nil {
+		m.log.Errorf("MFA SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "unable to confirm mfa")
+	}
+	if mfa.Confirmed() {
+		return nil, model.AlreadyExistsError("mfa already enabled")
+	}
+
+	step, ok := auth.ValidateTOTP(mfa.Secret, code, m.now())
+	if !ok {
+		return nil, model.ValidationError(model.FieldError{Field: "code", Message: "invalid mfa code"})
+	}
+
+	return m.confirm(ctx, userID, step)
+}
+
+func (m *mfaService) Disable(ctx context.Context, userID int64, code string) error {
+	m.log.Info("[MFA SERVICE]: Disable")
+
+	if code == "" {
+		return model.ValidationError(model.FieldError{Field: "code", Message: "code is required"})
+	}
+
+	key := mfaKey(userID)
+	if err := m.throttle.checkAccount(ctx, key); err != nil {
+		return err
+	}
+
+	mfa, err := m.mfa.MFA(ctx, userID)
+	if errors.Is(err, model.ErrNotFound) {
+		return model.NotFoundError("mfa not enabled")
+	}
+	if err != nil {
+		m.log.Errorf("MFA SERVICE: error: %v", err)
+		return model.WrapError(err, "unable to disable mfa")
+	}
+
+	// An enrollment that was never confirmed does not protect the account yet, its code is not asked for
+	if mfa.Confirmed() {
+		err = m.verifyCode(ctx, mfa, code)
+		if errors.Is(err, errInvalidMFACode) {
+			if failErr := m.throttle.fail(ctx, key, clientIPFromContext(ctx)); failErr != nil {
+				return failErr
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if err := m.throttle.clear(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	return m.Reset(ctx, userID)
+}
+
+func (m *mfaService) Reset(ctx context.Context, userID int64) error {
+	m.log.Info("[MFA SERVICE]: Reset")
+
+	err := m.mfa.DeleteMFA(ctx, userID)
+	if errors.Is(err, model.ErrNotFound) {
+		return model.NotFoundError("mfa not enabled")
+	}
+	if err != nil {
+		m.log.Errorf("MFA SERVICE: error: %v", err)
+		return model.WrapError(err, "unable to disable mfa")
+	}
+
+	return nil
+}
+
+func (m *mfaService) Challenge(ctx context.Context, user *model.User) (*model.LoginChallenge, error) {
+	m.log.Info("[MFA SERVICE]: Challenge")
+
+	mfa, err := m.mfa.MFA(ctx, user.ID)
+	if err != nil && !errors.Is(err, model.ErrNotFound) {
+		m.log.Errorf("MFA SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "unable to check mfa")
+	}
+	enabled := mfa != nil && mfa.Confirmed()
+
+	if !enabled {
+		required, err := m.required(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		if !required {
+			return nil, nil
+		}
+	}
+
+	token, err := newSecretToken()
+	if err != nil {
+		return nil, model.WrapError(err, "unable to create mfa challenge")
+	}
+
+	err = m.mfa.CreateMFAChallenge(ctx, user.ID, hashSecretToken(token), m.now().Add(MFAChallengeTTL))
+	if err != nil {
+		m.log.Errorf("MFA SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "unable to create mfa challenge")
+	}
+
+	return &model.LoginChallenge{Token: token, EnrollmentRequired: !enabled}, nil
+}
+
+func (m *mfaService) EnrollChallenge(ctx context.Context, token string) (*model.MFAEnrollment, error) {
+	m.log.Info("[MFA SERVICE]: Enroll Challenge")
+
+	challenge, err := m.challenge(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := m.users.UserById(ctx, challenge.UserID)
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, errInvalidMFAChallenge
+	}
+	if err != nil {
+		m.log.Errorf("MFA SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "unable to enroll mfa")
+	}
+
+	return m.enroll(ctx, user)
+}
+
+func (m *mfaService) Verify(ctx context.Context, token, code string) (*model.User, []string, error) {
+	m.log.Info("[MFA SERVICE]: Verify")
+
+	if code == "" {
+		return nil, nil, model.ValidationError(model.FieldError{Field: "code", Message: "code is required"})
+	}
+
+	challenge, err := m.challenge(ctx, token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key := mfaKey(challenge.UserID)
+	if err := m.throttle.checkAccount(ctx, key); err != nil {
+		return nil, nil, err
+	}
+
+	user, err := m.users.UserById(ctx, challenge.UserID)
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, nil, errInvalidMFAChallenge
+	}
+	if err != nil {
+		m.log.Errorf("MFA SERVICE: error: %v", err)
+		return nil, nil, model.WrapError(err, "unable to verify mfa")
+	}
+	// The account may have been suspended since the password was checked
+	if err := user.CheckActive(); err != nil {
+		return nil, nil, err
+	}
+
+	mfa, err := m.mfa.MFA(ctx, user.ID)
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, nil, model.InvalidArgumentError("mfa enrollment not started")
+	}
+	if err != nil {
+		m.log.Errorf("MFA SERVICE: error: %v", err)
+		return nil, nil, model.WrapError(err, "unable to verify mfa")
+	}
+
+	var recoveryCodes []string
+	if mfa.Confirmed() {
+		err = m.verifyCode(ctx, mfa, code)
+	} else {
+		// The challenge required enrollment, its first code confirms the new authenticator
+		step, ok := auth.ValidateTOTP(mfa.Secret, code, m.now())
+		if !ok {
+			err = errInvalidMFACode
+		} else {
+			recoveryCodes, err = m.confirm(ctx, user.ID, step)
+		}
+	}
+	if errors.Is(err, errInvalidMFACode) {
+		if failErr := m.throttle.fail(ctx, key, clientIPFromContext(ctx)); failErr != nil {
+			return nil, nil, failErr
+		}
+		return nil, nil, err
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = m.mfa.ConsumeMFAChallenge(ctx, hashSecretToken(token), m.now())
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, nil, errInvalidMFAChallenge
+	}
+	if err != nil {
+		m.log.Errorf("MFA SERVICE: error: %v", err)
+		return nil, nil, model.WrapError(err, "unable to verify mfa")
+	}
+
+	if err := m.throttle.clear(ctx, key); err != nil {
+		return nil, nil, err
+	}
+
+	return user, recoveryCodes, nil
+}
+
+func (m *mfaService) AdminMFARequired(ctx context.Context) (bool, error) {
+	value, err := m.settings.Setting(ctx, settingRequireAdminMFA)
+	if errors.Is(err, model.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		m.log.Errorf("MFA SERVICE: error: %v", err)
+		return false, model.WrapError(err, "unable to get mfa policy")
+	}
+
+	return value == "true", nil
+}
+
+func (m *mfaService) SetAdminMFARequired(ctx context.Context, required bool) error {
+	m.log.Info("[MFA SERVICE]: Set Admin MFA Required")
+
+	err := m.settings.SetSetting(ctx, settingRequireAdminMFA, strconv.FormatBool(required))
+	if err != nil {
+		m.log.Errorf("MFA SERVICE: error: %v", err)
+		return model.WrapError(err, "unable to set mfa policy")
+	}
+
+	return nil
+}
+
+// required reports whether user has to log in with MFA even though they have not set it up
+func (m *mfaService) required(ctx context.Context, user *model.User) (bool, error) {
+	if !user.IsAdmin() {
+		return false, nil
+	}
+	return m.AdminMFARequired(ctx)
+}
+
+func (m *mfaService) enroll(ctx context.Context, user *model.User) (*model.MFAEnrollment, error) {
+	mfa, err := m.mfa.MFA(ctx, user.ID)
+	if err != nil && !errors.Is(err, model.ErrNotFound) {
+		m.log.Errorf("MFA SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "unable to enroll mfa")
+	}
+	if mfa != nil && mfa.Confirmed() {
+		return nil, model.AlreadyExistsError("mfa already enabled")
+	}
+
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		return nil, model.WrapError(err, "unable to enroll mfa")
+	}
+
+	err = m.mfa.SaveMFA(ctx, user.ID, secret)
+	if err != nil {
+		m.log.Errorf("MFA SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "unable to enroll mfa")
+	}
+
+	return &model.MFAEnrollment{
+		Secret: secret,
+		URI:    auth.TOTPURI(MFAIssuer, user.Username, secret),
+	}, nil
+}
+
+// confirm enables the authenticator of the user and returns their new recovery codes
+func (m *mfaService) confirm(ctx context.Context, userID int64, step int64) ([]string, error) {
+	codes := make([]string, 0, RecoveryCodeCount)
+	hashes := make([]string, 0, RecoveryCodeCount)
+	for i := 0; i < RecoveryCodeCount; i++ {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, model.WrapError(err, "unable to confirm mfa")
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, hashSecretToken(normalizeRecoveryCode(code)))
+	}
+
+	err := m.mfa.ConfirmMFA(ctx, userID, step, hashes, m.now())
+	if err != nil {
+		m.log.Errorf("MFA SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "unable to confirm mfa")
+	}
+
+	return codes, nil
+}
+
+// verifyCode accepts a current TOTP code that was not used before or an unused recovery code
+func (m *mfaService) verifyCode(ctx context.Context, mfa *model.MFA, code string) error {
+	if step, ok := auth.ValidateTOTP(mfa.Secret, code, m.now()); ok {
+		err := m.mfa.UseMFAStep(ctx, mfa.UserID, step)
+		if errors.Is(err, model.ErrNotFound) {
+			return errInvalidMFACode
+		}
+		if err != nil {
+			m.log.Errorf("MFA SERVICE: error: %v", err)
+			return model.WrapError(err, "unable to verify mfa")
+		}
+		return nil
+	}
+
+	err := m.mfa.ConsumeRecoveryCode(ctx, mfa.UserID, hashSecretToken(normalizeRecoveryCode(code)), m.now())
+	if errors.Is(err, model.ErrNotFound) {
+		return errInvalidMFACode
+	}
+	if err != nil {
+		m.log.Errorf("MFA SERVICE: error: %v", err)
+		return model.WrapError(err, "unable to verify mfa")
+	}
+
+	return nil
+}
+
+func (m *mfaService) challenge(ctx context.Context, token string) (*model.MFAChallenge, error) {
+	if token == "" {
+		return nil, model.ValidationError(model.FieldError{Field: "mfa_token", Message: "mfa token is required"})
+	}
+
+	challenge, err := m.mfa.MFAChallenge(ctx, hashSecretToken(token), m.now())
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, errInvalidMFAChallenge
+	}
+	if err != nil {
+		m.log.Errorf("MFA SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "unable to check mfa token")
+	}
+
+	return challenge, nil
+}
+
+// newRecoveryCode returns 50 random bits formatted as two groups of five characters
+func newRecoveryCode() (string, error) {
+	b := make([]byte, 7)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))[:10]
+	return code[:5] + "-" + code[5:], nil
+}
+
+// normalizeRecoveryCode ignores case, dashes and spaces so codes can be typed the way they are read
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
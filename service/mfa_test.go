@@ -0,0 +1,483 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JamieBShaw/user-service/auth"
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockMFA keeps authenticators by user id, recovery codes by hash and challenges by token hash
+type mockMFA struct {
+	mu            sync.Mutex
+	mfa           map[int64]*model.MFA
+	recoveryCodes map[string]*model.MFARecoveryCode
+	challenges    map[string]*model.MFAChallenge
+}
+
+type mockSettings struct {
+	mu       sync.Mutex
+	settings map[string]string
+}
+
+// newTestMFAService returns an MFAService whose clock is moved forward by advancing now
+func newTestMFAService(t *testing.T, now *time.Time) (*mfaService, *mockMFA, *mockSettings) {
+	mfa := &mockMFA{
+		mfa:           map[int64]*model.MFA{},
+		recoveryCodes: map[string]*model.MFARecoveryCode{},
+		challenges:    map[string]*model.MFAChallenge{},
+	}
+	settings := &mockSettings{settings: map[string]string{}}
+	attempts := &mockLoginAttempts{attempts: map[string]*model.LoginAttempt{}}
+
+	service := NewMFAService(newTestRepository(t), mfa, settings, attempts, testLockoutPolicy)
+	service.now = func() time.Time { return *now }
+	service.throttle.now = func() time.Time { return *now }
+	return service, mfa, settings
+}
+
+// enrolledUser enables MFA for David (id 2) and returns his secret and recovery codes
+func enrolledUser(t *testing.T, service *mfaService, now time.Time) (string, []string) {
+	enrollment, err := service.Enroll(context.Background(), 2)
+	assert.NoError(t, err)
+
+	code, err := auth.GenerateTOTP(enrollment.Secret, now)
+	assert.NoError(t, err)
+
+	recoveryCodes, err := service.Confirm(context.Background(), 2, code)
+	assert.NoError(t, err)
+	return enrollment.Secret, recoveryCodes
+}
+
+func TestMFAService_Enroll(t *testing.T) {
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	service, _, _ := newTestMFAService(t, &now)
+
+	enrollment, err := service.Enroll(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, auth.TOTPURI(MFAIssuer, "David", enrollment.Secret), enrollment.URI)
+
+	_, err = service.Confirm(context.Background(), 2, "000000")
+	assert.Equal(t, "invalid mfa code", err.Error())
+
+	code, err := auth.GenerateTOTP(enrollment.Secret, now)
+	assert.NoError(t, err)
+
+	recoveryCodes, err := service.Confirm(context.Background(), 2, code)
+	assert.NoError(t, err)
+	assert.Len(t, recoveryCodes, RecoveryCodeCount)
+
+	_, err = service.Enroll(context.Background(), 2)
+	assert.Equal(t, model.CodeAlreadyExists, model.ErrorCodeOf(err))
+
+	_, err = service.Confirm(context.Background(), 2, code)
+	assert.Equal(t, model.CodeAlreadyExists, model.ErrorCodeOf(err))
+}
+
+func TestMFAService_Challenge_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name               string
+		user               *model.User
+		enrolled           bool
+		requireAdmin       bool
+		challenged         bool
+		enrollmentRequired bool
+	}{
+		{
+			name:       "user without mfa",
+			user:       &model.User{ID: 2, Username: "David"},
+			challenged: false,
+		},
+		{
+			name:       "user with mfa",
+			user:       &model.User{ID: 2, Username: "David"},
+			enrolled:   true,
+			challenged: true,
+		},
+		{
+			name:       "admin without mfa when it is not required",
+			user:       &model.User{ID: 2, Username: "David", Roles: []string{model.RoleAdmin}},
+			challenged: false,
+		},
+		{
+			name:               "admin without mfa when it is required",
+			user:               &model.User{ID: 2, Username: "David", Roles: []string{model.RoleAdmin}},
+			requireAdmin:       true,
+			challenged:         true,
+			enrollmentRequired: true,
+		},
+		{
+			name:         "user without mfa when it is required for admins",
+			user:         &model.User{ID: 2, Username: "David"},
+			requireAdmin: true,
+			challenged:   false,
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+			service, _, _ := newTestMFAService(t, &now)
+			if tc.enrolled {
+				enrolledUser(t, service, now)
+			}
+			assert.NoError(t, service.SetAdminMFARequired(context.Background(), tc.requireAdmin))
+
+			challenge, err := service.Challenge(context.Background(), tc.user)
+			assert.NoError(t, err)
+			if !tc.challenged {
+				assert.Nil(t, challenge)
+				return
+			}
+			assert.NotEmpty(t, challenge.Token)
+			assert.Equal(t, tc.enrollmentRequired, challenge.EnrollmentRequired)
+		})
+	}
+}
+
+func TestMFAService_Verify_Test_Cases(t *testing.T) {
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tt := []struct {
+		name string
+		// code returns the code to submit from the secret and recovery codes of the user
+		code   func(secret string, recoveryCodes []string) string
+		token  string
+		errMsg string
+	}{
+		{
+			name: "valid code",
+			code: func(secret string, _ []string) string {
+				code, _ := auth.GenerateTOTP(secret, now.Add(30*time.Second))
+				return code
+			},
+		},
+		{
+			name: "code used to confirm enrollment is not accepted again",
+			code: func(secret string, _ []string) string {
+				code, _ := auth.GenerateTOTP(secret, now)
+				return code
+			},
+			errMsg: "invalid mfa code",
+		},
+		{
+			name: "recovery code",
+			code: func(_ string, recoveryCodes []string) string {
+				return recoveryCodes[0]
+			},
+		},
+		{
+			name: "recovery code typed in upper case without the dash",
+			code: func(_ string, recoveryCodes []string) string {
+				return " " + strings.ToUpper(recoveryCodes[1][:5]+recoveryCodes[1][6:])
+			},
+		},
+		{
+			name: "wrong code",
+			code: func(string, []string) string {
+				return "000000"
+			},
+			errMsg: "invalid mfa code",
+		},
+		{
+			name: "unknown challenge",
+			code: func(secret string, _ []string) string {
+				code, _ := auth.GenerateTOTP(secret, now.Add(30*time.Second))
+				return code
+			},
+			token:  "unknown-token",
+			errMsg: "invalid or expired mfa token",
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			clock := now
+			service, _, _ := newTestMFAService(t, &clock)
+			secret, recoveryCodes := enrolledUser(t, service, clock)
+
+			challenge, err := service.Challenge(context.Background(), &model.User{ID: 2})
+			assert.NoError(t, err)
+			token := challenge.Token
+			if tc.token != "" {
+				token = tc.token
+			}
+
+			// The code used to confirm enrollment is still within the allowed clock skew
+			clock = now.Add(30 * time.Second)
+			user, codes, err := service.Verify(context.Background(), token, tc.code(secret, recoveryCodes))
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, err.Error())
+				assert.Equal(t, model.CodeUnauthenticated, model.ErrorCodeOf(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, int64(2), user.ID)
+			assert.Nil(t, codes)
+
+			// Every challenge and recovery code can only be used once
+			_, _, err = service.Verify(context.Background(), token, tc.code(secret, recoveryCodes))
+			assert.Equal(t, "invalid or expired mfa token", err.Error())
+		})
+	}
+}
+
+func TestMFAService_Verify_Enrollment(t *testing.T) {
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	service, _, _ := newTestMFAService(t, &now)
+	assert.NoError(t, service.SetAdminMFARequired(context.Background(), true))
+
+	challenge, err := service.Challenge(context.Background(), &model.User{ID: 2, Roles: []string{model.RoleAdmin}})
+	assert.NoError(t, err)
+	assert.True(t, challenge.EnrollmentRequired)
+
+	_, _, err = service.Verify(context.Background(), challenge.Token, "000000")
+	assert.Equal(t, "mfa enrollment not started", err.Error())
+
+	enrollment, err := service.EnrollChallenge(context.Background(), challenge.Token)
+	assert.NoError(t, err)
+
+	code, err := auth.GenerateTOTP(enrollment.Secret, now)
+	assert.NoError(t, err)
+
+	user, recoveryCodes, err := service.Verify(context.Background(), challenge.Token, code)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), user.ID)
+	assert.Len(t, recoveryCodes, RecoveryCodeCount)
+}
+
+func TestMFAService_Verify_Lockout(t *testing.T) {
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	service, _, _ := newTestMFAService(t, &now)
+	secret, _ := enrolledUser(t, service, now)
+	now = now.Add(time.Minute)
+
+	// Every login gets a new challenge, wrong codes are still counted against the user
+	for i := 0; i < testLockoutPolicy.MaxAccountFailures; i++ {
+		challenge, err := service.Challenge(context.Background(), &model.User{ID: 2})
+		assert.NoError(t, err)
+		_, _, err = service.Verify(context.Background(), challenge.Token, "000000")
+		assert.Equal(t, "invalid mfa code", err.Error())
+	}
+
+	challenge, err := service.Challenge(context.Background(), &model.User{ID: 2})
+	assert.NoError(t, err)
+	code, err := auth.GenerateTOTP(secret, now)
+	assert.NoError(t, err)
+
+	_, _, err = service.Verify(context.Background(), challenge.Token, code)
+	assert.Equal(t, model.CodeTooManyRequests, model.ErrorCodeOf(err))
+}
+
+func TestMFAService_Disable_Test_Cases(t *testing.T) {
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tt := []struct {
+		name    string
+		mfaCode func(secret string, recoveryCodes []string) string
+		errMsg  string
+		code    model.ErrorCode
+	}{
+		{
+			name: "current code",
+			mfaCode: func(secret string, _ []string) string {
+				code, _ := auth.GenerateTOTP(secret, now.Add(30*time.Second))
+				return code
+			},
+		},
+		{
+			name: "recovery code",
+			mfaCode: func(_ string, recoveryCodes []string) string {
+				return recoveryCodes[0]
+			},
+		},
+		{
+			name: "code used to confirm enrollment is not accepted again",
+			mfaCode: func(secret string, _ []string) string {
+				code, _ := auth.GenerateTOTP(secret, now)
+				return code
+			},
+			errMsg: "invalid mfa code",
+			code:   model.CodeUnauthenticated,
+		},
+		{
+			name: "wrong code",
+			mfaCode: func(string, []string) string {
+				return "000000"
+			},
+			errMsg: "invalid mfa code",
+			code:   model.CodeUnauthenticated,
+		},
+		{
+			name: "missing code",
+			mfaCode: func(string, []string) string {
+				return ""
+			},
+			errMsg: "code is required",
+			code:   model.CodeInvalidArgument,
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			clock := now
+			service, _, _ := newTestMFAService(t, &clock)
+			secret, recoveryCodes := enrolledUser(t, service, clock)
+			clock = now.Add(30 * time.Second)
+
+			err := service.Disable(context.Background(), 2, tc.mfaCode(secret, recoveryCodes))
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, err.Error())
+				assert.Equal(t, tc.code, model.ErrorCodeOf(err))
+
+				// MFA stays enabled
+				challenge, err := service.Challenge(context.Background(), &model.User{ID: 2})
+				assert.NoError(t, err)
+				assert.NotNil(t, challenge)
+				return
+			}
+			assert.NoError(t, err)
+
+			challenge, err := service.Challenge(context.Background(), &model.User{ID: 2})
+			assert.NoError(t, err)
+			assert.Nil(t, challenge)
+
+			err = service.Disable(context.Background(), 2, "000000")
+			assert.Equal(t, "mfa not enabled", err.Error())
+		})
+	}
+}
+
+func TestMFAService_Reset(t *testing.T) {
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	service, _, _ := newTestMFAService(t, &now)
+	enrolledUser(t, service, now)
+
+	assert.NoError(t, service.Reset(context.Background(), 2))
+
+	challenge, err := service.Challenge(context.Background(), &model.User{ID: 2})
+	assert.NoError(t, err)
+	assert.Nil(t, challenge)
+
+	err = service.Reset(context.Background(), 2)
+	assert.Equal(t, "mfa not enabled", err.Error())
+}
+
+func (m *mockMFA) MFA(ctx context.Context, userID int64) (*model.MFA, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mfa, ok := m.mfa[userID]
+	if !ok {
+		return nil, model.NotFoundError("mfa not found")
+	}
+	c := *mfa
+	return &c, nil
+}
+
+func (m *mockMFA) SaveMFA(ctx context.Context, userID int64, secret string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.mfa[userID] = &model.MFA{UserID: userID, Secret: secret}
+	return nil
+}
+
+func (m *mockMFA) ConfirmMFA(ctx context.Context, userID int64, step int64, recoveryCodeHashes []string, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mfa, ok := m.mfa[userID]
+	if !ok {
+		return model.NotFoundError("mfa not found")
+	}
+	mfa.ConfirmedAt = &now
+	mfa.LastUsedStep = step
+	for _, hash := range recoveryCodeHashes {
+		m.recoveryCodes[hash] = &model.MFARecoveryCode{UserID: userID, CodeHash: hash}
+	}
+	return nil
+}
+
+func (m *mockMFA) UseMFAStep(ctx context.Context, userID int64, step int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mfa, ok := m.mfa[userID]
+	if !ok || mfa.LastUsedStep >= step {
+		return model.NotFoundError("mfa not found")
+	}
+	mfa.LastUsedStep = step
+	return nil
+}
+
+func (m *mockMFA) ConsumeRecoveryCode(ctx context.Context, userID int64, codeHash string, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	code, ok := m.recoveryCodes[codeHash]
+	if !ok || code.UserID != userID || code.UsedAt != nil {
+		return model.NotFoundError("recovery code not found")
+	}
+	code.UsedAt = &now
+	return nil
+}
+
+func (m *mockMFA) DeleteMFA(ctx context.Context, userID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.mfa[userID]; !ok {
+		return model.NotFoundError("mfa not found")
+	}
+	delete(m.mfa, userID)
+	return nil
+}
+
+func (m *mockMFA) CreateMFAChallenge(ctx context.Context, userID int64, tokenHash string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.challenges[tokenHash] = &model.MFAChallenge{UserID: userID, TokenHash: tokenHash, ExpiresAt: expiresAt}
+	return nil
+}
+
+func (m *mockMFA) MFAChallenge(ctx context.Context, tokenHash string, now time.Time) (*model.MFAChallenge, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	challenge, ok := m.challenges[tokenHash]
+	if !ok || challenge.UsedAt != nil || !challenge.ExpiresAt.After(now) {
+		return nil, model.NotFoundError("mfa challenge not found")
+	}
+	c := *challenge
+	return &c, nil
+}
+
+func (m *mockMFA) ConsumeMFAChallenge(ctx context.Context, tokenHash string, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	challenge, ok := m.challenges[tokenHash]
+	if !ok || challenge.UsedAt != nil || !challenge.ExpiresAt.After(now) {
+		return model.NotFoundError("mfa challenge not found")
+	}
+	challenge.UsedAt = &now
+	return nil
+}
+
+func (m *mockSettings) Setting(ctx context.Context, key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	value, ok := m.settings[key]
+	if !ok {
+		return "", model.NotFoundError("setting not found")
+	}
+	return value, nil
+}
+
+func (m *mockSettings) SetSetting(ctx context.Context, key, value string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.settings[key] = value
+	return nil
+}
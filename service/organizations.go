@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/JamieBShaw/user-service/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// maxOrganizationNameLength is the size of the name column of organizations
+const maxOrganizationNameLength = 100
+
+// organizationSlug is lower case letters, digits and single hyphens between them, it fits the slug column
+var organizationSlug = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// OrganizationService resolves the organization of a request and manages which users belong to which organizations
+type OrganizationService interface {
+	// Resolve returns the organization with slug, unknown slugs are not found
+	Resolve(ctx context.Context, slug string) (*model.Organization, error)
+	// Create creates an organization with owner as its first member holding the admin role. Organizations can only
+	// be created from the default organization.
+	Create(ctx context.Context, ownerID int64, slug, name string) (*model.Organization, error)
+	// UserOrganizations returns the organizations a member of the organization of ctx belongs to
+	UserOrganizations(ctx context.Context, userID int64) ([]*model.Organization, error)
+	// IsMember reports whether the user belongs to the organization
+	IsMember(ctx context.Context, organizationID, userID int64) (bool, error)
+	// RemoveMember takes a member out of the organization of ctx along with their roles and groups in it. Users can
+	// not be removed from the organization they registered in.
+	RemoveMember(ctx context.Context, userID int64) error
+}
+
+type organizationService struct {
+	users         repository.Repository
+	organizations repository.OrganizationRepository
+	log           *logrus.Logger
+}
+
+func NewOrganizationService(users repository.Repository, organizations repository.OrganizationRepository) *organizationService {
+	return &organizationService{
+		users:         users,
+		organizations: organizations,
+		log:           l,
+	}
+}
+
+func (o *organizationService) Resolve(ctx context.Context, slug string) (*model.Organization, error) {
+	organization, err := o.organizations.OrganizationBySlug(ctx, strings.ToLower(strings.TrimSpace(slug)))
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		o.log.Errorf("ORGANIZATION SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "error resolving organization")
+	}
+
+	return organization, nil
+}
+
+func (o *organizationService) Create(ctx context.Context, ownerID int64, slug, name string) (*model.Organization, error) {
+	o.log.Info("[ORGANIZATION SERVICE]: Create Organization")
+
+	if model.OrganizationFromContext(ctx) != model.DefaultOrganizationID {
+		return nil, model.PermissionDeniedError("organizations can only be created from the default organization")
+	}
+
+	slug = strings.ToLower(strings.TrimSpace(slug))
+	name = strings.TrimSpace(name)
+
+	var fields []model.FieldError
+	if !organizationSlug.MatchString(slug) || len(slug) > 40 {
+		fields = append(fields, model.FieldError{Field: "slug", Message: "slug must be up to 40 lower case letters, digits and hyphens"})
+	}
+	if name == "" {
+		fields = append(fields, model.FieldError{Field: "name", Message: "name is required"})
+	} else if utf8.RuneCountInString(name) > maxOrganizationNameLength {
+		fields = append(fields, model.FieldError{Field: "name", Message: "name must be at most 100 characters"})
+	}
+	if len(fields) > 0 {
+		return nil, model.ValidationError(fields...)
+	}
+
+	organization, err := o.organizations.CreateOrganization(ctx, slug, name, ownerID)
+	if errors.Is(err, model.ErrNotFound) || errors.Is(err, model.ErrAlreadyExists) {
+		return nil, err
+	}
+	if err != nil {
+		o.log.Errorf("ORGANIZATION SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "error creating organization")
+	}
+
+	o.log.Infof("[ORGANIZATION SERVICE]: Organization %s created by user %d", organization.Slug, ownerID)
+
+	return organization, nil
+}
+
+func (o *organizationService) UserOrganizations(ctx context.Context, userID int64) ([]*model.Organization, error) {
+	o.log.Info("[ORGANIZATION SERVICE]: Get User Organizations")
+
+	if userID <= 0 {
+		return nil, model.InvalidArgumentError("invalid id")
+	}
+
+	// Only members of the organization of the request can be looked up
+	if _, err := o.users.UserById(ctx, userID); err != nil {
+		o.log.Errorf("ORGANIZATION SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "could not find user with id")
+	}
+
+	organizations, err := o.organizations.UserOrganizations(ctx, userID)
+	if err != nil {
+		o.log.Errorf("ORGANIZATION SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "error getting organizations")
+	}
+
+	return organizations, nil
+}
+
+func (o *organizationService) IsMember(ctx context.Context, organizationID, userID int64) (bool, error) {
+	member, err := o.organizations.IsMember(ctx, organizationID, userID)
+	if err != nil {
+		o.log.Errorf("ORGANIZATION SERVICE: error: %v", err)
+		return false, model.WrapError(err, "error checking organization member")
+	}
+
+	return member, nil
+}
+
+func (o *organizationService) RemoveMember(ctx context.Context, userID int64) error {
+	o.log.Info("[ORGANIZATION SERVICE]: Remove Organization Member")
+
+	if userID <= 0 {
+		return model.InvalidArgumentError("invalid id")
+	}
+
+	organizationID := model.OrganizationFromContext(ctx)
+
+	user, err := o.users.UserById(ctx, userID)
+	if errors.Is(err, model.ErrNotFound) {
+		return err
+	}
+	if err != nil {
+		o.log.Errorf("ORGANIZATION SERVICE: error: %v", err)
+		return model.WrapError(err, "could not find user with id")
+	}
+	// The organization a user registered in manages them, they leave it by being deleted
+	if user.OrganizationID == organizationID {
+		return model.InvalidArgumentError("users can not be removed from the organization they registered in")
+	}
+
+	err = o.organizations.RemoveMember(ctx, organizationID, userID)
+	if errors.Is(err, model.ErrNotFound) {
+		return err
+	}
+	if err != nil {
+		o.log.Errorf("ORGANIZATION SERVICE: error: %v", err)
+		return model.WrapError(err, "error removing organization member")
+	}
+
+	o.log.Infof("[ORGANIZATION SERVICE]: User %d removed from organization %d", userID, organizationID)
+
+	return nil
+}
@@ -0,0 +1,290 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingOrganizationDb fails every call with err
+type failingOrganizationDb struct {
+	err error
+}
+
+func TestOrganizationService_Create_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name         string
+		organization int64
+		slug         string
+		orgName      string
+		dbErr        error
+		errMsg       string
+		code         model.ErrorCode
+	}{
+		{
+			name:    "organization created",
+			slug:    " Globex ",
+			orgName: "Globex Corporation",
+		},
+		{
+			name:         "created from another organization",
+			organization: 2,
+			slug:         "globex",
+			orgName:      "Globex",
+			errMsg:       "organizations can only be created from the default organization",
+			code:         model.CodePermissionDenied,
+		},
+		{
+			name:    "invalid slug",
+			slug:    "globex corp",
+			orgName: "Globex",
+			errMsg:  "slug must be up to 40 lower case letters, digits and hyphens",
+			code:    model.CodeInvalidArgument,
+		},
+		{
+			name:   "missing name",
+			slug:   "globex",
+			errMsg: "name is required",
+			code:   model.CodeInvalidArgument,
+		},
+		{
+			name:    "slug taken",
+			slug:    "acme",
+			orgName: "Acme",
+			errMsg:  "organization already exists",
+			code:    model.CodeAlreadyExists,
+		},
+		{
+			name:    "repository error",
+			slug:    "globex",
+			orgName: "Globex",
+			dbErr:   errors.New("pq: connection refused"),
+			errMsg:  "error creating organization",
+			code:    model.CodeInternal,
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			service := newOrganizationTestService(t, tc.dbErr)
+			ctx := context.Background()
+			if tc.organization != 0 {
+				ctx = model.WithOrganization(ctx, tc.organization)
+			}
+
+			organization, err := service.Create(ctx, 1, tc.slug, tc.orgName)
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, err.Error())
+				assert.Equal(t, tc.code, model.ErrorCodeOf(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "globex", organization.Slug)
+		})
+	}
+}
+
+func TestOrganizationService_UserOrganizations_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name   string
+		id     int64
+		slugs  []string
+		errMsg string
+		code   model.ErrorCode
+	}{
+		{
+			name:  "user belongs to several organizations",
+			id:    2,
+			slugs: []string{"acme", "default"},
+		},
+		{
+			name:  "user of the default organization",
+			id:    1,
+			slugs: []string{"default"},
+		},
+		{
+			name:   "user not found",
+			id:     42,
+			errMsg: "could not find user with id",
+			code:   model.CodeNotFound,
+		},
+		{
+			name:   "invalid id",
+			id:     0,
+			errMsg: "invalid id",
+			code:   model.CodeInvalidArgument,
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			service := newOrganizationTestService(t, nil)
+
+			organizations, err := service.UserOrganizations(context.Background(), tc.id)
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, err.Error())
+				assert.Equal(t, tc.code, model.ErrorCodeOf(err))
+				return
+			}
+			assert.NoError(t, err)
+			var slugs []string
+			for _, organization := range organizations {
+				slugs = append(slugs, organization.Slug)
+			}
+			assert.Equal(t, tc.slugs, slugs)
+		})
+	}
+}
+
+func TestOrganizationService_Resolve_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name   string
+		slug   string
+		dbErr  error
+		id     int64
+		errMsg string
+	}{
+		{
+			name: "organization found ignoring case",
+			slug: "ACME",
+			id:   2,
+		},
+		{
+			name:   "unknown organization",
+			slug:   "globex",
+			errMsg: "organization not found",
+		},
+		{
+			name:   "repository error",
+			slug:   "acme",
+			dbErr:  errors.New("pq: connection refused"),
+			errMsg: "error resolving organization",
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			service := newOrganizationTestService(t, tc.dbErr)
+
+			organization, err := service.Resolve(context.Background(), tc.slug)
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, err.Error())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.id, organization.ID)
+		})
+	}
+}
+
+func TestOrganizationService_RemoveMember_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name         string
+		organization int64
+		userID       int64
+		dbErr        error
+		errMsg       string
+		code         model.ErrorCode
+	}{
+		{
+			name:         "member removed",
+			organization: 2,
+			userID:       2,
+		},
+		{
+			name:         "remove a user who is not a member",
+			organization: 2,
+			userID:       1,
+			errMsg:       "user not found",
+			code:         model.CodeNotFound,
+		},
+		{
+			name:         "remove a user from the organization they registered in",
+			organization: model.DefaultOrganizationID,
+			userID:       1,
+			errMsg:       "users can not be removed from the organization they registered in",
+			code:         model.CodeInvalidArgument,
+		},
+		{
+			name:         "remove an invalid id",
+			organization: 2,
+			errMsg:       "invalid id",
+			code:         model.CodeInvalidArgument,
+		},
+		{
+			name:         "remove with a repository error",
+			organization: 2,
+			userID:       2,
+			dbErr:        errors.New("pq: connection refused"),
+			errMsg:       "error removing organization member",
+			code:         model.CodeInternal,
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			service := newOrganizationTestService(t, tc.dbErr)
+			ctx := model.WithOrganization(context.Background(), tc.organization)
+
+			err := service.RemoveMember(ctx, tc.userID)
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, err.Error())
+				assert.Equal(t, tc.code, model.ErrorCodeOf(err))
+				return
+			}
+			assert.NoError(t, err)
+			member, err := service.IsMember(ctx, tc.organization, tc.userID)
+			assert.NoError(t, err)
+			assert.False(t, member)
+		})
+	}
+}
+
+// newOrganizationTestService runs against the test repository where David (id 2) created acme (id 2), its
+// organizations fail with dbErr when it is set
+func newOrganizationTestService(t *testing.T, dbErr error) *organizationService {
+	t.Helper()
+	db := newTestRepository(t)
+	if _, err := db.CreateOrganization(context.Background(), "acme", "Acme", 2); err != nil {
+		t.Fatalf("could not create organization: %v", err)
+	}
+	if dbErr != nil {
+		return NewOrganizationService(db, failingOrganizationDb{err: dbErr})
+	}
+	return NewOrganizationService(db, db)
+}
+
+func (m failingOrganizationDb) Organization(ctx context.Context, id int64) (*model.Organization, error) {
+	return nil, m.err
+}
+
+func (m failingOrganizationDb) OrganizationBySlug(ctx context.Context, slug string) (*model.Organization, error) {
+	return nil, m.err
+}
+
+func (m failingOrganizationDb) CreateOrganization(ctx context.Context, slug, name string, ownerID int64) (*model.Organization, error) {
+	return nil, m.err
+}
+
+func (m failingOrganizationDb) UserOrganizations(ctx context.Context, userID int64) ([]*model.Organization, error) {
+	return nil, m.err
+}
+
+func (m failingOrganizationDb) IsMember(ctx context.Context, organizationID, userID int64) (bool, error) {
+	return false, m.err
+}
+
+func (m failingOrganizationDb) AddMember(ctx context.Context, organizationID, userID int64) error {
+	return m.err
+}
+
+func (m failingOrganizationDb) RemoveMember(ctx context.Context, organizationID, userID int64) error {
+	return m.err
+}
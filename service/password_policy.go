@@ -0,0 +1,210 @@
+package service
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+)
+
+// Codes of the password policy violations, sent to clients with each field error so they can show their own message
+const (
+	PasswordTooShort         = "password_too_short"
+	PasswordTooLong          = "password_too_long"
+	PasswordMissingLowercase = "password_missing_lowercase"
+	PasswordMissingUppercase = "password_missing_uppercase"
+	PasswordMissingDigit     = "password_missing_digit"
+	PasswordMissingSymbol    = "password_missing_symbol"
+	PasswordContainsUsername = "password_contains_username"
+	PasswordBreached         = "password_breached"
+)
+
+// PasswordPolicy decides which passwords users may choose when they register, change or reset their password
+type PasswordPolicy struct {
+	// MinLength and MaxLength count characters, MaxLength also bounds the work of hashing a password
+	MinLength     int
+	MaxLength     int
+	RequireLower  bool
+	RequireUpper  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// DisallowUsername refuses passwords containing the username of the user, ignoring case
+	DisallowUsername bool
+	// Denylist refuses passwords known to be breached, every password is allowed when it is nil
+	Denylist PasswordDenylist
+}
+
+// DefaultPasswordPolicy follows NIST SP 800-63B, length matters and composition rules are left off. 64 characters
+// stay within the 72 bytes bcrypt hashes as long as they are ASCII.
+var DefaultPasswordPolicy = PasswordPolicy{
+	MinLength:        8,
+	MaxLength:        64,
+	DisallowUsername: true,
+}
+
+// PasswordDenylist holds passwords that must not be used, like those found in breaches
+type PasswordDenylist interface {
+	Contains(password string) bool
+}
+
+// Check returns a violation of the policy for every rule password breaks, reported against field. The username rule
+// is only checked when username is given.
+func (p PasswordPolicy) Check(field, password, username string) []model.FieldError {
+	var violations []model.FieldError
+	violate := func(code, message string) {
+		violations = append(violations, model.FieldError{Field: field, Message: message, Code: code})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		violate(PasswordTooShort, fmt.Sprintf("password must be at least %d characters", p.MinLength))
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		violate(PasswordTooLong, fmt.Sprintf("password must be at most %d characters", p.MaxLength))
+		// Long passwords are not checked any further, the policy is already broken
+		return violations
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsLetter(r) && !unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.RequireLower && !lower {
+		violate(PasswordMissingLowercase, "password must contain a lowercase letter")
+	}
+	if p.RequireUpper && !upper {
+		violate(PasswordMissingUppercase, "password must contain an uppercase letter")
+	}
+	if p.RequireDigit && !digit {
+		violate(PasswordMissingDigit, "password must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		violate(PasswordMissingSymbol, "password must contain a symbol")
+	}
+
+	if p.DisallowUsername && username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		violate(PasswordContainsUsername, "password must not contain the username")
+	}
+
+	if p.Denylist != nil && p.Denylist.Contains(password) {
+		violate(PasswordBreached, "password has appeared in a data breach, choose another one")
+	}
+
+	return violations
+}
+
+// passwordDenylist holds the SHA-1 hashes of denied passwords
+type passwordDenylist struct {
+	hashes map[[sha1.Size]byte]struct{}
+}
+
+// LoadPasswordDenylist reads denied passwords from path, which is either
+//   - a directory of k-anonymity range files, named after the first 5 hex characters of the SHA-1 hashes they hold
+//     and listing the remaining 35 as SUFFIX:COUNT lines, the layout of the Have I Been Pwned range downloads
+//   - a file of HASH:COUNT lines with whole SHA-1 hashes, where lines that are not a hash are taken as plain passwords
+func LoadPasswordDenylist(path string) (*passwordDenylist, error) {
+	denylist := &passwordDenylist{hashes: map[[sha1.Size]byte]struct{}{}}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		if err := denylist.load(path, ""); err != nil {
+			return nil, err
+		}
+		return denylist, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		prefix := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		if entry.IsDir() || len(prefix) != 5 || !isHex(prefix) {
+			continue
+		}
+		if err := denylist.load(filepath.Join(path, entry.Name()), prefix); err != nil {
+			return nil, err
+		}
+	}
+
+	return denylist, nil
+}
+
+func (d *passwordDenylist) Contains(password string) bool {
+	_, ok := d.hashes[sha1.Sum([]byte(password))]
+	return ok
+}
+
+// load adds the hashes of a file, prefix is the start of every hash in a range file and empty otherwise
+func (d *passwordDenylist) load(path, prefix string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		// Drop the breach count, it is not used
+		hash := text
+		if i := strings.LastIndexByte(text, ':'); i >= 0 && isCount(text[i+1:]) {
+			hash = text[:i]
+		}
+		hash = prefix + hash
+
+		var sum [sha1.Size]byte
+		switch {
+		case len(hash) == 2*sha1.Size && isHex(hash):
+			_, _ = hex.Decode(sum[:], []byte(hash))
+		case prefix != "":
+			return fmt.Errorf("%s:%d: not a SHA-1 hash suffix", path, line)
+		default:
+			sum = sha1.Sum([]byte(text))
+		}
+		d.hashes[sum] = struct{}{}
+	}
+
+	return scanner.Err()
+}
+
+func isHex(s string) bool {
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return false
+		}
+	}
+	return true
+}
+
+func isCount(s string) bool {
+	_, err := strconv.ParseUint(s, 10, 64)
+	return err == nil
+}
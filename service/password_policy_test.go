@@ -0,0 +1,138 @@
+package service
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockDenylist denies the passwords it holds
+type mockDenylist map[string]bool
+
+func TestPasswordPolicy_Check_Test_Cases(t *testing.T) {
+	strict := PasswordPolicy{
+		MinLength:        8,
+		MaxLength:        16,
+		RequireLower:     true,
+		RequireUpper:     true,
+		RequireDigit:     true,
+		RequireSymbol:    true,
+		DisallowUsername: true,
+		Denylist:         mockDenylist{"Passw0rd!": true},
+	}
+
+	tt := []struct {
+		name     string
+		policy   PasswordPolicy
+		password string
+		username string
+		codes    []string
+	}{
+		{
+			name:     "default policy allows long enough password",
+			policy:   DefaultPasswordPolicy,
+			password: "correct horse",
+			username: "david",
+		},
+		{
+			name:     "default policy counts characters, not bytes",
+			policy:   DefaultPasswordPolicy,
+			password: "ééééééé",
+			codes:    []string{PasswordTooShort},
+		},
+		{
+			name:     "too long",
+			policy:   strict,
+			password: strings.Repeat("a", 17),
+			codes:    []string{PasswordTooLong},
+		},
+		{
+			name:     "every character class missing",
+			policy:   strict,
+			password: "        ",
+			codes:    []string{PasswordMissingLowercase, PasswordMissingUppercase, PasswordMissingDigit, PasswordMissingSymbol},
+		},
+		{
+			name:     "contains username ignoring case",
+			policy:   strict,
+			password: "xDAVIDx1!",
+			username: "david",
+			codes:    []string{PasswordContainsUsername},
+		},
+		{
+			name:     "username rule skipped without username",
+			policy:   strict,
+			password: "xDAVIDx1!",
+		},
+		{
+			name:     "breached",
+			policy:   strict,
+			password: "Passw0rd!",
+			codes:    []string{PasswordBreached},
+		},
+		{
+			name:     "strict policy allows strong password",
+			policy:   strict,
+			password: "Tr0ub4dor&3",
+			username: "david",
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var codes []string
+			for _, violation := range tc.policy.Check("password", tc.password, tc.username) {
+				assert.Equal(t, "password", violation.Field)
+				codes = append(codes, violation.Code)
+			}
+			assert.Equal(t, tc.codes, codes)
+		})
+	}
+}
+
+func TestLoadPasswordDenylist_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "denylist.txt")
+	content := sha1Hex("123456") + ":37359195\n" + strings.ToLower(sha1Hex("qwerty")) + "\n\nletmein\n"
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	denylist, err := LoadPasswordDenylist(path)
+	assert.NoError(t, err)
+	assert.True(t, denylist.Contains("123456"))
+	assert.True(t, denylist.Contains("qwerty"))
+	assert.True(t, denylist.Contains("letmein"))
+	assert.False(t, denylist.Contains("correct horse"))
+}
+
+func TestLoadPasswordDenylist_Range_Directory(t *testing.T) {
+	dir := t.TempDir()
+	hash := sha1Hex("password")
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, hash[:5]+".txt"), []byte(hash[5:]+":9545824\r\n"), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "README"), []byte("not a range file"), 0o600))
+
+	denylist, err := LoadPasswordDenylist(dir)
+	assert.NoError(t, err)
+	assert.True(t, denylist.Contains("password"))
+	assert.False(t, denylist.Contains("correct horse"))
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "00000.txt"), []byte("not a hash\n"), 0o600))
+	_, err = LoadPasswordDenylist(dir)
+	assert.Error(t, err)
+
+	_, err = LoadPasswordDenylist(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
+
+func (m mockDenylist) Contains(password string) bool {
+	return m[password]
+}
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/JamieBShaw/user-service/auth"
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/JamieBShaw/user-service/notify"
+	"github.com/JamieBShaw/user-service/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// PasswordResetTokenTTL is how long a password reset token can be used for
+const PasswordResetTokenTTL = time.Hour
+
+type PasswordResetService interface {
+	// RequestReset sends a reset token to the user, it returns nil for unknown usernames so callers can not tell them apart
+	RequestReset(ctx context.Context, username string) error
+	// ConfirmReset sets a new password with a reset token and returns the id of the user it belongs to
+	ConfirmReset(ctx context.Context, token, newPassword string) (int64, error)
+}
+
+type passwordResetService struct {
+	users    repository.Repository
+	tokens   repository.PasswordResetRepository
+	notifier notify.Notifier
+	hasher   auth.PasswordHasher
+	policy   PasswordPolicy
+	log      *logrus.Logger
+	now      func() time.Time
+}
+
+func NewPasswordResetService(users repository.Repository, tokens repository.PasswordResetRepository, notifier notify.Notifier, hasher auth.PasswordHasher, policy PasswordPolicy) *passwordResetService {
+	return &passwordResetService{
+		users:    users,
+		tokens:   tokens,
+		notifier: notifier,
+		hasher:   hasher,
+		policy:   policy,
+		log:      l,
+		now:      time.Now,
+	}
+}
+
+func (p *passwordResetService) RequestReset(ctx context.Context, username string) error {
+	p.log.Info("[PASSWORD RESET SERVICE]: Request Reset")
+
+	user, err := p.users.UserByUsername(ctx, normalizeUsername(username))
+	if errors.Is(err, model.ErrNotFound) {
+		p.log.Infof("password reset requested for unknown username: %s", username)
+		return nil
+	}
+	if err != nil {
+		p.log.Errorf("PASSWORD RESET SERVICE: error: %v", err)
+		return model.WrapError(err, "unable to request password reset")
+	}
+
+	token, err := newSecretToken()
+	if err != nil {
+		return model.WrapError(err, "unable to request password reset")
+	}
+
+	err = p.tokens.CreateResetToken(ctx, user.ID, hashSecretToken(token), p.now().Add(PasswordResetTokenTTL))
+	if err != nil {
+		p.log.Errorf("PASSWORD RESET SERVICE: error: %v", err)
+		return model.WrapError(err, "unable to request password reset")
+	}
+
+	err = p.notifier.SendPasswordReset(ctx, user, token)
+	if err != nil {
+		p.log.Errorf("PASSWORD RESET SERVICE: error sending reset token: %v", err)
+		return model.WrapError(err, "unable to request password reset")
+	}
+
+	return nil
+}
+
+func (p *passwordResetService) ConfirmReset(ctx context.Context, token, newPassword string) (int64, error) {
+	p.log.Info("[PASSWORD RESET SERVICE]: Confirm Reset")
+
+	var fields []model.FieldError
+	if token == "" {
+		fields = append(fields, model.FieldError{Field: "token", Message: "token is required"})
+	}
+	fields = append(fields, p.policy.Check("new_password", newPassword, "")...)
+	if len(fields) > 0 {
+		return 0, model.ValidationError(fields...)
+	}
+
+	// The token is only looked at here so a password refused for containing the username does not use it up
+	tokenHash := hashSecretToken(token)
+	reset, err := p.tokens.ResetToken(ctx, tokenHash, p.now())
+	if errors.Is(err, model.ErrNotFound) {
+		return 0, model.InvalidArgumentError("invalid or expired reset token")
+	}
+	if err != nil {
+		p.log.Errorf("PASSWORD RESET SERVICE: error: %v", err)
+		return 0, model.WrapError(err, "unable to reset password")
+	}
+
+	user, err := p.users.UserById(ctx, reset.UserID)
+	if err != nil {
+		p.log.Errorf("PASSWORD RESET SERVICE: error: %v", err)
+		return 0, model.WrapError(err, "unable to reset password")
+	}
+	if violations := p.policy.Check("new_password", newPassword, user.Username); len(violations) > 0 {
+		return 0, model.ValidationError(violations...)
+	}
+
+	reset, err = p.tokens.ConsumeResetToken(ctx, tokenHash, p.now())
+	if errors.Is(err, model.ErrNotFound) {
+		return 0, model.InvalidArgumentError("invalid or expired reset token")
+	}
+	if err != nil {
+		p.log.Errorf("PASSWORD RESET SERVICE: error: %v", err)
+		return 0, model.WrapError(err, "unable to reset password")
+	}
+
+	hash, err := p.hasher.Hash(newPassword)
+	if err != nil {
+		p.log.Errorf("PASSWORD RESET SERVICE: error: %v", err)
+		return 0, model.WrapError(err, "unable to reset password")
+	}
+
+	err = p.users.UpdatePassword(ctx, reset.UserID, hash, false)
+	if err != nil {
+		p.log.Errorf("PASSWORD RESET SERVICE: error: %v", err)
+		return 0, model.WrapError(err, "unable to reset password")
+	}
+
+	return reset.UserID, nil
+}
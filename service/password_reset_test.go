@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockResetTokens keeps reset tokens in a map keyed by hash
+type mockResetTokens struct {
+	mu     sync.Mutex
+	tokens map[string]*model.PasswordResetToken
+}
+
+// mockNotifier records the last token it was asked to send
+type mockNotifier struct {
+	token string
+}
+
+func TestPasswordResetService_RequestReset_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name     string
+		username string
+		sent     bool
+	}{
+		{
+			name:     "reset token sent",
+			username: "David",
+			sent:     true,
+		},
+		{
+			name:     "unknown username is not an error",
+			username: "Nobody",
+			sent:     false,
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			tokens := &mockResetTokens{tokens: map[string]*model.PasswordResetToken{}}
+			notifier := &mockNotifier{}
+			service := NewPasswordResetService(newTestRepository(t), tokens, notifier, testHasher, DefaultPasswordPolicy)
+
+			err := service.RequestReset(context.Background(), tc.username)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.sent, notifier.token != "")
+			if tc.sent {
+				stored, ok := tokens.tokens[hashSecretToken(notifier.token)]
+				assert.True(t, ok)
+				assert.Equal(t, int64(2), stored.UserID)
+				assert.NotEqual(t, notifier.token, stored.TokenHash)
+			}
+		})
+	}
+}
+
+func TestPasswordResetService_ConfirmReset_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name     string
+		token    string
+		password string
+		now      time.Time
+		userID   int64
+		errMsg   string
+	}{
+		{
+			name:     "password reset",
+			token:    "valid-token",
+			password: "new-password",
+			now:      time.Now(),
+			userID:   2,
+		},
+		{
+			name:     "unknown token",
+			token:    "unknown-token",
+			password: "new-password",
+			now:      time.Now(),
+			errMsg:   "invalid or expired reset token",
+		},
+		{
+			name:     "expired token",
+			token:    "valid-token",
+			password: "new-password",
+			now:      time.Now().Add(2 * PasswordResetTokenTTL),
+			errMsg:   "invalid or expired reset token",
+		},
+		{
+			name:     "missing token and short password",
+			token:    "",
+			password: "short",
+			now:      time.Now(),
+			errMsg:   "token is required, password must be at least 8 characters",
+		},
+		{
+			name:     "password containing the username",
+			token:    "valid-token",
+			password: "david-password",
+			now:      time.Now(),
+			errMsg:   "password must not contain the username",
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			tokens := &mockResetTokens{tokens: map[string]*model.PasswordResetToken{}}
+			err := tokens.CreateResetToken(context.Background(), 2, hashSecretToken("valid-token"), time.Now().Add(PasswordResetTokenTTL))
+			assert.NoError(t, err)
+
+			service := NewPasswordResetService(newTestRepository(t), tokens, &mockNotifier{}, testHasher, DefaultPasswordPolicy)
+			service.now = func() time.Time { return tc.now }
+
+			userID, err := service.ConfirmReset(context.Background(), tc.token, tc.password)
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, err.Error())
+				assert.Equal(t, model.CodeInvalidArgument, model.ErrorCodeOf(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.userID, userID)
+		})
+	}
+}
+
+func TestPasswordResetService_ConfirmReset_Single_Use(t *testing.T) {
+	tokens := &mockResetTokens{tokens: map[string]*model.PasswordResetToken{}}
+	notifier := &mockNotifier{}
+	service := NewPasswordResetService(newTestRepository(t), tokens, notifier, testHasher, DefaultPasswordPolicy)
+
+	assert.NoError(t, service.RequestReset(context.Background(), "David"))
+
+	_, err := service.ConfirmReset(context.Background(), notifier.token, "new-password")
+	assert.NoError(t, err)
+
+	_, err = service.ConfirmReset(context.Background(), notifier.token, "another-password")
+	assert.Equal(t, "invalid or expired reset token", err.Error())
+}
+
+func TestPasswordResetService_ConfirmReset_Policy_Violation_Keeps_Token(t *testing.T) {
+	tokens := &mockResetTokens{tokens: map[string]*model.PasswordResetToken{}}
+	notifier := &mockNotifier{}
+	service := NewPasswordResetService(newTestRepository(t), tokens, notifier, testHasher, DefaultPasswordPolicy)
+
+	assert.NoError(t, service.RequestReset(context.Background(), "David"))
+
+	_, err := service.ConfirmReset(context.Background(), notifier.token, "my-david-password")
+	assert.Equal(t, "password must not contain the username", err.Error())
+
+	_, err = service.ConfirmReset(context.Background(), notifier.token, "new-password")
+	assert.NoError(t, err)
+}
+
+func (m *mockResetTokens) CreateResetToken(ctx context.Context, userID int64, tokenHash string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens[tokenHash] = &model.PasswordResetToken{UserID: userID, TokenHash: tokenHash, ExpiresAt: expiresAt}
+	return nil
+}
+
+func (m *mockResetTokens) ResetToken(ctx context.Context, tokenHash string, now time.Time) (*model.PasswordResetToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	token, ok := m.tokens[tokenHash]
+	if !ok || token.UsedAt != nil || !token.ExpiresAt.After(now) {
+		return nil, model.NotFoundError("reset token not found")
+	}
+	return token, nil
+}
+
+func (m *mockResetTokens) ConsumeResetToken(ctx context.Context, tokenHash string, now time.Time) (*model.PasswordResetToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	token, ok := m.tokens[tokenHash]
+	if !ok || token.UsedAt != nil || !token.ExpiresAt.After(now) {
+		return nil, model.NotFoundError("reset token not found")
+	}
+	token.UsedAt = &now
+	return token, nil
+}
+
+func (m *mockNotifier) SendPasswordReset(ctx context.Context, user *model.User, token string) error {
+	m.token = token
+	return nil
+}
+
+func (m *mockNotifier) SendEmailVerification(ctx context.Context, user *model.User, token string) error {
+	m.token = token
+	return nil
+}
+
+func (m *mockNotifier) SendInvitation(ctx context.Context, invitation *model.Invitation, token string) error {
+	m.token = token
+	return nil
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/JamieBShaw/user-service/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultDeletedRetention is how long deleted users can be restored before they are purged
+const DefaultDeletedRetention = 30 * 24 * time.Hour
+
+// PurgeService hard deletes users once they were soft deleted longer than the retention window ago
+type PurgeService interface {
+	// Purge hard deletes the users deleted before the retention window and returns how many were purged
+	Purge(ctx context.Context) (int, error)
+	// Run purges every interval until ctx is done
+	Run(ctx context.Context, interval time.Duration)
+}
+
+type purgeService struct {
+	users     repository.Repository
+	retention time.Duration
+	log       *logrus.Logger
+	now       func() time.Time
+}
+
+func NewPurgeService(users repository.Repository, retention time.Duration) *purgeService {
+	return &purgeService{
+		users:     users,
+		retention: retention,
+		log:       l,
+		now:       time.Now,
+	}
+}
+
+func (p *purgeService) Purge(ctx context.Context) (int, error) {
+	p.log.Info("[PURGE SERVICE]: Purge Deleted Users")
+
+	purged, err := p.users.PurgeDeleted(ctx, p.now().Add(-p.retention))
+	if err != nil {
+		p.log.Errorf("PURGE SERVICE: error: %v", err)
+		return 0, model.WrapError(err, "unable to purge deleted users")
+	}
+
+	if purged > 0 {
+		p.log.Infof("[PURGE SERVICE]: Purged %d deleted users", purged)
+	}
+
+	return purged, nil
+}
+
+func (p *purgeService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// A failed purge is retried on the next tick, the users stay deleted meanwhile
+		_, _ = p.Purge(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
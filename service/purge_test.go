@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/JamieBShaw/user-service/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+// purgeDb records the cutoff it was asked to purge before, it only implements PurgeDeleted
+type purgeDb struct {
+	repository.Repository
+	deletedBefore time.Time
+	purged        int
+	err           error
+}
+
+func TestPurgeService_Purge_Test_Cases(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tt := []struct {
+		name   string
+		db     *purgeDb
+		purged int
+		errMsg string
+	}{
+		{
+			name:   "users past the retention purged",
+			db:     &purgeDb{purged: 2},
+			purged: 2,
+		},
+		{
+			name:   "repository error",
+			db:     &purgeDb{err: errors.New("pq: connection refused")},
+			errMsg: "unable to purge deleted users",
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			service := NewPurgeService(tc.db, 24*time.Hour)
+			service.now = func() time.Time { return now }
+
+			purged, err := service.Purge(context.Background())
+			assert.Equal(t, now.Add(-24*time.Hour), tc.db.deletedBefore)
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, err.Error())
+				assert.Equal(t, model.CodeInternal, model.ErrorCodeOf(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.purged, purged)
+		})
+	}
+}
+
+func TestPurgeService_Run_Stops_With_Context(t *testing.T) {
+	db := &purgeDb{}
+	service := NewPurgeService(db, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		service.Run(ctx, time.Hour)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("purge job did not stop when its context was done")
+	}
+	assert.False(t, db.deletedBefore.IsZero())
+}
+
+func (m *purgeDb) PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int, error) {
+	m.deletedBefore = deletedBefore
+	return m.purged, m.err
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/JamieBShaw/user-service/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// RoleService manages the roles of users and answers whether a user holds a permission
+type RoleService interface {
+	// Roles returns every role with the permissions it grants
+	Roles(ctx context.Context) ([]*model.Role, error)
+	// AssignRole grants the role to the user and returns the user with their roles
+	AssignRole(ctx context.Context, userID int64, role string) (*model.User, error)
+	// RevokeRole takes the role away from the user and returns the user with their remaining roles
+	RevokeRole(ctx context.Context, userID int64, role string) (*model.User, error)
+	// HasPermission reports whether any role of the user grants permission
+	HasPermission(ctx context.Context, userID int64, permission string) (bool, error)
+}
+
+type roleService struct {
+	users repository.Repository
+	roles repository.RoleRepository
+	log   *logrus.Logger
+}
+
+func NewRoleService(users repository.Repository, roles repository.RoleRepository) *roleService {
+	return &roleService{
+		users: users,
+		roles: roles,
+		log:   l,
+	}
+}
+
+func (r *roleService) Roles(ctx context.Context) ([]*model.Role, error) {
+	r.log.Info("[ROLE SERVICE]: Get Roles")
+
+	roles, err := r.roles.Roles(ctx)
+	if err != nil {
+		r.log.Errorf("ROLE SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "error getting roles")
+	}
+
+	return roles, nil
+}
+
+func (r *roleService) AssignRole(ctx context.Context, userID int64, role string) (*model.User, error) {
+	r.log.Info("[ROLE SERVICE]: Assign Role")
+
+	role, err := validateRoleRequest(userID, role)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.roles.AssignRole(ctx, userID, role)
+	// The repository tells whether the user or the role is missing
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		r.log.Errorf("ROLE SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "error assigning role")
+	}
+
+	r.log.Infof("[ROLE SERVICE]: User %d granted role %s", userID, role)
+
+	return r.user(ctx, userID)
+}
+
+func (r *roleService) RevokeRole(ctx context.Context, userID int64, role string) (*model.User, error) {
+	r.log.Info("[ROLE SERVICE]: Revoke Role")
+
+	role, err := validateRoleRequest(userID, role)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.roles.RevokeRole(ctx, userID, role)
+	// The repository tells whether the user or the role is missing
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		r.log.Errorf("ROLE SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "error revoking role")
+	}
+
+	r.log.Infof("[ROLE SERVICE]: User %d lost role %s", userID, role)
+
+	return r.user(ctx, userID)
+}
+
+func (r *roleService) HasPermission(ctx context.Context, userID int64, permission string) (bool, error) {
+	permissions, err := r.roles.UserPermissions(ctx, userID)
+	if err != nil {
+		r.log.Errorf("ROLE SERVICE: error: %v", err)
+		return false, model.WrapError(err, "error checking permission")
+	}
+
+	for _, p := range permissions {
+		if p == permission || p == model.PermissionAll {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// user reads the user back with the roles they hold now
+func (r *roleService) user(ctx context.Context, userID int64) (*model.User, error) {
+	user, err := r.users.UserById(ctx, userID)
+	if err != nil {
+		r.log.Errorf("ROLE SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "could not find user with id")
+	}
+	return user, nil
+}
+
+func validateRoleRequest(userID int64, role string) (string, error) {
+	if userID <= 0 {
+		return "", model.InvalidArgumentError("invalid id")
+	}
+
+	role = strings.TrimSpace(role)
+	if role == "" {
+		return "", model.ValidationError(model.FieldError{Field: "role", Message: "role is required"})
+	}
+
+	return role, nil
+}
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingRoleDb fails every call with err
+type failingRoleDb struct {
+	err error
+}
+
+func TestRoleService_AssignRole_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name   string
+		id     int64
+		role   string
+		dbErr  error
+		errMsg string
+		code   model.ErrorCode
+	}{
+		{
+			name: "role granted",
+			id:   2,
+			role: " support ",
+		},
+		{
+			name:   "invalid id",
+			id:     0,
+			role:   model.RoleSupport,
+			errMsg: "invalid id",
+			code:   model.CodeInvalidArgument,
+		},
+		{
+			name:   "missing role",
+			id:     1,
+			role:   " ",
+			errMsg: "role is required",
+			code:   model.CodeInvalidArgument,
+		},
+		{
+			name:   "unknown role",
+			id:     1,
+			role:   "owner",
+			errMsg: "role not found",
+			code:   model.CodeNotFound,
+		},
+		{
+			name:   "user does not exist",
+			id:     42,
+			role:   model.RoleSupport,
+			errMsg: "user not found",
+			code:   model.CodeNotFound,
+		},
+		{
+			name:   "repository error",
+			id:     1,
+			role:   model.RoleSupport,
+			dbErr:  errors.New("pq: connection refused"),
+			errMsg: "error assigning role",
+			code:   model.CodeInternal,
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			db := newRoleTestRepository(t)
+			service := NewRoleService(db, db)
+			if tc.dbErr != nil {
+				service = NewRoleService(db, failingRoleDb{err: tc.dbErr})
+			}
+
+			user, err := service.AssignRole(context.Background(), tc.id, tc.role)
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, err.Error())
+				assert.Equal(t, tc.code, model.ErrorCodeOf(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.id, user.ID)
+			assert.Contains(t, user.Roles, model.RoleSupport)
+		})
+	}
+}
+
+func TestRoleService_RevokeRole_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name   string
+		id     int64
+		role   string
+		errMsg string
+		code   model.ErrorCode
+	}{
+		{
+			name: "role revoked",
+			id:   1,
+			role: model.RoleSupport,
+		},
+		{
+			name:   "user does not hold the role",
+			id:     2,
+			role:   model.RoleSupport,
+			errMsg: "user does not have the role",
+			code:   model.CodeNotFound,
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			db := newRoleTestRepository(t)
+			service := NewRoleService(db, db)
+
+			user, err := service.RevokeRole(context.Background(), tc.id, tc.role)
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, err.Error())
+				assert.Equal(t, tc.code, model.ErrorCodeOf(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Empty(t, user.Roles)
+		})
+	}
+}
+
+func TestRoleService_HasPermission_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name       string
+		id         int64
+		permission string
+		dbErr      error
+		granted    bool
+		errMsg     string
+	}{
+		{
+			name:       "granted by a role",
+			id:         1,
+			permission: "users:unlock",
+			granted:    true,
+		},
+		{
+			name:       "not granted by any role",
+			id:         1,
+			permission: "users:delete",
+			granted:    false,
+		},
+		{
+			name:       "user without roles",
+			id:         2,
+			permission: "users:list",
+			granted:    false,
+		},
+		{
+			name:       "admin role grants everything",
+			id:         3,
+			permission: "roles:manage",
+			granted:    true,
+		},
+		{
+			name:       "repository error",
+			id:         1,
+			permission: "users:list",
+			dbErr:      errors.New("pq: connection refused"),
+			errMsg:     "error checking permission",
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			db := newRoleTestRepository(t)
+			service := NewRoleService(db, db)
+			if tc.dbErr != nil {
+				service = NewRoleService(db, failingRoleDb{err: tc.dbErr})
+			}
+
+			granted, err := service.HasPermission(context.Background(), tc.id, tc.permission)
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, err.Error())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.granted, granted)
+		})
+	}
+}
+
+// newRoleTestRepository returns the test repository where James (id 1) is a support agent, Michael (id 3) is an
+// admin and David (id 2) holds no role
+func newRoleTestRepository(t *testing.T) testRepository {
+	t.Helper()
+	db := newTestRepository(t)
+	if err := db.AssignRole(context.Background(), 1, model.RoleSupport); err != nil {
+		t.Fatalf("could not assign role: %v", err)
+	}
+	if err := db.AssignRole(context.Background(), 3, model.RoleAdmin); err != nil {
+		t.Fatalf("could not assign role: %v", err)
+	}
+	return db
+}
+
+func (m failingRoleDb) Roles(ctx context.Context) ([]*model.Role, error) {
+	return nil, m.err
+}
+
+func (m failingRoleDb) AssignRole(ctx context.Context, userID int64, role string) error {
+	return m.err
+}
+
+func (m failingRoleDb) RevokeRole(ctx context.Context, userID int64, role string) error {
+	return m.err
+}
+
+func (m failingRoleDb) UserPermissions(ctx context.Context, userID int64) ([]string, error) {
+	return nil, m.err
+}
@@ -2,126 +2,579 @@ package service
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"net/mail"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/JamieBShaw/user-service/auth"
 	"github.com/JamieBShaw/user-service/domain/model"
 	"github.com/JamieBShaw/user-service/repository"
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 100
+	// maxStatusReasonLength bounds the reason an admin gives for a status change
+	maxStatusReasonLength = 500
+)
+
 var (
 	l = logrus.New()
+
+	errInvalidCredentials = model.UnauthenticatedError("invalid username or password")
 )
 
 type userService struct {
-	db  repository.Repository
-	log *logrus.Logger
+	db        repository.Repository
+	log       *logrus.Logger
+	opts      Options
+	throttle  loginThrottle
+	hasher    auth.PasswordHasher
+	policy    PasswordPolicy
+	usernames UsernamePolicy
+
+	dummyHashOnce sync.Once
+	dummyHash     string
+}
+
+// Options configures the policies of the user service, the zero value is the most permissive
+type Options struct {
+	// RequireVerifiedEmail stops users logging in until they verified their email address
+	RequireVerifiedEmail bool
+	// RequireApproval registers users as pending, they can not log in until an admin activates them
+	RequireApproval bool
+	// InviteOnly disables open registration, users can only register by accepting an invitation
+	InviteOnly bool
+	Lockout    LockoutPolicy
+	// Hasher hashes new passwords, auth.DefaultPasswordHasher is used when it is nil
+	Hasher auth.PasswordHasher
+	// PasswordPolicy decides which passwords users may choose, DefaultPasswordPolicy is used when it is nil
+	PasswordPolicy *PasswordPolicy
+	// UsernamePolicy decides which usernames users may choose, DefaultUsernamePolicy is used when it is nil
+	UsernamePolicy *UsernamePolicy
 }
 
 type UserService interface {
 	GetByID(ctx context.Context, id int64) (*model.User, error)
-	GetByUsernameAndPassword(ctx context.Context, username, password string) (*model.User, error)
-	GetUsers(ctx context.Context) ([]*model.User, error)
-	Create(ctx context.Context, username, password string) error
+	// GetByUsernameAndPassword returns the user with the given username, or email when login contains an @, and password
+	GetByUsernameAndPassword(ctx context.Context, login, password string) (*model.User, error)
+	GetUsers(ctx context.Context, filter model.UserFilter, page model.Page) (*model.UserPage, error)
+	Create(ctx context.Context, username, email, password string) (*model.User, error)
+	Update(ctx context.Context, id int64, update model.UserUpdate) (*model.User, error)
+	ChangePassword(ctx context.Context, id int64, currentPassword, newPassword string) error
+	ResetPassword(ctx context.Context, id int64, temporaryPassword string) error
+	// UnlockUser lifts a lockout caused by failed logins to the account
+	UnlockUser(ctx context.Context, id int64) error
+	// Delete soft deletes the user, they can be restored until the purge job removes them for good
 	Delete(ctx context.Context, id int64) error
+	// Restore brings back a deleted user that was not purged yet
+	Restore(ctx context.Context, id int64) (*model.User, error)
+	// ChangeStatus moves the user to status and records why, only active users can log in
+	ChangeStatus(ctx context.Context, id int64, status model.AccountStatus, reason string) (*model.User, error)
 }
 
-func NewUserService(db repository.Repository) *userService {
-	return &userService{db: db, log: l}
+func NewUserService(db repository.Repository, attempts repository.LoginAttemptRepository, opts Options) *userService {
+	hasher := opts.Hasher
+	if hasher == nil {
+		hasher = auth.DefaultPasswordHasher
+	}
+	policy := DefaultPasswordPolicy
+	if opts.PasswordPolicy != nil {
+		policy = *opts.PasswordPolicy
+	}
+	usernames := DefaultUsernamePolicy
+	if opts.UsernamePolicy != nil {
+		usernames = *opts.UsernamePolicy
+	}
+	return &userService{
+		db:   db,
+		log:  l,
+		opts: opts,
+		throttle: loginThrottle{
+			attempts: attempts,
+			policy:   opts.Lockout,
+			log:      l,
+			now:      time.Now,
+		},
+		hasher:    hasher,
+		policy:    policy,
+		usernames: usernames,
+	}
 }
 
 func (u *userService) GetByID(ctx context.Context, id int64) (*model.User, error) {
 	u.log.Info("[USER SERVICE]: Get User by ID")
 
 	if id <= 0 {
-		return nil, errors.New("invalid id")
+		return nil, model.InvalidArgumentError("invalid id")
 	}
 
 	user, err := u.db.UserById(ctx, id)
 	if err != nil {
 		u.log.Errorf("USER SERVICE: error: %v", err)
-		return nil, errors.New("could not find user with id")
+		return nil, model.WrapError(err, "could not find user with id")
 	}
 
 	err = user.Validate()
 	if err != nil {
 		u.log.Errorf("unable to validate user: %v", user)
-		return nil, errors.New("could not validate user")
+		return nil, model.WrapError(err, "could not validate user")
 	}
 
 	return user, nil
 }
 
-func (u *userService) Create(ctx context.Context, username, password string) error {
+func (u *userService) Create(ctx context.Context, username, email, password string) (*model.User, error) {
 	u.log.Info("[USER SERVICE]: Register User:" + username)
 
-	if username == "" || len(username) > 10 {
-		return errors.New("username invalid")
+	invited := invitedFromContext(ctx)
+	if u.opts.InviteOnly && !invited {
+		return nil, model.PermissionDeniedError("registration is by invitation only")
+	}
+
+	username = normalizeUsername(username)
+	email = model.NormalizeEmail(email)
+
+	fields := u.usernames.Check("username", username)
+	// The email is optional, unless users can not log in before they verified one
+	if email != "" || u.opts.RequireVerifiedEmail {
+		if err := validateEmail(email); err != nil {
+			fields = append(fields, model.FieldError{Field: "email", Message: err.Error()})
+		}
+	}
+	fields = append(fields, u.policy.Check("password", password, username)...)
+	if len(fields) > 0 {
+		return nil, model.ValidationError(fields...)
+	}
+
+	hash, err := u.hasher.Hash(password)
+	if err != nil {
+		u.log.Errorf("USER SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "error creating user")
+	}
+
+	// Inviting a user already approves them
+	status := model.StatusActive
+	if u.opts.RequireApproval && !invited {
+		status = model.StatusPending
+	}
+
+	user, err := u.db.Create(ctx, username, email, hash, status)
+	if errors.Is(err, model.ErrAlreadyExists) {
+		return nil, err
+	}
+	if err != nil {
+		u.log.Errorf("USER SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "error creating user")
+	}
+
+	return user, nil
+}
+
+func (u *userService) Update(ctx context.Context, id int64, update model.UserUpdate) (*model.User, error) {
+	u.log.Info("[USER SERVICE]: Update User")
+
+	if id <= 0 {
+		return nil, model.InvalidArgumentError("invalid id")
+	}
+
+	if update.Username != nil {
+		username := normalizeUsername(*update.Username)
+		if violations := u.usernames.Check("username", username); len(violations) > 0 {
+			return nil, model.ValidationError(violations...)
+		}
+		update.Username = &username
+	}
+
+	user, err := u.db.UserById(ctx, id)
+	if err != nil {
+		u.log.Errorf("USER SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "could not find user with id")
+	}
+
+	if update.Username != nil {
+		user.Username = *update.Username
+	}
+	if update.Admin != nil {
+		user.SetRole(model.RoleAdmin, *update.Admin)
+	}
+
+	user, err = u.db.Update(ctx, user)
+	if errors.Is(err, model.ErrAlreadyExists) {
+		return nil, model.WrapError(err, "username already exists")
+	}
+	if err != nil {
+		u.log.Errorf("USER SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "error updating user")
+	}
+
+	return user, nil
+}
+
+// ChangePassword replaces the password of a user once their current password is confirmed,
+// it also clears a password change required by an admin reset.
+func (u *userService) ChangePassword(ctx context.Context, id int64, currentPassword, newPassword string) error {
+	u.log.Info("[USER SERVICE]: Change Password")
+
+	if id <= 0 {
+		return model.InvalidArgumentError("invalid id")
+	}
+
+	user, err := u.db.UserById(ctx, id)
+	if err != nil {
+		u.log.Errorf("USER SERVICE: error: %v", err)
+		return model.WrapError(err, "could not find user with id")
+	}
+
+	// The current password is checked first, the policy violations would otherwise reveal the username and the
+	// password policy to whoever holds a stolen access token
+	err = u.hasher.Verify(user.Password, currentPassword)
+	if err != nil {
+		return model.UnauthenticatedError("current password incorrect")
+	}
+
+	if violations := u.policy.Check("new_password", newPassword, user.Username); len(violations) > 0 {
+		return model.ValidationError(violations...)
+	}
+
+	if currentPassword == newPassword {
+		return model.ValidationError(model.FieldError{Field: "new_password", Message: "new password must differ from the current password"})
+	}
+
+	hash, err := u.hasher.Hash(newPassword)
+	if err != nil {
+		u.log.Errorf("USER SERVICE: error: %v", err)
+		return model.WrapError(err, "error changing password")
+	}
+
+	err = u.db.UpdatePassword(ctx, id, hash, false)
+	if err != nil {
+		u.log.Errorf("USER SERVICE: error: %v", err)
+		return model.WrapError(err, "error changing password")
+	}
+
+	return nil
+}
+
+// ResetPassword sets a temporary password chosen by an admin, the user has to change it after their next login
+func (u *userService) ResetPassword(ctx context.Context, id int64, temporaryPassword string) error {
+	u.log.Info("[USER SERVICE]: Reset Password")
+
+	if id <= 0 {
+		return model.InvalidArgumentError("invalid id")
+	}
+
+	user, err := u.db.UserById(ctx, id)
+	if err != nil {
+		u.log.Errorf("USER SERVICE: error: %v", err)
+		return model.WrapError(err, "could not find user with id")
 	}
 
-	if password == "" || len(password) < 8 {
-		return errors.New("password invalid")
+	if violations := u.policy.Check("temporary_password", temporaryPassword, user.Username); len(violations) > 0 {
+		return model.ValidationError(violations...)
 	}
 
-	err := u.db.Create(ctx, username, password)
+	hash, err := u.hasher.Hash(temporaryPassword)
 	if err != nil {
-		return errors.New("error creating user")
+		u.log.Errorf("USER SERVICE: error: %v", err)
+		return model.WrapError(err, "error resetting password")
+	}
+
+	err = u.db.UpdatePassword(ctx, id, hash, true)
+	if err != nil {
+		u.log.Errorf("USER SERVICE: error: %v", err)
+		return model.WrapError(err, "error resetting password")
 	}
 
 	return nil
 }
 
-func (u *userService) GetUsers(ctx context.Context) ([]*model.User, error) {
+func (u *userService) GetUsers(ctx context.Context, filter model.UserFilter, page model.Page) (*model.UserPage, error) {
 	u.log.Info("[USER SERVICE]: Get Users")
 
-	users, err := u.db.GetUsers(ctx)
+	afterID, err := decodePageToken(page.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	size := pageSize(page)
+
+	// Fetch one extra user to find out whether there is a next page
+	users, err := u.db.GetUsers(ctx, filter, afterID, size+1)
 	if err != nil {
-		return nil, errors.New("unable to get users")
+		u.log.Errorf("USER SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "unable to get users")
+	}
+
+	res := &model.UserPage{Users: users}
+	if len(users) > size {
+		res.Users = users[:size]
+		res.NextPageToken = encodePageToken(res.Users[size-1].ID)
 	}
 
-	for _, user := range users {
+	for _, user := range res.Users {
 		err = user.Validate()
 		if err != nil {
 			u.log.Errorf("error user in users array not valid: %d, error: %v", user.ID, err)
 		}
 	}
 
-	return users, nil
+	return res, nil
 }
 
 func (u *userService) Delete(ctx context.Context, id int64) error {
 	u.log.Info("[USER SERVICE]: Delete User")
 
 	if id <= 0 {
-		return errors.New("invalid id")
+		return model.InvalidArgumentError("invalid id")
 	}
 
 	err := u.db.Delete(ctx, id)
 	if err != nil {
-		return errors.New("user not found with id")
+		u.log.Errorf("USER SERVICE: error: %v", err)
+		return model.WrapError(err, "user not found with id")
 	}
 
 	return nil
 }
 
-func (u *userService) GetByUsernameAndPassword(ctx context.Context, username, password string) (*model.User, error) {
-	u.log.Info("[USER SERVICE]: Get User by Username")
+func (u *userService) Restore(ctx context.Context, id int64) (*model.User, error) {
+	u.log.Info("[USER SERVICE]: Restore User")
 
-	user, err := u.db.UserByUsername(ctx, username)
+	if id <= 0 {
+		return nil, model.InvalidArgumentError("invalid id")
+	}
+
+	user, err := u.db.Restore(ctx, id)
 	if err != nil {
-		return nil, errors.New("user not found with username")
+		u.log.Errorf("USER SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "deleted user not found with id")
+	}
+
+	return user, nil
+}
+
+func (u *userService) GetByUsernameAndPassword(ctx context.Context, login, password string) (*model.User, error) {
+	u.log.Info("[USER SERVICE]: Get User by Username")
+
+	ip := clientIPFromContext(ctx)
+	if err := u.throttle.checkClient(ctx, ip); err != nil {
+		return nil, err
 	}
 
-	err = user.ValidatePassword(password)
+	var (
+		user *model.User
+		err  error
+	)
+	if strings.Contains(login, "@") {
+		user, err = u.db.UserByEmail(ctx, model.NormalizeEmail(login))
+	} else {
+		login = normalizeUsername(login)
+		user, err = u.db.UserByUsername(ctx, login)
+	}
+	if err != nil && !errors.Is(err, model.ErrNotFound) {
+		u.log.Errorf("USER SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "unable to get user")
+	}
+
+	key := loginKey(strings.ToLower(login))
+	if user != nil {
+		key = accountKey(user.ID)
+	}
+	if err := u.throttle.checkAccount(ctx, key); err != nil {
+		return nil, err
+	}
+
+	// Unknown logins are compared against a dummy hash, and get the same error as a wrong password,
+	// so neither the response nor its timing reveal which accounts exist
+	if user == nil {
+		_ = u.hasher.Verify(u.dummyPasswordHash(), password)
+		if err := u.throttle.fail(ctx, key, ip); err != nil {
+			return nil, err
+		}
+		return nil, errInvalidCredentials
+	}
+
+	err = u.hasher.Verify(user.Password, password)
 	if err != nil {
-		return nil, errors.New("user password incorrect")
+		if !errors.Is(err, auth.ErrPasswordMismatch) {
+			u.log.Errorf("USER SERVICE: error verifying password of user %d: %v", user.ID, err)
+		}
+		if err := u.throttle.fail(ctx, key, ip); err != nil {
+			return nil, err
+		}
+		return nil, errInvalidCredentials
+	}
+
+	if err := u.throttle.clear(ctx, key); err != nil {
+		return nil, err
+	}
+
+	u.rehashPassword(ctx, user, password)
+
+	// Only checked once the password is known to be right, so it does not reveal which accounts exist
+	if err := user.CheckActive(); err != nil {
+		return nil, err
+	}
+	if u.opts.RequireVerifiedEmail && !user.EmailVerified() {
+		return nil, model.PermissionDeniedError("email address not verified")
 	}
 
 	err = user.Validate()
 	if err != nil {
 		u.log.Errorf("unable to validate user: %v", user)
-		return nil, errors.New("could not validate user")
+		return nil, model.WrapError(err, "could not validate user")
+	}
+
+	return user, nil
+}
+
+func (u *userService) ChangeStatus(ctx context.Context, id int64, status model.AccountStatus, reason string) (*model.User, error) {
+	u.log.Info("[USER SERVICE]: Change Status")
+
+	if id <= 0 {
+		return nil, model.InvalidArgumentError("invalid id")
+	}
+
+	reason = strings.TrimSpace(reason)
+
+	var fields []model.FieldError
+	if !status.Valid() {
+		fields = append(fields, model.FieldError{Field: "status", Message: "status must be one of pending, active, suspended or disabled"})
+	}
+	if reason == "" && (status == model.StatusSuspended || status == model.StatusDisabled) {
+		fields = append(fields, model.FieldError{Field: "reason", Message: "reason is required to suspend or disable an account"})
+	}
+	if len(reason) > maxStatusReasonLength {
+		fields = append(fields, model.FieldError{Field: "reason", Message: fmt.Sprintf("reason must be at most %d characters", maxStatusReasonLength)})
+	}
+	if len(fields) > 0 {
+		return nil, model.ValidationError(fields...)
+	}
+
+	user, err := u.db.UserById(ctx, id)
+	if err != nil {
+		u.log.Errorf("USER SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "could not find user with id")
+	}
+
+	if user.Status == status {
+		return nil, model.InvalidArgumentError(fmt.Sprintf("account is already %s", status))
+	}
+	if !user.Status.CanTransitionTo(status) {
+		return nil, model.InvalidArgumentError(fmt.Sprintf("account can not change from %s to %s", user.Status, status))
+	}
+
+	user, err = u.db.UpdateStatus(ctx, id, user.Status, status, reason, time.Now())
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, model.WrapError(err, "account status changed meanwhile, try again")
+	}
+	if err != nil {
+		u.log.Errorf("USER SERVICE: error: %v", err)
+		return nil, model.WrapError(err, "error changing account status")
 	}
 
+	u.log.Infof("[USER SERVICE]: User %d is now %s", id, status)
+
 	return user, nil
 }
+
+// UnlockUser lets an admin lift a lockout before it runs out
+func (u *userService) UnlockUser(ctx context.Context, id int64) error {
+	u.log.Info("[USER SERVICE]: Unlock User")
+
+	if id <= 0 {
+		return model.InvalidArgumentError("invalid id")
+	}
+
+	_, err := u.db.UserById(ctx, id)
+	if err != nil {
+		u.log.Errorf("USER SERVICE: error: %v", err)
+		return model.WrapError(err, "could not find user with id")
+	}
+
+	return u.throttle.clear(ctx, accountKey(id))
+}
+
+// dummyPasswordHash is compared against when a login matches no account, it has the cost of a real password hash
+func (u *userService) dummyPasswordHash() string {
+	u.dummyHashOnce.Do(func() {
+		u.dummyHash, _ = u.hasher.Hash("dummy password")
+	})
+	return u.dummyHash
+}
+
+// rehashPassword replaces a stored hash made with an outdated algorithm or parameters while the password is
+// known, failures are only logged as the old hash still works
+func (u *userService) rehashPassword(ctx context.Context, user *model.User, password string) {
+	if !u.hasher.NeedsRehash(user.Password) {
+		return
+	}
+
+	hash, err := u.hasher.Hash(password)
+	if err != nil {
+		u.log.Errorf("USER SERVICE: error rehashing password of user %d: %v", user.ID, err)
+		return
+	}
+
+	err = u.db.ReplacePasswordHash(ctx, user.ID, user.Password, hash)
+	if err != nil {
+		u.log.Errorf("USER SERVICE: error rehashing password of user %d: %v", user.ID, err)
+		return
+	}
+	u.log.Infof("[USER SERVICE]: Rehashed password of user %d", user.ID)
+
+	user.Password = hash
+}
+
+func validateEmail(email string) error {
+	if email == "" || len(email) > 254 {
+		return model.InvalidArgumentError("email invalid")
+	}
+	// Only a bare address is accepted, not "Name <address>"
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return model.InvalidArgumentError("email invalid")
+	}
+	return nil
+}
+
+// pageSize is the number of items to return for page, DefaultPageSize when it asks for none and at most MaxPageSize
+func pageSize(page model.Page) int {
+	if page.Size <= 0 {
+		return DefaultPageSize
+	}
+	if page.Size > MaxPageSize {
+		return MaxPageSize
+	}
+	return page.Size
+}
+
+// encodePageToken returns an opaque token pointing after the user, or group, with the given id
+func encodePageToken(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+func decodePageToken(token string) (int64, error) {
+	if token == "" {
+		return 0, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, model.InvalidArgumentError("invalid page token")
+	}
+
+	id, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil || id < 0 {
+		return 0, model.InvalidArgumentError("invalid page token")
+	}
+
+	return id, nil
+}
@@ -2,15 +2,26 @@ package service
 
 import (
 	"context"
-	"errors"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/JamieBShaw/user-service/auth"
 	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/JamieBShaw/user-service/repository"
+	"github.com/JamieBShaw/user-service/repository/memory"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
 )
 
-type mockDb struct{}
+// verifiedAt is when David, the only seeded user with a verified email, verified it
+var verifiedAt = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// passwordHash is the hash of "password" shared by every mock user, hashed once with the minimum cost
+var passwordHash, _ = bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+
+// testHasher hashes with the cost of passwordHash, so logging in as a mock user needs no rehash
+var testHasher, _ = auth.NewBcryptHasher(bcrypt.MinCost)
 
 func TestUserService_GetUserById_Test_Cases(t *testing.T) {
 	tt := []struct {
@@ -21,11 +32,15 @@ func TestUserService_GetUserById_Test_Cases(t *testing.T) {
 	}{
 		{
 			name: "get valid user",
-			id:   1,
+			id:   2,
 			res: &model.User{
-				ID:       1,
-				Username: "David",
-				Admin:    false,
+				ID:             2,
+				Username:       "David",
+				Email:          "david@example.com",
+				Password:       string(passwordHash),
+				VerifiedAt:     &verifiedAt,
+				Status:         model.StatusActive,
+				OrganizationID: model.DefaultOrganizationID,
 			},
 			errMsg: "",
 		},
@@ -41,8 +56,9 @@ func TestUserService_GetUserById_Test_Cases(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
 			service := userService{
-				db:  mockDb{},
-				log: l,
+				db:     newTestRepository(t),
+				log:    l,
+				hasher: testHasher,
 			}
 			user, err := service.GetByID(context.Background(), tc.id)
 			if err != nil {
@@ -50,6 +66,8 @@ func TestUserService_GetUserById_Test_Cases(t *testing.T) {
 				assert.Equal(t, tc.res, user)
 				return
 			}
+			// Timestamps are set by the repository
+			user.CreatedAt, user.UpdatedAt = time.Time{}, time.Time{}
 			assert.Equal(t, tc.res, user)
 		})
 	}
@@ -59,26 +77,72 @@ func TestUserService_Create_Test_Cases(t *testing.T) {
 	tt := []struct {
 		name     string
 		username string
+		email    string
 		pasword  string
 		errMsg   string
 	}{
 		{
 			name:     "create user successfully",
-			username: "david",
+			username: "dave",
+			email:    "Dave@Example.com ",
+			pasword:  "password",
+			errMsg:   "",
+		},
+		{
+			name:     "create user with normalized username",
+			username: " ｄａｖｅ ",
+			email:    "Dave@Example.com ",
 			pasword:  "password",
 			errMsg:   "",
 		},
 		{
 			name:     "error creating user, invalid username request",
 			username: "",
+			email:    "david@example.com",
+			pasword:  "password",
+			errMsg:   "username must be at least 3 characters",
+		},
+		{
+			name:     "error creating user, username with invalid characters",
+			username: "da vid",
+			email:    "david@example.com",
+			pasword:  "password",
+			errMsg:   "username may only contain letters, digits, '.', '_' and '-'",
+		},
+		{
+			name:     "error creating user, reserved username",
+			username: "Admin",
+			email:    "admin@example.com",
+			pasword:  "password",
+			errMsg:   "username is reserved",
+		},
+		{
+			name:     "error creating user, username taken ignoring case",
+			username: "david",
+			email:    "dave@example.com",
 			pasword:  "password",
-			errMsg:   "username invalid",
+			errMsg:   "username already exists",
+		},
+		{
+			name:     "error creating user, invalid email request",
+			username: "david",
+			email:    "David <david@example.com>",
+			pasword:  "password",
+			errMsg:   "email invalid",
 		},
 		{
 			name:     "error creating user, invalid password request",
 			username: "david",
+			email:    "david@example.com",
 			pasword:  "",
-			errMsg:   "password invalid",
+			errMsg:   "password must be at least 8 characters",
+		},
+		{
+			name:     "error creating user, password contains username",
+			username: "david",
+			email:    "david@example.com",
+			pasword:  "David1234",
+			errMsg:   "password must not contain the username",
 		},
 	}
 	for _, tc := range tt {
@@ -86,14 +150,223 @@ func TestUserService_Create_Test_Cases(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
 			service := userService{
-				db:  mockDb{},
-				log: l,
+				db:        newTestRepository(t),
+				log:       l,
+				hasher:    testHasher,
+				policy:    DefaultPasswordPolicy,
+				usernames: DefaultUsernamePolicy,
 			}
-			err := service.Create(context.Background(), tc.username, tc.pasword)
+			user, err := service.Create(context.Background(), tc.username, tc.email, tc.pasword)
 			if err != nil {
 				assert.Equal(t, tc.errMsg, err.Error())
 				return
 			}
+			assert.Equal(t, "dave", user.Username)
+			assert.Equal(t, "dave@example.com", user.Email)
+		})
+	}
+}
+
+func TestUserService_GetByUsernameAndPassword_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name          string
+		login         string
+		password      string
+		requireVerify bool
+		id            int64
+		code          model.ErrorCode
+	}{
+		{
+			name:     "login with username",
+			login:    "Michael",
+			password: "password",
+			id:       3,
+		},
+		{
+			name:     "login with username ignores case and width",
+			login:    " ｍｉｃｈａｅｌ",
+			password: "password",
+			id:       3,
+		},
+		{
+			name:     "login with email",
+			login:    "Michael@Example.com",
+			password: "password",
+			id:       3,
+		},
+		{
+			name:     "unknown email",
+			login:    "john@example.com",
+			password: "password",
+			code:     model.CodeUnauthenticated,
+		},
+		{
+			name:     "wrong password",
+			login:    "michael@example.com",
+			password: "wrong-password",
+			code:     model.CodeUnauthenticated,
+		},
+		{
+			name:          "unverified email when verification is required",
+			login:         "Michael",
+			password:      "password",
+			requireVerify: true,
+			code:          model.CodePermissionDenied,
+		},
+		{
+			name:          "wrong password is checked before verification",
+			login:         "Michael",
+			password:      "wrong-password",
+			requireVerify: true,
+			code:          model.CodeUnauthenticated,
+		},
+		{
+			name:          "verified email when verification is required",
+			login:         "david@example.com",
+			password:      "password",
+			requireVerify: true,
+			id:            2,
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			service := NewUserService(newTestRepository(t), nil, Options{RequireVerifiedEmail: tc.requireVerify, Hasher: testHasher})
+
+			user, err := service.GetByUsernameAndPassword(context.Background(), tc.login, tc.password)
+			if tc.code != "" {
+				assert.Equal(t, tc.code, model.ErrorCodeOf(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.id, user.ID)
+		})
+	}
+}
+
+func TestUserService_GetByUsernameAndPassword_Rehash_Test_Cases(t *testing.T) {
+	argon2id, _ := auth.NewArgon2idHasher(auth.Argon2idParams{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+	bcryptCost5, _ := auth.NewBcryptHasher(5)
+
+	tt := []struct {
+		name       string
+		hasher     auth.PasswordHasher
+		replaceErr error
+		rehashed   bool
+	}{
+		{
+			name:     "hash with current parameters is kept",
+			hasher:   testHasher,
+			rehashed: false,
+		},
+		{
+			name:     "hash with another bcrypt cost is replaced",
+			hasher:   bcryptCost5,
+			rehashed: true,
+		},
+		{
+			name:     "bcrypt hash is replaced with argon2id",
+			hasher:   argon2id,
+			rehashed: true,
+		},
+		{
+			name:       "login succeeds when the hash can not be replaced",
+			hasher:     argon2id,
+			replaceErr: model.NotFoundError("user not found"),
+			rehashed:   false,
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			db := &rehashDb{Repository: newTestRepository(t), err: tc.replaceErr}
+			service := NewUserService(db, nil, Options{Hasher: tc.hasher})
+
+			user, err := service.GetByUsernameAndPassword(context.Background(), "Michael", "password")
+			assert.NoError(t, err)
+
+			if !tc.rehashed {
+				assert.Equal(t, string(passwordHash), user.Password)
+				return
+			}
+			assert.Equal(t, string(passwordHash), db.oldHash)
+			assert.Equal(t, db.newHash, user.Password)
+			assert.False(t, tc.hasher.NeedsRehash(user.Password))
+			assert.NoError(t, tc.hasher.Verify(user.Password, "password"))
+		})
+	}
+}
+
+func TestUserService_Update_Test_Cases(t *testing.T) {
+	username := "Dave"
+	longUsername := "usernameover32characterslongxxxxx"
+	admin := true
+
+	tt := []struct {
+		name   string
+		id     int64
+		update model.UserUpdate
+		res    *model.User
+		errMsg string
+	}{
+		{
+			name:   "update username and admin successfully",
+			id:     2,
+			update: model.UserUpdate{Username: &username, Admin: &admin},
+			res: &model.User{
+				ID:             2,
+				Username:       "Dave",
+				Email:          "david@example.com",
+				Password:       string(passwordHash),
+				Roles:          []string{model.RoleAdmin},
+				VerifiedAt:     &verifiedAt,
+				Status:         model.StatusActive,
+				OrganizationID: model.DefaultOrganizationID,
+			},
+			errMsg: "",
+		},
+		{
+			name:   "invalid request; not valid id",
+			id:     0,
+			update: model.UserUpdate{Admin: &admin},
+			res:    nil,
+			errMsg: "invalid id",
+		},
+		{
+			name:   "invalid request; username too long",
+			id:     2,
+			update: model.UserUpdate{Username: &longUsername},
+			res:    nil,
+			errMsg: "username must be at most 32 characters",
+		},
+		{
+			name:   "invalid request; user does not exist",
+			id:     42,
+			update: model.UserUpdate{Admin: &admin},
+			res:    nil,
+			errMsg: "could not find user with id",
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			service := userService{
+				db:        newTestRepository(t),
+				log:       l,
+				hasher:    testHasher,
+				usernames: DefaultUsernamePolicy,
+			}
+			user, err := service.Update(context.Background(), tc.id, tc.update)
+			if err != nil {
+				assert.Equal(t, tc.errMsg, err.Error())
+				assert.Equal(t, tc.res, user)
+				return
+			}
+			user.CreatedAt, user.UpdatedAt = time.Time{}, time.Time{}
+			assert.Equal(t, tc.res, user)
 		})
 	}
 }
@@ -126,8 +399,9 @@ func TestUserService_Delete_Test_Cases(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
 			service := userService{
-				db:  mockDb{},
-				log: l,
+				db:     newTestRepository(t),
+				log:    l,
+				hasher: testHasher,
 			}
 			err := service.Delete(context.Background(), tc.id)
 			if err != nil {
@@ -139,68 +413,552 @@ func TestUserService_Delete_Test_Cases(t *testing.T) {
 	}
 }
 
+func TestUserService_ChangePassword_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name            string
+		id              int64
+		currentPassword string
+		newPassword     string
+		errMsg          string
+		code            model.ErrorCode
+	}{
+		{
+			name:            "password changed",
+			id:              2,
+			currentPassword: "password",
+			newPassword:     "new-password",
+		},
+		{
+			name:            "current password incorrect",
+			id:              2,
+			currentPassword: "wrong-password",
+			newPassword:     "new-password",
+			errMsg:          "current password incorrect",
+			code:            model.CodeUnauthenticated,
+		},
+		{
+			name:            "current password incorrect with a weak new password",
+			id:              2,
+			currentPassword: "wrong-password",
+			newPassword:     "david",
+			errMsg:          "current password incorrect",
+			code:            model.CodeUnauthenticated,
+		},
+		{
+			name:            "new password too short",
+			id:              2,
+			currentPassword: "password",
+			newPassword:     "short",
+			errMsg:          "password must be at least 8 characters",
+			code:            model.CodeInvalidArgument,
+		},
+		{
+			name:            "new password contains username",
+			id:              2,
+			currentPassword: "password",
+			newPassword:     "new-david-password",
+			errMsg:          "password must not contain the username",
+			code:            model.CodeInvalidArgument,
+		},
+		{
+			name:            "new password same as current",
+			id:              2,
+			currentPassword: "password",
+			newPassword:     "password",
+			errMsg:          "new password must differ from the current password",
+			code:            model.CodeInvalidArgument,
+		},
+		{
+			name:            "user does not exist",
+			id:              42,
+			currentPassword: "password",
+			newPassword:     "new-password",
+			errMsg:          "could not find user with id",
+			code:            model.CodeNotFound,
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			service := userService{
+				db:     newTestRepository(t),
+				log:    l,
+				hasher: testHasher,
+				policy: DefaultPasswordPolicy,
+			}
+			err := service.ChangePassword(context.Background(), tc.id, tc.currentPassword, tc.newPassword)
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, err.Error())
+				assert.Equal(t, tc.code, model.ErrorCodeOf(err))
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestUserService_ResetPassword_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name     string
+		id       int64
+		password string
+		errMsg   string
+	}{
+		{
+			name:     "password reset",
+			id:       1,
+			password: "temporary",
+		},
+		{
+			name:     "temporary password too short",
+			id:       1,
+			password: "temp",
+			errMsg:   "password must be at least 8 characters",
+		},
+		{
+			name:     "user does not exist",
+			id:       42,
+			password: "temporary",
+			errMsg:   "could not find user with id",
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			service := userService{
+				db:     newTestRepository(t),
+				log:    l,
+				hasher: testHasher,
+				policy: DefaultPasswordPolicy,
+			}
+			err := service.ResetPassword(context.Background(), tc.id, tc.password)
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, err.Error())
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestUserService_ChangeStatus_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name   string
+		id     int64
+		from   model.AccountStatus
+		status model.AccountStatus
+		reason string
+		stale  bool
+		errMsg string
+		code   model.ErrorCode
+	}{
+		{
+			name:   "suspend active user",
+			id:     1,
+			from:   model.StatusActive,
+			status: model.StatusSuspended,
+			reason: "  chargeback  ",
+		},
+		{
+			name:   "activate pending user without reason",
+			id:     1,
+			from:   model.StatusPending,
+			status: model.StatusActive,
+		},
+		{
+			name:   "invalid id",
+			id:     0,
+			status: model.StatusActive,
+			errMsg: "invalid id",
+			code:   model.CodeInvalidArgument,
+		},
+		{
+			name:   "unknown status",
+			id:     1,
+			status: "banned",
+			reason: "spam",
+			errMsg: "status must be one of pending, active, suspended or disabled",
+			code:   model.CodeInvalidArgument,
+		},
+		{
+			name:   "suspend without reason",
+			id:     1,
+			status: model.StatusSuspended,
+			reason: "   ",
+			errMsg: "reason is required to suspend or disable an account",
+			code:   model.CodeInvalidArgument,
+		},
+		{
+			name:   "reason too long",
+			id:     1,
+			status: model.StatusDisabled,
+			reason: strings.Repeat("a", 501),
+			errMsg: "reason must be at most 500 characters",
+			code:   model.CodeInvalidArgument,
+		},
+		{
+			name:   "user does not exist",
+			id:     42,
+			status: model.StatusActive,
+			errMsg: "could not find user with id",
+			code:   model.CodeNotFound,
+		},
+		{
+			name:   "status unchanged",
+			id:     1,
+			from:   model.StatusActive,
+			status: model.StatusActive,
+			errMsg: "account is already active",
+			code:   model.CodeInvalidArgument,
+		},
+		{
+			name:   "disabled user can not be reactivated",
+			id:     1,
+			from:   model.StatusDisabled,
+			status: model.StatusActive,
+			errMsg: "account can not change from disabled to active",
+			code:   model.CodeInvalidArgument,
+		},
+		{
+			name:   "status changed concurrently",
+			id:     1,
+			from:   model.StatusActive,
+			status: model.StatusSuspended,
+			reason: "spam",
+			stale:  true,
+			errMsg: "account status changed meanwhile, try again",
+			code:   model.CodeNotFound,
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			db := newTestRepository(t)
+			setStatus(t, db, tc.id, tc.from)
+			service := userService{
+				db:     db,
+				log:    l,
+				hasher: testHasher,
+			}
+			if tc.stale {
+				service.db = staleDb{db}
+			}
+			user, err := service.ChangeStatus(context.Background(), tc.id, tc.status, tc.reason)
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, err.Error())
+				assert.Equal(t, tc.code, model.ErrorCodeOf(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.status, user.Status)
+			assert.Equal(t, strings.TrimSpace(tc.reason), user.StatusReason)
+			assert.NotNil(t, user.StatusChangedAt)
+		})
+	}
+}
+
+func TestUserService_Status_Login_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name   string
+		status model.AccountStatus
+		errMsg string
+	}{
+		{
+			name:   "active user logs in",
+			status: model.StatusActive,
+		},
+		{
+			name:   "pending user refused",
+			status: model.StatusPending,
+			errMsg: "account is pending activation",
+		},
+		{
+			name:   "suspended user refused",
+			status: model.StatusSuspended,
+			errMsg: "account is suspended",
+		},
+		{
+			name:   "disabled user refused",
+			status: model.StatusDisabled,
+			errMsg: "account is disabled",
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			db := newTestRepository(t)
+			setStatus(t, db, 3, tc.status)
+			service := NewUserService(db, nil, Options{Hasher: testHasher})
+
+			_, err := service.GetByUsernameAndPassword(context.Background(), "Michael", "password")
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, err.Error())
+				assert.Equal(t, model.CodePermissionDenied, model.ErrorCodeOf(err))
+				return
+			}
+			assert.NoError(t, err)
+
+			// A wrong password is refused without revealing the account status
+			_, err = service.GetByUsernameAndPassword(context.Background(), "Michael", "wrong-password")
+			assert.Equal(t, model.CodeUnauthenticated, model.ErrorCodeOf(err))
+		})
+	}
+}
+
+func TestUserService_Create_Require_Approval(t *testing.T) {
+	service := NewUserService(newTestRepository(t), nil, Options{Hasher: testHasher, RequireApproval: true})
+
+	user, err := service.Create(context.Background(), "dave", "dave@example.com", "password")
+	assert.NoError(t, err)
+	assert.Equal(t, model.StatusPending, user.Status)
+
+	service = NewUserService(newTestRepository(t), nil, Options{Hasher: testHasher})
+
+	user, err = service.Create(context.Background(), "dave", "dave@example.com", "password")
+	assert.NoError(t, err)
+	assert.Equal(t, model.StatusActive, user.Status)
+}
+
+func TestUserService_Create_Without_Email(t *testing.T) {
+	service := NewUserService(newTestRepository(t), nil, Options{Hasher: testHasher})
+
+	user, err := service.Create(context.Background(), "dave", "", "password")
+	assert.NoError(t, err)
+	assert.Equal(t, "dave", user.Username)
+	assert.Empty(t, user.Email)
+
+	// Users without an email do not take the empty one from each other
+	_, err = service.Create(context.Background(), "eve", "", "password")
+	assert.NoError(t, err)
+
+	// They could never log in when a verified email is required
+	service = NewUserService(newTestRepository(t), nil, Options{Hasher: testHasher, RequireVerifiedEmail: true})
+
+	_, err = service.Create(context.Background(), "dave", "", "password")
+	assert.Equal(t, model.CodeInvalidArgument, model.ErrorCodeOf(err))
+	assert.Contains(t, err.Error(), "email invalid")
+}
+
+func TestUserService_Create_Invite_Only(t *testing.T) {
+	service := NewUserService(newTestRepository(t), nil, Options{Hasher: testHasher, InviteOnly: true})
+
+	_, err := service.Create(context.Background(), "dave", "dave@example.com", "password")
+	assert.Equal(t, "registration is by invitation only", err.Error())
+	assert.Equal(t, model.CodePermissionDenied, model.ErrorCodeOf(err))
+
+	user, err := service.Create(withInvitation(context.Background()), "dave", "dave@example.com", "password")
+	assert.NoError(t, err)
+	assert.Equal(t, "dave", user.Username)
+}
+
 func TestUserService_GetUsers(t *testing.T) {
 	service := userService{
-		db:  mockDb{},
-		log: l,
+		db:     newTestRepository(t),
+		log:    l,
+		hasher: testHasher,
 	}
-	users, err := service.GetUsers(context.Background())
+	page, err := service.GetUsers(context.Background(), model.UserFilter{}, model.Page{})
 	if err != nil {
 		return
 	}
-	assert.IsType(t, []*model.User{}, users)
+	assert.IsType(t, []*model.User{}, page.Users)
 }
 
-func (m mockDb) UserById(ctx context.Context, id int64) (*model.User, error) {
-	users := generateUsers()
-
-	for _, user := range users {
-		if id == user.ID {
-			return user, nil
-		}
+func TestUserService_GetUsers_Pagination_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name          string
+		page          model.Page
+		ids           []int64
+		nextPageToken string
+		errMsg        string
+	}{
+		{
+			name:          "first page",
+			page:          model.Page{Size: 1},
+			ids:           []int64{1},
+			nextPageToken: encodePageToken(1),
+		},
+		{
+			name:          "last page",
+			page:          model.Page{Size: 2, Token: encodePageToken(1)},
+			ids:           []int64{2, 3},
+			nextPageToken: "",
+		},
+		{
+			name:   "invalid page token",
+			page:   model.Page{Size: 2, Token: "not-a-token"},
+			errMsg: "invalid page token",
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			service := userService{
+				db:     newTestRepository(t),
+				log:    l,
+				hasher: testHasher,
+			}
+			page, err := service.GetUsers(context.Background(), model.UserFilter{}, tc.page)
+			if err != nil {
+				assert.Equal(t, tc.errMsg, err.Error())
+				return
+			}
+			var ids []int64
+			for _, user := range page.Users {
+				ids = append(ids, user.ID)
+			}
+			assert.Equal(t, tc.ids, ids)
+			assert.Equal(t, tc.nextPageToken, page.NextPageToken)
+		})
 	}
-	return nil, errors.New("user not found")
 }
 
-func (m mockDb) Create(ctx context.Context, username, password string) error {
-	if username == "" {
-		return errors.New("invalid username")
+func TestUserService_Error_Codes_Test_Cases(t *testing.T) {
+	service := userService{
+		db:     newTestRepository(t),
+		log:    l,
+		hasher: testHasher,
+		policy: DefaultPasswordPolicy,
 	}
-	return nil
-}
 
-func (m mockDb) UserByUsername(ctx context.Context, username string) (*model.User, error) {
-	return nil, nil
+	tt := []struct {
+		name string
+		call func() error
+		code model.ErrorCode
+	}{
+		{
+			name: "get by invalid id",
+			call: func() error {
+				_, err := service.GetByID(context.Background(), 0)
+				return err
+			},
+			code: model.CodeInvalidArgument,
+		},
+		{
+			name: "get user that does not exist",
+			call: func() error {
+				_, err := service.GetByID(context.Background(), 42)
+				return err
+			},
+			code: model.CodeNotFound,
+		},
+		{
+			name: "create with invalid password",
+			call: func() error {
+				_, err := service.Create(context.Background(), "david", "david@example.com", "short")
+				return err
+			},
+			code: model.CodeInvalidArgument,
+		},
+		{
+			name: "create with taken username",
+			call: func() error {
+				_, err := service.Create(context.Background(), "James", "new@example.com", "password")
+				return err
+			},
+			code: model.CodeAlreadyExists,
+		},
+		{
+			name: "create with taken email",
+			call: func() error {
+				_, err := service.Create(context.Background(), "john", "james@example.com", "password")
+				return err
+			},
+			code: model.CodeAlreadyExists,
+		},
+		{
+			name: "delete user that does not exist",
+			call: func() error {
+				return service.Delete(context.Background(), 42)
+			},
+			code: model.CodeNotFound,
+		},
+		{
+			name: "restore user that is not deleted",
+			call: func() error {
+				_, err := service.Restore(context.Background(), 42)
+				return err
+			},
+			code: model.CodeNotFound,
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.code, model.ErrorCodeOf(tc.call()))
+		})
+	}
 }
 
-func (m mockDb) GetUsers(ctx context.Context) ([]*model.User, error) {
-	users := generateUsers()
-	return users, nil
+// testRepository is implemented by the memory repository the service tests run against
+type testRepository interface {
+	repository.Repository
+	repository.EmailVerificationRepository
+	repository.RoleRepository
+	repository.OrganizationRepository
+	repository.GroupRepository
 }
 
-func (m mockDb) Delete(ctx context.Context, id int64) error {
-	users := generateUsers()
+// newTestRepository returns a memory repository holding the active users James (id 1), David (id 2) and Michael
+// (id 3) of the default organization. Their password is "password", their email is their lower case name at
+// example.com and only David verified it, at verifiedAt.
+func newTestRepository(t *testing.T) testRepository {
+	t.Helper()
+	ctx := context.Background()
+	db := memory.NewRepository(l)
 
-	for _, user := range users {
-		if id == user.ID {
-			return nil
+	for _, name := range []string{"James", "David", "Michael"} {
+		if _, err := db.Create(ctx, name, strings.ToLower(name)+"@example.com", string(passwordHash), model.StatusActive); err != nil {
+			t.Fatalf("could not seed repository: %v", err)
 		}
 	}
-	return errors.New("no user found")
+	if err := db.CreateVerificationToken(ctx, 2, "david@example.com", "seed", verifiedAt.Add(time.Hour)); err != nil {
+		t.Fatalf("could not seed repository: %v", err)
+	}
+	if err := db.VerifyEmail(ctx, "seed", verifiedAt); err != nil {
+		t.Fatalf("could not seed repository: %v", err)
+	}
+
+	return db
 }
 
-func generateUsers() []*model.User {
-	var users []*model.User
-	names := []string{"James", "David", "Michael"}
+// setStatus moves the active user with id to status
+func setStatus(t *testing.T, db repository.Repository, id int64, status model.AccountStatus) {
+	t.Helper()
+	if status == "" || status == model.StatusActive {
+		return
+	}
+	if _, err := db.UpdateStatus(context.Background(), id, model.StatusActive, status, "", time.Now()); err != nil {
+		t.Fatalf("could not change status: %v", err)
+	}
+}
 
-	for i, name := range names {
-		users = append(users, &model.User{
-			ID:        int64(i),
-			Username:  name,
-			Admin:     false,
-			CreatedAt: time.Time{},
-			UpdatedAt: time.Time{},
-		})
+// rehashDb records the hash it was asked to replace before replacing it, or fails with err when it is set
+type rehashDb struct {
+	repository.Repository
+	err              error
+	oldHash, newHash string
+}
+
+func (m *rehashDb) ReplacePasswordHash(ctx context.Context, id int64, oldHash, newHash string) error {
+	if m.err != nil {
+		return m.err
 	}
-	return users
+	m.oldHash, m.newHash = oldHash, newHash
+	return m.Repository.ReplacePasswordHash(ctx, id, oldHash, newHash)
+}
+
+// staleDb fails every status change as if another admin changed the status first
+type staleDb struct {
+	repository.Repository
+}
+
+func (m staleDb) UpdateStatus(ctx context.Context, id int64, from, to model.AccountStatus, reason string, at time.Time) (*model.User, error) {
+	return nil, model.NotFoundError("no user found")
 }
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Codes of the username policy violations, sent to clients with each field error so they can show their own message
+const (
+	UsernameTooShort          = "username_too_short"
+	UsernameTooLong           = "username_too_long"
+	UsernameInvalidCharacters = "username_invalid_characters"
+	UsernameInvalidStart      = "username_invalid_start"
+	UsernameReserved          = "username_reserved"
+)
+
+// UsernameColumnLength is the size of the username column, no policy may allow longer usernames
+const UsernameColumnLength = 40
+
+// UsernamePolicy decides which usernames users may register or rename themselves to. Usernames are unique
+// ignoring case, which the repositories enforce.
+type UsernamePolicy struct {
+	// MinLength and MaxLength count characters after normalization
+	MinLength int
+	MaxLength int
+	// AllowUnicode allows letters and digits of every script, only ASCII letters and digits are allowed otherwise.
+	// '.', '_' and '-' are always allowed after the first character.
+	AllowUnicode bool
+	// Reserved usernames can not be taken by anyone, they are compared ignoring case
+	Reserved []string
+}
+
+// DefaultReservedUsernames are names users could mistake for the service or its operators
+var DefaultReservedUsernames = []string{
+	"admin", "administrator", "root", "system", "support", "security", "help", "api", "null", "undefined",
+}
+
+// DefaultUsernamePolicy allows ASCII usernames of 3 to 32 characters
+var DefaultUsernamePolicy = UsernamePolicy{
+	MinLength: 3,
+	MaxLength: 32,
+	Reserved:  DefaultReservedUsernames,
+}
+
+// normalizeUsername returns the form usernames are checked, stored and looked up in. NFKC folds characters that look
+// the same, like full width letters or ligatures, into one form so they can not be used to imitate another user.
+func normalizeUsername(username string) string {
+	return norm.NFKC.String(strings.TrimSpace(username))
+}
+
+// Check returns a violation of the policy for every rule username breaks, reported against field. username must
+// already be normalized.
+func (p UsernamePolicy) Check(field, username string) []model.FieldError {
+	var violations []model.FieldError
+	violate := func(code, message string) {
+		violations = append(violations, model.FieldError{Field: field, Message: message, Code: code})
+	}
+
+	length := utf8.RuneCountInString(username)
+	if length < p.MinLength || length == 0 {
+		violate(UsernameTooShort, fmt.Sprintf("username must be at least %d characters", max(p.MinLength, 1)))
+		if length == 0 {
+			return violations
+		}
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		violate(UsernameTooLong, fmt.Sprintf("username must be at most %d characters", p.MaxLength))
+		return violations
+	}
+
+	first, _ := utf8.DecodeRuneInString(username)
+	if !p.isLetterOrDigit(first) {
+		violate(UsernameInvalidStart, "username must start with a letter or digit")
+	}
+	for _, r := range username {
+		if !p.isLetterOrDigit(r) && r != '.' && r != '_' && r != '-' {
+			violate(UsernameInvalidCharacters, "username may only contain letters, digits, '.', '_' and '-'")
+			break
+		}
+	}
+
+	for _, reserved := range p.Reserved {
+		if strings.EqualFold(username, reserved) {
+			violate(UsernameReserved, "username is reserved")
+			break
+		}
+	}
+
+	return violations
+}
+
+func (p UsernamePolicy) isLetterOrDigit(r rune) bool {
+	if p.AllowUnicode {
+		return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+	}
+	return r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsernamePolicy_Check_Test_Cases(t *testing.T) {
+	unicodePolicy := DefaultUsernamePolicy
+	unicodePolicy.AllowUnicode = true
+
+	tt := []struct {
+		name     string
+		policy   UsernamePolicy
+		username string
+		codes    []string
+	}{
+		{
+			name:     "valid username",
+			policy:   DefaultUsernamePolicy,
+			username: "jamie.shaw_01-x",
+		},
+		{
+			name:     "empty",
+			policy:   DefaultUsernamePolicy,
+			username: "",
+			codes:    []string{UsernameTooShort},
+		},
+		{
+			name:     "zero policy still refuses empty usernames",
+			policy:   UsernamePolicy{},
+			username: "",
+			codes:    []string{UsernameTooShort},
+		},
+		{
+			name:     "too short",
+			policy:   DefaultUsernamePolicy,
+			username: "jo",
+			codes:    []string{UsernameTooShort},
+		},
+		{
+			name:     "too long",
+			policy:   DefaultUsernamePolicy,
+			username: strings.Repeat("a", 33),
+			codes:    []string{UsernameTooLong},
+		},
+		{
+			name:     "invalid start and characters",
+			policy:   DefaultUsernamePolicy,
+			username: "_jamie shaw",
+			codes:    []string{UsernameInvalidStart, UsernameInvalidCharacters},
+		},
+		{
+			name:     "non ASCII letters refused by default",
+			policy:   DefaultUsernamePolicy,
+			username: "zoë",
+			codes:    []string{UsernameInvalidCharacters},
+		},
+		{
+			name:     "non ASCII letters allowed with unicode",
+			policy:   unicodePolicy,
+			username: "zoë",
+		},
+		{
+			name:     "reserved ignoring case",
+			policy:   DefaultUsernamePolicy,
+			username: "Root",
+			codes:    []string{UsernameReserved},
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var codes []string
+			for _, violation := range tc.policy.Check("username", tc.username) {
+				assert.Equal(t, "username", violation.Field)
+				codes = append(codes, violation.Code)
+			}
+			assert.Equal(t, tc.codes, codes)
+		})
+	}
+}
+
+func TestNormalizeUsername(t *testing.T) {
+	assert.Equal(t, "admin", normalizeUsername(" ａｄｍｉｎ "))
+	// The decomposed ë is composed into a single character
+	assert.Equal(t, "zoë", normalizeUsername("zoë"))
+}
@@ -0,0 +1,65 @@
+package grpc
+
+import (
+	"errors"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// codeFromError maps the code of a domain error to a grpc status code
+func codeFromError(err error) codes.Code {
+	switch model.ErrorCodeOf(err) {
+	case model.CodeNotFound:
+		return codes.NotFound
+	case model.CodeAlreadyExists:
+		return codes.AlreadyExists
+	case model.CodeInvalidArgument:
+		return codes.InvalidArgument
+	case model.CodeUnauthenticated:
+		return codes.Unauthenticated
+	case model.CodePermissionDenied:
+		return codes.PermissionDenied
+	case model.CodeUnavailable, model.CodeBadGateway:
+		return codes.Unavailable
+	case model.CodeTimeout:
+		return codes.DeadlineExceeded
+	case model.CodeTooManyRequests:
+		return codes.ResourceExhausted
+	default:
+		return codes.Internal
+	}
+}
+
+// toStatus converts err into a grpc status error, the message of an error
+// that is not a domain error is never exposed.
+func toStatus(err error) error {
+	log.Errorf("error: %v", err)
+
+	var domainErr *model.Error
+	if !errors.As(err, &domainErr) {
+		return status.Error(codes.Internal, "internal error")
+	}
+
+	st := status.New(codeFromError(err), err.Error())
+
+	// Invalid fields are sent as a BadRequest detail so clients can tell which field broke which rule
+	fields := model.FieldErrorsOf(err)
+	if len(fields) == 0 {
+		return st.Err()
+	}
+	badRequest := &errdetails.BadRequest{}
+	for _, field := range fields {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       field.Field,
+			Description: field.Message,
+		})
+	}
+	withDetails, err := st.WithDetails(badRequest)
+	if err != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
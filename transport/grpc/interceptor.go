@@ -0,0 +1,140 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/JamieBShaw/user-service/auth"
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/JamieBShaw/user-service/protob"
+	"github.com/JamieBShaw/user-service/service"
+	googlegrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// organizationKey is the metadata key naming the slug of the organization of a call, calls without it are made in
+// the default organization
+const organizationKey = "x-organization"
+
+// methodActions is the authorization check of each rpc, rpcs that are not listed are public
+var methodActions = map[string]auth.Action{
+	"/UserService/GetById":  auth.ActionReadUser,
+	"/UserService/GetUsers": auth.ActionListUsers,
+	"/UserService/Update":   auth.ActionUpdateUser,
+	"/UserService/Delete":   auth.ActionDeleteUser,
+	"/UserService/Restore":  auth.ActionRestoreUser,
+
+	"/UserService/ChangePassword": auth.ActionChangePassword,
+	"/UserService/ResetPassword":  auth.ActionResetPassword,
+	"/UserService/UnlockUser":     auth.ActionUnlockUser,
+	"/UserService/ChangeStatus":   auth.ActionChangeStatus,
+	"/UserService/ListRoles":      auth.ActionListRoles,
+	"/UserService/AssignRole":     auth.ActionManageRoles,
+	"/UserService/RevokeRole":     auth.ActionManageRoles,
+
+	"/UserService/CreateOrganization":       auth.ActionCreateOrganization,
+	"/UserService/ListUserOrganizations":    auth.ActionReadUser,
+	"/UserService/JoinOrganization":         auth.ActionJoinOrganization,
+	"/UserService/RemoveOrganizationMember": auth.ActionManageMembers,
+
+	// Group rpcs authorize against the group in ID, except ListUserGroups which names a user
+	"/UserService/CreateGroup":       auth.ActionCreateGroup,
+	"/UserService/GetGroup":          auth.ActionReadGroup,
+	"/UserService/ListGroups":        auth.ActionListGroups,
+	"/UserService/DeleteGroup":       auth.ActionManageGroup,
+	"/UserService/AddGroupMember":    auth.ActionManageGroup,
+	"/UserService/RemoveGroupMember": auth.ActionManageGroup,
+	"/UserService/ListGroupMembers":  auth.ActionReadGroup,
+	"/UserService/ListUserGroups":    auth.ActionReadUser,
+
+	// AcceptInvitation is public, the invitee has no account yet
+	"/UserService/CreateInvitation": auth.ActionInviteUser,
+}
+
+// OrganizationInterceptor scopes every call to the organization in the "x-organization" metadata, it must run before
+// AuthInterceptor. Unknown organizations are not found.
+func OrganizationInterceptor(organizations service.OrganizationService) googlegrpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *googlegrpc.UnaryServerInfo, handler googlegrpc.UnaryHandler) (interface{}, error) {
+		var slug string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(organizationKey); len(values) > 0 {
+				slug = strings.TrimSpace(values[0])
+			}
+		}
+
+		if slug == "" {
+			return handler(model.WithOrganization(ctx, model.DefaultOrganizationID), req)
+		}
+
+		organization, err := organizations.Resolve(ctx, slug)
+		if err != nil {
+			return nil, toStatus(err)
+		}
+
+		return handler(model.WithOrganization(ctx, organization.ID), req)
+	}
+}
+
+// AuthInterceptor authenticates the caller from the "authorization: Bearer <token>" metadata, whose session must
+// still exist in the auth service, and applies the same authorization policies as the http transport.
+func AuthInterceptor(tokens auth.TokenParser, client protob.AuthServiceClient, authorizer auth.Authorizer) googlegrpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *googlegrpc.UnaryServerInfo, handler googlegrpc.UnaryHandler) (interface{}, error) {
+		action, ok := methodActions[info.FullMethod]
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		identity, err := authenticate(ctx, tokens, client)
+		if err != nil {
+			return nil, toStatus(err)
+		}
+		ctx = auth.WithIdentity(ctx, identity)
+
+		var targetID int64
+		if r, ok := req.(interface{ GetID() int64 }); ok {
+			targetID = r.GetID()
+		}
+
+		if err := authorizer.Authorize(ctx, action, targetID); err != nil {
+			return nil, toStatus(err)
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func authenticate(ctx context.Context, tokens auth.TokenParser, client protob.AuthServiceClient) (*auth.Identity, error) {
+	var header string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			header = values[0]
+		}
+	}
+
+	token, err := auth.BearerToken(header)
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := tokens.Parse(token)
+	if err != nil {
+		return nil, err
+	}
+
+	// A signed token stays valid until it expires, the session behind it is gone once the user logged out or their
+	// tokens were revoked
+	ctx, cancel := context.WithTimeout(ctx, authServiceTimeout)
+	defer cancel()
+
+	res, err := client.CheckAccessToken(ctx, &protob.CheckAccessTokenRequest{
+		AccessUuid: identity.AccessUuid,
+	})
+	if err != nil {
+		return nil, auth.ServiceError(err)
+	}
+	if res.GetID() != identity.UserID {
+		return nil, model.UnauthenticatedError("session not found")
+	}
+
+	return identity, nil
+}
@@ -2,24 +2,53 @@ package grpc
 
 import (
 	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
 
+	"github.com/JamieBShaw/user-service/auth"
+	"github.com/JamieBShaw/user-service/domain/model"
 	"github.com/JamieBShaw/user-service/protob"
 	"github.com/JamieBShaw/user-service/service"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 var log = logrus.New()
 
+// authServiceTimeout bounds every call made to the auth service
+const authServiceTimeout = 5 * time.Second
+
 type grpcServer struct {
 	protob.UnimplementedUserServiceServer
-	service service.UserService
+	service           service.UserService
+	verifications     service.EmailVerificationService
+	mfa               service.MFAService
+	roles             service.RoleService
+	organizations     service.OrganizationService
+	groups            service.GroupService
+	invitations       service.InvitationService
+	authorizer        auth.Authorizer
+	authServiceClient protob.AuthServiceClient
+	refreshTokens     auth.RefreshTokenParser
 }
 
-func NewGrpcServer(userService service.UserService) protob.UserServiceServer {
+func NewGrpcServer(userService service.UserService, verifications service.EmailVerificationService, mfa service.MFAService, roles service.RoleService, organizations service.OrganizationService, groups service.GroupService, invitations service.InvitationService, authorizer auth.Authorizer, client protob.AuthServiceClient, refreshTokens auth.RefreshTokenParser) protob.UserServiceServer {
 	return &grpcServer{
-		service: userService,
+		service:           userService,
+		verifications:     verifications,
+		mfa:               mfa,
+		roles:             roles,
+		organizations:     organizations,
+		groups:            groups,
+		invitations:       invitations,
+		authorizer:        authorizer,
+		authServiceClient: client,
+		refreshTokens:     refreshTokens,
 	}
 }
 
@@ -31,14 +60,10 @@ func (gs *grpcServer) GetById(ctx context.Context, req *protob.GetUserRequest) (
 	user, err := gs.service.GetByID(ctx, req.GetID())
 	if err != nil {
 		log.Errorf("error getting user by id: %v", req.GetID())
-		return nil, status.Errorf(codes.NotFound, "user not found")
+		return nil, toStatus(err)
 	}
 	res := &protob.GetUserResponse{
-		User: &protob.User{
-			ID:       user.ID,
-			Username: user.Username,
-			Admin:    user.Admin,
-		},
+		User: newUser(user),
 	}
 
 	return res, nil
@@ -49,33 +74,55 @@ func (gs *grpcServer) GetUsers(ctx context.Context, req *protob.GetUsersRequest)
 		return nil, status.Errorf(codes.InvalidArgument, "invalid request")
 	}
 
-	users, err := gs.service.GetUsers(ctx)
+	filter := model.UserFilter{
+		UsernamePrefix: req.GetUsernamePrefix(),
+	}
+	if req.GetAdmin() != nil {
+		admin := req.GetAdmin().GetValue()
+		filter.Admin = &admin
+	}
+	if req.GetCreatedAfter() != nil {
+		filter.CreatedAfter = req.GetCreatedAfter().AsTime()
+	}
+	if req.GetCreatedBefore() != nil {
+		filter.CreatedBefore = req.GetCreatedBefore().AsTime()
+	}
+
+	page, err := gs.service.GetUsers(ctx, filter, model.Page{
+		Size:  int(req.GetPageSize()),
+		Token: req.GetPageToken(),
+	})
 	if err != nil {
-		return nil, status.Errorf(codes.NotFound, "users not found")
+		return nil, toStatus(err)
 	}
 
-	res := &protob.GetUsersResponse{}
+	res := &protob.GetUsersResponse{
+		NextPageToken: page.NextPageToken,
+	}
 
-	for _, user := range users {
-		u := &protob.User{
-			ID:       user.ID,
-			Username: user.Username,
-			Admin:    user.Admin,
-		}
-		res.Users = append(res.Users, u)
+	for _, user := range page.Users {
+		res.Users = append(res.Users, newUser(user))
 	}
 
 	return res, nil
 }
 
 func (gs *grpcServer) Create(ctx context.Context, req *protob.CreateUserRequest) (*protob.CreateUserResponse, error) {
-	if req == nil || req.Username == "" {
+	if req == nil {
 		return nil, status.Errorf(codes.InvalidArgument, "invalid request")
 	}
 
-	err := gs.service.Create(ctx, req.Username, req.Password)
+	user, err := gs.service.Create(ctx, req.Username, req.Email, req.Password)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, err.Error())
+		return nil, toStatus(err)
+	}
+
+	// The user can ask for another verification email, so a failure here does not fail the registration
+	if user.Email != "" {
+		err = gs.verifications.SendVerification(ctx, user.ID)
+		if err != nil {
+			log.Errorf("error sending email verification: %v", err)
+		}
 	}
 
 	return &protob.CreateUserResponse{
@@ -83,6 +130,34 @@ func (gs *grpcServer) Create(ctx context.Context, req *protob.CreateUserRequest)
 	}, nil
 }
 
+func (gs *grpcServer) Update(ctx context.Context, req *protob.UpdateUserRequest) (*protob.UpdateUserResponse, error) {
+	if req == nil || req.GetID() == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request")
+	}
+
+	var update model.UserUpdate
+	if req.GetUsername() != nil {
+		username := req.GetUsername().GetValue()
+		update.Username = &username
+	}
+	if req.GetAdmin() != nil {
+		if err := gs.authorizer.Authorize(ctx, auth.ActionChangeAdmin, req.GetID()); err != nil {
+			return nil, toStatus(err)
+		}
+		admin := req.GetAdmin().GetValue()
+		update.Admin = &admin
+	}
+
+	user, err := gs.service.Update(ctx, req.GetID(), update)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &protob.UpdateUserResponse{
+		User: newUser(user),
+	}, nil
+}
+
 func (gs *grpcServer) Delete(ctx context.Context, req *protob.DeleteUserRequest) (*protob.DeleteUserResponse, error) {
 	if req == nil || req.GetID() == 0 {
 		return nil, status.Errorf(codes.InvalidArgument, "invalid request")
@@ -90,9 +165,595 @@ func (gs *grpcServer) Delete(ctx context.Context, req *protob.DeleteUserRequest)
 
 	err := gs.service.Delete(ctx, req.GetID())
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, err.Error())
+		return nil, toStatus(err)
 	}
 	return &protob.DeleteUserResponse{
 		Confirmation: "user deleted",
 	}, nil
 }
+
+func (gs *grpcServer) Restore(ctx context.Context, req *protob.RestoreUserRequest) (*protob.RestoreUserResponse, error) {
+	if req == nil || req.GetID() == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request")
+	}
+
+	user, err := gs.service.Restore(ctx, req.GetID())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &protob.RestoreUserResponse{
+		User: newUser(user),
+	}, nil
+}
+
+func (gs *grpcServer) ChangeStatus(ctx context.Context, req *protob.ChangeStatusRequest) (*protob.ChangeStatusResponse, error) {
+	if req == nil || req.GetID() == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request")
+	}
+
+	user, err := gs.service.ChangeStatus(ctx, req.GetID(), model.AccountStatus(req.GetStatus()), req.GetReason())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	if user.Status == model.StatusSuspended || user.Status == model.StatusDisabled {
+		gs.revokeAccessTokens(ctx, user.ID, "")
+	}
+
+	return &protob.ChangeStatusResponse{
+		User: newUser(user),
+	}, nil
+}
+
+func (gs *grpcServer) ListRoles(ctx context.Context, req *protob.ListRolesRequest) (*protob.ListRolesResponse, error) {
+	roles, err := gs.roles.Roles(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	res := &protob.ListRolesResponse{}
+	for _, role := range roles {
+		res.Roles = append(res.Roles, &protob.Role{
+			Name:        role.Name,
+			Description: role.Description,
+			Permissions: role.Permissions,
+		})
+	}
+
+	return res, nil
+}
+
+func (gs *grpcServer) AssignRole(ctx context.Context, req *protob.AssignRoleRequest) (*protob.AssignRoleResponse, error) {
+	if req == nil || req.GetID() == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request")
+	}
+
+	user, err := gs.roles.AssignRole(ctx, req.GetID(), req.GetRole())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &protob.AssignRoleResponse{
+		User: newUser(user),
+	}, nil
+}
+
+func (gs *grpcServer) RevokeRole(ctx context.Context, req *protob.RevokeRoleRequest) (*protob.RevokeRoleResponse, error) {
+	if req == nil || req.GetID() == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request")
+	}
+
+	user, err := gs.roles.RevokeRole(ctx, req.GetID(), req.GetRole())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &protob.RevokeRoleResponse{
+		User: newUser(user),
+	}, nil
+}
+
+func (gs *grpcServer) CreateOrganization(ctx context.Context, req *protob.CreateOrganizationRequest) (*protob.CreateOrganizationResponse, error) {
+	if req == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request")
+	}
+
+	identity, ok := auth.IdentityFromContext(ctx)
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "authentication required")
+	}
+
+	organization, err := gs.organizations.Create(ctx, identity.UserID, req.GetSlug(), req.GetName())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &protob.CreateOrganizationResponse{
+		Organization: &protob.Organization{
+			ID:   organization.ID,
+			Slug: organization.Slug,
+			Name: organization.Name,
+		},
+	}, nil
+}
+
+func (gs *grpcServer) ListUserOrganizations(ctx context.Context, req *protob.ListUserOrganizationsRequest) (*protob.ListUserOrganizationsResponse, error) {
+	if req == nil || req.GetID() == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request")
+	}
+
+	organizations, err := gs.organizations.UserOrganizations(ctx, req.GetID())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	res := &protob.ListUserOrganizationsResponse{}
+	for _, organization := range organizations {
+		res.Organizations = append(res.Organizations, &protob.Organization{
+			ID:   organization.ID,
+			Slug: organization.Slug,
+			Name: organization.Name,
+		})
+	}
+
+	return res, nil
+}
+
+func (gs *grpcServer) JoinOrganization(ctx context.Context, req *protob.JoinOrganizationRequest) (*protob.JoinOrganizationResponse, error) {
+	if req == nil || req.GetID() == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request")
+	}
+
+	organization, err := gs.invitations.Join(ctx, req.GetToken(), req.GetID())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &protob.JoinOrganizationResponse{
+		Organization: &protob.Organization{
+			ID:   organization.ID,
+			Slug: organization.Slug,
+			Name: organization.Name,
+		},
+	}, nil
+}
+
+func (gs *grpcServer) RemoveOrganizationMember(ctx context.Context, req *protob.OrganizationMemberRequest) (*protob.OrganizationMemberResponse, error) {
+	if req == nil || req.GetID() == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request")
+	}
+
+	err := gs.organizations.RemoveMember(ctx, req.GetID())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &protob.OrganizationMemberResponse{
+		Confirmation: "user removed from organization",
+	}, nil
+}
+
+func (gs *grpcServer) CreateGroup(ctx context.Context, req *protob.CreateGroupRequest) (*protob.CreateGroupResponse, error) {
+	if req == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request")
+	}
+
+	identity, ok := auth.IdentityFromContext(ctx)
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "authentication required")
+	}
+
+	ownerID := req.GetOwnerId()
+	if ownerID == 0 {
+		ownerID = identity.UserID
+	}
+
+	// The owner manages the group, so only callers who may manage any group can hand one to someone else
+	if ownerID != identity.UserID {
+		if err := gs.authorizer.Authorize(ctx, auth.ActionAssignGroupOwner, ownerID); err != nil {
+			return nil, toStatus(err)
+		}
+	}
+
+	group, err := gs.groups.Create(ctx, ownerID, req.GetName(), req.GetDescription())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &protob.CreateGroupResponse{Group: newGroup(group)}, nil
+}
+
+func (gs *grpcServer) GetGroup(ctx context.Context, req *protob.GetGroupRequest) (*protob.GetGroupResponse, error) {
+	if req == nil || req.GetID() == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request")
+	}
+
+	group, err := gs.groups.Group(ctx, req.GetID())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &protob.GetGroupResponse{Group: newGroup(group)}, nil
+}
+
+func (gs *grpcServer) ListGroups(ctx context.Context, req *protob.ListGroupsRequest) (*protob.ListGroupsResponse, error) {
+	if req == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request")
+	}
+
+	page, err := gs.groups.Groups(ctx, model.Page{
+		Size:  int(req.GetPageSize()),
+		Token: req.GetPageToken(),
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	res := &protob.ListGroupsResponse{
+		NextPageToken: page.NextPageToken,
+	}
+	for _, group := range page.Groups {
+		res.Groups = append(res.Groups, newGroup(group))
+	}
+
+	return res, nil
+}
+
+func (gs *grpcServer) DeleteGroup(ctx context.Context, req *protob.DeleteGroupRequest) (*protob.DeleteGroupResponse, error) {
+	if req == nil || req.GetID() == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request")
+	}
+
+	err := gs.groups.Delete(ctx, req.GetID())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &protob.DeleteGroupResponse{
+		Confirmation: "group deleted",
+	}, nil
+}
+
+func (gs *grpcServer) AddGroupMember(ctx context.Context, req *protob.GroupMemberRequest) (*protob.GroupMemberResponse, error) {
+	if req == nil || req.GetID() == 0 || req.GetUserId() == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request")
+	}
+
+	err := gs.groups.AddMember(ctx, req.GetID(), req.GetUserId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &protob.GroupMemberResponse{
+		Confirmation: "user added to group",
+	}, nil
+}
+
+func (gs *grpcServer) RemoveGroupMember(ctx context.Context, req *protob.GroupMemberRequest) (*protob.GroupMemberResponse, error) {
+	if req == nil || req.GetID() == 0 || req.GetUserId() == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request")
+	}
+
+	err := gs.groups.RemoveMember(ctx, req.GetID(), req.GetUserId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &protob.GroupMemberResponse{
+		Confirmation: "user removed from group",
+	}, nil
+}
+
+func (gs *grpcServer) ListGroupMembers(ctx context.Context, req *protob.ListGroupMembersRequest) (*protob.ListGroupMembersResponse, error) {
+	if req == nil || req.GetID() == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request")
+	}
+
+	page, err := gs.groups.Members(ctx, req.GetID(), model.Page{
+		Size:  int(req.GetPageSize()),
+		Token: req.GetPageToken(),
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	res := &protob.ListGroupMembersResponse{
+		NextPageToken: page.NextPageToken,
+	}
+	for _, user := range page.Users {
+		res.Users = append(res.Users, newUser(user))
+	}
+
+	return res, nil
+}
+
+func (gs *grpcServer) ListUserGroups(ctx context.Context, req *protob.ListUserGroupsRequest) (*protob.ListUserGroupsResponse, error) {
+	if req == nil || req.GetID() == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request")
+	}
+
+	page, err := gs.groups.UserGroups(ctx, req.GetID(), model.Page{
+		Size:  int(req.GetPageSize()),
+		Token: req.GetPageToken(),
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	res := &protob.ListUserGroupsResponse{
+		NextPageToken: page.NextPageToken,
+	}
+	for _, group := range page.Groups {
+		res.Groups = append(res.Groups, newGroup(group))
+	}
+
+	return res, nil
+}
+
+func (gs *grpcServer) CreateInvitation(ctx context.Context, req *protob.CreateInvitationRequest) (*protob.CreateInvitationResponse, error) {
+	if req == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request")
+	}
+
+	identity, ok := auth.IdentityFromContext(ctx)
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "authentication required")
+	}
+
+	// The invitee is granted the role, so inviting with one needs the permission to grant it
+	if strings.TrimSpace(req.GetRole()) != "" {
+		if err := gs.authorizer.Authorize(ctx, auth.ActionManageRoles, 0); err != nil {
+			return nil, toStatus(err)
+		}
+	}
+
+	invitation, err := gs.invitations.Invite(ctx, identity.UserID, req.GetEmail(), req.GetRole())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &protob.CreateInvitationResponse{Invitation: newInvitation(invitation)}, nil
+}
+
+func (gs *grpcServer) AcceptInvitation(ctx context.Context, req *protob.AcceptInvitationRequest) (*protob.AcceptInvitationResponse, error) {
+	if req == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request")
+	}
+
+	_, err := gs.invitations.Accept(ctx, req.GetToken(), req.GetUsername(), req.GetPassword())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &protob.AcceptInvitationResponse{
+		Confirmation: "user created",
+	}, nil
+}
+
+func (gs *grpcServer) Login(ctx context.Context, req *protob.LoginRequest) (*protob.LoginResponse, error) {
+	if req == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request")
+	}
+
+	var fields []model.FieldError
+	if req.GetUsername() == "" {
+		fields = append(fields, model.FieldError{Field: "username", Message: "username is required"})
+	}
+	if req.GetPassword() == "" {
+		fields = append(fields, model.FieldError{Field: "password", Message: "password is required"})
+	}
+	if len(fields) > 0 {
+		return nil, toStatus(model.ValidationError(fields...))
+	}
+
+	loginCtx := service.WithClientIP(ctx, clientIP(ctx))
+	user, err := gs.service.GetByUsernameAndPassword(loginCtx, req.GetUsername(), req.GetPassword())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	challenge, err := gs.mfa.Challenge(loginCtx, user)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if challenge != nil {
+		return &protob.LoginResponse{
+			MfaRequired:           true,
+			MfaToken:              challenge.Token,
+			MfaEnrollmentRequired: challenge.EnrollmentRequired,
+		}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, authServiceTimeout)
+	defer cancel()
+
+	res, err := gs.authServiceClient.CreateAccessToken(ctx, &protob.CreateAccessTokenRequest{
+		ID: user.ID,
+	})
+	if err != nil {
+		return nil, toStatus(auth.ServiceError(err))
+	}
+
+	return newLoginResponse(res, user), nil
+}
+
+func (gs *grpcServer) VerifyMFA(ctx context.Context, req *protob.VerifyMFARequest) (*protob.LoginResponse, error) {
+	if req == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request")
+	}
+
+	user, recoveryCodes, err := gs.mfa.Verify(service.WithClientIP(ctx, clientIP(ctx)), req.GetMfaToken(), req.GetCode())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, authServiceTimeout)
+	defer cancel()
+
+	res, err := gs.authServiceClient.CreateAccessToken(ctx, &protob.CreateAccessTokenRequest{
+		ID: user.ID,
+	})
+	if err != nil {
+		return nil, toStatus(auth.ServiceError(err))
+	}
+
+	loginRes := newLoginResponse(res, user)
+	loginRes.RecoveryCodes = recoveryCodes
+	return loginRes, nil
+}
+
+func (gs *grpcServer) RefreshToken(ctx context.Context, req *protob.RefreshTokenRequest) (*protob.LoginResponse, error) {
+	if req == nil || req.GetRefreshToken() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request")
+	}
+
+	refresh, err := gs.refreshTokens.ParseRefresh(req.GetRefreshToken())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	user, err := gs.service.GetByID(ctx, refresh.UserID)
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, toStatus(model.UnauthenticatedError("user no longer exists"))
+	}
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if err := user.CheckActive(); err != nil {
+		return nil, toStatus(err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, authServiceTimeout)
+	defer cancel()
+
+	res, err := gs.authServiceClient.RefreshAccessToken(ctx, &protob.RefreshAccessTokenRequest{
+		RefreshToken: req.GetRefreshToken(),
+	})
+	if err != nil {
+		return nil, toStatus(auth.ServiceError(err))
+	}
+
+	return newLoginResponse(res, user), nil
+}
+
+func (gs *grpcServer) ChangePassword(ctx context.Context, req *protob.ChangePasswordRequest) (*protob.ChangePasswordResponse, error) {
+	if req == nil || req.GetID() == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request")
+	}
+
+	err := gs.service.ChangePassword(ctx, req.GetID(), req.GetCurrentPassword(), req.GetNewPassword())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	// Keep the session the password was changed from
+	var keepAccessUuid string
+	if identity, ok := auth.IdentityFromContext(ctx); ok {
+		keepAccessUuid = identity.AccessUuid
+	}
+
+	gs.revokeAccessTokens(ctx, req.GetID(), keepAccessUuid)
+
+	return &protob.ChangePasswordResponse{
+		Confirmation: "password changed",
+	}, nil
+}
+
+func (gs *grpcServer) ResetPassword(ctx context.Context, req *protob.ResetPasswordRequest) (*protob.ResetPasswordResponse, error) {
+	if req == nil || req.GetID() == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request")
+	}
+
+	err := gs.service.ResetPassword(ctx, req.GetID(), req.GetTemporaryPassword())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	gs.revokeAccessTokens(ctx, req.GetID(), "")
+
+	return &protob.ResetPasswordResponse{
+		Confirmation: "password reset",
+	}, nil
+}
+
+// revokeAccessTokens signs the user out of every session but keepAccessUuid after their password or status changed.
+// The change is saved by then, so a failure is logged instead of failing the request.
+func (gs *grpcServer) revokeAccessTokens(ctx context.Context, userID int64, keepAccessUuid string) {
+	ctx, cancel := context.WithTimeout(ctx, authServiceTimeout)
+	defer cancel()
+
+	_, err := gs.authServiceClient.DeleteUserAccessTokens(ctx, &protob.DeleteUserAccessTokensRequest{
+		ID:             userID,
+		KeepAccessUuid: keepAccessUuid,
+	})
+	if err != nil {
+		log.Errorf("error revoking access tokens of user %d: %v", userID, err)
+	}
+}
+
+// UnlockUser lets an admin lift the lockout of an account after too many failed logins
+func (gs *grpcServer) UnlockUser(ctx context.Context, req *protob.UnlockUserRequest) (*protob.UnlockUserResponse, error) {
+	if req == nil || req.GetID() == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request")
+	}
+
+	err := gs.service.UnlockUser(ctx, req.GetID())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &protob.UnlockUserResponse{
+		Confirmation: "user unlocked",
+	}, nil
+}
+
+// clientIP is the address of the peer that sent the rpc
+func clientIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+func newUser(u *model.User) *protob.User {
+	return &protob.User{
+		ID:            u.ID,
+		Username:      u.Username,
+		Admin:         u.IsAdmin(),
+		Email:         u.Email,
+		EmailVerified: u.EmailVerified(),
+		Status:        string(u.Status),
+		Roles:         u.Roles,
+	}
+}
+
+func newGroup(group *model.Group) *protob.Group {
+	return &protob.Group{
+		ID:          group.ID,
+		Name:        group.Name,
+		Description: group.Description,
+		OwnerId:     group.OwnerID,
+	}
+}
+
+func newInvitation(invitation *model.Invitation) *protob.Invitation {
+	return &protob.Invitation{
+		ID:        invitation.ID,
+		Email:     invitation.Email,
+		Role:      invitation.Role,
+		InvitedBy: invitation.InvitedBy,
+		ExpiresAt: timestamppb.New(invitation.ExpiresAt),
+	}
+}
+
+func newLoginResponse(res *protob.CreateAccessTokenResponse, user *model.User) *protob.LoginResponse {
+	return &protob.LoginResponse{
+		AccessToken:            res.GetAuthToken(),
+		RefreshToken:           res.GetRefreshToken(),
+		TokenType:              auth.TokenType,
+		ExpiresIn:              res.GetExpiresIn(),
+		PasswordChangeRequired: user.PasswordChangeRequired,
+	}
+}
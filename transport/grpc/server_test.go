@@ -6,15 +6,59 @@ import (
 	"testing"
 	"time"
 
+	"github.com/JamieBShaw/user-service/auth"
 	"github.com/JamieBShaw/user-service/domain/model"
 	"github.com/JamieBShaw/user-service/protob"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	googlegrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
 type mockUserService struct {
 }
 
+// mockAdminUserService is a mockUserService where user 1 is an admin
+type mockAdminUserService struct {
+	mockUserService
+}
+
+type mockTokenParser struct{}
+
+// mockAuthClient fails every rpc with err when it is set
+type mockAuthClient struct {
+	err error
+}
+
+// mockEmailVerificationService fails to send every verification with err when it is set
+type mockEmailVerificationService struct {
+	err error
+}
+
+// mockMFAService challenges Michael (id 2) and accepts the code "123456" for the tokens "mfa-token" and "enroll-token"
+type mockMFAService struct{}
+
+// mockRoleService holds the default roles and grants every permission to user 1 only
+type mockRoleService struct{}
+
+// mockOrganizationService knows the default organization and acme (id 2), which only David (id 1) belongs to
+type mockOrganizationService struct{}
+
+// mockGroupService knows Engineering (id 10), owned by Michael (id 2) with David (id 1) and Michael as members, and
+// Support (id 11), owned by David without members
+type mockGroupService struct{}
+
+// mockInvitationService accepts "valid-token" and refuses to invite emails of registered users like david@example.com
+type mockInvitationService struct{}
+
+// mockAuthorizer lets every caller perform every action except changing the admin flag of user 2
+type mockAuthorizer struct{}
+
 func TestGrpcServer_GetById_Test_Cases(t *testing.T) {
 	tt := []struct {
 		name     string
@@ -31,12 +75,13 @@ func TestGrpcServer_GetById_Test_Cases(t *testing.T) {
 				ID:       1,
 				Username: "David",
 				Admin:    false,
+				Status:   "active",
 			},
 		},
 		{
 			name:     "user not found with id",
 			id:       42,
-			errMsg:   "user not found",
+			errMsg:   "could not find user with id",
 			response: nil,
 			errCode:  "NotFound",
 		},
@@ -64,17 +109,26 @@ func TestGrpcServer_GetById_Test_Cases(t *testing.T) {
 
 func TestGrpcServer_GetUsers(t *testing.T) {
 	tt := []struct {
-		name     string
-		req      *protob.GetUsersRequest
-		errMsg   string
-		response []*protob.User
-		errCode  string
+		name          string
+		req           *protob.GetUsersRequest
+		errMsg        string
+		response      []*protob.User
+		nextPageToken string
+		errCode       string
 	}{
 		{
-			name:     "valid request, users returned",
-			req:      &protob.GetUsersRequest{},
-			errMsg:   "",
-			response: generateProtoUsers(),
+			name:          "valid request, users returned",
+			req:           &protob.GetUsersRequest{PageSize: 3},
+			errMsg:        "",
+			response:      generateProtoUsers(),
+			nextPageToken: "Mg",
+		},
+		{
+			name:     "invalid page token, no users returned",
+			req:      &protob.GetUsersRequest{PageToken: "not-a-token"},
+			errMsg:   "invalid page token",
+			response: nil,
+			errCode:  "InvalidArgument",
 		},
 		{
 			name:     "invalid request, no users returned",
@@ -100,27 +154,49 @@ func TestGrpcServer_GetUsers(t *testing.T) {
 				return
 			}
 			assert.Equal(t, tc.response, res.GetUsers())
+			assert.Equal(t, tc.nextPageToken, res.GetNextPageToken())
 		})
 	}
 }
 
 func TestGrpcServer_Create_Test_Cases(t *testing.T) {
 	tt := []struct {
-		name   string
-		req    *protob.CreateUserRequest
-		res    string
-		errMsg string
-		code   string
+		name      string
+		req       *protob.CreateUserRequest
+		verifyErr error
+		res       string
+		errMsg    string
+		code      string
 	}{
 		{
 			name: "request good, create valid user",
 			req: &protob.CreateUserRequest{
 				Username: "James",
+				Email:    "james@example.com",
 			},
 			res:    "user created",
 			errMsg: "",
 			code:   "",
 		},
+		{
+			name: "request good, user created when verification could not be sent",
+			req: &protob.CreateUserRequest{
+				Username: "James",
+				Email:    "james@example.com",
+			},
+			verifyErr: errors.New("smtp: connection refused"),
+			res:       "user created",
+			errMsg:    "",
+			code:      "",
+		},
+		{
+			name: "request good, user without an email created without a verification",
+			req: &protob.CreateUserRequest{
+				Username: "James",
+			},
+			verifyErr: errors.New("user has no email address"),
+			res:       "user created",
+		},
 		{
 			name:   "bad request, username invalid",
 			req:    nil,
@@ -128,12 +204,21 @@ func TestGrpcServer_Create_Test_Cases(t *testing.T) {
 			errMsg: "invalid request",
 			code:   "InvalidArgument",
 		},
+		{
+			name: "bad request, username already exists",
+			req: &protob.CreateUserRequest{
+				Username: "David",
+			},
+			res:    "",
+			errMsg: "username already exists",
+			code:   "AlreadyExists",
+		},
 	}
 	for _, tc := range tt {
 		tc := tc
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
-			server := grpcServer{service: mockUserService{}}
+			server := grpcServer{service: mockUserService{}, verifications: mockEmailVerificationService{err: tc.verifyErr}}
 			res, err := server.Create(context.Background(), tc.req)
 			if err != nil {
 				statusErr, ok := status.FromError(err)
@@ -149,6 +234,72 @@ func TestGrpcServer_Create_Test_Cases(t *testing.T) {
 	}
 }
 
+func TestGrpcServer_Update_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name     string
+		req      *protob.UpdateUserRequest
+		response *protob.User
+		errMsg   string
+		code     string
+	}{
+		{
+			name: "user updated successfully",
+			req: &protob.UpdateUserRequest{
+				ID:       1,
+				Username: wrapperspb.String("Dave"),
+				Admin:    wrapperspb.Bool(true),
+			},
+			response: &protob.User{
+				ID:       1,
+				Username: "Dave",
+				Admin:    true,
+				Status:   "active",
+				Roles:    []string{model.RoleAdmin},
+			},
+		},
+		{
+			name:     "user not found with that id",
+			req:      &protob.UpdateUserRequest{ID: 42, Admin: wrapperspb.Bool(true)},
+			response: nil,
+			errMsg:   "could not find user with id",
+			code:     "NotFound",
+		},
+		{
+			name:     "invalid request, nil request",
+			req:      nil,
+			response: nil,
+			errMsg:   "invalid request",
+			code:     "InvalidArgument",
+		},
+		{
+			name:     "permission denied, admin flag changed by non admin",
+			req:      &protob.UpdateUserRequest{ID: 2, Admin: wrapperspb.Bool(true)},
+			response: nil,
+			errMsg:   "permission users:change_admin required",
+			code:     "PermissionDenied",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := grpcServer{service: mockUserService{}, authorizer: mockAuthorizer{}}
+			res, err := server.Update(context.Background(), tc.req)
+			if err != nil {
+				statusErr, ok := status.FromError(err)
+				if ok {
+					assert.Equal(t, tc.code, statusErr.Code().String())
+					assert.Equal(t, tc.errMsg, statusErr.Message())
+					assert.Equal(t, tc.response, res.GetUser())
+				}
+				return
+			}
+			assert.Equal(t, tc.response, res.GetUser())
+		})
+	}
+}
+
 func TestGrpcServer_Delete_Test_Cases(t *testing.T) {
 	tt := []struct {
 		name   string
@@ -171,8 +322,8 @@ func TestGrpcServer_Delete_Test_Cases(t *testing.T) {
 				ID: 42,
 			},
 			res:    "",
-			errMsg: "user not found",
-			code:   "Internal",
+			errMsg: "user not found with id",
+			code:   "NotFound",
 		},
 		{
 			name:   "invalid request, nil request",
@@ -212,7 +363,15 @@ func TestGrpcServer_Delete_Test_Cases(t *testing.T) {
 }
 
 func (m mockUserService) GetByUsernameAndPassword(ctx context.Context, username, password string) (*model.User, error) {
-	panic("implement me")
+	for _, user := range generateUsers() {
+		if username == user.Username {
+			if password != "password" {
+				return nil, model.UnauthenticatedError("user password incorrect")
+			}
+			return user, nil
+		}
+	}
+	return nil, model.UnauthenticatedError("user not found with username")
 }
 
 func (m mockUserService) GetByID(ctx context.Context, id int64) (*model.User, error) {
@@ -222,19 +381,39 @@ func (m mockUserService) GetByID(ctx context.Context, id int64) (*model.User, er
 			return user, nil
 		}
 	}
-	return nil, errors.New("error: getting user by Id")
+	return nil, model.NotFoundError("could not find user with id")
 }
 
-func (m mockUserService) GetUsers(ctx context.Context) ([]*model.User, error) {
-	return generateUsers(), nil
+func (m mockUserService) GetUsers(ctx context.Context, filter model.UserFilter, page model.Page) (*model.UserPage, error) {
+	if page.Token != "" {
+		return nil, model.InvalidArgumentError("invalid page token")
+	}
+	return &model.UserPage{Users: generateUsers(), NextPageToken: "Mg"}, nil
 }
 
-func (m mockUserService) Create(ctx context.Context, username, password string) error {
+func (m mockUserService) Create(ctx context.Context, username, email, password string) (*model.User, error) {
 	if username == "James" {
-		return nil
+		return &model.User{ID: 3, Username: username, Email: email}, nil
+	}
+	return nil, model.AlreadyExistsError("username already exists")
+}
+func (m mockUserService) Update(ctx context.Context, id int64, update model.UserUpdate) (*model.User, error) {
+	users := generateUsers()
+
+	for _, user := range users {
+		if id == user.ID {
+			if update.Username != nil {
+				user.Username = *update.Username
+			}
+			if update.Admin != nil {
+				user.SetRole(model.RoleAdmin, *update.Admin)
+			}
+			return user, nil
+		}
 	}
-	return errors.New("cannot create user")
+	return nil, model.NotFoundError("could not find user with id")
 }
+
 func (m mockUserService) Delete(ctx context.Context, id int64) error {
 	users := generateUsers()
 
@@ -243,7 +422,1870 @@ func (m mockUserService) Delete(ctx context.Context, id int64) error {
 			return nil
 		}
 	}
-	return errors.New("user not found")
+	return model.NotFoundError("user not found with id")
+}
+
+func (m mockUserService) Restore(ctx context.Context, id int64) (*model.User, error) {
+	for _, user := range generateUsers() {
+		if id == user.ID {
+			return user, nil
+		}
+	}
+	return nil, model.NotFoundError("deleted user not found with id")
+}
+
+func (m mockUserService) ChangeStatus(ctx context.Context, id int64, status model.AccountStatus, reason string) (*model.User, error) {
+	if !status.Valid() {
+		return nil, model.ValidationError(model.FieldError{Field: "status", Message: "status must be one of pending, active, suspended or disabled"})
+	}
+	for _, user := range generateUsers() {
+		if id == user.ID {
+			user.Status, user.StatusReason = status, reason
+			return user, nil
+		}
+	}
+	return nil, model.NotFoundError("could not find user with id")
+}
+
+func TestToStatus_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name   string
+		err    error
+		errMsg string
+		code   string
+	}{
+		{
+			name:   "not found",
+			err:    model.NotFoundError("user not found"),
+			errMsg: "user not found",
+			code:   "NotFound",
+		},
+		{
+			name:   "unauthenticated",
+			err:    model.UnauthenticatedError("user password incorrect"),
+			errMsg: "user password incorrect",
+			code:   "Unauthenticated",
+		},
+		{
+			name:   "permission denied",
+			err:    model.PermissionDeniedError("admin only"),
+			errMsg: "admin only",
+			code:   "PermissionDenied",
+		},
+		{
+			name:   "unavailable",
+			err:    model.UnavailableError("auth service unavailable"),
+			errMsg: "auth service unavailable",
+			code:   "Unavailable",
+		},
+		{
+			name:   "too many requests",
+			err:    model.TooManyRequestsError("too many failed login attempts, try again later"),
+			errMsg: "too many failed login attempts, try again later",
+			code:   "ResourceExhausted",
+		},
+		{
+			name:   "internal error message is hidden",
+			err:    errors.New("pq: connection refused"),
+			errMsg: "internal error",
+			code:   "Internal",
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			statusErr, ok := status.FromError(toStatus(tc.err))
+			assert.True(t, ok)
+			assert.Equal(t, tc.code, statusErr.Code().String())
+			assert.Equal(t, tc.errMsg, statusErr.Message())
+		})
+	}
+}
+
+func TestToStatus_Field_Violations(t *testing.T) {
+	err := model.ValidationError(
+		model.FieldError{Field: "username", Message: "username is reserved", Code: "username_reserved"},
+		model.FieldError{Field: "password", Message: "password must be at least 8 characters", Code: "password_too_short"},
+	)
+
+	statusErr, ok := status.FromError(toStatus(err))
+	assert.True(t, ok)
+	assert.Equal(t, codes.InvalidArgument, statusErr.Code())
+	assert.Equal(t, "username is reserved, password must be at least 8 characters", statusErr.Message())
+
+	details := statusErr.Details()
+	assert.Len(t, details, 1)
+	badRequest, ok := details[0].(*errdetails.BadRequest)
+	assert.True(t, ok)
+	assert.Len(t, badRequest.GetFieldViolations(), 2)
+	assert.Equal(t, "username", badRequest.GetFieldViolations()[0].GetField())
+	assert.Equal(t, "username is reserved", badRequest.GetFieldViolations()[0].GetDescription())
+	assert.Equal(t, "password", badRequest.GetFieldViolations()[1].GetField())
+}
+
+func TestGrpcServer_Login_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name       string
+		req        *protob.LoginRequest
+		authClient mockAuthClient
+		response   *protob.LoginResponse
+		errMsg     string
+		code       string
+	}{
+		{
+			name:       "user logged in",
+			req:        &protob.LoginRequest{Username: "David", Password: "password"},
+			authClient: mockAuthClient{},
+			response: &protob.LoginResponse{
+				AccessToken:  "3214343254",
+				RefreshToken: "5435436265",
+				TokenType:    "Bearer",
+				ExpiresIn:    900,
+			},
+		},
+		{
+			name:       "user with mfa is challenged",
+			req:        &protob.LoginRequest{Username: "Michael", Password: "password"},
+			authClient: mockAuthClient{},
+			response: &protob.LoginResponse{
+				MfaRequired: true,
+				MfaToken:    "mfa-token",
+			},
+		},
+		{
+			name:       "invalid request, nil request",
+			req:        nil,
+			authClient: mockAuthClient{},
+			errMsg:     "invalid request",
+			code:       "InvalidArgument",
+		},
+		{
+			name:       "invalid request, missing password",
+			req:        &protob.LoginRequest{Username: "David"},
+			authClient: mockAuthClient{},
+			errMsg:     "password is required",
+			code:       "InvalidArgument",
+		},
+		{
+			name:       "wrong password",
+			req:        &protob.LoginRequest{Username: "David", Password: "wrong-password"},
+			authClient: mockAuthClient{},
+			errMsg:     "user password incorrect",
+			code:       "Unauthenticated",
+		},
+		{
+			name:       "auth service timed out",
+			req:        &protob.LoginRequest{Username: "David", Password: "password"},
+			authClient: mockAuthClient{err: status.Error(codes.DeadlineExceeded, "deadline exceeded")},
+			errMsg:     "auth service timed out",
+			code:       "DeadlineExceeded",
+		},
+		{
+			name:       "auth service failed",
+			req:        &protob.LoginRequest{Username: "David", Password: "password"},
+			authClient: mockAuthClient{err: status.Error(codes.Internal, "redis: connection refused")},
+			errMsg:     "auth service error",
+			code:       "Unavailable",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := grpcServer{service: mockUserService{}, mfa: mockMFAService{}, authServiceClient: tc.authClient}
+			res, err := server.Login(context.Background(), tc.req)
+			if tc.code != "" {
+				statusErr, ok := status.FromError(err)
+				assert.True(t, ok)
+				assert.Equal(t, tc.code, statusErr.Code().String())
+				assert.Equal(t, tc.errMsg, statusErr.Message())
+				assert.Nil(t, res)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, proto.Equal(tc.response, res))
+		})
+	}
+}
+
+func TestGrpcServer_VerifyMFA_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name       string
+		req        *protob.VerifyMFARequest
+		authClient mockAuthClient
+		response   *protob.LoginResponse
+		errMsg     string
+		code       string
+	}{
+		{
+			name:       "user logged in with mfa",
+			req:        &protob.VerifyMFARequest{MfaToken: "mfa-token", Code: "123456"},
+			authClient: mockAuthClient{},
+			response: &protob.LoginResponse{
+				AccessToken:  "3214343254",
+				RefreshToken: "5435436265",
+				TokenType:    "Bearer",
+				ExpiresIn:    900,
+			},
+		},
+		{
+			name:       "user confirmed a required enrollment",
+			req:        &protob.VerifyMFARequest{MfaToken: "enroll-token", Code: "123456"},
+			authClient: mockAuthClient{},
+			response: &protob.LoginResponse{
+				AccessToken:   "3214343254",
+				RefreshToken:  "5435436265",
+				TokenType:     "Bearer",
+				ExpiresIn:     900,
+				RecoveryCodes: []string{"abcde-fghij"},
+			},
+		},
+		{
+			name:       "invalid request, nil request",
+			req:        nil,
+			authClient: mockAuthClient{},
+			errMsg:     "invalid request",
+			code:       "InvalidArgument",
+		},
+		{
+			name:       "wrong code",
+			req:        &protob.VerifyMFARequest{MfaToken: "mfa-token", Code: "000000"},
+			authClient: mockAuthClient{},
+			errMsg:     "invalid mfa code",
+			code:       "Unauthenticated",
+		},
+		{
+			name:       "expired token",
+			req:        &protob.VerifyMFARequest{MfaToken: "expired-token", Code: "123456"},
+			authClient: mockAuthClient{},
+			errMsg:     "invalid or expired mfa token",
+			code:       "Unauthenticated",
+		},
+		{
+			name:       "auth service timed out",
+			req:        &protob.VerifyMFARequest{MfaToken: "mfa-token", Code: "123456"},
+			authClient: mockAuthClient{err: status.Error(codes.DeadlineExceeded, "deadline exceeded")},
+			errMsg:     "auth service timed out",
+			code:       "DeadlineExceeded",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := grpcServer{service: mockUserService{}, mfa: mockMFAService{}, authServiceClient: tc.authClient}
+			res, err := server.VerifyMFA(context.Background(), tc.req)
+			if tc.code != "" {
+				statusErr, ok := status.FromError(err)
+				assert.True(t, ok)
+				assert.Equal(t, tc.code, statusErr.Code().String())
+				assert.Equal(t, tc.errMsg, statusErr.Message())
+				assert.Nil(t, res)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, proto.Equal(tc.response, res))
+		})
+	}
+}
+
+func TestGrpcServer_RefreshToken_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name       string
+		req        *protob.RefreshTokenRequest
+		authClient mockAuthClient
+		response   *protob.LoginResponse
+		errMsg     string
+		code       string
+	}{
+		{
+			name:       "tokens refreshed",
+			req:        &protob.RefreshTokenRequest{RefreshToken: "refresh-token"},
+			authClient: mockAuthClient{},
+			response: &protob.LoginResponse{
+				AccessToken:  "7685645342",
+				RefreshToken: "8796756453",
+				TokenType:    "Bearer",
+				ExpiresIn:    900,
+			},
+		},
+		{
+			name:       "invalid request, missing refresh token",
+			req:        &protob.RefreshTokenRequest{},
+			authClient: mockAuthClient{},
+			errMsg:     "invalid request",
+			code:       "InvalidArgument",
+		},
+		{
+			name:       "invalid refresh token",
+			req:        &protob.RefreshTokenRequest{RefreshToken: "forged-token"},
+			authClient: mockAuthClient{},
+			errMsg:     "invalid refresh token",
+			code:       "Unauthenticated",
+		},
+		{
+			name:       "user no longer exists",
+			req:        &protob.RefreshTokenRequest{RefreshToken: "deleted-user-refresh-token"},
+			authClient: mockAuthClient{},
+			errMsg:     "user no longer exists",
+			code:       "Unauthenticated",
+		},
+		{
+			name:       "auth service unavailable",
+			req:        &protob.RefreshTokenRequest{RefreshToken: "refresh-token"},
+			authClient: mockAuthClient{err: status.Error(codes.Unavailable, "connection refused")},
+			errMsg:     "auth service unavailable",
+			code:       "Unavailable",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := grpcServer{service: mockUserService{}, authServiceClient: tc.authClient, refreshTokens: mockTokenParser{}}
+			res, err := server.RefreshToken(context.Background(), tc.req)
+			if tc.code != "" {
+				statusErr, ok := status.FromError(err)
+				assert.True(t, ok)
+				assert.Equal(t, tc.code, statusErr.Code().String())
+				assert.Equal(t, tc.errMsg, statusErr.Message())
+				assert.Nil(t, res)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, proto.Equal(tc.response, res))
+		})
+	}
+}
+
+func TestGrpcServer_ChangePassword_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name       string
+		req        *protob.ChangePasswordRequest
+		authClient mockAuthClient
+		res        string
+		errMsg     string
+		code       string
+	}{
+		{
+			name:       "password changed",
+			req:        &protob.ChangePasswordRequest{ID: 2, CurrentPassword: "password", NewPassword: "new-password"},
+			authClient: mockAuthClient{},
+			res:        "password changed",
+		},
+		{
+			name:       "current password incorrect",
+			req:        &protob.ChangePasswordRequest{ID: 2, CurrentPassword: "wrong-password", NewPassword: "new-password"},
+			authClient: mockAuthClient{},
+			errMsg:     "current password incorrect",
+			code:       "Unauthenticated",
+		},
+		{
+			name:       "password changed although other sessions could not be revoked",
+			req:        &protob.ChangePasswordRequest{ID: 2, CurrentPassword: "password", NewPassword: "new-password"},
+			authClient: mockAuthClient{err: status.Error(codes.DeadlineExceeded, "deadline exceeded")},
+			res:        "password changed",
+		},
+		{
+			name:       "invalid request, nil request",
+			req:        nil,
+			authClient: mockAuthClient{},
+			errMsg:     "invalid request",
+			code:       "InvalidArgument",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := grpcServer{service: mockUserService{}, authServiceClient: tc.authClient}
+			ctx := auth.WithIdentity(context.Background(), &auth.Identity{UserID: 2, AccessUuid: "user-uuid"})
+			res, err := server.ChangePassword(ctx, tc.req)
+			if tc.code != "" {
+				statusErr, ok := status.FromError(err)
+				assert.True(t, ok)
+				assert.Equal(t, tc.code, statusErr.Code().String())
+				assert.Equal(t, tc.errMsg, statusErr.Message())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.res, res.GetConfirmation())
+		})
+	}
+}
+
+func TestGrpcServer_ResetPassword_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name   string
+		req    *protob.ResetPasswordRequest
+		res    string
+		errMsg string
+		code   string
+	}{
+		{
+			name: "password reset",
+			req:  &protob.ResetPasswordRequest{ID: 2, TemporaryPassword: "temporary"},
+			res:  "password reset",
+		},
+		{
+			name:   "user not found with that id",
+			req:    &protob.ResetPasswordRequest{ID: 42, TemporaryPassword: "temporary"},
+			errMsg: "could not find user with id",
+			code:   "NotFound",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := grpcServer{service: mockUserService{}, authServiceClient: mockAuthClient{}}
+			res, err := server.ResetPassword(context.Background(), tc.req)
+			if tc.code != "" {
+				statusErr, ok := status.FromError(err)
+				assert.True(t, ok)
+				assert.Equal(t, tc.code, statusErr.Code().String())
+				assert.Equal(t, tc.errMsg, statusErr.Message())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.res, res.GetConfirmation())
+		})
+	}
+}
+
+func TestGrpcServer_Restore_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name   string
+		req    *protob.RestoreUserRequest
+		id     int64
+		errMsg string
+		code   string
+	}{
+		{
+			name: "user restored",
+			req:  &protob.RestoreUserRequest{ID: 2},
+			id:   2,
+		},
+		{
+			name:   "no deleted user with that id",
+			req:    &protob.RestoreUserRequest{ID: 42},
+			errMsg: "deleted user not found with id",
+			code:   "NotFound",
+		},
+		{
+			name:   "missing id",
+			req:    &protob.RestoreUserRequest{},
+			errMsg: "invalid request",
+			code:   "InvalidArgument",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := grpcServer{service: mockUserService{}}
+			res, err := server.Restore(context.Background(), tc.req)
+			if tc.code != "" {
+				statusErr, ok := status.FromError(err)
+				assert.True(t, ok)
+				assert.Equal(t, tc.code, statusErr.Code().String())
+				assert.Equal(t, tc.errMsg, statusErr.Message())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.id, res.GetUser().GetID())
+		})
+	}
+}
+
+func TestGrpcServer_ChangeStatus_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name       string
+		req        *protob.ChangeStatusRequest
+		authClient mockAuthClient
+		status     string
+		errMsg     string
+		code       string
+	}{
+		{
+			name:       "user suspended",
+			req:        &protob.ChangeStatusRequest{ID: 2, Status: "suspended", Reason: "chargeback"},
+			authClient: mockAuthClient{},
+			status:     "suspended",
+		},
+		{
+			name:       "user activated without the auth service",
+			req:        &protob.ChangeStatusRequest{ID: 2, Status: "active"},
+			authClient: mockAuthClient{err: status.Error(codes.Unavailable, "connection refused")},
+			status:     "active",
+		},
+		{
+			name:       "user disabled when their sessions can not be revoked",
+			req:        &protob.ChangeStatusRequest{ID: 2, Status: "disabled", Reason: "spam"},
+			authClient: mockAuthClient{err: status.Error(codes.Unavailable, "connection refused")},
+			status:     "disabled",
+		},
+		{
+			name:       "unknown status",
+			req:        &protob.ChangeStatusRequest{ID: 2, Status: "banned"},
+			authClient: mockAuthClient{},
+			errMsg:     "status must be one of pending, active, suspended or disabled",
+			code:       "InvalidArgument",
+		},
+		{
+			name:       "user does not exist",
+			req:        &protob.ChangeStatusRequest{ID: 42, Status: "active"},
+			authClient: mockAuthClient{},
+			errMsg:     "could not find user with id",
+			code:       "NotFound",
+		},
+		{
+			name:       "missing id",
+			req:        &protob.ChangeStatusRequest{Status: "active"},
+			authClient: mockAuthClient{},
+			errMsg:     "invalid request",
+			code:       "InvalidArgument",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := grpcServer{service: mockUserService{}, authServiceClient: tc.authClient}
+			res, err := server.ChangeStatus(context.Background(), tc.req)
+			if tc.code != "" {
+				statusErr, ok := status.FromError(err)
+				assert.True(t, ok)
+				assert.Equal(t, tc.code, statusErr.Code().String())
+				assert.Equal(t, tc.errMsg, statusErr.Message())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.status, res.GetUser().GetStatus())
+		})
+	}
+}
+
+func TestGrpcServer_ListRoles(t *testing.T) {
+	server := grpcServer{roles: mockRoleService{}}
+
+	res, err := server.ListRoles(context.Background(), &protob.ListRolesRequest{})
+	assert.NoError(t, err)
+	assert.Len(t, res.GetRoles(), len(model.DefaultRoles()))
+	assert.Equal(t, model.RoleAdmin, res.GetRoles()[0].GetName())
+	assert.Equal(t, []string{model.PermissionAll}, res.GetRoles()[0].GetPermissions())
+}
+
+func TestGrpcServer_AssignRole_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name   string
+		req    *protob.AssignRoleRequest
+		roles  []string
+		admin  bool
+		errMsg string
+		code   string
+	}{
+		{
+			name:  "role granted",
+			req:   &protob.AssignRoleRequest{ID: 2, Role: model.RoleSupport},
+			roles: []string{model.RoleSupport},
+		},
+		{
+			name:  "admin role granted",
+			req:   &protob.AssignRoleRequest{ID: 2, Role: model.RoleAdmin},
+			roles: []string{model.RoleAdmin},
+			admin: true,
+		},
+		{
+			name:   "unknown role",
+			req:    &protob.AssignRoleRequest{ID: 2, Role: "owner"},
+			errMsg: "role not found",
+			code:   "NotFound",
+		},
+		{
+			name:   "user does not exist",
+			req:    &protob.AssignRoleRequest{ID: 42, Role: model.RoleSupport},
+			errMsg: "could not find user with id",
+			code:   "NotFound",
+		},
+		{
+			name:   "missing id",
+			req:    &protob.AssignRoleRequest{Role: model.RoleSupport},
+			errMsg: "invalid request",
+			code:   "InvalidArgument",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := grpcServer{roles: mockRoleService{}}
+			res, err := server.AssignRole(context.Background(), tc.req)
+			if tc.code != "" {
+				statusErr, ok := status.FromError(err)
+				assert.True(t, ok)
+				assert.Equal(t, tc.code, statusErr.Code().String())
+				assert.Equal(t, tc.errMsg, statusErr.Message())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.roles, res.GetUser().GetRoles())
+			assert.Equal(t, tc.admin, res.GetUser().GetAdmin())
+		})
+	}
+}
+
+func TestGrpcServer_RevokeRole_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name   string
+		req    *protob.RevokeRoleRequest
+		errMsg string
+		code   string
+	}{
+		{
+			name: "role revoked",
+			req:  &protob.RevokeRoleRequest{ID: 1, Role: model.RoleAdmin},
+		},
+		{
+			name:   "user does not hold the role",
+			req:    &protob.RevokeRoleRequest{ID: 2, Role: model.RoleSupport},
+			errMsg: "user does not have the role",
+			code:   "NotFound",
+		},
+		{
+			name:   "missing id",
+			req:    &protob.RevokeRoleRequest{Role: model.RoleSupport},
+			errMsg: "invalid request",
+			code:   "InvalidArgument",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := grpcServer{roles: mockRoleService{}}
+			res, err := server.RevokeRole(context.Background(), tc.req)
+			if tc.code != "" {
+				statusErr, ok := status.FromError(err)
+				assert.True(t, ok)
+				assert.Equal(t, tc.code, statusErr.Code().String())
+				assert.Equal(t, tc.errMsg, statusErr.Message())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Empty(t, res.GetUser().GetRoles())
+			assert.False(t, res.GetUser().GetAdmin())
+		})
+	}
+}
+
+func TestGrpcServer_CreateOrganization_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name     string
+		req      *protob.CreateOrganizationRequest
+		identity *auth.Identity
+		response *protob.Organization
+		errMsg   string
+		code     string
+	}{
+		{
+			name:     "organization created",
+			req:      &protob.CreateOrganizationRequest{Slug: "globex", Name: "Globex"},
+			identity: &auth.Identity{UserID: 1},
+			response: &protob.Organization{ID: 3, Slug: "globex", Name: "Globex"},
+		},
+		{
+			name:     "slug taken",
+			req:      &protob.CreateOrganizationRequest{Slug: "acme", Name: "Acme"},
+			identity: &auth.Identity{UserID: 1},
+			errMsg:   "organization already exists",
+			code:     "AlreadyExists",
+		},
+		{
+			name:   "unauthenticated",
+			req:    &protob.CreateOrganizationRequest{Slug: "globex", Name: "Globex"},
+			errMsg: "authentication required",
+			code:   "Unauthenticated",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := grpcServer{organizations: mockOrganizationService{}}
+			ctx := context.Background()
+			if tc.identity != nil {
+				ctx = auth.WithIdentity(ctx, tc.identity)
+			}
+			res, err := server.CreateOrganization(ctx, tc.req)
+			if tc.code != "" {
+				statusErr, ok := status.FromError(err)
+				assert.True(t, ok)
+				assert.Equal(t, tc.code, statusErr.Code().String())
+				assert.Equal(t, tc.errMsg, statusErr.Message())
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, proto.Equal(tc.response, res.GetOrganization()))
+		})
+	}
+}
+
+func TestGrpcServer_ListUserOrganizations_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name   string
+		req    *protob.ListUserOrganizationsRequest
+		slugs  []string
+		errMsg string
+		code   string
+	}{
+		{
+			name:  "user belongs to several organizations",
+			req:   &protob.ListUserOrganizationsRequest{ID: 1},
+			slugs: []string{"acme", "default"},
+		},
+		{
+			name:   "user not found with that id",
+			req:    &protob.ListUserOrganizationsRequest{ID: 42},
+			errMsg: "could not find user with id",
+			code:   "NotFound",
+		},
+		{
+			name:   "missing id",
+			req:    &protob.ListUserOrganizationsRequest{},
+			errMsg: "invalid request",
+			code:   "InvalidArgument",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := grpcServer{organizations: mockOrganizationService{}}
+			res, err := server.ListUserOrganizations(context.Background(), tc.req)
+			if tc.code != "" {
+				statusErr, ok := status.FromError(err)
+				assert.True(t, ok)
+				assert.Equal(t, tc.code, statusErr.Code().String())
+				assert.Equal(t, tc.errMsg, statusErr.Message())
+				return
+			}
+			assert.NoError(t, err)
+			var slugs []string
+			for _, organization := range res.GetOrganizations() {
+				slugs = append(slugs, organization.GetSlug())
+			}
+			assert.Equal(t, tc.slugs, slugs)
+		})
+	}
+}
+
+func TestGrpcServer_JoinOrganization_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name     string
+		req      *protob.JoinOrganizationRequest
+		response *protob.Organization
+		errMsg   string
+		code     string
+	}{
+		{
+			name:     "invitation accepted",
+			req:      &protob.JoinOrganizationRequest{ID: 1, Token: "valid-token"},
+			response: &protob.Organization{ID: 2, Slug: "acme", Name: "Acme"},
+		},
+		{
+			name:   "invalid token",
+			req:    &protob.JoinOrganizationRequest{ID: 1, Token: "expired-token"},
+			errMsg: "invalid or expired invitation token",
+			code:   "InvalidArgument",
+		},
+		{
+			name:   "missing user id",
+			req:    &protob.JoinOrganizationRequest{Token: "valid-token"},
+			errMsg: "invalid request",
+			code:   "InvalidArgument",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := grpcServer{invitations: mockInvitationService{}}
+
+			res, err := server.JoinOrganization(context.Background(), tc.req)
+			if tc.code != "" {
+				statusErr, ok := status.FromError(err)
+				assert.True(t, ok)
+				assert.Equal(t, tc.code, statusErr.Code().String())
+				assert.Equal(t, tc.errMsg, statusErr.Message())
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, proto.Equal(tc.response, res.GetOrganization()))
+		})
+	}
+}
+
+func TestGrpcServer_RemoveOrganizationMember_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name         string
+		organization int64
+		req          *protob.OrganizationMemberRequest
+		errMsg       string
+		code         string
+	}{
+		{
+			name:         "member removed",
+			organization: 2,
+			req:          &protob.OrganizationMemberRequest{ID: 1},
+		},
+		{
+			name:         "remove a user who is not a member",
+			organization: 2,
+			req:          &protob.OrganizationMemberRequest{ID: 2},
+			errMsg:       "user is not a member of the organization",
+			code:         "NotFound",
+		},
+		{
+			name:         "remove a user from the organization they registered in",
+			organization: model.DefaultOrganizationID,
+			req:          &protob.OrganizationMemberRequest{ID: 2},
+			errMsg:       "users can not be removed from the organization they registered in",
+			code:         "InvalidArgument",
+		},
+		{
+			name:   "missing user id",
+			req:    &protob.OrganizationMemberRequest{},
+			errMsg: "invalid request",
+			code:   "InvalidArgument",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := grpcServer{organizations: mockOrganizationService{}}
+			ctx := model.WithOrganization(context.Background(), tc.organization)
+
+			_, err := server.RemoveOrganizationMember(ctx, tc.req)
+			if tc.code != "" {
+				statusErr, ok := status.FromError(err)
+				assert.True(t, ok)
+				assert.Equal(t, tc.code, statusErr.Code().String())
+				assert.Equal(t, tc.errMsg, statusErr.Message())
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestGrpcServer_CreateGroup_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name     string
+		req      *protob.CreateGroupRequest
+		identity *auth.Identity
+		response *protob.Group
+		errMsg   string
+		code     string
+	}{
+		{
+			name:     "caller owns the group they create",
+			req:      &protob.CreateGroupRequest{Name: "Design", Description: "Product designers"},
+			identity: &auth.Identity{UserID: 1},
+			response: &protob.Group{ID: 12, Name: "Design", Description: "Product designers", OwnerId: 1},
+		},
+		{
+			name:     "group created for another owner",
+			req:      &protob.CreateGroupRequest{Name: "Design", OwnerId: 2},
+			identity: &auth.Identity{UserID: 1},
+			response: &protob.Group{ID: 12, Name: "Design", OwnerId: 2},
+		},
+		{
+			name:     "creator without groups:manage can not create a group for another owner",
+			req:      &protob.CreateGroupRequest{Name: "Design", OwnerId: 1},
+			identity: &auth.Identity{UserID: 2},
+			errMsg:   "permission groups:manage required",
+			code:     "PermissionDenied",
+		},
+		{
+			name:     "group name taken",
+			req:      &protob.CreateGroupRequest{Name: "Engineering"},
+			identity: &auth.Identity{UserID: 1},
+			errMsg:   "group already exists",
+			code:     "AlreadyExists",
+		},
+		{
+			name:   "unauthenticated",
+			req:    &protob.CreateGroupRequest{Name: "Design"},
+			errMsg: "authentication required",
+			code:   "Unauthenticated",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := grpcServer{groups: mockGroupService{}, authorizer: auth.NewAuthorizer(mockAdminUserService{}, mockRoleService{}, mockOrganizationService{}, mockGroupService{})}
+			ctx := context.Background()
+			if tc.identity != nil {
+				ctx = auth.WithIdentity(ctx, tc.identity)
+			}
+			res, err := server.CreateGroup(ctx, tc.req)
+			if tc.code != "" {
+				statusErr, ok := status.FromError(err)
+				assert.True(t, ok)
+				assert.Equal(t, tc.code, statusErr.Code().String())
+				assert.Equal(t, tc.errMsg, statusErr.Message())
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, proto.Equal(tc.response, res.GetGroup()))
+		})
+	}
+}
+
+func TestGrpcServer_CreateInvitation_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name     string
+		req      *protob.CreateInvitationRequest
+		identity *auth.Identity
+		response *protob.Invitation
+		errMsg   string
+		code     string
+	}{
+		{
+			name:     "email invited",
+			req:      &protob.CreateInvitationRequest{Email: "new@example.com"},
+			identity: &auth.Identity{UserID: 2},
+			response: &protob.Invitation{ID: 1, Email: "new@example.com", InvitedBy: 2, ExpiresAt: timestamppb.New(time.Time{})},
+		},
+		{
+			name:     "email invited with a role",
+			req:      &protob.CreateInvitationRequest{Email: "new@example.com", Role: "support"},
+			identity: &auth.Identity{UserID: 1},
+			response: &protob.Invitation{ID: 1, Email: "new@example.com", Role: "support", InvitedBy: 1, ExpiresAt: timestamppb.New(time.Time{})},
+		},
+		{
+			name:     "inviting with a role needs the permission to manage roles",
+			req:      &protob.CreateInvitationRequest{Email: "new@example.com", Role: model.RoleAdmin},
+			identity: &auth.Identity{UserID: 2},
+			errMsg:   "permission roles:manage required",
+			code:     "PermissionDenied",
+		},
+		{
+			name:     "email of a registered user",
+			req:      &protob.CreateInvitationRequest{Email: "david@example.com"},
+			identity: &auth.Identity{UserID: 1},
+			errMsg:   "email already exists",
+			code:     "AlreadyExists",
+		},
+		{
+			name:   "unauthenticated",
+			req:    &protob.CreateInvitationRequest{Email: "new@example.com"},
+			errMsg: "authentication required",
+			code:   "Unauthenticated",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := grpcServer{
+				invitations: mockInvitationService{},
+				authorizer:  auth.NewAuthorizer(mockAdminUserService{}, mockRoleService{}, mockOrganizationService{}, mockGroupService{}),
+			}
+			ctx := context.Background()
+			if tc.identity != nil {
+				ctx = auth.WithIdentity(ctx, tc.identity)
+			}
+			res, err := server.CreateInvitation(ctx, tc.req)
+			if tc.code != "" {
+				statusErr, ok := status.FromError(err)
+				assert.True(t, ok)
+				assert.Equal(t, tc.code, statusErr.Code().String())
+				assert.Equal(t, tc.errMsg, statusErr.Message())
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, proto.Equal(tc.response, res.GetInvitation()))
+		})
+	}
+}
+
+func TestGrpcServer_AcceptInvitation_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name   string
+		req    *protob.AcceptInvitationRequest
+		errMsg string
+		code   string
+	}{
+		{
+			name: "invitation accepted",
+			req:  &protob.AcceptInvitationRequest{Token: "valid-token", Username: "newuser", Password: "Password1"},
+		},
+		{
+			name:   "invalid invitation token",
+			req:    &protob.AcceptInvitationRequest{Token: "used-token", Username: "newuser", Password: "Password1"},
+			errMsg: "invalid or expired invitation token",
+			code:   "InvalidArgument",
+		},
+		{
+			name:   "missing request",
+			errMsg: "invalid request",
+			code:   "InvalidArgument",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := grpcServer{invitations: mockInvitationService{}}
+			res, err := server.AcceptInvitation(context.Background(), tc.req)
+			if tc.code != "" {
+				statusErr, ok := status.FromError(err)
+				assert.True(t, ok)
+				assert.Equal(t, tc.code, statusErr.Code().String())
+				assert.Equal(t, tc.errMsg, statusErr.Message())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "user created", res.GetConfirmation())
+		})
+	}
+}
+
+func TestGrpcServer_GroupMember_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name   string
+		add    bool
+		req    *protob.GroupMemberRequest
+		errMsg string
+		code   string
+	}{
+		{
+			name: "member added",
+			add:  true,
+			req:  &protob.GroupMemberRequest{ID: 11, UserId: 2},
+		},
+		{
+			name:   "unknown group",
+			add:    true,
+			req:    &protob.GroupMemberRequest{ID: 42, UserId: 1},
+			errMsg: "group not found",
+			code:   "NotFound",
+		},
+		{
+			name: "member removed",
+			req:  &protob.GroupMemberRequest{ID: 10, UserId: 1},
+		},
+		{
+			name:   "remove a user who is not a member",
+			req:    &protob.GroupMemberRequest{ID: 11, UserId: 1},
+			errMsg: "user is not a member of the group",
+			code:   "NotFound",
+		},
+		{
+			name:   "missing group id",
+			req:    &protob.GroupMemberRequest{UserId: 1},
+			errMsg: "invalid request",
+			code:   "InvalidArgument",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := grpcServer{groups: mockGroupService{}}
+
+			var err error
+			if tc.add {
+				_, err = server.AddGroupMember(context.Background(), tc.req)
+			} else {
+				_, err = server.RemoveGroupMember(context.Background(), tc.req)
+			}
+			if tc.code != "" {
+				statusErr, ok := status.FromError(err)
+				assert.True(t, ok)
+				assert.Equal(t, tc.code, statusErr.Code().String())
+				assert.Equal(t, tc.errMsg, statusErr.Message())
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestGrpcServer_ListGroupMembers_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name          string
+		req           *protob.ListGroupMembersRequest
+		usernames     []string
+		nextPageToken string
+		errMsg        string
+		code          string
+	}{
+		{
+			name:      "every member",
+			req:       &protob.ListGroupMembersRequest{ID: 10},
+			usernames: []string{"David", "Michael"},
+		},
+		{
+			name:          "first page of members",
+			req:           &protob.ListGroupMembersRequest{ID: 10, PageSize: 1},
+			usernames:     []string{"David"},
+			nextPageToken: "next",
+		},
+		{
+			name:   "unknown group",
+			req:    &protob.ListGroupMembersRequest{ID: 42},
+			errMsg: "group not found",
+			code:   "NotFound",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := grpcServer{groups: mockGroupService{}}
+			res, err := server.ListGroupMembers(context.Background(), tc.req)
+			if tc.code != "" {
+				statusErr, ok := status.FromError(err)
+				assert.True(t, ok)
+				assert.Equal(t, tc.code, statusErr.Code().String())
+				assert.Equal(t, tc.errMsg, statusErr.Message())
+				return
+			}
+			assert.NoError(t, err)
+			var usernames []string
+			for _, user := range res.GetUsers() {
+				usernames = append(usernames, user.GetUsername())
+			}
+			assert.Equal(t, tc.usernames, usernames)
+			assert.Equal(t, tc.nextPageToken, res.GetNextPageToken())
+		})
+	}
+}
+
+func TestGrpcServer_ListUserGroups_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name   string
+		req    *protob.ListUserGroupsRequest
+		groups []string
+		errMsg string
+		code   string
+	}{
+		{
+			name:   "user belongs to a group",
+			req:    &protob.ListUserGroupsRequest{ID: 1},
+			groups: []string{"Engineering"},
+		},
+		{
+			name:   "missing id",
+			req:    &protob.ListUserGroupsRequest{},
+			errMsg: "invalid request",
+			code:   "InvalidArgument",
+		},
+		{
+			name:   "user not found with that id",
+			req:    &protob.ListUserGroupsRequest{ID: 42},
+			errMsg: "could not find user with id",
+			code:   "NotFound",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := grpcServer{groups: mockGroupService{}}
+			res, err := server.ListUserGroups(context.Background(), tc.req)
+			if tc.code != "" {
+				statusErr, ok := status.FromError(err)
+				assert.True(t, ok)
+				assert.Equal(t, tc.code, statusErr.Code().String())
+				assert.Equal(t, tc.errMsg, statusErr.Message())
+				return
+			}
+			assert.NoError(t, err)
+			var groups []string
+			for _, group := range res.GetGroups() {
+				groups = append(groups, group.GetName())
+			}
+			assert.Equal(t, tc.groups, groups)
+		})
+	}
+}
+
+func TestOrganizationInterceptor_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name         string
+		organization string
+		id           int64
+		errMsg       string
+		code         string
+	}{
+		{
+			name: "no metadata uses the default organization",
+			id:   model.DefaultOrganizationID,
+		},
+		{
+			name:         "organization from metadata",
+			organization: "acme",
+			id:           2,
+		},
+		{
+			name:         "unknown organization",
+			organization: "globex",
+			errMsg:       "organization not found",
+			code:         "NotFound",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			interceptor := OrganizationInterceptor(mockOrganizationService{})
+
+			ctx := context.Background()
+			if tc.organization != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("x-organization", tc.organization))
+			}
+
+			var id int64
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				id = model.OrganizationFromContext(ctx)
+				return nil, nil
+			}
+
+			_, err := interceptor(ctx, &protob.GetUsersRequest{}, &googlegrpc.UnaryServerInfo{FullMethod: "/UserService/GetUsers"}, handler)
+			if tc.code != "" {
+				statusErr, ok := status.FromError(err)
+				assert.True(t, ok)
+				assert.Equal(t, tc.code, statusErr.Code().String())
+				assert.Equal(t, tc.errMsg, statusErr.Message())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.id, id)
+		})
+	}
+}
+
+func TestGrpcServer_UnlockUser_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name   string
+		req    *protob.UnlockUserRequest
+		res    string
+		errMsg string
+		code   string
+	}{
+		{
+			name: "user unlocked",
+			req:  &protob.UnlockUserRequest{ID: 2},
+			res:  "user unlocked",
+		},
+		{
+			name:   "user not found with that id",
+			req:    &protob.UnlockUserRequest{ID: 42},
+			errMsg: "could not find user with id",
+			code:   "NotFound",
+		},
+		{
+			name:   "missing id",
+			req:    &protob.UnlockUserRequest{},
+			errMsg: "invalid request",
+			code:   "InvalidArgument",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := grpcServer{service: mockUserService{}}
+			res, err := server.UnlockUser(context.Background(), tc.req)
+			if tc.code != "" {
+				statusErr, ok := status.FromError(err)
+				assert.True(t, ok)
+				assert.Equal(t, tc.code, statusErr.Code().String())
+				assert.Equal(t, tc.errMsg, statusErr.Message())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.res, res.GetConfirmation())
+		})
+	}
+}
+
+func TestAuthInterceptor_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name       string
+		method     string
+		req        interface{}
+		token      string
+		authClient mockAuthClient
+		errMsg     string
+		code       string
+	}{
+		{
+			name:   "public rpc needs no token",
+			method: "/UserService/Create",
+			req:    &protob.CreateUserRequest{Username: "James"},
+			token:  "",
+		},
+		{
+			name:   "admin lists users",
+			method: "/UserService/GetUsers",
+			req:    &protob.GetUsersRequest{},
+			token:  "admin-token",
+		},
+		{
+			name:   "user can not list users",
+			method: "/UserService/GetUsers",
+			req:    &protob.GetUsersRequest{},
+			token:  "user-token",
+			errMsg: "permission users:list required",
+			code:   "PermissionDenied",
+		},
+		{
+			name:   "user reads themselves",
+			method: "/UserService/GetById",
+			req:    &protob.GetUserRequest{ID: 2},
+			token:  "user-token",
+		},
+		{
+			name:   "user can not delete another user",
+			method: "/UserService/Delete",
+			req:    &protob.DeleteUserRequest{ID: 1},
+			token:  "user-token",
+			errMsg: "users may only perform this action on themselves",
+			code:   "PermissionDenied",
+		},
+		{
+			name:   "admin updates another user",
+			method: "/UserService/Update",
+			req:    &protob.UpdateUserRequest{ID: 2},
+			token:  "admin-token",
+		},
+		{
+			name:   "user can not reset a password",
+			method: "/UserService/ResetPassword",
+			req:    &protob.ResetPasswordRequest{ID: 2},
+			token:  "user-token",
+			errMsg: "permission users:reset_password required",
+			code:   "PermissionDenied",
+		},
+		{
+			name:   "user can not grant roles",
+			method: "/UserService/AssignRole",
+			req:    &protob.AssignRoleRequest{ID: 2, Role: model.RoleAdmin},
+			token:  "user-token",
+			errMsg: "permission roles:manage required",
+			code:   "PermissionDenied",
+		},
+		{
+			name:   "owner deletes their group",
+			method: "/UserService/DeleteGroup",
+			req:    &protob.DeleteGroupRequest{ID: 10},
+			token:  "user-token",
+		},
+		{
+			name:   "user can not add members to a group they do not own",
+			method: "/UserService/AddGroupMember",
+			req:    &protob.GroupMemberRequest{ID: 11, UserId: 2},
+			token:  "user-token",
+			errMsg: "only the owner of the group may perform this action",
+			code:   "PermissionDenied",
+		},
+		{
+			name:   "user can not list groups",
+			method: "/UserService/ListGroups",
+			req:    &protob.ListGroupsRequest{},
+			token:  "user-token",
+			errMsg: "permission groups:list required",
+			code:   "PermissionDenied",
+		},
+		{
+			name:   "invitations are accepted without a token",
+			method: "/UserService/AcceptInvitation",
+			req:    &protob.AcceptInvitationRequest{Token: "valid-token"},
+			token:  "",
+		},
+		{
+			name:   "user can not invite",
+			method: "/UserService/CreateInvitation",
+			req:    &protob.CreateInvitationRequest{Email: "new@example.com"},
+			token:  "user-token",
+			errMsg: "permission users:invite required",
+			code:   "PermissionDenied",
+		},
+		{
+			name:   "missing access token",
+			method: "/UserService/Delete",
+			req:    &protob.DeleteUserRequest{ID: 2},
+			token:  "",
+			errMsg: "missing bearer access token",
+			code:   "Unauthenticated",
+		},
+		{
+			name:   "invalid access token",
+			method: "/UserService/GetById",
+			req:    &protob.GetUserRequest{ID: 2},
+			token:  "forged-token",
+			errMsg: "invalid access token",
+			code:   "Unauthenticated",
+		},
+		{
+			name:   "revoked access token",
+			method: "/UserService/GetById",
+			req:    &protob.GetUserRequest{ID: 2},
+			token:  "revoked-token",
+			errMsg: "session not found",
+			code:   "Unauthenticated",
+		},
+		{
+			name:       "auth service unavailable",
+			method:     "/UserService/GetById",
+			req:        &protob.GetUserRequest{ID: 2},
+			token:      "user-token",
+			authClient: mockAuthClient{err: status.Error(codes.Unavailable, "connection refused")},
+			errMsg:     "auth service unavailable",
+			code:       "Unavailable",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			interceptor := AuthInterceptor(mockTokenParser{}, tc.authClient, auth.NewAuthorizer(mockAdminUserService{}, mockRoleService{}, mockOrganizationService{}, mockGroupService{}))
+
+			ctx := context.Background()
+			if tc.token != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer "+tc.token))
+			}
+
+			called := false
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				called = true
+				return nil, nil
+			}
+
+			_, err := interceptor(ctx, tc.req, &googlegrpc.UnaryServerInfo{FullMethod: tc.method}, handler)
+			if tc.code != "" {
+				statusErr, ok := status.FromError(err)
+				assert.True(t, ok)
+				assert.Equal(t, tc.code, statusErr.Code().String())
+				assert.Equal(t, tc.errMsg, statusErr.Message())
+				assert.False(t, called)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, called)
+		})
+	}
+}
+
+func (m mockUserService) ChangePassword(ctx context.Context, id int64, currentPassword, newPassword string) error {
+	for _, user := range generateUsers() {
+		if id == user.ID {
+			if currentPassword != "password" {
+				return model.UnauthenticatedError("current password incorrect")
+			}
+			return nil
+		}
+	}
+	return model.NotFoundError("could not find user with id")
+}
+
+func (m mockUserService) ResetPassword(ctx context.Context, id int64, temporaryPassword string) error {
+	for _, user := range generateUsers() {
+		if id == user.ID {
+			return nil
+		}
+	}
+	return model.NotFoundError("could not find user with id")
+}
+
+func (m mockUserService) UnlockUser(ctx context.Context, id int64) error {
+	for _, user := range generateUsers() {
+		if id == user.ID {
+			return nil
+		}
+	}
+	return model.NotFoundError("could not find user with id")
+}
+
+func (m mockAdminUserService) GetByID(ctx context.Context, id int64) (*model.User, error) {
+	user, err := m.mockUserService.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if id == 1 {
+		user.Roles = []string{model.RoleAdmin}
+	}
+	return user, nil
+}
+
+func (m mockAuthClient) CreateAccessToken(ctx context.Context, in *protob.CreateAccessTokenRequest, opts ...googlegrpc.CallOption) (*protob.CreateAccessTokenResponse, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &protob.CreateAccessTokenResponse{
+		AuthToken:    "3214343254",
+		RefreshToken: "5435436265",
+		ExpiresIn:    900,
+	}, nil
+}
+
+func (m mockAuthClient) RefreshAccessToken(ctx context.Context, in *protob.RefreshAccessTokenRequest, opts ...googlegrpc.CallOption) (*protob.CreateAccessTokenResponse, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &protob.CreateAccessTokenResponse{
+		AuthToken:    "7685645342",
+		RefreshToken: "8796756453",
+		ExpiresIn:    900,
+	}, nil
+}
+
+func (m mockAuthClient) DeleteAccessToken(ctx context.Context, in *protob.DeleteAccessTokenRequest, opts ...googlegrpc.CallOption) (*protob.DeleteAccessTokenResponse, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &protob.DeleteAccessTokenResponse{Confirmation: "Success"}, nil
+}
+
+func (m mockAuthClient) DeleteUserAccessTokens(ctx context.Context, in *protob.DeleteUserAccessTokensRequest, opts ...googlegrpc.CallOption) (*protob.DeleteUserAccessTokensResponse, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &protob.DeleteUserAccessTokensResponse{Confirmation: "Success"}, nil
+}
+
+// CheckAccessToken knows the sessions of the tokens of mockTokenParser, except the revoked one
+func (m mockAuthClient) CheckAccessToken(ctx context.Context, in *protob.CheckAccessTokenRequest, opts ...googlegrpc.CallOption) (*protob.CheckAccessTokenResponse, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	switch in.GetAccessUuid() {
+	case "admin-uuid":
+		return &protob.CheckAccessTokenResponse{ID: 1}, nil
+	case "user-uuid":
+		return &protob.CheckAccessTokenResponse{ID: 2}, nil
+	}
+	return nil, status.Error(codes.NotFound, "access token not found")
+}
+
+func (m mockTokenParser) Parse(token string) (*auth.Identity, error) {
+	switch token {
+	case "admin-token":
+		return &auth.Identity{UserID: 1, AccessUuid: "admin-uuid"}, nil
+	case "user-token":
+		return &auth.Identity{UserID: 2, AccessUuid: "user-uuid"}, nil
+	case "revoked-token":
+		return &auth.Identity{UserID: 2, AccessUuid: "revoked-uuid"}, nil
+	}
+	return nil, model.UnauthenticatedError("invalid access token")
+}
+
+func (m mockTokenParser) ParseRefresh(token string) (*auth.RefreshToken, error) {
+	switch token {
+	case "refresh-token":
+		return &auth.RefreshToken{UserID: 2, RefreshUuid: "refresh-uuid"}, nil
+	case "deleted-user-refresh-token":
+		return &auth.RefreshToken{UserID: 42, RefreshUuid: "deleted-uuid"}, nil
+	}
+	return nil, model.UnauthenticatedError("invalid refresh token")
+}
+
+func (m mockGroupService) Group(_ context.Context, id int64) (*model.Group, error) {
+	for _, group := range mockGroups() {
+		if group.ID == id {
+			return group, nil
+		}
+	}
+	return nil, model.NotFoundError("group not found")
+}
+
+func (m mockGroupService) Groups(_ context.Context, page model.Page) (*model.GroupPage, error) {
+	return &model.GroupPage{Groups: mockGroups()}, nil
+}
+
+func (m mockGroupService) Create(ctx context.Context, ownerID int64, name, description string) (*model.Group, error) {
+	if name == "Engineering" {
+		return nil, model.AlreadyExistsError("group already exists")
+	}
+	return &model.Group{ID: 12, Name: name, Description: description, OwnerID: ownerID}, nil
+}
+
+func (m mockGroupService) Delete(ctx context.Context, id int64) error {
+	_, err := m.Group(ctx, id)
+	return err
+}
+
+func (m mockGroupService) AddMember(ctx context.Context, groupID, userID int64) error {
+	if _, err := m.Group(ctx, groupID); err != nil {
+		return err
+	}
+	_, err := (mockUserService{}).GetByID(ctx, userID)
+	return err
+}
+
+func (m mockGroupService) RemoveMember(ctx context.Context, groupID, userID int64) error {
+	if _, err := m.Group(ctx, groupID); err != nil {
+		return err
+	}
+	if groupID != 10 || (userID != 1 && userID != 2) {
+		return model.NotFoundError("user is not a member of the group")
+	}
+	return nil
+}
+
+func (m mockGroupService) Members(ctx context.Context, groupID int64, page model.Page) (*model.UserPage, error) {
+	if _, err := m.Group(ctx, groupID); err != nil {
+		return nil, err
+	}
+	res := &model.UserPage{}
+	if groupID != 10 {
+		return res, nil
+	}
+	for _, id := range []int64{1, 2} {
+		user, _ := (mockUserService{}).GetByID(ctx, id)
+		res.Users = append(res.Users, user)
+	}
+	if page.Size == 1 {
+		res.Users = res.Users[:1]
+		res.NextPageToken = "next"
+	}
+	return res, nil
+}
+
+func (m mockGroupService) UserGroups(ctx context.Context, userID int64, page model.Page) (*model.GroupPage, error) {
+	if _, err := (mockUserService{}).GetByID(ctx, userID); err != nil {
+		return nil, err
+	}
+	res := &model.GroupPage{}
+	if userID == 1 || userID == 2 {
+		res.Groups = mockGroups()[:1]
+	}
+	return res, nil
+}
+
+func mockGroups() []*model.Group {
+	return []*model.Group{
+		{ID: 10, Name: "Engineering", Description: "Builds the product", OwnerID: 2},
+		{ID: 11, Name: "Support", OwnerID: 1},
+	}
+}
+
+func (m mockRoleService) Roles(ctx context.Context) ([]*model.Role, error) {
+	return model.DefaultRoles(), nil
+}
+
+func (m mockRoleService) AssignRole(ctx context.Context, userID int64, role string) (*model.User, error) {
+	if !m.known(role) {
+		return nil, model.NotFoundError("role not found")
+	}
+	user, err := mockAdminUserService{}.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	user.SetRole(role, true)
+	return user, nil
+}
+
+func (m mockRoleService) RevokeRole(ctx context.Context, userID int64, role string) (*model.User, error) {
+	user, err := mockAdminUserService{}.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.HasRole(role) {
+		return nil, model.NotFoundError("user does not have the role")
+	}
+	user.SetRole(role, false)
+	return user, nil
+}
+
+func (m mockRoleService) HasPermission(ctx context.Context, userID int64, permission string) (bool, error) {
+	return userID == 1, nil
+}
+
+func (m mockRoleService) known(role string) bool {
+	for _, r := range model.DefaultRoles() {
+		if r.Name == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (m mockOrganizationService) Resolve(_ context.Context, slug string) (*model.Organization, error) {
+	for _, organization := range mockOrganizations() {
+		if organization.Slug == slug {
+			return organization, nil
+		}
+	}
+	return nil, model.NotFoundError("organization not found")
+}
+
+func (m mockOrganizationService) Create(ctx context.Context, ownerID int64, slug, name string) (*model.Organization, error) {
+	if _, err := m.Resolve(ctx, slug); err == nil {
+		return nil, model.AlreadyExistsError("organization already exists")
+	}
+	return &model.Organization{ID: 3, Slug: slug, Name: name}, nil
+}
+
+func (m mockOrganizationService) UserOrganizations(ctx context.Context, userID int64) ([]*model.Organization, error) {
+	if _, err := (mockUserService{}).GetByID(ctx, userID); err != nil {
+		return nil, err
+	}
+	var organizations []*model.Organization
+	for _, organization := range mockOrganizations() {
+		if member, _ := m.IsMember(ctx, organization.ID, userID); member {
+			organizations = append(organizations, organization)
+		}
+	}
+	return organizations, nil
+}
+
+func (m mockOrganizationService) IsMember(_ context.Context, organizationID, userID int64) (bool, error) {
+	return organizationID == model.DefaultOrganizationID || userID == 1, nil
+}
+
+// RemoveMember treats every user as registered in the default organization
+func (m mockOrganizationService) RemoveMember(ctx context.Context, userID int64) error {
+	if _, err := (mockUserService{}).GetByID(ctx, userID); err != nil {
+		return err
+	}
+	organizationID := model.OrganizationFromContext(ctx)
+	if organizationID == model.DefaultOrganizationID {
+		return model.InvalidArgumentError("users can not be removed from the organization they registered in")
+	}
+	if member, _ := m.IsMember(ctx, organizationID, userID); !member {
+		return model.NotFoundError("user is not a member of the organization")
+	}
+	return nil
+}
+
+func mockOrganizations() []*model.Organization {
+	return []*model.Organization{
+		{ID: 2, Slug: "acme", Name: "Acme"},
+		{ID: model.DefaultOrganizationID, Slug: model.DefaultOrganizationSlug, Name: "Default"},
+	}
+}
+
+func (m mockInvitationService) Invite(ctx context.Context, invitedBy int64, email, role string) (*model.Invitation, error) {
+	if email == "david@example.com" {
+		return nil, model.AlreadyExistsError("email already exists")
+	}
+	return &model.Invitation{ID: 1, Email: email, Role: role, InvitedBy: invitedBy}, nil
+}
+
+func (m mockInvitationService) Accept(ctx context.Context, token, username, password string) (*model.User, error) {
+	if token != "valid-token" {
+		return nil, model.InvalidArgumentError("invalid or expired invitation token")
+	}
+	return &model.User{ID: 3, Username: username}, nil
+}
+
+// Join only knows "valid-token", an invitation to acme
+func (m mockInvitationService) Join(ctx context.Context, token string, userID int64) (*model.Organization, error) {
+	if token != "valid-token" {
+		return nil, model.InvalidArgumentError("invalid or expired invitation token")
+	}
+	if _, err := (mockUserService{}).GetByID(ctx, userID); err != nil {
+		return nil, err
+	}
+	return mockOrganizations()[0], nil
+}
+
+func (m mockAuthorizer) Authorize(_ context.Context, action auth.Action, targetID int64) error {
+	if action == auth.ActionChangeAdmin && targetID == 2 {
+		return model.PermissionDeniedError("permission users:change_admin required")
+	}
+	return nil
+}
+
+func (m mockEmailVerificationService) SendVerification(ctx context.Context, userID int64) error {
+	return m.err
+}
+
+func (m mockEmailVerificationService) ResendVerification(ctx context.Context, email string) error {
+	return m.err
+}
+
+func (m mockEmailVerificationService) Verify(ctx context.Context, token string) error {
+	return m.err
+}
+
+func (m mockMFAService) Enroll(ctx context.Context, userID int64) (*model.MFAEnrollment, error) {
+	return nil, nil
+}
+
+func (m mockMFAService) Confirm(ctx context.Context, userID int64, code string) ([]string, error) {
+	return nil, nil
+}
+
+func (m mockMFAService) Disable(ctx context.Context, userID int64, code string) error {
+	return nil
+}
+
+func (m mockMFAService) Reset(ctx context.Context, userID int64) error {
+	return nil
+}
+
+func (m mockMFAService) Challenge(ctx context.Context, user *model.User) (*model.LoginChallenge, error) {
+	if user.ID == 2 {
+		return &model.LoginChallenge{Token: "mfa-token"}, nil
+	}
+	return nil, nil
+}
+
+func (m mockMFAService) EnrollChallenge(ctx context.Context, token string) (*model.MFAEnrollment, error) {
+	return nil, nil
+}
+
+func (m mockMFAService) Verify(ctx context.Context, token, code string) (*model.User, []string, error) {
+	if token != "mfa-token" && token != "enroll-token" {
+		return nil, nil, model.UnauthenticatedError("invalid or expired mfa token")
+	}
+	if code != "123456" {
+		return nil, nil, model.UnauthenticatedError("invalid mfa code")
+	}
+	if token == "enroll-token" {
+		return generateUsers()[2], []string{"abcde-fghij"}, nil
+	}
+	return generateUsers()[2], nil, nil
+}
+
+func (m mockMFAService) AdminMFARequired(ctx context.Context) (bool, error) {
+	return false, nil
+}
+
+func (m mockMFAService) SetAdminMFARequired(ctx context.Context, required bool) error {
+	return nil
 }
 
 func generateUsers() []*model.User {
@@ -254,7 +2296,7 @@ func generateUsers() []*model.User {
 		users = append(users, &model.User{
 			ID:        int64(i),
 			Username:  name,
-			Admin:     false,
+			Status:    model.StatusActive,
 			CreatedAt: time.Time{},
 			UpdatedAt: time.Time{},
 		})
@@ -271,6 +2313,7 @@ func generateProtoUsers() []*protob.User {
 			ID:       int64(i),
 			Username: name,
 			Admin:    false,
+			Status:   string(model.StatusActive),
 		})
 	}
 	return users
@@ -0,0 +1,231 @@
+package http
+
+import (
+	"time"
+
+	"github.com/JamieBShaw/user-service/auth"
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/JamieBShaw/user-service/protob"
+)
+
+// user is the http representation of a model.User, it deliberately has no
+// credential fields so handlers can never write them to a response.
+type user struct {
+	ID            int64  `json:"id"`
+	Username      string `json:"username"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	// Admin is true when the user holds the admin role
+	Admin  bool                `json:"admin"`
+	Roles  []string            `json:"roles"`
+	Status model.AccountStatus `json:"status"`
+	// StatusReason and StatusChangedAt are only set once an admin changed the status
+	StatusReason    string     `json:"status_reason,omitempty"`
+	StatusChangedAt *time.Time `json:"status_changed_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+type usersPage struct {
+	Users         []user `json:"users"`
+	NextPageToken string `json:"next_page_token"`
+}
+
+func newUser(u *model.User) user {
+	return user{
+		ID:              u.ID,
+		Username:        u.Username,
+		Email:           u.Email,
+		EmailVerified:   u.EmailVerified(),
+		Admin:           u.IsAdmin(),
+		Roles:           append([]string{}, u.Roles...),
+		Status:          u.Status,
+		StatusReason:    u.StatusReason,
+		StatusChangedAt: u.StatusChangedAt,
+		CreatedAt:       u.CreatedAt,
+		UpdatedAt:       u.UpdatedAt,
+	}
+}
+
+func newUsersPage(page *model.UserPage) usersPage {
+	res := usersPage{
+		Users:         make([]user, 0, len(page.Users)),
+		NextPageToken: page.NextPageToken,
+	}
+	for _, u := range page.Users {
+		res.Users = append(res.Users, newUser(u))
+	}
+	return res
+}
+
+// role is the http representation of a model.Role
+type role struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+type rolesResponse struct {
+	Roles []role `json:"roles"`
+}
+
+func newRolesResponse(roles []*model.Role) rolesResponse {
+	res := rolesResponse{
+		Roles: make([]role, 0, len(roles)),
+	}
+	for _, r := range roles {
+		res.Roles = append(res.Roles, role{
+			Name:        r.Name,
+			Description: r.Description,
+			Permissions: append([]string{}, r.Permissions...),
+		})
+	}
+	return res
+}
+
+// organization is the http representation of a model.Organization
+type organization struct {
+	ID   int64  `json:"id"`
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+}
+
+type organizationsResponse struct {
+	Organizations []organization `json:"organizations"`
+}
+
+func newOrganization(o *model.Organization) organization {
+	return organization{
+		ID:   o.ID,
+		Slug: o.Slug,
+		Name: o.Name,
+	}
+}
+
+func newOrganizationsResponse(organizations []*model.Organization) organizationsResponse {
+	res := organizationsResponse{
+		Organizations: make([]organization, 0, len(organizations)),
+	}
+	for _, o := range organizations {
+		res.Organizations = append(res.Organizations, newOrganization(o))
+	}
+	return res
+}
+
+// group is the http representation of a model.Group
+type group struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// OwnerID is left out once the owner was purged
+	OwnerID   int64     `json:"owner_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type groupsPage struct {
+	Groups        []group `json:"groups"`
+	NextPageToken string  `json:"next_page_token"`
+}
+
+func newGroup(g *model.Group) group {
+	return group{
+		ID:          g.ID,
+		Name:        g.Name,
+		Description: g.Description,
+		OwnerID:     g.OwnerID,
+		CreatedAt:   g.CreatedAt,
+		UpdatedAt:   g.UpdatedAt,
+	}
+}
+
+func newGroupsPage(page *model.GroupPage) groupsPage {
+	res := groupsPage{
+		Groups:        make([]group, 0, len(page.Groups)),
+		NextPageToken: page.NextPageToken,
+	}
+	for _, g := range page.Groups {
+		res.Groups = append(res.Groups, newGroup(g))
+	}
+	return res
+}
+
+// tokenResponse is the body returned by a successful login
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	// PasswordChangeRequired is only sent after an admin password reset
+	PasswordChangeRequired bool `json:"password_change_required,omitempty"`
+	// RecoveryCodes is only sent when the login confirmed a required MFA enrollment
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+// mfaChallengeResponse is returned by a login with a valid password when a second factor is needed
+type mfaChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	// MFAEnrollmentRequired is sent when the user must set up MFA with the token before logging in
+	MFAEnrollmentRequired bool `json:"mfa_enrollment_required,omitempty"`
+}
+
+// mfaEnrollmentResponse holds what an authenticator app needs to start generating codes
+type mfaEnrollmentResponse struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauth_uri"`
+}
+
+type recoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type mfaPolicy struct {
+	RequireAdminMFA bool `json:"require_admin_mfa"`
+}
+
+func newMFAChallengeResponse(challenge *model.LoginChallenge) mfaChallengeResponse {
+	return mfaChallengeResponse{
+		MFARequired:           true,
+		MFAToken:              challenge.Token,
+		MFAEnrollmentRequired: challenge.EnrollmentRequired,
+	}
+}
+
+func newMFAEnrollmentResponse(enrollment *model.MFAEnrollment) mfaEnrollmentResponse {
+	return mfaEnrollmentResponse{
+		Secret:     enrollment.Secret,
+		OtpauthURI: enrollment.URI,
+	}
+}
+
+func newTokenResponse(res *protob.CreateAccessTokenResponse, u *model.User) tokenResponse {
+	return tokenResponse{
+		AccessToken:            res.GetAuthToken(),
+		RefreshToken:           res.GetRefreshToken(),
+		TokenType:              auth.TokenType,
+		ExpiresIn:              res.GetExpiresIn(),
+		PasswordChangeRequired: u.PasswordChangeRequired,
+	}
+}
+
+// invitation is the http representation of a model.Invitation, its token is only ever sent to the invitee
+type invitation struct {
+	ID        int64     `json:"id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role,omitempty"`
+	InvitedBy int64     `json:"invited_by,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newInvitation(i *model.Invitation) invitation {
+	return invitation{
+		ID:        i.ID,
+		Email:     i.Email,
+		Role:      i.Role,
+		InvitedBy: i.InvitedBy,
+		ExpiresAt: i.ExpiresAt,
+		CreatedAt: i.CreatedAt,
+	}
+}
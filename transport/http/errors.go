@@ -0,0 +1,76 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/JamieBShaw/user-service/domain/model"
+)
+
+const problemContentType = "application/problem+json"
+
+// problem is an RFC 7807 problem details response body
+type problem struct {
+	Type      string             `json:"type"`
+	Title     string             `json:"title"`
+	Status    int                `json:"status"`
+	Detail    string             `json:"detail"`
+	Code      model.ErrorCode    `json:"code"`
+	RequestID string             `json:"request_id,omitempty"`
+	Errors    []model.FieldError `json:"errors,omitempty"`
+}
+
+// statusFromError maps the code of a domain error to the http status returned to the client
+func statusFromError(err error) int {
+	switch model.ErrorCodeOf(err) {
+	case model.CodeNotFound:
+		return http.StatusNotFound
+	case model.CodeAlreadyExists:
+		return http.StatusConflict
+	case model.CodeInvalidArgument:
+		return http.StatusBadRequest
+	case model.CodeUnauthenticated:
+		return http.StatusUnauthorized
+	case model.CodePermissionDenied:
+		return http.StatusForbidden
+	case model.CodeUnavailable:
+		return http.StatusServiceUnavailable
+	case model.CodeTimeout:
+		return http.StatusGatewayTimeout
+	case model.CodeBadGateway:
+		return http.StatusBadGateway
+	case model.CodeTooManyRequests:
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeError logs err and writes it to the client as a problem, the message of
+// an error that is not a domain error is never exposed.
+func (s *httpServer) writeError(rw http.ResponseWriter, r *http.Request, err error) {
+	s.log.Errorf("error: %v", err)
+
+	status := statusFromError(err)
+	code := model.ErrorCodeOf(err)
+
+	body := problem{
+		Type:      "/problems/" + string(code),
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    "internal server error",
+		Code:      code,
+		RequestID: requestIDFromContext(r.Context()),
+	}
+
+	var domainErr *model.Error
+	if errors.As(err, &domainErr) {
+		body.Detail = err.Error()
+		body.Errors = model.FieldErrorsOf(err)
+	}
+
+	rw.Header().Set("Content-Type", problemContentType)
+	if err := model.ToJson(rw, status, body); err != nil {
+		s.log.Errorf("error writing problem response: %v", err)
+	}
+}
@@ -5,25 +5,32 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/JamieBShaw/user-service/auth"
 	"github.com/JamieBShaw/user-service/domain/model"
 	"github.com/JamieBShaw/user-service/protob"
+	"github.com/JamieBShaw/user-service/service"
 	"github.com/gorilla/mux"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
 )
 
+// authServiceTimeout bounds every call made to the auth service
+const authServiceTimeout = 5 * time.Second
+
+// messageResponse is the body of responses that only confirm an action
+type messageResponse struct {
+	Message string `json:"message"`
+}
+
 func (s *httpServer) GetById(rw http.ResponseWriter, r *http.Request) {
 	s.log.Info("[HTTP SERVER]: Executing GetById Handler")
 	userId := strings.TrimSpace(mux.Vars(r)["id"])
 
 	if userId == "" {
-		err := errors.New("id not given")
-		s.log.Errorf("error: %v", err.Error())
-		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		s.writeError(rw, r, model.InvalidArgumentError("id not given"))
 		return
 	}
 
@@ -31,27 +38,26 @@ func (s *httpServer) GetById(rw http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		s.log.Errorf("error: %v", err.Error())
-		http.Error(rw, errors.New("invalid query parameter").Error(), http.StatusInternalServerError)
+		s.writeError(rw, r, model.InvalidArgumentError("invalid query parameter"))
 		return
 	}
 
-	user, err := s.service.GetByID(context.Background(), int64(id))
+	user, err := s.service.GetByID(r.Context(), int64(id))
 	if err != nil {
-		s.log.Errorf("error: %v", err.Error())
-		http.Error(rw, err.Error(), http.StatusNotFound)
+		s.writeError(rw, r, err)
 		return
 	}
 
-	err = model.ToJson(rw, http.StatusOK, user)
+	err = model.ToJson(rw, http.StatusOK, newUser(user))
 	if err != nil {
 		s.log.Errorf("error: %v", err.Error())
-		http.Error(rw, err.Error(), http.StatusNotFound)
 	}
 }
 
 func (s *httpServer) Register() http.HandlerFunc {
 	type request struct {
 		Username string `json:"username"`
+		Email    string `json:"email"`
 		Password string `json:"password"`
 	}
 	return func(rw http.ResponseWriter, r *http.Request) {
@@ -61,36 +67,99 @@ func (s *httpServer) Register() http.HandlerFunc {
 		err := json.NewDecoder(r.Body).Decode(&req)
 		if err != nil {
 			s.log.Errorf("error: %v", err)
-			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			s.writeError(rw, r, model.InvalidArgumentError("invalid request body"))
 			return
 		}
 		defer r.Body.Close()
 
-		err = s.service.Create(context.Background(), req.Username, req.Password)
+		user, err := s.service.Create(r.Context(), req.Username, req.Email, req.Password)
+		if err != nil {
+			s.writeError(rw, r, err)
+			return
+		}
+
+		// The user can ask for another verification email, so a failure here does not fail the registration
+		if user.Email != "" {
+			err = s.verifications.SendVerification(r.Context(), user.ID)
+			if err != nil {
+				s.log.Errorf("error sending email verification: %v", err)
+			}
+		}
+
+		err = model.ToJson(rw, http.StatusCreated, messageResponse{Message: "User successfully created"})
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+		}
+	}
+}
+
+func (s *httpServer) Update() http.HandlerFunc {
+	type request struct {
+		Username *string `json:"username"`
+		Admin    *bool   `json:"admin"`
+	}
+	return func(rw http.ResponseWriter, r *http.Request) {
+		s.log.Info("[HTTP SERVER]: Executing Update Handler")
+		userId := strings.TrimSpace(mux.Vars(r)["id"])
+
+		id, err := strconv.Atoi(userId)
+		if err != nil {
+			s.log.Errorf("error: %v", err.Error())
+			s.writeError(rw, r, model.InvalidArgumentError("invalid query parameter"))
+			return
+		}
+
+		var req request
+
+		err = json.NewDecoder(r.Body).Decode(&req)
 		if err != nil {
 			s.log.Errorf("error: %v", err)
-			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			s.writeError(rw, r, model.InvalidArgumentError("invalid request body"))
+			return
+		}
+		defer r.Body.Close()
+
+		if req.Admin != nil {
+			if err := s.authorizer.Authorize(r.Context(), auth.ActionChangeAdmin, int64(id)); err != nil {
+				s.writeError(rw, r, err)
+				return
+			}
+		}
+
+		user, err := s.service.Update(r.Context(), int64(id), model.UserUpdate{
+			Username: req.Username,
+			Admin:    req.Admin,
+		})
+		if err != nil {
+			s.writeError(rw, r, err)
 			return
 		}
 
-		rw.WriteHeader(http.StatusCreated)
-		rw.Write([]byte("User successfully created"))
+		err = model.ToJson(rw, http.StatusOK, newUser(user))
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+		}
 	}
 }
 
 func (s *httpServer) GetUsers(rw http.ResponseWriter, r *http.Request) {
 	s.log.Info("[HTTP SERVER]: Executing GetUsers Handler")
-	users, err := s.service.GetUsers(context.Background())
+
+	filter, page, err := parseUsersQuery(r.URL.Query())
 	if err != nil {
-		s.log.Errorf("error: %v", err)
-		http.Error(rw, err.Error(), http.StatusNotFound)
+		s.writeError(rw, r, err)
+		return
+	}
+
+	users, err := s.service.GetUsers(r.Context(), filter, page)
+	if err != nil {
+		s.writeError(rw, r, err)
 		return
 	}
 
-	err = model.ToJson(rw, http.StatusOK, users)
+	err = model.ToJson(rw, http.StatusOK, newUsersPage(users))
 	if err != nil {
 		s.log.Errorf("error: %v", err)
-		http.Error(rw, err.Error(), http.StatusInternalServerError)
 	}
 }
 
@@ -101,18 +170,45 @@ func (s *httpServer) Delete(rw http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(userId)
 	if err != nil {
 		s.log.Errorf("error: %v", err.Error())
-		http.Error(rw, errors.New("invalid query parameter").Error(), http.StatusInternalServerError)
+		s.writeError(rw, r, model.InvalidArgumentError("invalid query parameter"))
 		return
 	}
 
-	err = s.service.Delete(context.Background(), int64(id))
+	err = s.service.Delete(r.Context(), int64(id))
 	if err != nil {
-		s.log.Errorf("error: %v", err.Error())
-		http.Error(rw, err.Error(), http.StatusNotFound)
+		s.writeError(rw, r, err)
 		return
 	}
-	rw.WriteHeader(http.StatusOK)
-	rw.Write([]byte("User successfully deleted"))
+	err = model.ToJson(rw, http.StatusOK, messageResponse{Message: "User successfully deleted"})
+	if err != nil {
+		s.log.Errorf("error: %v", err)
+	}
+}
+
+// Restore brings back a deleted user before the purge job removes them
+func (s *httpServer) Restore() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		s.log.Info("[HTTP SERVER]: Executing Restore Handler")
+		userId := strings.TrimSpace(mux.Vars(r)["id"])
+
+		id, err := strconv.Atoi(userId)
+		if err != nil {
+			s.log.Errorf("error: %v", err.Error())
+			s.writeError(rw, r, model.InvalidArgumentError("invalid query parameter"))
+			return
+		}
+
+		user, err := s.service.Restore(r.Context(), int64(id))
+		if err != nil {
+			s.writeError(rw, r, err)
+			return
+		}
+
+		err = model.ToJson(rw, http.StatusOK, newUser(user))
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+		}
+	}
 }
 
 func (s *httpServer) Login() http.HandlerFunc {
@@ -129,65 +225,1261 @@ func (s *httpServer) Login() http.HandlerFunc {
 		decodeErr := json.NewDecoder(r.Body).Decode(&req)
 		if decodeErr != nil {
 			s.log.Errorf("error: %v", decodeErr)
-			http.Error(rw, decodeErr.Error(), http.StatusInternalServerError)
+			s.writeError(rw, r, model.InvalidArgumentError("invalid request body"))
 			return
 		}
 		defer r.Body.Close()
 
-		user, err := s.service.GetByUsernameAndPassword(context.Background(), req.Username, req.Password)
+		var fields []model.FieldError
+		if req.Username == "" {
+			fields = append(fields, model.FieldError{Field: "username", Message: "username is required"})
+		}
+		if req.Password == "" {
+			fields = append(fields, model.FieldError{Field: "password", Message: "password is required"})
+		}
+		if len(fields) > 0 {
+			s.writeError(rw, r, model.ValidationError(fields...))
+			return
+		}
+
+		ctx := service.WithClientIP(r.Context(), clientIP(r))
+		user, err := s.service.GetByUsernameAndPassword(ctx, req.Username, req.Password)
+		if err != nil {
+			s.writeError(rw, r, err)
+			return
+		}
+
+		challenge, err := s.mfa.Challenge(ctx, user)
+		if err != nil {
+			s.writeError(rw, r, err)
+			return
+		}
+		if challenge != nil {
+			err = model.ToJson(rw, http.StatusOK, newMFAChallengeResponse(challenge))
+			if err != nil {
+				s.log.Errorf("error: %v", err)
+			}
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), authServiceTimeout)
+		defer cancel()
+
+		res, err := s.authServiceClient.CreateAccessToken(ctx, &protob.CreateAccessTokenRequest{
+			ID: user.ID,
+		})
+		if err != nil {
+			s.writeError(rw, r, auth.ServiceError(err))
+			return
+		}
+
+		err = model.ToJson(rw, http.StatusOK, newTokenResponse(res, user))
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+		}
+	}
+}
+
+// VerifyMFA completes a login that was challenged for a second factor with a TOTP or recovery code
+func (s *httpServer) VerifyMFA() http.HandlerFunc {
+	type request struct {
+		MFAToken string `json:"mfa_token"`
+		Code     string `json:"code"`
+	}
+	return func(rw http.ResponseWriter, r *http.Request) {
+		s.log.Info("[HTTP SERVER]: Executing VerifyMFA Handler")
+		var req request
+
+		err := json.NewDecoder(r.Body).Decode(&req)
 		if err != nil {
 			s.log.Errorf("error: %v", err)
-			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			s.writeError(rw, r, model.InvalidArgumentError("invalid request body"))
 			return
 		}
+		defer r.Body.Close()
 
-		s.log.Info("passed service")
+		ctx := service.WithClientIP(r.Context(), clientIP(r))
+		user, recoveryCodes, err := s.mfa.Verify(ctx, req.MFAToken, req.Code)
+		if err != nil {
+			s.writeError(rw, r, err)
+			return
+		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ctx, cancel := context.WithTimeout(r.Context(), authServiceTimeout)
 		defer cancel()
 
 		res, err := s.authServiceClient.CreateAccessToken(ctx, &protob.CreateAccessTokenRequest{
 			ID: user.ID,
 		})
+		if err != nil {
+			s.writeError(rw, r, auth.ServiceError(err))
+			return
+		}
+
+		body := newTokenResponse(res, user)
+		body.RecoveryCodes = recoveryCodes
+
+		err = model.ToJson(rw, http.StatusOK, body)
 		if err != nil {
 			s.log.Errorf("error: %v", err)
+		}
+	}
+}
 
-			err, ok := status.FromError(err)
-			if ok {
-				if err.Code() == codes.DeadlineExceeded {
-					s.log.Errorf("timeout exceeded: %v", err)
-					return
-				}
-				s.log.Errorf("unexpected error: %v", err)
-			}
-			s.log.Errorf("error whilke calling CreateAccessToken RPC: %v", err)
+// EnrollMFAChallenge starts MFA enrollment for a login that was challenged before the user set it up
+func (s *httpServer) EnrollMFAChallenge() http.HandlerFunc {
+	type request struct {
+		MFAToken string `json:"mfa_token"`
+	}
+	return func(rw http.ResponseWriter, r *http.Request) {
+		s.log.Info("[HTTP SERVER]: Executing EnrollMFAChallenge Handler")
+		var req request
+
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+			s.writeError(rw, r, model.InvalidArgumentError("invalid request body"))
+			return
+		}
+		defer r.Body.Close()
+
+		enrollment, err := s.mfa.EnrollChallenge(r.Context(), req.MFAToken)
+		if err != nil {
+			s.writeError(rw, r, err)
 			return
 		}
-		tokens := map[string]string{
-			"access_token":  res.GetAuthToken(),
-			"refresh_token": res.GetRefreshToken(),
+
+		err = model.ToJson(rw, http.StatusOK, newMFAEnrollmentResponse(enrollment))
+		if err != nil {
+			s.log.Errorf("error: %v", err)
 		}
+	}
+}
+
+// RefreshToken exchanges a refresh token for new tokens, as long as the user it was issued to still exists
+func (s *httpServer) RefreshToken() http.HandlerFunc {
+	type request struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	return func(rw http.ResponseWriter, r *http.Request) {
+		s.log.Info("[HTTP SERVER]: Executing RefreshToken Handler")
+		var req request
 
-		err = json.NewEncoder(rw).Encode(&tokens)
+		err := json.NewDecoder(r.Body).Decode(&req)
 		if err != nil {
 			s.log.Errorf("error: %v", err)
-			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			s.writeError(rw, r, model.InvalidArgumentError("invalid request body"))
+			return
+		}
+		defer r.Body.Close()
+
+		if req.RefreshToken == "" {
+			s.writeError(rw, r, model.ValidationError(model.FieldError{Field: "refresh_token", Message: "refresh_token is required"}))
+			return
+		}
+
+		refresh, err := s.refreshTokens.ParseRefresh(req.RefreshToken)
+		if err != nil {
+			s.writeError(rw, r, err)
+			return
+		}
+
+		user, err := s.service.GetByID(r.Context(), refresh.UserID)
+		if errors.Is(err, model.ErrNotFound) {
+			s.writeError(rw, r, model.UnauthenticatedError("user no longer exists"))
+			return
+		}
+		if err != nil {
+			s.writeError(rw, r, err)
+			return
+		}
+		if err := user.CheckActive(); err != nil {
+			s.writeError(rw, r, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), authServiceTimeout)
+		defer cancel()
+
+		res, err := s.authServiceClient.RefreshAccessToken(ctx, &protob.RefreshAccessTokenRequest{
+			RefreshToken: req.RefreshToken,
+		})
+		if err != nil {
+			s.writeError(rw, r, auth.ServiceError(err))
 			return
 		}
 
-		rw.WriteHeader(http.StatusOK)
+		err = model.ToJson(rw, http.StatusOK, newTokenResponse(res, user))
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+		}
 	}
 }
 
-func (s *httpServer) Logout() http.HandlerFunc {
+// ChangePassword changes the password of the caller and revokes every other session they have
+func (s *httpServer) ChangePassword() http.HandlerFunc {
+	type request struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	return func(rw http.ResponseWriter, r *http.Request) {
+		s.log.Info("[HTTP SERVER]: Executing ChangePassword Handler")
+		userId := strings.TrimSpace(mux.Vars(r)["id"])
+
+		id, err := strconv.Atoi(userId)
+		if err != nil {
+			s.log.Errorf("error: %v", err.Error())
+			s.writeError(rw, r, model.InvalidArgumentError("invalid query parameter"))
+			return
+		}
+
+		var req request
+
+		err = json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+			s.writeError(rw, r, model.InvalidArgumentError("invalid request body"))
+			return
+		}
+		defer r.Body.Close()
+
+		err = s.service.ChangePassword(r.Context(), int64(id), req.CurrentPassword, req.NewPassword)
+		if err != nil {
+			s.writeError(rw, r, err)
+			return
+		}
+
+		// Keep the session the password was changed from
+		var keepAccessUuid string
+		if identity, ok := auth.IdentityFromContext(r.Context()); ok {
+			keepAccessUuid = identity.AccessUuid
+		}
+
+		s.revokeAccessTokens(r.Context(), int64(id), keepAccessUuid)
+
+		err = model.ToJson(rw, http.StatusOK, messageResponse{Message: "Password successfully changed"})
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+		}
+	}
+}
 
+// ResetPassword lets an admin set a temporary password, the user is signed out everywhere
+// and must change it after their next login.
+func (s *httpServer) ResetPassword() http.HandlerFunc {
+	type request struct {
+		TemporaryPassword string `json:"temporary_password"`
+	}
 	return func(rw http.ResponseWriter, r *http.Request) {
-		s.log.Info("[HTTP SERVER]: Executing Logout Handler")
+		s.log.Info("[HTTP SERVER]: Executing ResetPassword Handler")
+		userId := strings.TrimSpace(mux.Vars(r)["id"])
+
+		id, err := strconv.Atoi(userId)
+		if err != nil {
+			s.log.Errorf("error: %v", err.Error())
+			s.writeError(rw, r, model.InvalidArgumentError("invalid query parameter"))
+			return
+		}
+
+		var req request
+
+		err = json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+			s.writeError(rw, r, model.InvalidArgumentError("invalid request body"))
+			return
+		}
+		defer r.Body.Close()
+
+		err = s.service.ResetPassword(r.Context(), int64(id), req.TemporaryPassword)
+		if err != nil {
+			s.writeError(rw, r, err)
+			return
+		}
+
+		s.revokeAccessTokens(r.Context(), int64(id), "")
+
+		err = model.ToJson(rw, http.StatusOK, messageResponse{Message: "Password successfully reset"})
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+		}
 	}
 }
 
-func (s *httpServer) Healthz(rw http.ResponseWriter, r *http.Request) {
-	s.log.Info("Ping Request has been made....")
-	rw.WriteHeader(http.StatusOK)
-	rw.Write([]byte("Healthy!"))
+// revokeAccessTokens signs the user out of every session but keepAccessUuid after their password or status changed.
+// The change is saved by then, so a failure is logged instead of failing the request.
+func (s *httpServer) revokeAccessTokens(ctx context.Context, userID int64, keepAccessUuid string) {
+	ctx, cancel := context.WithTimeout(ctx, authServiceTimeout)
+	defer cancel()
+
+	_, err := s.authServiceClient.DeleteUserAccessTokens(ctx, &protob.DeleteUserAccessTokensRequest{
+		ID:             userID,
+		KeepAccessUuid: keepAccessUuid,
+	})
+	if err != nil {
+		s.log.Errorf("error revoking access tokens of user %d: %v", userID, err)
+	}
+}
+
+// UnlockUser lets an admin lift the lockout of an account after too many failed logins
+func (s *httpServer) UnlockUser() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		s.log.Info("[HTTP SERVER]: Executing UnlockUser Handler")
+		userId := strings.TrimSpace(mux.Vars(r)["id"])
+
+		id, err := strconv.Atoi(userId)
+		if err != nil {
+			s.log.Errorf("error: %v", err.Error())
+			s.writeError(rw, r, model.InvalidArgumentError("invalid query parameter"))
+			return
+		}
+
+		err = s.service.UnlockUser(r.Context(), int64(id))
+		if err != nil {
+			s.writeError(rw, r, err)
+			return
+		}
+
+		err = model.ToJson(rw, http.StatusOK, messageResponse{Message: "User successfully unlocked"})
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+		}
+	}
+}
+
+// ChangeStatus lets an admin activate, suspend or disable an account, suspended and disabled users are signed out everywhere
+func (s *httpServer) ChangeStatus() http.HandlerFunc {
+	type request struct {
+		Status string `json:"status"`
+		Reason string `json:"reason"`
+	}
+	return func(rw http.ResponseWriter, r *http.Request) {
+		s.log.Info("[HTTP SERVER]: Executing ChangeStatus Handler")
+		userId := strings.TrimSpace(mux.Vars(r)["id"])
+
+		id, err := strconv.Atoi(userId)
+		if err != nil {
+			s.log.Errorf("error: %v", err.Error())
+			s.writeError(rw, r, model.InvalidArgumentError("invalid query parameter"))
+			return
+		}
+
+		var req request
+
+		err = json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+			s.writeError(rw, r, model.InvalidArgumentError("invalid request body"))
+			return
+		}
+		defer r.Body.Close()
+
+		user, err := s.service.ChangeStatus(r.Context(), int64(id), model.AccountStatus(req.Status), req.Reason)
+		if err != nil {
+			s.writeError(rw, r, err)
+			return
+		}
+
+		if user.Status == model.StatusSuspended || user.Status == model.StatusDisabled {
+			s.revokeAccessTokens(r.Context(), user.ID, "")
+		}
+
+		err = model.ToJson(rw, http.StatusOK, newUser(user))
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+		}
+	}
+}
+
+// GetRoles lists the roles that can be assigned with the permissions they grant
+func (s *httpServer) GetRoles(rw http.ResponseWriter, r *http.Request) {
+	s.log.Info("[HTTP SERVER]: Executing GetRoles Handler")
+
+	roles, err := s.roles.Roles(r.Context())
+	if err != nil {
+		s.writeError(rw, r, err)
+		return
+	}
+
+	err = model.ToJson(rw, http.StatusOK, newRolesResponse(roles))
+	if err != nil {
+		s.log.Errorf("error: %v", err)
+	}
+}
+
+// AssignRole grants the role in the path to the user, granting a role the user already holds changes nothing
+func (s *httpServer) AssignRole() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		s.log.Info("[HTTP SERVER]: Executing AssignRole Handler")
+		s.changeRole(rw, r, s.roles.AssignRole)
+	}
+}
+
+// RevokeRole takes the role in the path away from the user
+func (s *httpServer) RevokeRole() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		s.log.Info("[HTTP SERVER]: Executing RevokeRole Handler")
+		s.changeRole(rw, r, s.roles.RevokeRole)
+	}
+}
+
+// changeRole applies change to the user and role in the path and writes the user with their roles
+func (s *httpServer) changeRole(rw http.ResponseWriter, r *http.Request, change func(ctx context.Context, userID int64, role string) (*model.User, error)) {
+	vars := mux.Vars(r)
+
+	id, err := strconv.Atoi(strings.TrimSpace(vars["id"]))
+	if err != nil {
+		s.log.Errorf("error: %v", err.Error())
+		s.writeError(rw, r, model.InvalidArgumentError("invalid query parameter"))
+		return
+	}
+
+	user, err := change(r.Context(), int64(id), vars["role"])
+	if err != nil {
+		s.writeError(rw, r, err)
+		return
+	}
+
+	err = model.ToJson(rw, http.StatusOK, newUser(user))
+	if err != nil {
+		s.log.Errorf("error: %v", err)
+	}
+}
+
+// EnrollMFA starts setting up an authenticator, MFA is not enabled until it is confirmed with a first code
+func (s *httpServer) EnrollMFA() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		s.log.Info("[HTTP SERVER]: Executing EnrollMFA Handler")
+		userId := strings.TrimSpace(mux.Vars(r)["id"])
+
+		id, err := strconv.Atoi(userId)
+		if err != nil {
+			s.log.Errorf("error: %v", err.Error())
+			s.writeError(rw, r, model.InvalidArgumentError("invalid query parameter"))
+			return
+		}
+
+		enrollment, err := s.mfa.Enroll(r.Context(), int64(id))
+		if err != nil {
+			s.writeError(rw, r, err)
+			return
+		}
+
+		err = model.ToJson(rw, http.StatusOK, newMFAEnrollmentResponse(enrollment))
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+		}
+	}
+}
+
+// ConfirmMFA enables MFA and returns the recovery codes, they are never shown again
+func (s *httpServer) ConfirmMFA() http.HandlerFunc {
+	type request struct {
+		Code string `json:"code"`
+	}
+	return func(rw http.ResponseWriter, r *http.Request) {
+		s.log.Info("[HTTP SERVER]: Executing ConfirmMFA Handler")
+		userId := strings.TrimSpace(mux.Vars(r)["id"])
+
+		id, err := strconv.Atoi(userId)
+		if err != nil {
+			s.log.Errorf("error: %v", err.Error())
+			s.writeError(rw, r, model.InvalidArgumentError("invalid query parameter"))
+			return
+		}
+
+		var req request
+
+		err = json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+			s.writeError(rw, r, model.InvalidArgumentError("invalid request body"))
+			return
+		}
+		defer r.Body.Close()
+
+		recoveryCodes, err := s.mfa.Confirm(r.Context(), int64(id), req.Code)
+		if err != nil {
+			s.writeError(rw, r, err)
+			return
+		}
+
+		err = model.ToJson(rw, http.StatusOK, recoveryCodesResponse{RecoveryCodes: recoveryCodes})
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+		}
+	}
+}
+
+// DisableMFA turns MFA off, users turning off their own send a current code or a recovery code while users allowed
+// to disable the MFA of anyone do not need one for others
+func (s *httpServer) DisableMFA() http.HandlerFunc {
+	type request struct {
+		Code string `json:"code"`
+	}
+	return func(rw http.ResponseWriter, r *http.Request) {
+		s.log.Info("[HTTP SERVER]: Executing DisableMFA Handler")
+		userId := strings.TrimSpace(mux.Vars(r)["id"])
+
+		id, err := strconv.Atoi(userId)
+		if err != nil {
+			s.log.Errorf("error: %v", err.Error())
+			s.writeError(rw, r, model.InvalidArgumentError("invalid query parameter"))
+			return
+		}
+
+		identity, ok := auth.IdentityFromContext(r.Context())
+		if !ok {
+			s.writeError(rw, r, model.UnauthenticatedError("authentication required"))
+			return
+		}
+
+		if identity.UserID == int64(id) {
+			var req request
+
+			err = json.NewDecoder(r.Body).Decode(&req)
+			if err != nil {
+				s.log.Errorf("error: %v", err)
+				s.writeError(rw, r, model.InvalidArgumentError("invalid request body"))
+				return
+			}
+			defer r.Body.Close()
+
+			err = s.mfa.Disable(r.Context(), int64(id), req.Code)
+		} else {
+			err = s.mfa.Reset(r.Context(), int64(id))
+		}
+		if err != nil {
+			s.writeError(rw, r, err)
+			return
+		}
+
+		err = model.ToJson(rw, http.StatusOK, messageResponse{Message: "MFA successfully disabled"})
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+		}
+	}
+}
+
+func (s *httpServer) GetMFAPolicy(rw http.ResponseWriter, r *http.Request) {
+	s.log.Info("[HTTP SERVER]: Executing GetMFAPolicy Handler")
+
+	required, err := s.mfa.AdminMFARequired(r.Context())
+	if err != nil {
+		s.writeError(rw, r, err)
+		return
+	}
+
+	err = model.ToJson(rw, http.StatusOK, mfaPolicy{RequireAdminMFA: required})
+	if err != nil {
+		s.log.Errorf("error: %v", err)
+	}
+}
+
+func (s *httpServer) UpdateMFAPolicy() http.HandlerFunc {
+	type request struct {
+		RequireAdminMFA *bool `json:"require_admin_mfa"`
+	}
+	return func(rw http.ResponseWriter, r *http.Request) {
+		s.log.Info("[HTTP SERVER]: Executing UpdateMFAPolicy Handler")
+		var req request
+
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+			s.writeError(rw, r, model.InvalidArgumentError("invalid request body"))
+			return
+		}
+		defer r.Body.Close()
+
+		if req.RequireAdminMFA == nil {
+			s.writeError(rw, r, model.ValidationError(model.FieldError{Field: "require_admin_mfa", Message: "require_admin_mfa is required"}))
+			return
+		}
+
+		err = s.mfa.SetAdminMFARequired(r.Context(), *req.RequireAdminMFA)
+		if err != nil {
+			s.writeError(rw, r, err)
+			return
+		}
+
+		err = model.ToJson(rw, http.StatusOK, mfaPolicy{RequireAdminMFA: *req.RequireAdminMFA})
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+		}
+	}
+}
+
+// ForgotPassword sends a password reset token to the user, it always responds 202 so
+// callers can not find out which usernames exist.
+func (s *httpServer) ForgotPassword() http.HandlerFunc {
+	type request struct {
+		Username string `json:"username"`
+	}
+	return func(rw http.ResponseWriter, r *http.Request) {
+		s.log.Info("[HTTP SERVER]: Executing ForgotPassword Handler")
+		var req request
+
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+			s.writeError(rw, r, model.InvalidArgumentError("invalid request body"))
+			return
+		}
+		defer r.Body.Close()
+
+		if req.Username == "" {
+			s.writeError(rw, r, model.ValidationError(model.FieldError{Field: "username", Message: "username is required"}))
+			return
+		}
+
+		err = s.passwordResets.RequestReset(r.Context(), req.Username)
+		if err != nil {
+			s.log.Errorf("error requesting password reset: %v", err)
+		}
+
+		err = model.ToJson(rw, http.StatusAccepted, messageResponse{Message: "If the account exists a password reset has been sent"})
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+		}
+	}
+}
+
+// ConfirmPasswordReset sets a new password with a reset token and signs the user out everywhere
+func (s *httpServer) ConfirmPasswordReset() http.HandlerFunc {
+	type request struct {
+		Token       string `json:"token"`
+		NewPassword string `json:"new_password"`
+	}
+	return func(rw http.ResponseWriter, r *http.Request) {
+		s.log.Info("[HTTP SERVER]: Executing ConfirmPasswordReset Handler")
+		var req request
+
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+			s.writeError(rw, r, model.InvalidArgumentError("invalid request body"))
+			return
+		}
+		defer r.Body.Close()
+
+		userID, err := s.passwordResets.ConfirmReset(r.Context(), req.Token, req.NewPassword)
+		if err != nil {
+			s.writeError(rw, r, err)
+			return
+		}
+
+		// The reset token is used up, failing now would leave the user unable to retry
+		s.revokeAccessTokens(r.Context(), userID, "")
+
+		err = model.ToJson(rw, http.StatusOK, messageResponse{Message: "Password successfully reset"})
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+		}
+	}
+}
+
+// VerifyEmail marks the email address a verification token was sent to as verified
+func (s *httpServer) VerifyEmail() http.HandlerFunc {
+	type request struct {
+		Token string `json:"token"`
+	}
+	return func(rw http.ResponseWriter, r *http.Request) {
+		s.log.Info("[HTTP SERVER]: Executing VerifyEmail Handler")
+		var req request
+
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+			s.writeError(rw, r, model.InvalidArgumentError("invalid request body"))
+			return
+		}
+		defer r.Body.Close()
+
+		err = s.verifications.Verify(r.Context(), req.Token)
+		if err != nil {
+			s.writeError(rw, r, err)
+			return
+		}
+
+		err = model.ToJson(rw, http.StatusOK, messageResponse{Message: "Email successfully verified"})
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+		}
+	}
+}
+
+// ResendVerification always responds the same way so it can not be used to find registered emails
+func (s *httpServer) ResendVerification() http.HandlerFunc {
+	type request struct {
+		Email string `json:"email"`
+	}
+	return func(rw http.ResponseWriter, r *http.Request) {
+		s.log.Info("[HTTP SERVER]: Executing ResendVerification Handler")
+		var req request
+
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+			s.writeError(rw, r, model.InvalidArgumentError("invalid request body"))
+			return
+		}
+		defer r.Body.Close()
+
+		if req.Email == "" {
+			s.writeError(rw, r, model.ValidationError(model.FieldError{Field: "email", Message: "email is required"}))
+			return
+		}
+
+		err = s.verifications.ResendVerification(r.Context(), req.Email)
+		if err != nil {
+			s.log.Errorf("error resending email verification: %v", err)
+		}
+
+		err = model.ToJson(rw, http.StatusAccepted, messageResponse{Message: "If the email is registered and unverified a verification has been sent"})
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+		}
+	}
+}
+
+// Logout revokes the access token the request was authenticated with
+func (s *httpServer) Logout() http.HandlerFunc {
+
+	return func(rw http.ResponseWriter, r *http.Request) {
+		s.log.Info("[HTTP SERVER]: Executing Logout Handler")
+
+		identity, ok := auth.IdentityFromContext(r.Context())
+		if !ok {
+			s.writeError(rw, r, model.UnauthenticatedError("authentication required"))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), authServiceTimeout)
+		defer cancel()
+
+		_, err := s.authServiceClient.DeleteAccessToken(ctx, &protob.DeleteAccessTokenRequest{
+			AccessUuid: identity.AccessUuid,
+		})
+		if err != nil {
+			s.writeError(rw, r, auth.ServiceError(err))
+			return
+		}
+
+		err = model.ToJson(rw, http.StatusOK, messageResponse{Message: "User successfully logged out"})
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+		}
+	}
+}
+
+// LogoutAll revokes every access token of the caller, signing them out of all their sessions
+func (s *httpServer) LogoutAll() http.HandlerFunc {
+
+	return func(rw http.ResponseWriter, r *http.Request) {
+		s.log.Info("[HTTP SERVER]: Executing LogoutAll Handler")
+
+		identity, ok := auth.IdentityFromContext(r.Context())
+		if !ok {
+			s.writeError(rw, r, model.UnauthenticatedError("authentication required"))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), authServiceTimeout)
+		defer cancel()
+
+		_, err := s.authServiceClient.DeleteUserAccessTokens(ctx, &protob.DeleteUserAccessTokensRequest{
+			ID: identity.UserID,
+		})
+		if err != nil {
+			s.writeError(rw, r, auth.ServiceError(err))
+			return
+		}
+
+		err = model.ToJson(rw, http.StatusOK, messageResponse{Message: "User successfully logged out of all sessions"})
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+		}
+	}
+}
+
+func (s *httpServer) Healthz(rw http.ResponseWriter, r *http.Request) {
+	s.log.Info("Ping Request has been made....")
+	err := model.ToJson(rw, http.StatusOK, messageResponse{Message: "Healthy!"})
+	if err != nil {
+		s.log.Errorf("error: %v", err)
+	}
+}
+
+// parseUsersQuery reads the listing filters and page from the query parameters of GET /users
+func parseUsersQuery(query url.Values) (model.UserFilter, model.Page, error) {
+	filter := model.UserFilter{
+		UsernamePrefix: query.Get("username_prefix"),
+	}
+	page, err := parsePage(query)
+	if err != nil {
+		return filter, page, err
+	}
+
+	if v := query.Get("admin"); v != "" {
+		admin, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, page, model.InvalidArgumentError("invalid admin query parameter")
+		}
+		filter.Admin = &admin
+	}
+	if v := query.Get("created_after"); v != "" {
+		createdAfter, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return filter, page, model.InvalidArgumentError("invalid created_after query parameter")
+		}
+		filter.CreatedAfter = createdAfter
+	}
+	if v := query.Get("created_before"); v != "" {
+		createdBefore, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return filter, page, model.InvalidArgumentError("invalid created_before query parameter")
+		}
+		filter.CreatedBefore = createdBefore
+	}
+
+	return filter, page, nil
+}
+
+// parsePage reads the page_size and page_token query parameters of a listing
+func parsePage(query url.Values) (model.Page, error) {
+	page := model.Page{
+		Token: query.Get("page_token"),
+	}
+
+	if v := query.Get("page_size"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil || size < 0 {
+			return page, model.InvalidArgumentError("invalid page_size query parameter")
+		}
+		page.Size = size
+	}
+
+	return page, nil
+}
+
+// CreateOrganization creates an organization the caller becomes the first member and admin of
+func (s *httpServer) CreateOrganization() http.HandlerFunc {
+	type request struct {
+		Slug string `json:"slug"`
+		Name string `json:"name"`
+	}
+	return func(rw http.ResponseWriter, r *http.Request) {
+		s.log.Info("[HTTP SERVER]: Executing CreateOrganization Handler")
+
+		identity, ok := auth.IdentityFromContext(r.Context())
+		if !ok {
+			s.writeError(rw, r, model.UnauthenticatedError("authentication required"))
+			return
+		}
+
+		var req request
+
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+			s.writeError(rw, r, model.InvalidArgumentError("invalid request body"))
+			return
+		}
+		defer r.Body.Close()
+
+		created, err := s.organizations.Create(r.Context(), identity.UserID, req.Slug, req.Name)
+		if err != nil {
+			s.writeError(rw, r, err)
+			return
+		}
+
+		err = model.ToJson(rw, http.StatusCreated, newOrganization(created))
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+		}
+	}
+}
+
+// GetUserOrganizations lists the organizations the user belongs to
+func (s *httpServer) GetUserOrganizations(rw http.ResponseWriter, r *http.Request) {
+	s.log.Info("[HTTP SERVER]: Executing GetUserOrganizations Handler")
+	userId := strings.TrimSpace(mux.Vars(r)["id"])
+
+	id, err := strconv.Atoi(userId)
+	if err != nil {
+		s.log.Errorf("error: %v", err.Error())
+		s.writeError(rw, r, model.InvalidArgumentError("invalid query parameter"))
+		return
+	}
+
+	organizations, err := s.organizations.UserOrganizations(r.Context(), int64(id))
+	if err != nil {
+		s.writeError(rw, r, err)
+		return
+	}
+
+	err = model.ToJson(rw, http.StatusOK, newOrganizationsResponse(organizations))
+	if err != nil {
+		s.log.Errorf("error: %v", err)
+	}
+}
+
+// JoinOrganization accepts an invitation sent to the caller, a member of another organization, and joins its organization
+func (s *httpServer) JoinOrganization() http.HandlerFunc {
+	type request struct {
+		Token string `json:"token"`
+	}
+	return func(rw http.ResponseWriter, r *http.Request) {
+		s.log.Info("[HTTP SERVER]: Executing JoinOrganization Handler")
+		userId := strings.TrimSpace(mux.Vars(r)["id"])
+
+		id, err := strconv.Atoi(userId)
+		if err != nil {
+			s.log.Errorf("error: %v", err.Error())
+			s.writeError(rw, r, model.InvalidArgumentError("invalid query parameter"))
+			return
+		}
+
+		var req request
+
+		err = json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+			s.writeError(rw, r, model.InvalidArgumentError("invalid request body"))
+			return
+		}
+		defer r.Body.Close()
+
+		organization, err := s.invitations.Join(r.Context(), req.Token, int64(id))
+		if err != nil {
+			s.writeError(rw, r, err)
+			return
+		}
+
+		err = model.ToJson(rw, http.StatusOK, newOrganization(organization))
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+		}
+	}
+}
+
+func (s *httpServer) RemoveOrganizationMember() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		s.log.Info("[HTTP SERVER]: Executing RemoveOrganizationMember Handler")
+		userId := strings.TrimSpace(mux.Vars(r)["id"])
+
+		id, err := strconv.Atoi(userId)
+		if err != nil {
+			s.log.Errorf("error: %v", err.Error())
+			s.writeError(rw, r, model.InvalidArgumentError("invalid query parameter"))
+			return
+		}
+
+		err = s.organizations.RemoveMember(r.Context(), int64(id))
+		if err != nil {
+			s.writeError(rw, r, err)
+			return
+		}
+
+		err = model.ToJson(rw, http.StatusOK, messageResponse{Message: "User successfully removed from organization"})
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+		}
+	}
+}
+
+func (s *httpServer) GetGroups(rw http.ResponseWriter, r *http.Request) {
+	s.log.Info("[HTTP SERVER]: Executing GetGroups Handler")
+
+	page, err := parsePage(r.URL.Query())
+	if err != nil {
+		s.writeError(rw, r, err)
+		return
+	}
+
+	groups, err := s.groups.Groups(r.Context(), page)
+	if err != nil {
+		s.writeError(rw, r, err)
+		return
+	}
+
+	err = model.ToJson(rw, http.StatusOK, newGroupsPage(groups))
+	if err != nil {
+		s.log.Errorf("error: %v", err)
+	}
+}
+
+func (s *httpServer) GetGroup(rw http.ResponseWriter, r *http.Request) {
+	s.log.Info("[HTTP SERVER]: Executing GetGroup Handler")
+	groupId := strings.TrimSpace(mux.Vars(r)["id"])
+
+	id, err := strconv.Atoi(groupId)
+	if err != nil {
+		s.log.Errorf("error: %v", err.Error())
+		s.writeError(rw, r, model.InvalidArgumentError("invalid query parameter"))
+		return
+	}
+
+	group, err := s.groups.Group(r.Context(), int64(id))
+	if err != nil {
+		s.writeError(rw, r, err)
+		return
+	}
+
+	err = model.ToJson(rw, http.StatusOK, newGroup(group))
+	if err != nil {
+		s.log.Errorf("error: %v", err)
+	}
+}
+
+// CreateGroup creates a group owned by the user in owner_id, or by the caller when it is left out
+func (s *httpServer) CreateGroup() http.HandlerFunc {
+	type request struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		OwnerID     int64  `json:"owner_id"`
+	}
+	return func(rw http.ResponseWriter, r *http.Request) {
+		s.log.Info("[HTTP SERVER]: Executing CreateGroup Handler")
+
+		identity, ok := auth.IdentityFromContext(r.Context())
+		if !ok {
+			s.writeError(rw, r, model.UnauthenticatedError("authentication required"))
+			return
+		}
+
+		var req request
+
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+			s.writeError(rw, r, model.InvalidArgumentError("invalid request body"))
+			return
+		}
+		defer r.Body.Close()
+
+		if req.OwnerID == 0 {
+			req.OwnerID = identity.UserID
+		}
+
+		// The owner manages the group, so only callers who may manage any group can hand one to someone else
+		if req.OwnerID != identity.UserID {
+			if err := s.authorizer.Authorize(r.Context(), auth.ActionAssignGroupOwner, req.OwnerID); err != nil {
+				s.writeError(rw, r, err)
+				return
+			}
+		}
+
+		created, err := s.groups.Create(r.Context(), req.OwnerID, req.Name, req.Description)
+		if err != nil {
+			s.writeError(rw, r, err)
+			return
+		}
+
+		err = model.ToJson(rw, http.StatusCreated, newGroup(created))
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+		}
+	}
+}
+
+func (s *httpServer) DeleteGroup(rw http.ResponseWriter, r *http.Request) {
+	s.log.Info("[HTTP SERVER]: Executing DeleteGroup Handler")
+	groupId := strings.TrimSpace(mux.Vars(r)["id"])
+
+	id, err := strconv.Atoi(groupId)
+	if err != nil {
+		s.log.Errorf("error: %v", err.Error())
+		s.writeError(rw, r, model.InvalidArgumentError("invalid query parameter"))
+		return
+	}
+
+	err = s.groups.Delete(r.Context(), int64(id))
+	if err != nil {
+		s.writeError(rw, r, err)
+		return
+	}
+	err = model.ToJson(rw, http.StatusOK, messageResponse{Message: "Group successfully deleted"})
+	if err != nil {
+		s.log.Errorf("error: %v", err)
+	}
+}
+
+func (s *httpServer) AddGroupMember() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		s.log.Info("[HTTP SERVER]: Executing AddGroupMember Handler")
+		s.changeGroupMember(rw, r, s.groups.AddMember, "User successfully added to group")
+	}
+}
+
+func (s *httpServer) RemoveGroupMember() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		s.log.Info("[HTTP SERVER]: Executing RemoveGroupMember Handler")
+		s.changeGroupMember(rw, r, s.groups.RemoveMember, "User successfully removed from group")
+	}
+}
+
+func (s *httpServer) changeGroupMember(rw http.ResponseWriter, r *http.Request, change func(ctx context.Context, groupID, userID int64) error, message string) {
+	vars := mux.Vars(r)
+
+	groupID, err := strconv.Atoi(strings.TrimSpace(vars["id"]))
+	if err != nil {
+		s.log.Errorf("error: %v", err.Error())
+		s.writeError(rw, r, model.InvalidArgumentError("invalid query parameter"))
+		return
+	}
+	userID, err := strconv.Atoi(strings.TrimSpace(vars["user_id"]))
+	if err != nil {
+		s.log.Errorf("error: %v", err.Error())
+		s.writeError(rw, r, model.InvalidArgumentError("invalid query parameter"))
+		return
+	}
+
+	err = change(r.Context(), int64(groupID), int64(userID))
+	if err != nil {
+		s.writeError(rw, r, err)
+		return
+	}
+
+	err = model.ToJson(rw, http.StatusOK, messageResponse{Message: message})
+	if err != nil {
+		s.log.Errorf("error: %v", err)
+	}
+}
+
+func (s *httpServer) GetGroupMembers(rw http.ResponseWriter, r *http.Request) {
+	s.log.Info("[HTTP SERVER]: Executing GetGroupMembers Handler")
+	groupId := strings.TrimSpace(mux.Vars(r)["id"])
+
+	id, err := strconv.Atoi(groupId)
+	if err != nil {
+		s.log.Errorf("error: %v", err.Error())
+		s.writeError(rw, r, model.InvalidArgumentError("invalid query parameter"))
+		return
+	}
+
+	page, err := parsePage(r.URL.Query())
+	if err != nil {
+		s.writeError(rw, r, err)
+		return
+	}
+
+	members, err := s.groups.Members(r.Context(), int64(id), page)
+	if err != nil {
+		s.writeError(rw, r, err)
+		return
+	}
+
+	err = model.ToJson(rw, http.StatusOK, newUsersPage(members))
+	if err != nil {
+		s.log.Errorf("error: %v", err)
+	}
+}
+
+func (s *httpServer) GetUserGroups(rw http.ResponseWriter, r *http.Request) {
+	s.log.Info("[HTTP SERVER]: Executing GetUserGroups Handler")
+	userId := strings.TrimSpace(mux.Vars(r)["id"])
+
+	id, err := strconv.Atoi(userId)
+	if err != nil {
+		s.log.Errorf("error: %v", err.Error())
+		s.writeError(rw, r, model.InvalidArgumentError("invalid query parameter"))
+		return
+	}
+
+	page, err := parsePage(r.URL.Query())
+	if err != nil {
+		s.writeError(rw, r, err)
+		return
+	}
+
+	groups, err := s.groups.UserGroups(r.Context(), int64(id), page)
+	if err != nil {
+		s.writeError(rw, r, err)
+		return
+	}
+
+	err = model.ToJson(rw, http.StatusOK, newGroupsPage(groups))
+	if err != nil {
+		s.log.Errorf("error: %v", err)
+	}
+}
+
+// CreateInvitation invites the email in the body to register in the organization of the request, inviting with a
+// role also needs the permission to manage roles since the invitee is granted it
+func (s *httpServer) CreateInvitation() http.HandlerFunc {
+	type request struct {
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}
+	return func(rw http.ResponseWriter, r *http.Request) {
+		s.log.Info("[HTTP SERVER]: Executing CreateInvitation Handler")
+
+		identity, ok := auth.IdentityFromContext(r.Context())
+		if !ok {
+			s.writeError(rw, r, model.UnauthenticatedError("authentication required"))
+			return
+		}
+
+		var req request
+
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+			s.writeError(rw, r, model.InvalidArgumentError("invalid request body"))
+			return
+		}
+		defer r.Body.Close()
+
+		if strings.TrimSpace(req.Role) != "" {
+			if err := s.authorizer.Authorize(r.Context(), auth.ActionManageRoles, 0); err != nil {
+				s.writeError(rw, r, err)
+				return
+			}
+		}
+
+		created, err := s.invitations.Invite(r.Context(), identity.UserID, req.Email, req.Role)
+		if err != nil {
+			s.writeError(rw, r, err)
+			return
+		}
+
+		err = model.ToJson(rw, http.StatusCreated, newInvitation(created))
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+		}
+	}
+}
+
+// AcceptInvitation registers the invitee, it is the only way to register when open registration is disabled
+func (s *httpServer) AcceptInvitation() http.HandlerFunc {
+	type request struct {
+		Token    string `json:"token"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	return func(rw http.ResponseWriter, r *http.Request) {
+		s.log.Info("[HTTP SERVER]: Executing AcceptInvitation Handler")
+		var req request
+
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+			s.writeError(rw, r, model.InvalidArgumentError("invalid request body"))
+			return
+		}
+		defer r.Body.Close()
+
+		_, err = s.invitations.Accept(r.Context(), req.Token, req.Username, req.Password)
+		if err != nil {
+			s.writeError(rw, r, err)
+			return
+		}
+
+		err = model.ToJson(rw, http.StatusCreated, messageResponse{Message: "User successfully created"})
+		if err != nil {
+			s.log.Errorf("error: %v", err)
+		}
+	}
 }
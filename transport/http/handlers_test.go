@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
@@ -13,9 +14,12 @@ import (
 	"testing"
 	"time"
 
+	"github.com/JamieBShaw/user-service/auth"
 	"github.com/JamieBShaw/user-service/protob"
 	"github.com/gorilla/mux"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/JamieBShaw/user-service/domain/model"
 	"github.com/JamieBShaw/user-service/repository"
@@ -26,7 +30,39 @@ type mockUserService struct {
 	db repository.Repository
 }
 
-type mockAuthClient struct{}
+// mockAuthClient fails every rpc with err when it is set
+type mockAuthClient struct {
+	err error
+}
+
+type mockTokenParser struct{}
+
+// mockPasswordResetService accepts "valid-token" for David (id 2) and fails to send reset tokens to "broken"
+type mockPasswordResetService struct{}
+
+// mockEmailVerificationService accepts "valid-token" and fails to resend verifications to broken@example.com
+type mockEmailVerificationService struct{}
+
+// mockMFAService challenges Michael (id 3) and makes jimmy (id 4) enroll, it accepts the code "123456"
+type mockMFAService struct{}
+
+// mockRoleService grants every permission to James (id 1), who holds the admin role, users:invite to David (id 2),
+// groups:create to Michael (id 3) and none to anyone else
+type mockRoleService struct{}
+
+// mockOrganizationService knows the default organization and acme (id 2), which only James (id 1) belongs to
+type mockOrganizationService struct{}
+
+// mockGroupService knows Engineering (id 10), owned by David (id 2) with David and Michael (id 3) as members, and
+// Support (id 11), owned by James (id 1) without members
+type mockGroupService struct{}
+
+// mockInvitationService accepts "valid-token", it refuses to invite emails of registered users like
+// david@example.com and knows the roles admin and support
+type mockInvitationService struct{}
+
+// mockAuthorizer lets every caller perform every action except changing the admin flag of user 2
+type mockAuthorizer struct{}
 
 func TestHttpServer_GetUsers_Valid_Response(t *testing.T) {
 	serverMock := httpServer{
@@ -43,10 +79,68 @@ func TestHttpServer_GetUsers_Valid_Response(t *testing.T) {
 
 	handler.ServeHTTP(rec, req)
 	res := rec.Result()
-	users := marshalResponse(res)
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("could not read response: %v", err)
+	}
+	page := marshalResponse(b)
 
 	assert.Equal(t, http.StatusOK, res.StatusCode)
-	assert.IsType(t, []*model.User{}, users)
+	assert.Len(t, page.Users, len(generateUsers()))
+	assert.NotContains(t, string(b), "password")
+}
+
+func TestHttpServer_GetUsers_Invalid_Query_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name   string
+		query  string
+		errMsg string
+	}{
+		{
+			name:   "invalid page size",
+			query:  "page_size=ten",
+			errMsg: "invalid page_size query parameter",
+		},
+		{
+			name:   "invalid admin flag",
+			query:  "admin=maybe",
+			errMsg: "invalid admin query parameter",
+		},
+		{
+			name:   "invalid created after",
+			query:  "created_after=yesterday",
+			errMsg: "invalid created_after query parameter",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			serverMock := httpServer{
+				service: mockUserService{},
+				log:     l,
+			}
+			req, err := http.NewRequest("GET", "localhost:50051/users?"+tc.query, nil)
+			if err != nil {
+				t.Fatalf("could not create mock request: %v", err)
+			}
+			rec := httptest.NewRecorder()
+
+			handler := http.HandlerFunc(serverMock.GetUsers)
+			handler.ServeHTTP(rec, req)
+
+			res := rec.Result()
+			defer res.Body.Close()
+
+			b, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatalf("could not read response: %v", err)
+			}
+			assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+			assert.Equal(t, tc.errMsg, problemDetail(t, b))
+		})
+	}
 }
 
 func TestHttpServer_GetById_Test_Cases(t *testing.T) {
@@ -61,7 +155,7 @@ func TestHttpServer_GetById_Test_Cases(t *testing.T) {
 			name:        "valid user response",
 			status:      200,
 			errMsg:      "",
-			expectedRes: "{\"id\":1,\"username\":\"James\",\"password\":\"password\",\"admin\":false}",
+			expectedRes: "{\"id\":1,\"username\":\"James\",\"email\":\"james@example.com\",\"email_verified\":false,\"admin\":false,\"roles\":[],\"status\":\"active\",\"created_at\":\"0001-01-01T00:00:00Z\",\"updated_at\":\"0001-01-01T00:00:00Z\"}",
 			userId:      "1",
 		},
 		{
@@ -73,14 +167,14 @@ func TestHttpServer_GetById_Test_Cases(t *testing.T) {
 		},
 		{
 			name:        "invalid url parameter, invalid id given",
-			status:      500,
+			status:      400,
 			expectedRes: "",
 			errMsg:      "invalid query parameter",
 			userId:      "foxtrot",
 		},
 		{
 			name:        "invalid url parameter, no id given",
-			status:      500,
+			status:      400,
 			expectedRes: "",
 			errMsg:      "id not given",
 			userId:      "",
@@ -121,7 +215,7 @@ func TestHttpServer_GetById_Test_Cases(t *testing.T) {
 				return
 			}
 			assert.Equal(t, tc.status, res.StatusCode)
-			assert.Equal(t, tc.errMsg, string(bytes.TrimSpace(b)))
+			assert.Equal(t, tc.errMsg, problemDetail(t, b))
 		})
 	}
 }
@@ -138,20 +232,20 @@ func TestHttpServer_Create(t *testing.T) {
 			name:        "valid user created",
 			username:    "David",
 			status:      201,
-			expectedRes: "User successfully created",
+			expectedRes: "{\"message\":\"User successfully created\"}",
 			errMsg:      "",
 		},
 		{
 			name:        "invalid request",
 			username:    "1234",
-			status:      500,
+			status:      400,
 			expectedRes: "",
 			errMsg:      "user not created",
 		},
 		{
 			name:        "invalid request, username too long",
 			username:    "longusernameover10",
-			status:      500,
+			status:      400,
 			expectedRes: "",
 			errMsg:      "user not created",
 		},
@@ -162,8 +256,9 @@ func TestHttpServer_Create(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
 			serverMock := httpServer{
-				service: mockUserService{},
-				log:     l,
+				service:       mockUserService{},
+				verifications: mockEmailVerificationService{},
+				log:           l,
 			}
 			body := strings.NewReader("{\n\"username\": \"" + tc.username + "\"\n}")
 
@@ -188,11 +283,97 @@ func TestHttpServer_Create(t *testing.T) {
 
 			if tc.errMsg == "" {
 				// Good Path
-				assert.Equal(t, tc.expectedRes, string(b))
+				assert.Equal(t, tc.expectedRes, string(bytes.TrimSpace(b)))
+				assert.Equal(t, tc.status, res.StatusCode)
+				return
+			}
+			assert.Equal(t, tc.errMsg, problemDetail(t, b))
+			assert.Equal(t, tc.status, res.StatusCode)
+		})
+	}
+}
+
+func TestHttpServer_Update_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name   string
+		id     string
+		body   string
+		res    string
+		errMsg string
+		status int
+	}{
+		{
+			name:   "User successfully updated",
+			id:     "1",
+			body:   "{\"username\": \"Dave\", \"admin\": true}",
+			res:    "{\"id\":1,\"username\":\"Dave\",\"email\":\"james@example.com\",\"email_verified\":false,\"admin\":true,\"roles\":[\"admin\"],\"status\":\"active\",\"created_at\":\"0001-01-01T00:00:00Z\",\"updated_at\":\"0001-01-01T00:00:00Z\"}",
+			errMsg: "",
+			status: 200,
+		},
+		{
+			name:   "Invalid request, only admins can change admin",
+			id:     "2",
+			body:   "{\"admin\": true}",
+			res:    "",
+			errMsg: "permission users:change_admin required",
+			status: 403,
+		},
+		{
+			name:   "Invalid request, id not convertible to int",
+			id:     "notAnInt",
+			body:   "{\"admin\": true}",
+			res:    "",
+			errMsg: "invalid query parameter",
+			status: 400,
+		},
+		{
+			name:   "Invalid request, user does not exist",
+			id:     "42",
+			body:   "{\"admin\": true}",
+			res:    "",
+			errMsg: "could not find user with id",
+			status: 404,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			serverMock := httpServer{
+				service:    mockUserService{},
+				log:        l,
+				authorizer: mockAuthorizer{},
+			}
+
+			req, err := http.NewRequest("PATCH", "localhost:50051/users/", strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatalf("could not create mock request: %v", err)
+			}
+			req = mux.SetURLVars(req, map[string]string{
+				"id": tc.id,
+			})
+
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+
+			handler := serverMock.Update()
+			handler.ServeHTTP(rec, req)
+
+			res := rec.Result()
+
+			b, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatalf("could not read response: %v", err)
+			}
+
+			if tc.errMsg == "" {
+				// Good Path
+				assert.Equal(t, tc.res, string(bytes.TrimSpace(b)))
 				assert.Equal(t, tc.status, res.StatusCode)
 				return
 			}
-			assert.Equal(t, tc.errMsg, string(bytes.TrimSpace(b)))
+			assert.Equal(t, tc.errMsg, problemDetail(t, b))
 			assert.Equal(t, tc.status, res.StatusCode)
 		})
 	}
@@ -209,7 +390,7 @@ func TestHttpServer_Delete_Test_Cases(t *testing.T) {
 		{
 			name:   "User successfully deleted",
 			id:     "1",
-			res:    "User successfully deleted",
+			res:    "{\"message\":\"User successfully deleted\"}",
 			errMsg: "",
 			status: 200,
 		},
@@ -218,13 +399,20 @@ func TestHttpServer_Delete_Test_Cases(t *testing.T) {
 			id:     "notAnInt",
 			res:    "",
 			errMsg: "invalid query parameter",
-			status: 500,
+			status: 400,
 		},
 		{
 			name:   "Invalid request, id not allowed",
 			id:     "0",
 			res:    "",
 			errMsg: "invalid id",
+			status: 400,
+		},
+		{
+			name:   "Invalid request, user does not exist",
+			id:     "42",
+			res:    "",
+			errMsg: "user does not exist",
 			status: 404,
 		},
 	}
@@ -265,7 +453,7 @@ func TestHttpServer_Delete_Test_Cases(t *testing.T) {
 				assert.Equal(t, tc.status, res.StatusCode)
 				return
 			}
-			assert.Equal(t, tc.errMsg, string(bytes.TrimSpace(b)))
+			assert.Equal(t, tc.errMsg, problemDetail(t, b))
 			assert.Equal(t, tc.status, res.StatusCode)
 		})
 	}
@@ -273,10 +461,7 @@ func TestHttpServer_Delete_Test_Cases(t *testing.T) {
 
 func TestHttpServer_Healthz(t *testing.T) {
 	server := httpServer{
-		nil,
-		nil,
-		l,
-		nil,
+		log: l,
 	}
 	req, err := http.NewRequest("GET", "localhost:50051/ping", nil)
 	if err != nil {
@@ -294,197 +479,2696 @@ func TestHttpServer_Healthz(t *testing.T) {
 		t.Fatalf("could not read respinse: %v", err)
 	}
 
-	assert.Equal(t, "Healthy!", string(b))
+	assert.Equal(t, "{\"message\":\"Healthy!\"}", string(bytes.TrimSpace(b)))
 	assert.Equal(t, http.StatusOK, res.StatusCode)
 }
 
-//
-//func TestHttpServer_Login(t *testing.T) {
-//	tt := []struct {
-//		name string
-//		username string
-//		password string
-//		errMsg string
-//		status int
-//		response string
-//	}{
-//		{
-//			name: "User successfuly recieved",
-//			username: "James",
-//			password: "password",
-//			errMsg: "",
-//			status: 200,
-//			response: "{\"access_token\":\"3214343254\",\"refresh_token\":\"5435436265\"}",
-//		},
-//		{
-//			name: "User not found",
-//			username: "",
-//			password: "password",
-//			errMsg: "user not found with username",
-//			status: 500,
-//			response: "",
-//		},
-//		{
-//			name: "invalid username",
-//			username: "ia1",
-//			password: "password",
-//			errMsg: "invalid username",
-//			status: 500,
-//			response: "",
-//		},
-//	}
-//	for _, tc := range tt {
-//		tc := tc
-//
-//		type UserLoginRequest struct {
-//			Username string `json:"username"`
-//			Password string `json:"password"`
-//		}
-//
-//		t.Run(tc.name, func(t *testing.T) {
-//			t.Parallel()
-//			serverMock := httpServer{
-//				service: mockUserService{},
-//				log:     l,
-//				authServiceClient: mockAuthClient{},
-//			}
-//
-//			loginReq := &UserLoginRequest{
-//				Username: tc.username,
-//				Password: tc.password,
-//			}
-//
-//			reqByte, _ := json.Marshal(loginReq)
-//
-//			body := bytes.NewReader(reqByte)
-//
-//			req, err := http.NewRequest("POST", "localhost:50051/login", body)
-//			if err != nil {
-//				t.Fatalf("could not create mock request: %v", err)
-//			}
-//
-//			req.Header.Set("Content-Type", "application/json")
-//			rec := httptest.NewRecorder()
-//
-//			handler := serverMock.Login()
-//			handler.ServeHTTP(rec, req)
-//
-//			res := rec.Result()
-//
-//			b, err := ioutil.ReadAll(res.Body)
-//			if err != nil {
-//				t.Fatalf("could not read response: %v", err)
-//			}
-//
-//			if tc.errMsg == "" {
-//				// Good Path
-//				assert.Equal(t, tc.response, string(bytes.TrimSpace(b)))
-//				assert.Equal(t, tc.status, res.StatusCode)
-//				return
-//			}
-//			assert.Equal(t, tc.errMsg, string(b))
-//			assert.Equal(t, tc.status, res.StatusCode)
-//		})
-//	}
-//
-//}
-
-func (m mockUserService) GetByID(_ context.Context, id int64) (*model.User, error) {
-	users := generateUsers()
-
-	for _, user := range users {
-		if id == user.ID {
-			return user, nil
-		}
+func TestHttpServer_WriteError_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name   string
+		err    error
+		status int
+		res    problem
+	}{
+		{
+			name:   "already exists",
+			err:    model.AlreadyExistsError("username already exists"),
+			status: http.StatusConflict,
+			res: problem{
+				Type:      "/problems/already_exists",
+				Title:     "Conflict",
+				Status:    http.StatusConflict,
+				Detail:    "username already exists",
+				Code:      model.CodeAlreadyExists,
+				RequestID: "request-1",
+			},
+		},
+		{
+			name:   "unauthenticated",
+			err:    model.UnauthenticatedError("user password incorrect"),
+			status: http.StatusUnauthorized,
+			res: problem{
+				Type:      "/problems/unauthenticated",
+				Title:     "Unauthorized",
+				Status:    http.StatusUnauthorized,
+				Detail:    "user password incorrect",
+				Code:      model.CodeUnauthenticated,
+				RequestID: "request-1",
+			},
+		},
+		{
+			name: "validation error lists invalid fields",
+			err: model.ValidationError(
+				model.FieldError{Field: "username", Message: "username invalid"},
+				model.FieldError{Field: "password", Message: "password must be at least 8 characters", Code: "password_too_short"},
+			),
+			status: http.StatusBadRequest,
+			res: problem{
+				Type:      "/problems/invalid_argument",
+				Title:     "Bad Request",
+				Status:    http.StatusBadRequest,
+				Detail:    "username invalid, password must be at least 8 characters",
+				Code:      model.CodeInvalidArgument,
+				RequestID: "request-1",
+				Errors: []model.FieldError{
+					{Field: "username", Message: "username invalid"},
+					{Field: "password", Message: "password must be at least 8 characters", Code: "password_too_short"},
+				},
+			},
+		},
+		{
+			name:   "dependency timed out",
+			err:    model.TimeoutError("auth service timed out"),
+			status: http.StatusGatewayTimeout,
+			res: problem{
+				Type:      "/problems/timeout",
+				Title:     "Gateway Timeout",
+				Status:    http.StatusGatewayTimeout,
+				Detail:    "auth service timed out",
+				Code:      model.CodeTimeout,
+				RequestID: "request-1",
+			},
+		},
+		{
+			name:   "dependency failed",
+			err:    model.BadGatewayError("auth service error"),
+			status: http.StatusBadGateway,
+			res: problem{
+				Type:      "/problems/bad_gateway",
+				Title:     "Bad Gateway",
+				Status:    http.StatusBadGateway,
+				Detail:    "auth service error",
+				Code:      model.CodeBadGateway,
+				RequestID: "request-1",
+			},
+		},
+		{
+			name:   "too many requests",
+			err:    model.TooManyRequestsError("too many failed login attempts, try again later"),
+			status: http.StatusTooManyRequests,
+			res: problem{
+				Type:      "/problems/too_many_requests",
+				Title:     "Too Many Requests",
+				Status:    http.StatusTooManyRequests,
+				Detail:    "too many failed login attempts, try again later",
+				Code:      model.CodeTooManyRequests,
+				RequestID: "request-1",
+			},
+		},
+		{
+			name:   "internal error message is hidden",
+			err:    errors.New("pq: connection refused"),
+			status: http.StatusInternalServerError,
+			res: problem{
+				Type:      "/problems/internal",
+				Title:     "Internal Server Error",
+				Status:    http.StatusInternalServerError,
+				Detail:    "internal server error",
+				Code:      model.CodeInternal,
+				RequestID: "request-1",
+			},
+		},
 	}
-	return nil, errors.New("could not find user with id")
-}
 
-func (m mockUserService) GetUsers(_ context.Context) ([]*model.User, error) {
-	users := generateUsers()
-	return users, nil
-}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			serverMock := httpServer{log: l}
+			req, err := http.NewRequest("GET", "localhost:50051/users", nil)
+			if err != nil {
+				t.Fatalf("could not create mock request: %v", err)
+			}
+			req = req.WithContext(context.WithValue(req.Context(), requestIDKey, "request-1"))
+			rec := httptest.NewRecorder()
 
-func (m mockUserService) Create(_ context.Context, username, password string) error {
-	users := generateUsers()
+			serverMock.writeError(rec, req, tc.err)
 
-	for _, user := range users {
-		if username == user.Username {
-			return nil
-		}
+			res := rec.Result()
+			var body problem
+			if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+				t.Fatalf("could not read response: %v", err)
+			}
+			assert.Equal(t, tc.status, res.StatusCode)
+			assert.Equal(t, "application/problem+json", res.Header.Get("Content-Type"))
+			assert.Equal(t, tc.res, body)
+		})
 	}
-	return errors.New("user not created")
 }
 
-func (m mockUserService) Delete(_ context.Context, id int64) error {
-	if id <= 0 {
-		return errors.New("invalid id")
-	}
-	users := generateUsers()
-	for _, user := range users {
-		if id == user.ID {
-			return nil
-		}
+func TestHttpServer_RequestID(t *testing.T) {
+	server := NewHttpHandler(mockUserService{}, mockPasswordResetService{}, mockEmailVerificationService{}, mockMFAService{}, mockRoleService{}, mockOrganizationService{}, mockGroupService{}, mockInvitationService{}, mux.NewRouter(), mockAuthClient{}, mockTokenParser{}, mockTokenParser{})
+
+	req, err := http.NewRequest("GET", "/unknown", nil)
+	if err != nil {
+		t.Fatalf("could not create mock request: %v", err)
 	}
-	return errors.New("user does not exist")
-}
+	req.Header.Set("X-Request-ID", "request-42")
+	rec := httptest.NewRecorder()
 
-func (m mockUserService) GetByUsernameAndPassword(_ context.Context, username, password string) (*model.User, error) {
-	users := generateUsers()
+	server.ServeHTTP(rec, req)
 
-	for _, user := range users {
-		if username == user.Username {
-			log.Printf("username: %v", username)
-			return user, nil
-		}
+	res := rec.Result()
+	var body problem
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatalf("could not read response: %v", err)
 	}
-
-	return nil, errors.New("error username")
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	assert.Equal(t, "request-42", res.Header.Get("X-Request-ID"))
+	assert.Equal(t, "request-42", body.RequestID)
+	assert.Equal(t, "route not found", body.Detail)
 }
 
-func (m mockAuthClient) CreateAccessToken(ctx context.Context, in *protob.CreateAccessTokenRequest, opts ...grpc.CallOption) (*protob.CreateAccessTokenResponse, error) {
-	return &protob.CreateAccessTokenResponse{
-		AuthToken:    "3214343254",
-		RefreshToken: "5435436265",
-	}, nil
-}
+func TestHttpServer_Protected_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		errMsg string
+		status int
+	}{
+		{
+			name:   "admin lists users",
+			method: "GET",
+			path:   "/users",
+			token:  "admin-token",
+			status: 200,
+		},
+		{
+			name:   "user can not list users",
+			method: "GET",
+			path:   "/users",
+			token:  "user-token",
+			errMsg: "permission users:list required",
+			status: 403,
+		},
+		{
+			name:   "user reads themselves",
+			method: "GET",
+			path:   "/users/2",
+			token:  "user-token",
+			status: 200,
+		},
+		{
+			name:   "user can not delete another user",
+			method: "DELETE",
+			path:   "/users/1",
+			token:  "user-token",
+			errMsg: "users may only perform this action on themselves",
+			status: 403,
+		},
+		{
+			name:   "admin deletes another user",
+			method: "DELETE",
+			path:   "/users/2",
+			token:  "admin-token",
+			status: 200,
+		},
+		{
+			name:   "missing access token",
+			method: "DELETE",
+			path:   "/users/2",
+			token:  "",
+			errMsg: "missing bearer access token",
+			status: 401,
+		},
+		{
+			name:   "invalid access token",
+			method: "GET",
+			path:   "/users/2",
+			token:  "forged-token",
+			errMsg: "invalid access token",
+			status: 401,
+		},
+	}
 
-func (m mockAuthClient) DeleteAccessToken(ctx context.Context, in *protob.DeleteAccessTokenRequest, opts ...grpc.CallOption) (*protob.DeleteAccessTokenResponse, error) {
-	return &protob.DeleteAccessTokenResponse{Confirmation: "Success"}, nil
-}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := NewHttpHandler(mockAdminUserService{}, mockPasswordResetService{}, mockEmailVerificationService{}, mockMFAService{}, mockRoleService{}, mockOrganizationService{}, mockGroupService{}, mockInvitationService{}, mux.NewRouter(), mockAuthClient{}, mockTokenParser{}, mockTokenParser{})
 
-func generateUsers() []*model.User {
-	var users []*model.User
-	names := []string{"James", "David", "Michael", "jimmy", "michael", "teddy", "maclom"}
-	passwords := []string{"password", "password123", "laskdkad", "djpsafd", "jdfpsajf", "dpsa111", "dlwfops"}
+			req, err := http.NewRequest(tc.method, tc.path, nil)
+			if err != nil {
+				t.Fatalf("could not create mock request: %v", err)
+			}
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			rec := httptest.NewRecorder()
 
-	for i, name := range names {
-		for _, password := range passwords {
-			users = append(users, &model.User{
-				ID:        int64(i + 1),
-				Username:  name,
-				Admin:     false,
-				Password:  password,
-				CreatedAt: time.Time{},
-				UpdatedAt: time.Time{},
-			})
-		}
-	}
+			server.ServeHTTP(rec, req)
 
-	return users
-}
+			res := rec.Result()
+			b, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatalf("could not read response: %v", err)
+			}
 
-func marshalResponse(res *http.Response) []*model.User {
-	b, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		return nil
+			assert.Equal(t, tc.status, res.StatusCode)
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, problemDetail(t, b))
+			}
+		})
 	}
-	var users []*model.User
+}
 
-	err = json.Unmarshal(b, &users)
-	if err != nil {
-		return nil
+func TestHttpServer_Logout_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name       string
+		path       string
+		token      string
+		authClient mockAuthClient
+		response   string
+		errMsg     string
+		status     int
+	}{
+		{
+			name:       "user logged out",
+			path:       "/logout",
+			token:      "user-token",
+			authClient: mockAuthClient{},
+			response:   "{\"message\":\"User successfully logged out\"}",
+			status:     200,
+		},
+		{
+			name:       "user logged out of all sessions",
+			path:       "/logout/all",
+			token:      "user-token",
+			authClient: mockAuthClient{},
+			response:   "{\"message\":\"User successfully logged out of all sessions\"}",
+			status:     200,
+		},
+		{
+			name:       "missing access token",
+			path:       "/logout",
+			token:      "",
+			authClient: mockAuthClient{},
+			errMsg:     "missing bearer access token",
+			status:     401,
+		},
+		{
+			name:       "auth service timed out",
+			path:       "/logout",
+			token:      "user-token",
+			authClient: mockAuthClient{err: status.Error(codes.DeadlineExceeded, "deadline exceeded")},
+			errMsg:     "auth service timed out",
+			status:     504,
+		},
+		{
+			name:       "auth service unavailable",
+			path:       "/logout/all",
+			token:      "user-token",
+			authClient: mockAuthClient{err: status.Error(codes.Unavailable, "connection refused")},
+			errMsg:     "auth service unavailable",
+			status:     503,
+		},
+		{
+			name:       "session already revoked",
+			path:       "/logout",
+			token:      "user-token",
+			authClient: mockAuthClient{err: status.Error(codes.NotFound, "access token not found")},
+			errMsg:     "session not found",
+			status:     401,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := NewHttpHandler(mockUserService{}, mockPasswordResetService{}, mockEmailVerificationService{}, mockMFAService{}, mockRoleService{}, mockOrganizationService{}, mockGroupService{}, mockInvitationService{}, mux.NewRouter(), tc.authClient, mockTokenParser{}, mockTokenParser{})
+
+			req, err := http.NewRequest("POST", tc.path, nil)
+			if err != nil {
+				t.Fatalf("could not create mock request: %v", err)
+			}
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			rec := httptest.NewRecorder()
+
+			server.ServeHTTP(rec, req)
+
+			res := rec.Result()
+			b, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatalf("could not read response: %v", err)
+			}
+
+			assert.Equal(t, tc.status, res.StatusCode)
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, problemDetail(t, b))
+				return
+			}
+			assert.Equal(t, tc.response, string(bytes.TrimSpace(b)))
+		})
+	}
+}
+
+func TestHttpServer_Login_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name       string
+		username   string
+		password   string
+		authClient mockAuthClient
+		response   string
+		errMsg     string
+		status     int
+	}{
+		{
+			name:       "user logged in",
+			username:   "James",
+			password:   "password",
+			authClient: mockAuthClient{},
+			response:   "{\"access_token\":\"3214343254\",\"refresh_token\":\"5435436265\",\"token_type\":\"Bearer\",\"expires_in\":900}",
+			status:     200,
+		},
+		{
+			name:       "user logged in after a password reset",
+			username:   "teddy",
+			password:   "dpsa111",
+			authClient: mockAuthClient{},
+			response:   "{\"access_token\":\"3214343254\",\"refresh_token\":\"5435436265\",\"token_type\":\"Bearer\",\"expires_in\":900,\"password_change_required\":true}",
+			status:     200,
+		},
+		{
+			name:       "user with mfa is challenged",
+			username:   "Michael",
+			password:   "laskdkad",
+			authClient: mockAuthClient{},
+			response:   "{\"mfa_required\":true,\"mfa_token\":\"mfa-token\"}",
+			status:     200,
+		},
+		{
+			name:       "user without required mfa is made to enroll",
+			username:   "jimmy",
+			password:   "djpsafd",
+			authClient: mockAuthClient{},
+			response:   "{\"mfa_required\":true,\"mfa_token\":\"enroll-token\",\"mfa_enrollment_required\":true}",
+			status:     200,
+		},
+		{
+			name:       "missing username",
+			username:   "",
+			password:   "password",
+			authClient: mockAuthClient{},
+			errMsg:     "username is required",
+			status:     400,
+		},
+		{
+			name:       "user not found",
+			username:   "ia1",
+			password:   "password",
+			authClient: mockAuthClient{},
+			errMsg:     "error username",
+			status:     401,
+		},
+		{
+			name:       "auth service unavailable",
+			username:   "James",
+			password:   "password",
+			authClient: mockAuthClient{err: status.Error(codes.Unavailable, "connection refused")},
+			errMsg:     "auth service unavailable",
+			status:     503,
+		},
+		{
+			name:       "auth service timed out",
+			username:   "James",
+			password:   "password",
+			authClient: mockAuthClient{err: status.Error(codes.DeadlineExceeded, "deadline exceeded")},
+			errMsg:     "auth service timed out",
+			status:     504,
+		},
+		{
+			name:       "auth service failed",
+			username:   "James",
+			password:   "password",
+			authClient: mockAuthClient{err: status.Error(codes.Internal, "redis: connection refused")},
+			errMsg:     "auth service error",
+			status:     502,
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+
+		type UserLoginRequest struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+		}
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			serverMock := httpServer{
+				service:           mockUserService{},
+				mfa:               mockMFAService{},
+				log:               l,
+				authServiceClient: tc.authClient,
+			}
+
+			loginReq := &UserLoginRequest{
+				Username: tc.username,
+				Password: tc.password,
+			}
+
+			reqByte, _ := json.Marshal(loginReq)
+
+			body := bytes.NewReader(reqByte)
+
+			req, err := http.NewRequest("POST", "localhost:50051/login", body)
+			if err != nil {
+				t.Fatalf("could not create mock request: %v", err)
+			}
+
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+
+			handler := serverMock.Login()
+			handler.ServeHTTP(rec, req)
+
+			res := rec.Result()
+
+			b, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatalf("could not read response: %v", err)
+			}
+
+			assert.Equal(t, tc.status, res.StatusCode)
+			if tc.errMsg == "" {
+				// Good Path
+				assert.Equal(t, tc.response, string(bytes.TrimSpace(b)))
+				return
+			}
+			assert.Equal(t, tc.errMsg, problemDetail(t, b))
+		})
+	}
+
+}
+
+func TestHttpServer_RefreshToken_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name       string
+		body       string
+		authClient mockAuthClient
+		response   string
+		errMsg     string
+		status     int
+	}{
+		{
+			name:       "tokens refreshed",
+			body:       `{"refresh_token":"refresh-token"}`,
+			authClient: mockAuthClient{},
+			response:   "{\"access_token\":\"7685645342\",\"refresh_token\":\"8796756453\",\"token_type\":\"Bearer\",\"expires_in\":900}",
+			status:     200,
+		},
+		{
+			name:       "missing refresh token",
+			body:       `{}`,
+			authClient: mockAuthClient{},
+			errMsg:     "refresh_token is required",
+			status:     400,
+		},
+		{
+			name:       "invalid refresh token",
+			body:       `{"refresh_token":"forged-token"}`,
+			authClient: mockAuthClient{},
+			errMsg:     "invalid refresh token",
+			status:     401,
+		},
+		{
+			name:       "user no longer exists",
+			body:       `{"refresh_token":"deleted-user-refresh-token"}`,
+			authClient: mockAuthClient{},
+			errMsg:     "user no longer exists",
+			status:     401,
+		},
+		{
+			name:       "suspended user can not refresh",
+			body:       `{"refresh_token":"suspended-user-refresh-token"}`,
+			authClient: mockAuthClient{},
+			errMsg:     "account is suspended",
+			status:     403,
+		},
+		{
+			name:       "refresh token already used",
+			body:       `{"refresh_token":"refresh-token"}`,
+			authClient: mockAuthClient{err: status.Error(codes.NotFound, "refresh token not found")},
+			errMsg:     "session not found",
+			status:     401,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := NewHttpHandler(mockUserService{}, mockPasswordResetService{}, mockEmailVerificationService{}, mockMFAService{}, mockRoleService{}, mockOrganizationService{}, mockGroupService{}, mockInvitationService{}, mux.NewRouter(), tc.authClient, mockTokenParser{}, mockTokenParser{})
+
+			req, err := http.NewRequest("POST", "/token/refresh", strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatalf("could not create mock request: %v", err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+
+			server.ServeHTTP(rec, req)
+
+			res := rec.Result()
+			b, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatalf("could not read response: %v", err)
+			}
+
+			assert.Equal(t, tc.status, res.StatusCode)
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, problemDetail(t, b))
+				return
+			}
+			assert.Equal(t, tc.response, string(bytes.TrimSpace(b)))
+		})
+	}
+}
+
+func TestHttpServer_Password_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name       string
+		path       string
+		token      string
+		body       string
+		authClient mockAuthClient
+		response   string
+		errMsg     string
+		status     int
+	}{
+		{
+			name:       "user changes their password",
+			path:       "/users/2/password",
+			token:      "user-token",
+			body:       `{"current_password":"password123","new_password":"new-password"}`,
+			authClient: mockAuthClient{},
+			response:   "{\"message\":\"Password successfully changed\"}",
+			status:     200,
+		},
+		{
+			name:       "current password incorrect",
+			path:       "/users/2/password",
+			token:      "user-token",
+			body:       `{"current_password":"wrong-password","new_password":"new-password"}`,
+			authClient: mockAuthClient{},
+			errMsg:     "current password incorrect",
+			status:     401,
+		},
+		{
+			name:       "admin can not change another users password",
+			path:       "/users/2/password",
+			token:      "admin-token",
+			body:       `{"current_password":"password123","new_password":"new-password"}`,
+			authClient: mockAuthClient{},
+			errMsg:     "users may only perform this action on themselves",
+			status:     403,
+		},
+		{
+			name:       "password changed although other sessions could not be revoked",
+			path:       "/users/2/password",
+			token:      "user-token",
+			body:       `{"current_password":"password123","new_password":"new-password"}`,
+			authClient: mockAuthClient{err: status.Error(codes.Unavailable, "connection refused")},
+			response:   "{\"message\":\"Password successfully changed\"}",
+			status:     200,
+		},
+		{
+			name:       "admin resets a password",
+			path:       "/users/2/password/reset",
+			token:      "admin-token",
+			body:       `{"temporary_password":"temporary"}`,
+			authClient: mockAuthClient{},
+			response:   "{\"message\":\"Password successfully reset\"}",
+			status:     200,
+		},
+		{
+			name:       "user can not reset a password",
+			path:       "/users/2/password/reset",
+			token:      "user-token",
+			body:       `{"temporary_password":"temporary"}`,
+			authClient: mockAuthClient{},
+			errMsg:     "permission users:reset_password required",
+			status:     403,
+		},
+		{
+			name:       "password reset although sessions could not be revoked",
+			path:       "/users/2/password/reset",
+			token:      "admin-token",
+			body:       `{"temporary_password":"temporary"}`,
+			authClient: mockAuthClient{err: status.Error(codes.Unavailable, "connection refused")},
+			response:   "{\"message\":\"Password successfully reset\"}",
+			status:     200,
+		},
+		{
+			name:       "reset password of user that does not exist",
+			path:       "/users/42/password/reset",
+			token:      "admin-token",
+			body:       `{"temporary_password":"temporary"}`,
+			authClient: mockAuthClient{},
+			errMsg:     "could not find user with id",
+			status:     404,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := NewHttpHandler(mockAdminUserService{}, mockPasswordResetService{}, mockEmailVerificationService{}, mockMFAService{}, mockRoleService{}, mockOrganizationService{}, mockGroupService{}, mockInvitationService{}, mux.NewRouter(), tc.authClient, mockTokenParser{}, mockTokenParser{})
+
+			req, err := http.NewRequest("POST", tc.path, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatalf("could not create mock request: %v", err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+			rec := httptest.NewRecorder()
+
+			server.ServeHTTP(rec, req)
+
+			res := rec.Result()
+			b, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatalf("could not read response: %v", err)
+			}
+
+			assert.Equal(t, tc.status, res.StatusCode)
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, problemDetail(t, b))
+				return
+			}
+			assert.Equal(t, tc.response, string(bytes.TrimSpace(b)))
+		})
+	}
+}
+
+func TestHttpServer_ChangeStatus_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name       string
+		path       string
+		token      string
+		body       string
+		authClient mockAuthClient
+		status     model.AccountStatus
+		errMsg     string
+		code       int
+	}{
+		{
+			name:       "admin suspends a user",
+			path:       "/users/2/status",
+			token:      "admin-token",
+			body:       `{"status":"suspended","reason":"chargeback"}`,
+			authClient: mockAuthClient{},
+			status:     model.StatusSuspended,
+			code:       200,
+		},
+		{
+			name:       "admin reactivates a suspended user without the auth service",
+			path:       "/users/7/status",
+			token:      "admin-token",
+			body:       `{"status":"active"}`,
+			authClient: mockAuthClient{err: status.Error(codes.Unavailable, "connection refused")},
+			status:     model.StatusActive,
+			code:       200,
+		},
+		{
+			name:       "user disabled when their sessions can not be revoked",
+			path:       "/users/2/status",
+			token:      "admin-token",
+			body:       `{"status":"disabled","reason":"spam"}`,
+			authClient: mockAuthClient{err: status.Error(codes.Unavailable, "connection refused")},
+			status:     model.StatusDisabled,
+			code:       200,
+		},
+		{
+			name:       "user can not change a status",
+			path:       "/users/2/status",
+			token:      "user-token",
+			body:       `{"status":"active"}`,
+			authClient: mockAuthClient{},
+			errMsg:     "permission users:change_status required",
+			code:       403,
+		},
+		{
+			name:       "unknown status",
+			path:       "/users/2/status",
+			token:      "admin-token",
+			body:       `{"status":"banned"}`,
+			authClient: mockAuthClient{},
+			errMsg:     "status must be one of pending, active, suspended or disabled",
+			code:       400,
+		},
+		{
+			name:       "invalid request body",
+			path:       "/users/2/status",
+			token:      "admin-token",
+			body:       `{"status":`,
+			authClient: mockAuthClient{},
+			errMsg:     "invalid request body",
+			code:       400,
+		},
+		{
+			name:       "user does not exist",
+			path:       "/users/42/status",
+			token:      "admin-token",
+			body:       `{"status":"active"}`,
+			authClient: mockAuthClient{},
+			errMsg:     "could not find user with id",
+			code:       404,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := NewHttpHandler(mockAdminUserService{}, mockPasswordResetService{}, mockEmailVerificationService{}, mockMFAService{}, mockRoleService{}, mockOrganizationService{}, mockGroupService{}, mockInvitationService{}, mux.NewRouter(), tc.authClient, mockTokenParser{}, mockTokenParser{})
+
+			req, err := http.NewRequest("PUT", tc.path, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatalf("could not create mock request: %v", err)
+			}
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+
+			server.ServeHTTP(rec, req)
+
+			res := rec.Result()
+			b, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatalf("could not read response: %v", err)
+			}
+
+			assert.Equal(t, tc.code, res.StatusCode)
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, problemDetail(t, b))
+				return
+			}
+			var changed user
+			assert.NoError(t, json.Unmarshal(b, &changed))
+			assert.Equal(t, tc.status, changed.Status)
+		})
+	}
+}
+
+func TestHttpServer_Roles_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		roles  []string
+		admin  bool
+		errMsg string
+		status int
+	}{
+		{
+			name:   "admin grants a role",
+			method: "PUT",
+			path:   "/users/2/roles/support",
+			token:  "admin-token",
+			roles:  []string{model.RoleSupport},
+			status: 200,
+		},
+		{
+			name:   "admin revokes a role",
+			method: "DELETE",
+			path:   "/users/1/roles/admin",
+			token:  "admin-token",
+			roles:  []string{},
+			status: 200,
+		},
+		{
+			name:   "granting the admin role makes an admin",
+			method: "PUT",
+			path:   "/users/2/roles/admin",
+			token:  "admin-token",
+			roles:  []string{model.RoleAdmin},
+			admin:  true,
+			status: 200,
+		},
+		{
+			name:   "user can not grant roles",
+			method: "PUT",
+			path:   "/users/2/roles/admin",
+			token:  "user-token",
+			errMsg: "permission roles:manage required",
+			status: 403,
+		},
+		{
+			name:   "unknown role",
+			method: "PUT",
+			path:   "/users/2/roles/owner",
+			token:  "admin-token",
+			errMsg: "role not found",
+			status: 404,
+		},
+		{
+			name:   "revoke role the user does not hold",
+			method: "DELETE",
+			path:   "/users/2/roles/support",
+			token:  "admin-token",
+			errMsg: "user does not have the role",
+			status: 404,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := NewHttpHandler(mockAdminUserService{}, mockPasswordResetService{}, mockEmailVerificationService{}, mockMFAService{}, mockRoleService{}, mockOrganizationService{}, mockGroupService{}, mockInvitationService{}, mux.NewRouter(), mockAuthClient{}, mockTokenParser{}, mockTokenParser{})
+
+			req, err := http.NewRequest(tc.method, tc.path, nil)
+			if err != nil {
+				t.Fatalf("could not create mock request: %v", err)
+			}
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+			rec := httptest.NewRecorder()
+
+			server.ServeHTTP(rec, req)
+
+			res := rec.Result()
+			b, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatalf("could not read response: %v", err)
+			}
+
+			assert.Equal(t, tc.status, res.StatusCode)
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, problemDetail(t, b))
+				return
+			}
+			var changed user
+			assert.NoError(t, json.Unmarshal(b, &changed))
+			assert.Equal(t, tc.roles, changed.Roles)
+			assert.Equal(t, tc.admin, changed.Admin)
+		})
+	}
+}
+
+func TestHttpServer_Organization_Header_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name         string
+		organization string
+		token        string
+		errMsg       string
+		status       int
+	}{
+		{
+			name:   "no header uses the default organization",
+			token:  "user-token",
+			status: 200,
+		},
+		{
+			name:         "member of the organization",
+			organization: "acme",
+			token:        "admin-token",
+			status:       200,
+		},
+		{
+			name:         "caller of another organization",
+			organization: "acme",
+			token:        "user-token",
+			errMsg:       "caller is not a member of this organization",
+			status:       403,
+		},
+		{
+			name:         "unknown organization",
+			organization: "globex",
+			token:        "admin-token",
+			errMsg:       "organization not found",
+			status:       404,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := NewHttpHandler(mockAdminUserService{}, mockPasswordResetService{}, mockEmailVerificationService{}, mockMFAService{}, mockRoleService{}, mockOrganizationService{}, mockGroupService{}, mockInvitationService{}, mux.NewRouter(), mockAuthClient{}, mockTokenParser{}, mockTokenParser{})
+
+			req, err := http.NewRequest("GET", "/users/2", nil)
+			if err != nil {
+				t.Fatalf("could not create mock request: %v", err)
+			}
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+			if tc.organization != "" {
+				req.Header.Set("X-Organization", tc.organization)
+			}
+			rec := httptest.NewRecorder()
+
+			server.ServeHTTP(rec, req)
+
+			res := rec.Result()
+			b, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatalf("could not read response: %v", err)
+			}
+
+			assert.Equal(t, tc.status, res.StatusCode)
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, problemDetail(t, b))
+			}
+		})
+	}
+}
+
+func TestHttpServer_CreateOrganization_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name         string
+		organization string
+		token        string
+		body         string
+		response     string
+		errMsg       string
+		status       int
+	}{
+		{
+			name:     "admin creates an organization",
+			token:    "admin-token",
+			body:     `{"slug": "globex", "name": "Globex"}`,
+			response: `{"id":3,"slug":"globex","name":"Globex"}`,
+			status:   201,
+		},
+		{
+			name:   "slug taken",
+			token:  "admin-token",
+			body:   `{"slug": "acme", "name": "Acme"}`,
+			errMsg: "organization already exists",
+			status: 409,
+		},
+		{
+			name:         "created from another organization",
+			organization: "acme",
+			token:        "admin-token",
+			body:         `{"slug": "globex", "name": "Globex"}`,
+			errMsg:       "organizations can only be created from the default organization",
+			status:       403,
+		},
+		{
+			name:   "user can not create organizations",
+			token:  "user-token",
+			body:   `{"slug": "globex", "name": "Globex"}`,
+			errMsg: "permission organizations:create required",
+			status: 403,
+		},
+		{
+			name:   "invalid body",
+			token:  "admin-token",
+			body:   `{"slug":`,
+			errMsg: "invalid request body",
+			status: 400,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := NewHttpHandler(mockAdminUserService{}, mockPasswordResetService{}, mockEmailVerificationService{}, mockMFAService{}, mockRoleService{}, mockOrganizationService{}, mockGroupService{}, mockInvitationService{}, mux.NewRouter(), mockAuthClient{}, mockTokenParser{}, mockTokenParser{})
+
+			req, err := http.NewRequest("POST", "/organizations", strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatalf("could not create mock request: %v", err)
+			}
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+			if tc.organization != "" {
+				req.Header.Set("X-Organization", tc.organization)
+			}
+			rec := httptest.NewRecorder()
+
+			server.ServeHTTP(rec, req)
+
+			res := rec.Result()
+			b, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatalf("could not read response: %v", err)
+			}
+
+			assert.Equal(t, tc.status, res.StatusCode)
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, problemDetail(t, b))
+				return
+			}
+			assert.Equal(t, tc.response, strings.TrimSpace(string(b)))
+		})
+	}
+}
+
+func TestHttpServer_GetUserOrganizations_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name     string
+		path     string
+		token    string
+		response string
+		errMsg   string
+		status   int
+	}{
+		{
+			name:     "user belongs to several organizations",
+			path:     "/users/1/organizations",
+			token:    "admin-token",
+			response: `{"organizations":[{"id":2,"slug":"acme","name":"Acme"},{"id":1,"slug":"default","name":"Default"}]}`,
+			status:   200,
+		},
+		{
+			name:     "user lists their own organizations",
+			path:     "/users/2/organizations",
+			token:    "user-token",
+			response: `{"organizations":[{"id":1,"slug":"default","name":"Default"}]}`,
+			status:   200,
+		},
+		{
+			name:   "user can not list the organizations of another user",
+			path:   "/users/1/organizations",
+			token:  "user-token",
+			errMsg: "users may only perform this action on themselves",
+			status: 403,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := NewHttpHandler(mockAdminUserService{}, mockPasswordResetService{}, mockEmailVerificationService{}, mockMFAService{}, mockRoleService{}, mockOrganizationService{}, mockGroupService{}, mockInvitationService{}, mux.NewRouter(), mockAuthClient{}, mockTokenParser{}, mockTokenParser{})
+
+			req, err := http.NewRequest("GET", tc.path, nil)
+			if err != nil {
+				t.Fatalf("could not create mock request: %v", err)
+			}
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+			rec := httptest.NewRecorder()
+
+			server.ServeHTTP(rec, req)
+
+			res := rec.Result()
+			b, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatalf("could not read response: %v", err)
+			}
+
+			assert.Equal(t, tc.status, res.StatusCode)
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, problemDetail(t, b))
+				return
+			}
+			assert.Equal(t, tc.response, strings.TrimSpace(string(b)))
+		})
+	}
+}
+
+func TestHttpServer_OrganizationMember_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name         string
+		method       string
+		path         string
+		organization string
+		token        string
+		body         string
+		response     string
+		errMsg       string
+		status       int
+	}{
+		{
+			name:     "user accepts an invitation to another organization",
+			method:   "POST",
+			path:     "/users/2/organizations",
+			token:    "user-token",
+			body:     `{"token": "valid-token"}`,
+			response: `{"id":2,"slug":"acme","name":"Acme"}`,
+			status:   200,
+		},
+		{
+			name:   "invalid invitation token",
+			method: "POST",
+			path:   "/users/2/organizations",
+			token:  "user-token",
+			body:   `{"token": "expired-token"}`,
+			errMsg: "invalid or expired invitation token",
+			status: 400,
+		},
+		{
+			name:   "admin can not accept an invitation for another user",
+			method: "POST",
+			path:   "/users/2/organizations",
+			token:  "admin-token",
+			body:   `{"token": "valid-token"}`,
+			errMsg: "users may only perform this action on themselves",
+			status: 403,
+		},
+		{
+			name:   "invalid body",
+			method: "POST",
+			path:   "/users/2/organizations",
+			token:  "user-token",
+			body:   `{"token":`,
+			errMsg: "invalid request body",
+			status: 400,
+		},
+		{
+			name:         "admin removes a member from the organization",
+			method:       "DELETE",
+			path:         "/organization/members/1",
+			organization: "acme",
+			token:        "admin-token",
+			response:     `{"message":"User successfully removed from organization"}`,
+			status:       200,
+		},
+		{
+			name:         "user is not a member",
+			method:       "DELETE",
+			path:         "/organization/members/2",
+			organization: "acme",
+			token:        "admin-token",
+			errMsg:       "user is not a member of the organization",
+			status:       404,
+		},
+		{
+			name:   "user can not leave the organization they registered in",
+			method: "DELETE",
+			path:   "/organization/members/2",
+			token:  "admin-token",
+			errMsg: "users can not be removed from the organization they registered in",
+			status: 400,
+		},
+		{
+			name:   "user can not remove members",
+			method: "DELETE",
+			path:   "/organization/members/3",
+			token:  "user-token",
+			errMsg: "permission organizations:manage_members required",
+			status: 403,
+		},
+		{
+			name:   "admins can no longer add users of other organizations",
+			method: "PUT",
+			path:   "/organization/members/2",
+			token:  "admin-token",
+			status: 405,
+		},
+		{
+			name:   "invalid id",
+			method: "DELETE",
+			path:   "/organization/members/abc",
+			token:  "admin-token",
+			errMsg: "invalid query parameter",
+			status: 400,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := NewHttpHandler(mockAdminUserService{}, mockPasswordResetService{}, mockEmailVerificationService{}, mockMFAService{}, mockRoleService{}, mockOrganizationService{}, mockGroupService{}, mockInvitationService{}, mux.NewRouter(), mockAuthClient{}, mockTokenParser{}, mockTokenParser{})
+
+			req, err := http.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatalf("could not create mock request: %v", err)
+			}
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+			if tc.organization != "" {
+				req.Header.Set("X-Organization", tc.organization)
+			}
+			rec := httptest.NewRecorder()
+
+			server.ServeHTTP(rec, req)
+
+			res := rec.Result()
+			b, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatalf("could not read response: %v", err)
+			}
+
+			assert.Equal(t, tc.status, res.StatusCode)
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, problemDetail(t, b))
+				return
+			}
+			assert.Equal(t, tc.response, strings.TrimSpace(string(b)))
+		})
+	}
+}
+
+func TestHttpServer_Groups_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name     string
+		method   string
+		path     string
+		token    string
+		body     string
+		contains string
+		errMsg   string
+		status   int
+	}{
+		{
+			name:     "admin lists groups",
+			method:   "GET",
+			path:     "/groups",
+			token:    "admin-token",
+			contains: `"name":"Engineering"`,
+			status:   200,
+		},
+		{
+			name:   "user can not list groups",
+			method: "GET",
+			path:   "/groups",
+			token:  "user-token",
+			errMsg: "permission groups:list required",
+			status: 403,
+		},
+		{
+			name:     "owner reads their group",
+			method:   "GET",
+			path:     "/groups/10",
+			token:    "user-token",
+			contains: `"owner_id":2`,
+			status:   200,
+		},
+		{
+			name:   "user can not read a group they do not own",
+			method: "GET",
+			path:   "/groups/11",
+			token:  "user-token",
+			errMsg: "only the owner of the group may perform this action",
+			status: 403,
+		},
+		{
+			name:   "unknown group",
+			method: "GET",
+			path:   "/groups/42",
+			token:  "admin-token",
+			errMsg: "group not found",
+			status: 404,
+		},
+		{
+			name:     "caller owns the group they create",
+			method:   "POST",
+			path:     "/groups",
+			token:    "admin-token",
+			body:     `{"name": "Design", "description": "Product designers"}`,
+			contains: `"owner_id":1`,
+			status:   201,
+		},
+		{
+			name:     "group created for another owner",
+			method:   "POST",
+			path:     "/groups",
+			token:    "admin-token",
+			body:     `{"name": "Design", "owner_id": 2}`,
+			contains: `"owner_id":2`,
+			status:   201,
+		},
+		{
+			name:     "creator owns the group they create",
+			method:   "POST",
+			path:     "/groups",
+			token:    "creator-token",
+			body:     `{"name": "Design", "owner_id": 3}`,
+			contains: `"owner_id":3`,
+			status:   201,
+		},
+		{
+			name:   "creator without groups:manage can not create a group for another owner",
+			method: "POST",
+			path:   "/groups",
+			token:  "creator-token",
+			body:   `{"name": "Design", "owner_id": 2}`,
+			errMsg: "permission groups:manage required",
+			status: 403,
+		},
+		{
+			name:   "group name taken",
+			method: "POST",
+			path:   "/groups",
+			token:  "admin-token",
+			body:   `{"name": "Engineering"}`,
+			errMsg: "group already exists",
+			status: 409,
+		},
+		{
+			name:   "user can not create groups",
+			method: "POST",
+			path:   "/groups",
+			token:  "user-token",
+			body:   `{"name": "Design"}`,
+			errMsg: "permission groups:create required",
+			status: 403,
+		},
+		{
+			name:     "owner adds a member",
+			method:   "PUT",
+			path:     "/groups/10/members/1",
+			token:    "user-token",
+			contains: "User successfully added to group",
+			status:   200,
+		},
+		{
+			name:   "unknown user added to a group",
+			method: "PUT",
+			path:   "/groups/10/members/42",
+			token:  "user-token",
+			errMsg: "could not find user with id",
+			status: 404,
+		},
+		{
+			name:     "owner removes a member",
+			method:   "DELETE",
+			path:     "/groups/10/members/3",
+			token:    "user-token",
+			contains: "User successfully removed from group",
+			status:   200,
+		},
+		{
+			name:   "remove a user who is not a member",
+			method: "DELETE",
+			path:   "/groups/10/members/4",
+			token:  "user-token",
+			errMsg: "user is not a member of the group",
+			status: 404,
+		},
+		{
+			name:   "user can not remove members of a group they do not own",
+			method: "DELETE",
+			path:   "/groups/11/members/1",
+			token:  "user-token",
+			errMsg: "only the owner of the group may perform this action",
+			status: 403,
+		},
+		{
+			name:     "owner deletes a group with members",
+			method:   "DELETE",
+			path:     "/groups/10",
+			token:    "user-token",
+			contains: "Group successfully deleted",
+			status:   200,
+		},
+		{
+			name:     "members listed a page at a time",
+			method:   "GET",
+			path:     "/groups/10/members?page_size=1",
+			token:    "user-token",
+			contains: `"updated_at":"0001-01-01T00:00:00Z"}],"next_page_token":"next"`,
+			status:   200,
+		},
+		{
+			name:   "invalid page size",
+			method: "GET",
+			path:   "/groups/10/members?page_size=ten",
+			token:  "user-token",
+			errMsg: "invalid page_size query parameter",
+			status: 400,
+		},
+		{
+			name:     "user lists their groups",
+			method:   "GET",
+			path:     "/users/3/groups",
+			token:    "admin-token",
+			contains: `"groups":[{"id":10,"name":"Engineering"`,
+			status:   200,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := NewHttpHandler(mockAdminUserService{}, mockPasswordResetService{}, mockEmailVerificationService{}, mockMFAService{}, mockRoleService{}, mockOrganizationService{}, mockGroupService{}, mockInvitationService{}, mux.NewRouter(), mockAuthClient{}, mockTokenParser{}, mockTokenParser{})
+
+			req, err := http.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatalf("could not create mock request: %v", err)
+			}
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+			rec := httptest.NewRecorder()
+
+			server.ServeHTTP(rec, req)
+
+			res := rec.Result()
+			b, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatalf("could not read response: %v", err)
+			}
+
+			assert.Equal(t, tc.status, res.StatusCode)
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, problemDetail(t, b))
+				return
+			}
+			assert.Contains(t, string(b), tc.contains)
+		})
+	}
+}
+
+func TestHttpServer_Invitations_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name     string
+		path     string
+		token    string
+		body     string
+		contains string
+		errMsg   string
+		status   int
+	}{
+		{
+			name:     "user invited",
+			path:     "/invitations",
+			token:    "user-token",
+			body:     `{"email": "new@example.com"}`,
+			contains: `"email":"new@example.com","invited_by":2`,
+			status:   201,
+		},
+		{
+			name:     "admin invites with a role",
+			path:     "/invitations",
+			token:    "admin-token",
+			body:     `{"email": "new@example.com", "role": "support"}`,
+			contains: `"role":"support"`,
+			status:   201,
+		},
+		{
+			name:   "inviting with a role needs the permission to manage roles",
+			path:   "/invitations",
+			token:  "user-token",
+			body:   `{"email": "new@example.com", "role": "admin"}`,
+			errMsg: "permission roles:manage required",
+			status: 403,
+		},
+		{
+			name:   "unknown role",
+			path:   "/invitations",
+			token:  "admin-token",
+			body:   `{"email": "new@example.com", "role": "owner"}`,
+			errMsg: "unknown role",
+			status: 400,
+		},
+		{
+			name:   "email of a registered user",
+			path:   "/invitations",
+			token:  "admin-token",
+			body:   `{"email": "david@example.com"}`,
+			errMsg: "email already exists",
+			status: 409,
+		},
+		{
+			name:   "invalid body",
+			path:   "/invitations",
+			token:  "admin-token",
+			body:   `{"email":`,
+			errMsg: "invalid request body",
+			status: 400,
+		},
+		{
+			name:   "unauthenticated invite",
+			path:   "/invitations",
+			body:   `{"email": "new@example.com"}`,
+			errMsg: "missing bearer access token",
+			status: 401,
+		},
+		{
+			name:     "invitation accepted without signing in",
+			path:     "/invitations/accept",
+			body:     `{"token": "valid-token", "username": "newuser", "password": "Password1"}`,
+			contains: "User successfully created",
+			status:   201,
+		},
+		{
+			name:   "invalid invitation token",
+			path:   "/invitations/accept",
+			body:   `{"token": "used-token", "username": "newuser", "password": "Password1"}`,
+			errMsg: "invalid or expired invitation token",
+			status: 400,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := NewHttpHandler(mockAdminUserService{}, mockPasswordResetService{}, mockEmailVerificationService{}, mockMFAService{}, mockRoleService{}, mockOrganizationService{}, mockGroupService{}, mockInvitationService{}, mux.NewRouter(), mockAuthClient{}, mockTokenParser{}, mockTokenParser{})
+
+			req, err := http.NewRequest("POST", tc.path, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatalf("could not create mock request: %v", err)
+			}
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			rec := httptest.NewRecorder()
+
+			server.ServeHTTP(rec, req)
+
+			res := rec.Result()
+			b, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatalf("could not read response: %v", err)
+			}
+
+			assert.Equal(t, tc.status, res.StatusCode)
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, problemDetail(t, b))
+				return
+			}
+			assert.Contains(t, string(b), tc.contains)
+		})
+	}
+}
+
+func TestHttpServer_GetRoles_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name   string
+		token  string
+		roles  int
+		errMsg string
+		status int
+	}{
+		{
+			name:   "admin lists roles",
+			token:  "admin-token",
+			roles:  4,
+			status: 200,
+		},
+		{
+			name:   "user can not list roles",
+			token:  "user-token",
+			errMsg: "permission roles:list required",
+			status: 403,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := NewHttpHandler(mockAdminUserService{}, mockPasswordResetService{}, mockEmailVerificationService{}, mockMFAService{}, mockRoleService{}, mockOrganizationService{}, mockGroupService{}, mockInvitationService{}, mux.NewRouter(), mockAuthClient{}, mockTokenParser{}, mockTokenParser{})
+
+			req, err := http.NewRequest("GET", "/roles", nil)
+			if err != nil {
+				t.Fatalf("could not create mock request: %v", err)
+			}
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+			rec := httptest.NewRecorder()
+
+			server.ServeHTTP(rec, req)
+
+			res := rec.Result()
+			b, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatalf("could not read response: %v", err)
+			}
+
+			assert.Equal(t, tc.status, res.StatusCode)
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, problemDetail(t, b))
+				return
+			}
+			var roles rolesResponse
+			assert.NoError(t, json.Unmarshal(b, &roles))
+			assert.Len(t, roles.Roles, tc.roles)
+			assert.Equal(t, []string{model.PermissionAll}, roles.Roles[0].Permissions)
+		})
+	}
+}
+
+func TestHttpServer_Restore_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name   string
+		path   string
+		token  string
+		id     int64
+		errMsg string
+		status int
+	}{
+		{
+			name:   "admin restores a deleted user",
+			path:   "/users/2/restore",
+			token:  "admin-token",
+			id:     2,
+			status: 200,
+		},
+		{
+			name:   "user can not restore a user",
+			path:   "/users/2/restore",
+			token:  "user-token",
+			errMsg: "permission users:restore required",
+			status: 403,
+		},
+		{
+			name:   "restore user that is not deleted",
+			path:   "/users/42/restore",
+			token:  "admin-token",
+			errMsg: "deleted user not found with id",
+			status: 404,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := NewHttpHandler(mockAdminUserService{}, mockPasswordResetService{}, mockEmailVerificationService{}, mockMFAService{}, mockRoleService{}, mockOrganizationService{}, mockGroupService{}, mockInvitationService{}, mux.NewRouter(), mockAuthClient{}, mockTokenParser{}, mockTokenParser{})
+
+			req, err := http.NewRequest("POST", tc.path, nil)
+			if err != nil {
+				t.Fatalf("could not create mock request: %v", err)
+			}
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+			rec := httptest.NewRecorder()
+
+			server.ServeHTTP(rec, req)
+
+			res := rec.Result()
+			b, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatalf("could not read response: %v", err)
+			}
+
+			assert.Equal(t, tc.status, res.StatusCode)
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, problemDetail(t, b))
+				return
+			}
+			var restored user
+			assert.NoError(t, json.Unmarshal(b, &restored))
+			assert.Equal(t, tc.id, restored.ID)
+		})
+	}
+}
+
+func TestHttpServer_UnlockUser_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name     string
+		path     string
+		token    string
+		response string
+		errMsg   string
+		status   int
+	}{
+		{
+			name:     "admin unlocks a user",
+			path:     "/users/2/unlock",
+			token:    "admin-token",
+			response: "{\"message\":\"User successfully unlocked\"}",
+			status:   200,
+		},
+		{
+			name:   "user can not unlock their account",
+			path:   "/users/2/unlock",
+			token:  "user-token",
+			errMsg: "permission users:unlock required",
+			status: 403,
+		},
+		{
+			name:   "unlock user that does not exist",
+			path:   "/users/42/unlock",
+			token:  "admin-token",
+			errMsg: "could not find user with id",
+			status: 404,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := NewHttpHandler(mockAdminUserService{}, mockPasswordResetService{}, mockEmailVerificationService{}, mockMFAService{}, mockRoleService{}, mockOrganizationService{}, mockGroupService{}, mockInvitationService{}, mux.NewRouter(), mockAuthClient{}, mockTokenParser{}, mockTokenParser{})
+
+			req, err := http.NewRequest("POST", tc.path, nil)
+			if err != nil {
+				t.Fatalf("could not create mock request: %v", err)
+			}
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+			rec := httptest.NewRecorder()
+
+			server.ServeHTTP(rec, req)
+
+			res := rec.Result()
+			b, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatalf("could not read response: %v", err)
+			}
+
+			assert.Equal(t, tc.status, res.StatusCode)
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, problemDetail(t, b))
+				return
+			}
+			assert.Equal(t, tc.response, string(bytes.TrimSpace(b)))
+		})
+	}
+}
+
+func TestHttpServer_MFA_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name     string
+		method   string
+		path     string
+		token    string
+		body     string
+		response string
+		errMsg   string
+		status   int
+	}{
+		{
+			name:     "login with mfa code",
+			method:   "POST",
+			path:     "/login/mfa",
+			body:     "{\"mfa_token\":\"mfa-token\",\"code\":\"123456\"}",
+			response: "{\"access_token\":\"3214343254\",\"refresh_token\":\"5435436265\",\"token_type\":\"Bearer\",\"expires_in\":900}",
+			status:   200,
+		},
+		{
+			name:     "login confirming a required enrollment returns recovery codes",
+			method:   "POST",
+			path:     "/login/mfa",
+			body:     "{\"mfa_token\":\"enroll-token\",\"code\":\"123456\"}",
+			response: "{\"access_token\":\"3214343254\",\"refresh_token\":\"5435436265\",\"token_type\":\"Bearer\",\"expires_in\":900,\"recovery_codes\":[\"abcde-fghij\"]}",
+			status:   200,
+		},
+		{
+			name:   "login with wrong mfa code",
+			method: "POST",
+			path:   "/login/mfa",
+			body:   "{\"mfa_token\":\"mfa-token\",\"code\":\"000000\"}",
+			errMsg: "invalid mfa code",
+			status: 401,
+		},
+		{
+			name:   "login with expired mfa token",
+			method: "POST",
+			path:   "/login/mfa",
+			body:   "{\"mfa_token\":\"expired-token\",\"code\":\"123456\"}",
+			errMsg: "invalid or expired mfa token",
+			status: 401,
+		},
+		{
+			name:     "enroll during login",
+			method:   "POST",
+			path:     "/login/mfa/enroll",
+			body:     "{\"mfa_token\":\"enroll-token\"}",
+			response: "{\"secret\":\"JBSWY3DPEHPK3PXP\",\"otpauth_uri\":\"otpauth://totp/user-service:jimmy?secret=JBSWY3DPEHPK3PXP\"}",
+			status:   200,
+		},
+		{
+			name:     "user enrolls",
+			method:   "POST",
+			path:     "/users/2/mfa",
+			token:    "user-token",
+			response: "{\"secret\":\"JBSWY3DPEHPK3PXP\",\"otpauth_uri\":\"otpauth://totp/user-service:David?secret=JBSWY3DPEHPK3PXP\"}",
+			status:   200,
+		},
+		{
+			name:   "admin can not enroll another user",
+			method: "POST",
+			path:   "/users/2/mfa",
+			token:  "admin-token",
+			errMsg: "users may only perform this action on themselves",
+			status: 403,
+		},
+		{
+			name:     "user confirms enrollment",
+			method:   "POST",
+			path:     "/users/2/mfa/confirm",
+			token:    "user-token",
+			body:     "{\"code\":\"123456\"}",
+			response: "{\"recovery_codes\":[\"abcde-fghij\"]}",
+			status:   200,
+		},
+		{
+			name:   "user confirms enrollment with wrong code",
+			method: "POST",
+			path:   "/users/2/mfa/confirm",
+			token:  "user-token",
+			body:   "{\"code\":\"000000\"}",
+			errMsg: "invalid mfa code",
+			status: 400,
+		},
+		{
+			name:     "admin disables mfa of a user",
+			method:   "DELETE",
+			path:     "/users/2/mfa",
+			token:    "admin-token",
+			response: "{\"message\":\"MFA successfully disabled\"}",
+			status:   200,
+		},
+		{
+			name:     "user disables mfa with a code",
+			method:   "DELETE",
+			path:     "/users/2/mfa",
+			token:    "user-token",
+			body:     "{\"code\":\"123456\"}",
+			response: "{\"message\":\"MFA successfully disabled\"}",
+			status:   200,
+		},
+		{
+			name:   "user disables mfa with a wrong code",
+			method: "DELETE",
+			path:   "/users/2/mfa",
+			token:  "user-token",
+			body:   "{\"code\":\"000000\"}",
+			errMsg: "invalid mfa code",
+			status: 401,
+		},
+		{
+			name:   "user disables mfa without a code",
+			method: "DELETE",
+			path:   "/users/2/mfa",
+			token:  "user-token",
+			errMsg: "invalid request body",
+			status: 400,
+		},
+		{
+			name:     "admin reads the mfa policy",
+			method:   "GET",
+			path:     "/mfa/policy",
+			token:    "admin-token",
+			response: "{\"require_admin_mfa\":false}",
+			status:   200,
+		},
+		{
+			name:     "admin requires mfa for admins",
+			method:   "PUT",
+			path:     "/mfa/policy",
+			token:    "admin-token",
+			body:     "{\"require_admin_mfa\":true}",
+			response: "{\"require_admin_mfa\":true}",
+			status:   200,
+		},
+		{
+			name:   "mfa policy without a value",
+			method: "PUT",
+			path:   "/mfa/policy",
+			token:  "admin-token",
+			body:   "{}",
+			errMsg: "require_admin_mfa is required",
+			status: 400,
+		},
+		{
+			name:   "user can not change the mfa policy",
+			method: "PUT",
+			path:   "/mfa/policy",
+			token:  "user-token",
+			body:   "{\"require_admin_mfa\":false}",
+			errMsg: "permission mfa:manage_policy required",
+			status: 403,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := NewHttpHandler(mockAdminUserService{}, mockPasswordResetService{}, mockEmailVerificationService{}, mockMFAService{}, mockRoleService{}, mockOrganizationService{}, mockGroupService{}, mockInvitationService{}, mux.NewRouter(), mockAuthClient{}, mockTokenParser{}, mockTokenParser{})
+
+			req, err := http.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatalf("could not create mock request: %v", err)
+			}
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			rec := httptest.NewRecorder()
+
+			server.ServeHTTP(rec, req)
+
+			res := rec.Result()
+			b, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatalf("could not read response: %v", err)
+			}
+
+			assert.Equal(t, tc.status, res.StatusCode)
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, problemDetail(t, b))
+				return
+			}
+			assert.Equal(t, tc.response, string(bytes.TrimSpace(b)))
+		})
+	}
+}
+
+func TestHttpServer_PasswordReset_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name       string
+		path       string
+		body       string
+		authClient mockAuthClient
+		response   string
+		errMsg     string
+		status     int
+	}{
+		{
+			name:       "reset requested",
+			path:       "/password/forgot",
+			body:       `{"username":"David"}`,
+			authClient: mockAuthClient{},
+			response:   "{\"message\":\"If the account exists a password reset has been sent\"}",
+			status:     202,
+		},
+		{
+			name:       "reset requested for unknown username looks the same",
+			path:       "/password/forgot",
+			body:       `{"username":"Nobody"}`,
+			authClient: mockAuthClient{},
+			response:   "{\"message\":\"If the account exists a password reset has been sent\"}",
+			status:     202,
+		},
+		{
+			name:       "reset request that failed looks the same",
+			path:       "/password/forgot",
+			body:       `{"username":"broken"}`,
+			authClient: mockAuthClient{},
+			response:   "{\"message\":\"If the account exists a password reset has been sent\"}",
+			status:     202,
+		},
+		{
+			name:       "reset request without username",
+			path:       "/password/forgot",
+			body:       `{}`,
+			authClient: mockAuthClient{},
+			errMsg:     "username is required",
+			status:     400,
+		},
+		{
+			name:       "password reset with token",
+			path:       "/password/reset",
+			body:       `{"token":"valid-token","new_password":"new-password"}`,
+			authClient: mockAuthClient{},
+			response:   "{\"message\":\"Password successfully reset\"}",
+			status:     200,
+		},
+		{
+			name:       "password reset with invalid token",
+			path:       "/password/reset",
+			body:       `{"token":"used-token","new_password":"new-password"}`,
+			authClient: mockAuthClient{},
+			errMsg:     "invalid or expired reset token",
+			status:     400,
+		},
+		{
+			name:       "password reset although sessions could not be revoked",
+			path:       "/password/reset",
+			body:       `{"token":"valid-token","new_password":"new-password"}`,
+			authClient: mockAuthClient{err: status.Error(codes.Unavailable, "connection refused")},
+			response:   "{\"message\":\"Password successfully reset\"}",
+			status:     200,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := NewHttpHandler(mockUserService{}, mockPasswordResetService{}, mockEmailVerificationService{}, mockMFAService{}, mockRoleService{}, mockOrganizationService{}, mockGroupService{}, mockInvitationService{}, mux.NewRouter(), tc.authClient, mockTokenParser{}, mockTokenParser{})
+
+			req, err := http.NewRequest("POST", tc.path, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatalf("could not create mock request: %v", err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+
+			server.ServeHTTP(rec, req)
+
+			res := rec.Result()
+			b, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatalf("could not read response: %v", err)
+			}
+
+			assert.Equal(t, tc.status, res.StatusCode)
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, problemDetail(t, b))
+				return
+			}
+			assert.Equal(t, tc.response, string(bytes.TrimSpace(b)))
+		})
+	}
+}
+
+func TestHttpServer_EmailVerification_Test_Cases(t *testing.T) {
+	tt := []struct {
+		name     string
+		path     string
+		body     string
+		response string
+		errMsg   string
+		status   int
+	}{
+		{
+			name:     "email verified",
+			path:     "/email/verify",
+			body:     `{"token":"valid-token"}`,
+			response: "{\"message\":\"Email successfully verified\"}",
+			status:   200,
+		},
+		{
+			name:   "email verified with invalid token",
+			path:   "/email/verify",
+			body:   `{"token":"used-token"}`,
+			errMsg: "invalid or expired verification token",
+			status: 400,
+		},
+		{
+			name:     "verification resent",
+			path:     "/email/verify/resend",
+			body:     `{"email":"david@example.com"}`,
+			response: "{\"message\":\"If the email is registered and unverified a verification has been sent\"}",
+			status:   202,
+		},
+		{
+			name:     "verification resend that failed looks the same",
+			path:     "/email/verify/resend",
+			body:     `{"email":"broken@example.com"}`,
+			response: "{\"message\":\"If the email is registered and unverified a verification has been sent\"}",
+			status:   202,
+		},
+		{
+			name:   "verification resend without email",
+			path:   "/email/verify/resend",
+			body:   `{}`,
+			errMsg: "email is required",
+			status: 400,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			server := NewHttpHandler(mockUserService{}, mockPasswordResetService{}, mockEmailVerificationService{}, mockMFAService{}, mockRoleService{}, mockOrganizationService{}, mockGroupService{}, mockInvitationService{}, mux.NewRouter(), mockAuthClient{}, mockTokenParser{}, mockTokenParser{})
+
+			req, err := http.NewRequest("POST", tc.path, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatalf("could not create mock request: %v", err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+
+			server.ServeHTTP(rec, req)
+
+			res := rec.Result()
+			b, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatalf("could not read response: %v", err)
+			}
+
+			assert.Equal(t, tc.status, res.StatusCode)
+			if tc.errMsg != "" {
+				assert.Equal(t, tc.errMsg, problemDetail(t, b))
+				return
+			}
+			assert.Equal(t, tc.response, string(bytes.TrimSpace(b)))
+		})
+	}
+}
+
+// mockAdminUserService is a mockUserService where James (id 1) is an admin
+type mockAdminUserService struct {
+	mockUserService
+}
+
+func (m mockAdminUserService) GetByID(ctx context.Context, id int64) (*model.User, error) {
+	user, err := m.mockUserService.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user.ID == 1 {
+		user.Roles = []string{model.RoleAdmin}
+	}
+	return user, nil
+}
+
+func (m mockUserService) GetByID(_ context.Context, id int64) (*model.User, error) {
+	users := generateUsers()
+
+	for _, user := range users {
+		if id == user.ID {
+			return user, nil
+		}
+	}
+	return nil, model.NotFoundError("could not find user with id")
+}
+
+func (m mockUserService) GetUsers(_ context.Context, filter model.UserFilter, page model.Page) (*model.UserPage, error) {
+	users := generateUsers()
+	return &model.UserPage{Users: users}, nil
+}
+
+func (m mockUserService) Create(_ context.Context, username, email, password string) (*model.User, error) {
+	users := generateUsers()
+
+	for _, user := range users {
+		if username == user.Username {
+			return user, nil
+		}
+	}
+	return nil, model.InvalidArgumentError("user not created")
+}
+
+func (m mockUserService) Update(_ context.Context, id int64, update model.UserUpdate) (*model.User, error) {
+	users := generateUsers()
+
+	for _, user := range users {
+		if id == user.ID {
+			if update.Username != nil {
+				user.Username = *update.Username
+			}
+			if update.Admin != nil {
+				user.SetRole(model.RoleAdmin, *update.Admin)
+			}
+			return user, nil
+		}
+	}
+	return nil, model.NotFoundError("could not find user with id")
+}
+
+func (m mockUserService) Delete(_ context.Context, id int64) error {
+	if id <= 0 {
+		return model.InvalidArgumentError("invalid id")
+	}
+	users := generateUsers()
+	for _, user := range users {
+		if id == user.ID {
+			return nil
+		}
+	}
+	return model.NotFoundError("user does not exist")
+}
+
+func (m mockUserService) Restore(_ context.Context, id int64) (*model.User, error) {
+	for _, user := range generateUsers() {
+		if id == user.ID {
+			return user, nil
+		}
+	}
+	return nil, model.NotFoundError("deleted user not found with id")
+}
+
+func (m mockUserService) ChangeStatus(_ context.Context, id int64, status model.AccountStatus, reason string) (*model.User, error) {
+	if !status.Valid() {
+		return nil, model.ValidationError(model.FieldError{Field: "status", Message: "status must be one of pending, active, suspended or disabled"})
+	}
+	for _, user := range generateUsers() {
+		if id == user.ID {
+			if user.Status == status {
+				return nil, model.InvalidArgumentError(fmt.Sprintf("account is already %s", status))
+			}
+			user.Status, user.StatusReason = status, reason
+			return user, nil
+		}
+	}
+	return nil, model.NotFoundError("could not find user with id")
+}
+
+func (m mockUserService) GetByUsernameAndPassword(_ context.Context, username, password string) (*model.User, error) {
+	users := generateUsers()
+
+	for _, user := range users {
+		if username == user.Username {
+			log.Printf("username: %v", username)
+			return user, nil
+		}
+	}
+
+	return nil, model.UnauthenticatedError("error username")
+}
+
+func (m mockUserService) ChangePassword(_ context.Context, id int64, currentPassword, newPassword string) error {
+	for _, user := range generateUsers() {
+		if id == user.ID {
+			if currentPassword != user.Password {
+				return model.UnauthenticatedError("current password incorrect")
+			}
+			return nil
+		}
+	}
+	return model.NotFoundError("could not find user with id")
+}
+
+func (m mockUserService) ResetPassword(_ context.Context, id int64, temporaryPassword string) error {
+	for _, user := range generateUsers() {
+		if id == user.ID {
+			return nil
+		}
+	}
+	return model.NotFoundError("could not find user with id")
+}
+
+func (m mockUserService) UnlockUser(_ context.Context, id int64) error {
+	for _, user := range generateUsers() {
+		if id == user.ID {
+			return nil
+		}
+	}
+	return model.NotFoundError("could not find user with id")
+}
+
+func (m mockPasswordResetService) RequestReset(_ context.Context, username string) error {
+	if username == "broken" {
+		return errors.New("smtp: connection refused")
+	}
+	return nil
+}
+
+func (m mockPasswordResetService) ConfirmReset(_ context.Context, token, newPassword string) (int64, error) {
+	if token != "valid-token" {
+		return 0, model.InvalidArgumentError("invalid or expired reset token")
+	}
+	return 2, nil
+}
+
+func (m mockEmailVerificationService) SendVerification(_ context.Context, userID int64) error {
+	return nil
+}
+
+func (m mockEmailVerificationService) ResendVerification(_ context.Context, email string) error {
+	if email == "broken@example.com" {
+		return errors.New("smtp: connection refused")
+	}
+	return nil
+}
+
+func (m mockEmailVerificationService) Verify(_ context.Context, token string) error {
+	if token != "valid-token" {
+		return model.InvalidArgumentError("invalid or expired verification token")
+	}
+	return nil
+}
+
+func (m mockRoleService) Roles(_ context.Context) ([]*model.Role, error) {
+	return model.DefaultRoles(), nil
+}
+
+func (m mockRoleService) AssignRole(ctx context.Context, userID int64, role string) (*model.User, error) {
+	user, err := m.user(ctx, userID, role)
+	if err != nil {
+		return nil, err
+	}
+	user.SetRole(role, true)
+	return user, nil
+}
+
+func (m mockRoleService) RevokeRole(ctx context.Context, userID int64, role string) (*model.User, error) {
+	user, err := m.user(ctx, userID, role)
+	if err != nil {
+		return nil, err
+	}
+	if !user.HasRole(role) {
+		return nil, model.NotFoundError("user does not have the role")
+	}
+	user.SetRole(role, false)
+	return user, nil
+}
+
+func (m mockRoleService) HasPermission(_ context.Context, userID int64, permission string) (bool, error) {
+	return userID == 1 ||
+		userID == 2 && permission == string(auth.ActionInviteUser) ||
+		userID == 3 && permission == string(auth.ActionCreateGroup), nil
+}
+
+func (m mockOrganizationService) Resolve(_ context.Context, slug string) (*model.Organization, error) {
+	for _, organization := range mockOrganizations() {
+		if organization.Slug == slug {
+			return organization, nil
+		}
+	}
+	return nil, model.NotFoundError("organization not found")
+}
+
+func (m mockOrganizationService) Create(ctx context.Context, ownerID int64, slug, name string) (*model.Organization, error) {
+	if model.OrganizationFromContext(ctx) != model.DefaultOrganizationID {
+		return nil, model.PermissionDeniedError("organizations can only be created from the default organization")
+	}
+	if _, err := m.Resolve(ctx, slug); err == nil {
+		return nil, model.AlreadyExistsError("organization already exists")
+	}
+	return &model.Organization{ID: 3, Slug: slug, Name: name}, nil
+}
+
+func (m mockOrganizationService) UserOrganizations(ctx context.Context, userID int64) ([]*model.Organization, error) {
+	if _, err := (mockUserService{}).GetByID(ctx, userID); err != nil {
+		return nil, err
+	}
+	var organizations []*model.Organization
+	for _, organization := range mockOrganizations() {
+		if member, _ := m.IsMember(ctx, organization.ID, userID); member {
+			organizations = append(organizations, organization)
+		}
+	}
+	return organizations, nil
+}
+
+func (m mockOrganizationService) IsMember(_ context.Context, organizationID, userID int64) (bool, error) {
+	return organizationID == model.DefaultOrganizationID || userID == 1, nil
+}
+
+// RemoveMember treats every user as registered in the default organization
+func (m mockOrganizationService) RemoveMember(ctx context.Context, userID int64) error {
+	if _, err := (mockUserService{}).GetByID(ctx, userID); err != nil {
+		return err
+	}
+	organizationID := model.OrganizationFromContext(ctx)
+	if organizationID == model.DefaultOrganizationID {
+		return model.InvalidArgumentError("users can not be removed from the organization they registered in")
+	}
+	if member, _ := m.IsMember(ctx, organizationID, userID); !member {
+		return model.NotFoundError("user is not a member of the organization")
+	}
+	return nil
+}
+
+func (m mockGroupService) Group(_ context.Context, id int64) (*model.Group, error) {
+	for _, group := range mockGroups() {
+		if group.ID == id {
+			return group, nil
+		}
+	}
+	return nil, model.NotFoundError("group not found")
+}
+
+func (m mockGroupService) Groups(_ context.Context, page model.Page) (*model.GroupPage, error) {
+	return &model.GroupPage{Groups: mockGroups()}, nil
+}
+
+func (m mockGroupService) Create(ctx context.Context, ownerID int64, name, description string) (*model.Group, error) {
+	if name == "Engineering" {
+		return nil, model.AlreadyExistsError("group already exists")
+	}
+	if _, err := (mockUserService{}).GetByID(ctx, ownerID); err != nil {
+		return nil, err
+	}
+	return &model.Group{ID: 12, Name: name, Description: description, OwnerID: ownerID}, nil
+}
+
+func (m mockGroupService) Delete(ctx context.Context, id int64) error {
+	_, err := m.Group(ctx, id)
+	return err
+}
+
+func (m mockGroupService) AddMember(ctx context.Context, groupID, userID int64) error {
+	if _, err := m.Group(ctx, groupID); err != nil {
+		return err
+	}
+	_, err := (mockUserService{}).GetByID(ctx, userID)
+	return err
+}
+
+func (m mockGroupService) RemoveMember(ctx context.Context, groupID, userID int64) error {
+	if _, err := m.Group(ctx, groupID); err != nil {
+		return err
+	}
+	if groupID != 10 || (userID != 2 && userID != 3) {
+		return model.NotFoundError("user is not a member of the group")
+	}
+	return nil
+}
+
+func (m mockGroupService) Members(ctx context.Context, groupID int64, page model.Page) (*model.UserPage, error) {
+	if _, err := m.Group(ctx, groupID); err != nil {
+		return nil, err
+	}
+	res := &model.UserPage{}
+	if groupID != 10 {
+		return res, nil
+	}
+	for _, id := range []int64{2, 3} {
+		user, _ := (mockUserService{}).GetByID(ctx, id)
+		res.Users = append(res.Users, user)
+	}
+	if page.Size == 1 {
+		res.Users = res.Users[:1]
+		res.NextPageToken = "next"
+	}
+	return res, nil
+}
+
+func (m mockGroupService) UserGroups(ctx context.Context, userID int64, page model.Page) (*model.GroupPage, error) {
+	if _, err := (mockUserService{}).GetByID(ctx, userID); err != nil {
+		return nil, err
+	}
+	res := &model.GroupPage{}
+	if userID == 2 || userID == 3 {
+		res.Groups = mockGroups()[:1]
+	}
+	return res, nil
+}
+
+func mockGroups() []*model.Group {
+	return []*model.Group{
+		{ID: 10, Name: "Engineering", Description: "Builds the product", OwnerID: 2},
+		{ID: 11, Name: "Support", OwnerID: 1},
+	}
+}
+
+func (m mockInvitationService) Invite(ctx context.Context, invitedBy int64, email, role string) (*model.Invitation, error) {
+	if email == "david@example.com" {
+		return nil, model.AlreadyExistsError("email already exists")
+	}
+	if role != "" && role != model.RoleAdmin && role != "support" {
+		return nil, model.ValidationError(model.FieldError{Field: "role", Message: "unknown role"})
+	}
+	return &model.Invitation{ID: 1, Email: email, Role: role, InvitedBy: invitedBy}, nil
+}
+
+func (m mockInvitationService) Accept(ctx context.Context, token, username, password string) (*model.User, error) {
+	if token != "valid-token" {
+		return nil, model.InvalidArgumentError("invalid or expired invitation token")
+	}
+	return &model.User{ID: 8, Username: username}, nil
+}
+
+// Join only knows "valid-token", an invitation to acme
+func (m mockInvitationService) Join(ctx context.Context, token string, userID int64) (*model.Organization, error) {
+	if token != "valid-token" {
+		return nil, model.InvalidArgumentError("invalid or expired invitation token")
+	}
+	if _, err := (mockUserService{}).GetByID(ctx, userID); err != nil {
+		return nil, err
+	}
+	return mockOrganizations()[0], nil
+}
+
+func mockOrganizations() []*model.Organization {
+	return []*model.Organization{
+		{ID: 2, Slug: "acme", Name: "Acme"},
+		{ID: model.DefaultOrganizationID, Slug: model.DefaultOrganizationSlug, Name: "Default"},
+	}
+}
+
+// user returns the user of mockAdminUserService after checking role is a default role
+func (m mockRoleService) user(ctx context.Context, userID int64, role string) (*model.User, error) {
+	for _, r := range model.DefaultRoles() {
+		if r.Name == role {
+			return mockAdminUserService{}.GetByID(ctx, userID)
+		}
+	}
+	return nil, model.NotFoundError("role not found")
+}
+
+func (m mockMFAService) Enroll(ctx context.Context, userID int64) (*model.MFAEnrollment, error) {
+	user, err := mockUserService{}.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.MFAEnrollment{
+		Secret: "JBSWY3DPEHPK3PXP",
+		URI:    "otpauth://totp/user-service:" + user.Username + "?secret=JBSWY3DPEHPK3PXP",
+	}, nil
+}
+
+func (m mockMFAService) Confirm(_ context.Context, userID int64, code string) ([]string, error) {
+	if code != "123456" {
+		return nil, model.ValidationError(model.FieldError{Field: "code", Message: "invalid mfa code"})
+	}
+	return []string{"abcde-fghij"}, nil
+}
+
+func (m mockMFAService) Disable(_ context.Context, userID int64, code string) error {
+	if code != "123456" {
+		return model.UnauthenticatedError("invalid mfa code")
+	}
+	return nil
+}
+
+func (m mockMFAService) Reset(_ context.Context, userID int64) error {
+	return nil
+}
+
+func (m mockMFAService) Challenge(_ context.Context, user *model.User) (*model.LoginChallenge, error) {
+	switch user.ID {
+	case 3:
+		return &model.LoginChallenge{Token: "mfa-token"}, nil
+	case 4:
+		return &model.LoginChallenge{Token: "enroll-token", EnrollmentRequired: true}, nil
+	}
+	return nil, nil
+}
+
+func (m mockMFAService) EnrollChallenge(ctx context.Context, token string) (*model.MFAEnrollment, error) {
+	if token != "enroll-token" {
+		return nil, model.UnauthenticatedError("invalid or expired mfa token")
+	}
+	return m.Enroll(ctx, 4)
+}
+
+func (m mockMFAService) Verify(ctx context.Context, token, code string) (*model.User, []string, error) {
+	var userID int64
+	switch token {
+	case "mfa-token":
+		userID = 3
+	case "enroll-token":
+		userID = 4
+	default:
+		return nil, nil, model.UnauthenticatedError("invalid or expired mfa token")
+	}
+	if code != "123456" {
+		return nil, nil, model.UnauthenticatedError("invalid mfa code")
+	}
+
+	user, err := mockUserService{}.GetByID(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if userID == 4 {
+		return user, []string{"abcde-fghij"}, nil
+	}
+	return user, nil, nil
+}
+
+func (m mockMFAService) AdminMFARequired(_ context.Context) (bool, error) {
+	return false, nil
+}
+
+func (m mockMFAService) SetAdminMFARequired(_ context.Context, required bool) error {
+	return nil
+}
+
+func (m mockAuthClient) CreateAccessToken(ctx context.Context, in *protob.CreateAccessTokenRequest, opts ...grpc.CallOption) (*protob.CreateAccessTokenResponse, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &protob.CreateAccessTokenResponse{
+		AuthToken:    "3214343254",
+		RefreshToken: "5435436265",
+		ExpiresIn:    900,
+	}, nil
+}
+
+func (m mockAuthClient) RefreshAccessToken(ctx context.Context, in *protob.RefreshAccessTokenRequest, opts ...grpc.CallOption) (*protob.CreateAccessTokenResponse, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &protob.CreateAccessTokenResponse{
+		AuthToken:    "7685645342",
+		RefreshToken: "8796756453",
+		ExpiresIn:    900,
+	}, nil
+}
+
+func (m mockAuthClient) DeleteAccessToken(ctx context.Context, in *protob.DeleteAccessTokenRequest, opts ...grpc.CallOption) (*protob.DeleteAccessTokenResponse, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &protob.DeleteAccessTokenResponse{Confirmation: "Success"}, nil
+}
+
+func (m mockAuthClient) DeleteUserAccessTokens(ctx context.Context, in *protob.DeleteUserAccessTokensRequest, opts ...grpc.CallOption) (*protob.DeleteUserAccessTokensResponse, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &protob.DeleteUserAccessTokensResponse{Confirmation: "Success"}, nil
+}
+
+func (m mockAuthClient) CheckAccessToken(ctx context.Context, in *protob.CheckAccessTokenRequest, opts ...grpc.CallOption) (*protob.CheckAccessTokenResponse, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &protob.CheckAccessTokenResponse{ID: 1}, nil
+}
+
+func (m mockTokenParser) Parse(token string) (*auth.Identity, error) {
+	switch token {
+	case "admin-token":
+		return &auth.Identity{UserID: 1, AccessUuid: "admin-uuid"}, nil
+	case "user-token":
+		return &auth.Identity{UserID: 2, AccessUuid: "user-uuid"}, nil
+	case "creator-token":
+		return &auth.Identity{UserID: 3, AccessUuid: "creator-uuid"}, nil
+	}
+	return nil, model.UnauthenticatedError("invalid access token")
+}
+
+func (m mockTokenParser) ParseRefresh(token string) (*auth.RefreshToken, error) {
+	switch token {
+	case "refresh-token":
+		return &auth.RefreshToken{UserID: 2, RefreshUuid: "refresh-uuid"}, nil
+	case "deleted-user-refresh-token":
+		return &auth.RefreshToken{UserID: 42, RefreshUuid: "deleted-uuid"}, nil
+	case "suspended-user-refresh-token":
+		return &auth.RefreshToken{UserID: 7, RefreshUuid: "suspended-uuid"}, nil
+	}
+	return nil, model.UnauthenticatedError("invalid refresh token")
+}
+
+func (m mockAuthorizer) Authorize(_ context.Context, action auth.Action, targetID int64) error {
+	if action == auth.ActionChangeAdmin && targetID == 2 {
+		return model.PermissionDeniedError("permission users:change_admin required")
+	}
+	return nil
+}
+
+func generateUsers() []*model.User {
+	var users []*model.User
+	names := []string{"James", "David", "Michael", "jimmy", "michael", "teddy", "maclom"}
+	passwords := []string{"password", "password123", "laskdkad", "djpsafd", "jdfpsajf", "dpsa111", "dlwfops"}
+
+	for i, name := range names {
+		user := &model.User{
+			ID:       int64(i + 1),
+			Username: name,
+			Email:    strings.ToLower(name) + "@example.com",
+			Password: passwords[i],
+			Status:   model.StatusActive,
+			// teddy had their password reset by an admin
+			PasswordChangeRequired: name == "teddy",
+			CreatedAt:              time.Time{},
+			UpdatedAt:              time.Time{},
+		}
+		// maclom was suspended by an admin
+		if name == "maclom" {
+			user.Status = model.StatusSuspended
+		}
+		users = append(users, user)
 	}
 
 	return users
 }
+
+// problemDetail decodes a problem+json response body and returns its detail
+func problemDetail(t *testing.T, b []byte) string {
+	var p problem
+	if err := json.Unmarshal(b, &p); err != nil {
+		t.Fatalf("could not decode problem response: %v, body: %s", err, b)
+	}
+	return p.Detail
+}
+
+func marshalResponse(b []byte) *usersPage {
+	var page usersPage
+
+	err := json.Unmarshal(b, &page)
+	if err != nil {
+		return nil
+	}
+
+	return &page
+}
@@ -0,0 +1,122 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/JamieBShaw/user-service/auth"
+	"github.com/JamieBShaw/user-service/domain/model"
+	"github.com/gorilla/mux"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// organizationHeader names the slug of the organization a request is made in, requests without it are made in the
+// default organization
+const organizationHeader = "X-Organization"
+
+type contextKey string
+
+const requestIDKey contextKey = "request_id"
+
+// withRequestID tags every request with an id, taken from the X-Request-ID header
+// when the caller sets one, and echoes it back on the response.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if id == "" || len(id) > 128 {
+			id = newRequestID()
+		}
+
+		rw.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(rw, r.WithContext(context.WithValue(r.Context(), requestIDKey, id)))
+	})
+}
+
+// withOrganization scopes the request to the organization in the X-Organization header, unknown organizations are not found
+func (s *httpServer) withOrganization(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		slug := strings.TrimSpace(r.Header.Get(organizationHeader))
+		if slug == "" {
+			next.ServeHTTP(rw, r.WithContext(model.WithOrganization(r.Context(), model.DefaultOrganizationID)))
+			return
+		}
+
+		organization, err := s.organizations.Resolve(r.Context(), slug)
+		if err != nil {
+			s.writeError(rw, r, err)
+			return
+		}
+
+		next.ServeHTTP(rw, r.WithContext(model.WithOrganization(r.Context(), organization.ID)))
+	})
+}
+
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// clientIP is the address the request came from, X-Forwarded-For is ignored because any client can set it
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// authenticate resolves the caller from the bearer access token and adds it to the request context
+func (s *httpServer) authenticate(next http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		token, err := auth.BearerToken(r.Header.Get("Authorization"))
+		if err != nil {
+			s.writeError(rw, r, err)
+			return
+		}
+
+		identity, err := s.tokens.Parse(token)
+		if err != nil {
+			s.writeError(rw, r, err)
+			return
+		}
+
+		next(rw, r.WithContext(auth.WithIdentity(r.Context(), identity)))
+	}
+}
+
+// authorize checks the authenticated caller may perform action on the user, or group, in the {id} route variable
+func (s *httpServer) authorize(action auth.Action, next http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		var targetID int64
+
+		if userId := strings.TrimSpace(mux.Vars(r)["id"]); userId != "" {
+			id, err := strconv.ParseInt(userId, 10, 64)
+			if err != nil {
+				s.writeError(rw, r, model.InvalidArgumentError("invalid query parameter"))
+				return
+			}
+			targetID = id
+		}
+
+		if err := s.authorizer.Authorize(r.Context(), action, targetID); err != nil {
+			s.writeError(rw, r, err)
+			return
+		}
+
+		next(rw, r)
+	}
+}
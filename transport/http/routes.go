@@ -3,24 +3,79 @@ package http
 import (
 	"net/http"
 
-	auth "github.com/JamieBShaw/auth-service/middleware/http_middleware"
+	authMiddleware "github.com/JamieBShaw/auth-service/middleware/http_middleware"
+	"github.com/JamieBShaw/user-service/auth"
+	"github.com/JamieBShaw/user-service/domain/model"
 )
 
 func (s *httpServer) routes() {
+	s.router.NotFoundHandler = http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		s.writeError(rw, r, model.NotFoundError("route not found"))
+	})
 
 	get := s.router.Methods(http.MethodGet).Subrouter()
 	post := s.router.Methods(http.MethodPost).Subrouter()
+	put := s.router.Methods(http.MethodPut).Subrouter()
+	patch := s.router.Methods(http.MethodPatch).Subrouter()
 	deleteR := s.router.Methods(http.MethodDelete).Subrouter()
 	//Get
-	get.HandleFunc("/users/{id}", s.GetById)
-	get.HandleFunc("/users", s.GetUsers)
+	get.HandleFunc("/users/{id}", s.protected(auth.ActionReadUser, s.GetById))
+	get.HandleFunc("/users", s.protected(auth.ActionListUsers, s.GetUsers))
+	get.HandleFunc("/mfa/policy", s.protected(auth.ActionReadMFAPolicy, s.GetMFAPolicy))
+	get.HandleFunc("/roles", s.protected(auth.ActionListRoles, s.GetRoles))
+	get.HandleFunc("/users/{id}/organizations", s.protected(auth.ActionReadUser, s.GetUserOrganizations))
+	get.HandleFunc("/users/{id}/groups", s.protected(auth.ActionReadUser, s.GetUserGroups))
+	get.HandleFunc("/groups", s.protected(auth.ActionListGroups, s.GetGroups))
+	get.HandleFunc("/groups/{id}", s.protected(auth.ActionReadGroup, s.GetGroup))
+	get.HandleFunc("/groups/{id}/members", s.protected(auth.ActionReadGroup, s.GetGroupMembers))
 	//Post
 	post.HandleFunc("/register", s.Register())
 	post.HandleFunc("/login", s.Login())
-	post.HandleFunc("/logout", auth.AuthenticationMiddleware(s.Logout()))
+	post.HandleFunc("/login/mfa", s.VerifyMFA())
+	post.HandleFunc("/login/mfa/enroll", s.EnrollMFAChallenge())
+	post.HandleFunc("/token/refresh", s.RefreshToken())
+	post.HandleFunc("/logout", s.authenticated(s.Logout()))
+	post.HandleFunc("/logout/all", s.authenticated(s.LogoutAll()))
+	post.HandleFunc("/users/{id}/password", s.protected(auth.ActionChangePassword, s.ChangePassword()))
+	post.HandleFunc("/users/{id}/password/reset", s.protected(auth.ActionResetPassword, s.ResetPassword()))
+	post.HandleFunc("/users/{id}/unlock", s.protected(auth.ActionUnlockUser, s.UnlockUser()))
+	post.HandleFunc("/users/{id}/restore", s.protected(auth.ActionRestoreUser, s.Restore()))
+	post.HandleFunc("/users/{id}/mfa", s.protected(auth.ActionEnrollMFA, s.EnrollMFA()))
+	post.HandleFunc("/users/{id}/mfa/confirm", s.protected(auth.ActionEnrollMFA, s.ConfirmMFA()))
+	post.HandleFunc("/password/forgot", s.ForgotPassword())
+	post.HandleFunc("/password/reset", s.ConfirmPasswordReset())
+	post.HandleFunc("/email/verify", s.VerifyEmail())
+	post.HandleFunc("/email/verify/resend", s.ResendVerification())
+	post.HandleFunc("/organizations", s.protected(auth.ActionCreateOrganization, s.CreateOrganization()))
+	post.HandleFunc("/users/{id}/organizations", s.protected(auth.ActionJoinOrganization, s.JoinOrganization()))
+	post.HandleFunc("/groups", s.protected(auth.ActionCreateGroup, s.CreateGroup()))
+	post.HandleFunc("/invitations", s.protected(auth.ActionInviteUser, s.CreateInvitation()))
+	post.HandleFunc("/invitations/accept", s.AcceptInvitation())
+	//Put
+	put.HandleFunc("/users/{id}/status", s.protected(auth.ActionChangeStatus, s.ChangeStatus()))
+	put.HandleFunc("/mfa/policy", s.protected(auth.ActionManageMFAPolicy, s.UpdateMFAPolicy()))
+	put.HandleFunc("/users/{id}/roles/{role}", s.protected(auth.ActionManageRoles, s.AssignRole()))
+	put.HandleFunc("/groups/{id}/members/{user_id}", s.protected(auth.ActionManageGroup, s.AddGroupMember()))
+	//Patch
+	patch.HandleFunc("/users/{id}", s.protected(auth.ActionUpdateUser, s.Update()))
 	//Delete
-	deleteR.HandleFunc("/users/{id}", s.Delete)
+	deleteR.HandleFunc("/users/{id}", s.protected(auth.ActionDeleteUser, s.Delete))
+	deleteR.HandleFunc("/users/{id}/mfa", s.protected(auth.ActionDisableMFA, s.DisableMFA()))
+	deleteR.HandleFunc("/users/{id}/roles/{role}", s.protected(auth.ActionManageRoles, s.RevokeRole()))
+	deleteR.HandleFunc("/groups/{id}", s.protected(auth.ActionManageGroup, s.DeleteGroup))
+	deleteR.HandleFunc("/groups/{id}/members/{user_id}", s.protected(auth.ActionManageGroup, s.RemoveGroupMember()))
+	deleteR.HandleFunc("/organization/members/{id}", s.protected(auth.ActionManageMembers, s.RemoveOrganizationMember()))
 	//PING
 	get.HandleFunc("/healthz", s.Healthz)
 
 }
+
+// authenticated only serves next to callers with a valid access token
+func (s *httpServer) authenticated(next http.HandlerFunc) http.HandlerFunc {
+	return authMiddleware.AuthenticationMiddleware(s.authenticate(next))
+}
+
+// protected only serves next to authenticated callers that are allowed to perform action
+func (s *httpServer) protected(action auth.Action, next http.HandlerFunc) http.HandlerFunc {
+	return s.authenticated(s.authorize(action, next))
+}
@@ -3,6 +3,7 @@ package http
 import (
 	"net/http"
 
+	"github.com/JamieBShaw/user-service/auth"
 	"github.com/JamieBShaw/user-service/protob"
 	"github.com/JamieBShaw/user-service/service"
 	"github.com/gorilla/mux"
@@ -17,26 +18,70 @@ type Server interface {
 	GetById(rw http.ResponseWriter, r *http.Request)
 	GetUsers(rw http.ResponseWriter, r *http.Request)
 	Register() http.HandlerFunc
+	Update() http.HandlerFunc
 	Login() http.HandlerFunc
 	Logout() http.HandlerFunc
+	LogoutAll() http.HandlerFunc
+	RefreshToken() http.HandlerFunc
+	ChangePassword() http.HandlerFunc
+	ResetPassword() http.HandlerFunc
+	UnlockUser() http.HandlerFunc
+	ForgotPassword() http.HandlerFunc
+	ConfirmPasswordReset() http.HandlerFunc
+	VerifyEmail() http.HandlerFunc
+	ResendVerification() http.HandlerFunc
+	VerifyMFA() http.HandlerFunc
+	EnrollMFAChallenge() http.HandlerFunc
+	EnrollMFA() http.HandlerFunc
+	ConfirmMFA() http.HandlerFunc
+	DisableMFA() http.HandlerFunc
+	GetMFAPolicy(rw http.ResponseWriter, r *http.Request)
+	UpdateMFAPolicy() http.HandlerFunc
 	Delete(rw http.ResponseWriter, r *http.Request)
+	Restore() http.HandlerFunc
+	ChangeStatus() http.HandlerFunc
+	GetRoles(rw http.ResponseWriter, r *http.Request)
+	AssignRole() http.HandlerFunc
+	RevokeRole() http.HandlerFunc
+	CreateOrganization() http.HandlerFunc
+	GetUserOrganizations(rw http.ResponseWriter, r *http.Request)
+	GetGroups(rw http.ResponseWriter, r *http.Request)
+	GetGroup(rw http.ResponseWriter, r *http.Request)
+	CreateGroup() http.HandlerFunc
+	DeleteGroup(rw http.ResponseWriter, r *http.Request)
+	AddGroupMember() http.HandlerFunc
+	RemoveGroupMember() http.HandlerFunc
+	GetGroupMembers(rw http.ResponseWriter, r *http.Request)
+	GetUserGroups(rw http.ResponseWriter, r *http.Request)
+	CreateInvitation() http.HandlerFunc
+	AcceptInvitation() http.HandlerFunc
 	Healthz(rw http.ResponseWriter, r *http.Request)
 	ServeHTTP(rw http.ResponseWriter, r *http.Request)
 }
 
 type httpServer struct {
 	service           service.UserService
+	passwordResets    service.PasswordResetService
+	verifications     service.EmailVerificationService
+	mfa               service.MFAService
+	roles             service.RoleService
+	organizations     service.OrganizationService
+	groups            service.GroupService
+	invitations       service.InvitationService
 	router            *mux.Router
 	log               *logrus.Logger
 	authServiceClient protob.AuthServiceClient
+	tokens            auth.TokenParser
+	refreshTokens     auth.RefreshTokenParser
+	authorizer        auth.Authorizer
 }
 
 func (s *httpServer) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
-	s.router.ServeHTTP(rw, r)
+	withRequestID(s.withOrganization(s.router)).ServeHTTP(rw, r)
 }
 
-func NewHttpHandler(service service.UserService, router *mux.Router, client protob.AuthServiceClient) http.Handler {
-	server := &httpServer{service, router, l, client}
+func NewHttpHandler(service service.UserService, passwordResets service.PasswordResetService, verifications service.EmailVerificationService, mfa service.MFAService, roles service.RoleService, organizations service.OrganizationService, groups service.GroupService, invitations service.InvitationService, router *mux.Router, client protob.AuthServiceClient, tokens auth.TokenParser, refreshTokens auth.RefreshTokenParser) http.Handler {
+	server := &httpServer{service, passwordResets, verifications, mfa, roles, organizations, groups, invitations, router, l, client, tokens, refreshTokens, auth.NewAuthorizer(service, roles, organizations, groups)}
 	server.routes()
 
 	return server